@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"fmt"
 	"net/http"
 	"os"
@@ -11,19 +12,20 @@ import (
 
 	"featureflags/config"
 	"featureflags/controller"
+	"featureflags/entity"
 	"featureflags/handler"
 	"featureflags/migrations"
+	"featureflags/pkg/events"
 	"featureflags/pkg/logger"
 	"featureflags/repository"
 	"featureflags/service"
+	"featureflags/validator"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 	_ "github.com/lib/pq"
 )
 
-
-
 func main() {
 	// Load configuration
 	cfg, err := config.Load()
@@ -46,9 +48,12 @@ func main() {
 		"log_level", cfg.Logger.Level,
 		"log_mode", cfg.Logger.Mode,
 	)
+	if len(cfg.Auth.Tokens) == 0 {
+		log.Warnw("AUTH_TOKENS is empty; every API request will be rejected with 401")
+	}
 
 	// Connect to database
-	db, err := connectDB(cfg)
+	db, err := connectDB(cfg, log)
 	if err != nil {
 		log.Fatalw("Failed to connect to database", "error", err)
 	}
@@ -60,29 +65,78 @@ func main() {
 		"database", cfg.Database.Name,
 	)
 
-	// Run migrations
-	if err := migrations.RunMigrations(db.DB, "./migrations"); err != nil {
-		log.Fatalw("Failed to run database migrations", "error", err)
+	// Restrict which actors may make changes
+	actorPolicy := validator.NewActorPolicy(cfg.Actors.Allowlist, cfg.Actors.Denylist)
+
+	// Enforce the organisation's change-management rules on reasons, if any
+	for action := range cfg.Reasons.MinLengths {
+		if !entity.AuditAction(action).IsValid() {
+			log.Fatalw("Unknown action in REASON_MIN_LENGTHS", "action", action)
+		}
+	}
+	reasonPolicy, err := validator.NewReasonPolicy(cfg.Reasons.MinLengths, cfg.Reasons.TicketPattern)
+	if err != nil {
+		log.Fatalw("Invalid reason policy", "error", err)
 	}
 
-	log.Infow("Database migrations completed successfully")
+	// Enforce the organisation's flag naming convention, if any
+	namePolicy, err := validator.NewNamePolicy(cfg.Naming.FlagNamePattern)
+	if err != nil {
+		log.Fatalw("Invalid flag name pattern", "error", err)
+	}
 
 	// Initialize repositories
-	flagRepo := repository.NewFlagRepository(db)
+	flagRepo := repository.NewFlagRepository(db, repository.WithMaxDependencyDepth(cfg.Dependencies.MaxDepth))
+	if cfg.FlagCache.Enabled {
+		flagRepo = repository.NewCachingFlagRepository(flagRepo, cfg.FlagCache.Size, cfg.FlagCache.TTL)
+		log.Infow("Flag cache enabled", "size", cfg.FlagCache.Size, "ttl", cfg.FlagCache.TTL)
+	}
 	auditRepo := repository.NewAuditRepository(db)
+	pendingEnableRepo := repository.NewPendingEnableRepository(db)
+	pendingCascadeRepo := repository.NewPendingCascadeRepository(db)
+	cascadeEventRepo := repository.NewCascadeEventRepository(db)
+	scheduledChangeRepo := repository.NewScheduledChangeRepository(db)
+	rolloutScheduleRepo := repository.NewRolloutScheduleRepository(db)
+	environmentRepo := repository.NewEnvironmentRepository(db)
+	idempotencyKeyRepo := repository.NewIdempotencyKeyRepository(db)
 
 	// Initialize services
-	flagService := service.NewFlagService(flagRepo, auditRepo, log)
+	eventHub := events.NewHub()
+	serviceOpts := []service.Option{
+		service.WithEventHub(eventHub),
+		service.WithPendingEnableRepository(pendingEnableRepo),
+		service.WithCascadeGracePeriod(pendingCascadeRepo, cfg.Cascade.GracePeriod),
+		service.WithCascadeEventRepository(cascadeEventRepo),
+		service.WithScheduledChangeRepository(scheduledChangeRepo),
+		service.WithRolloutScheduleRepository(rolloutScheduleRepo),
+		service.WithEnvironmentRepository(environmentRepo),
+		service.WithIdempotencyKeys(idempotencyKeyRepo, cfg.Idempotency.KeyTTL),
+		service.WithConfirmationSecret([]byte(cfg.Confirmation.Secret), cfg.Confirmation.TTL),
+		service.WithDriftAutoCorrect(cfg.Drift.AutoCorrect),
+		service.WithStrictAudit(cfg.Audit.Strict),
+		service.WithSyncClient(&http.Client{Timeout: cfg.Sync.Timeout}),
+		service.WithActorPolicy(actorPolicy),
+		service.WithNamePolicy(namePolicy),
+		service.WithReasonPolicy(reasonPolicy),
+	}
+	if cfg.Audit.RetryQueueSize > 0 {
+		auditRetries := service.NewAuditRetryQueue(cfg.Audit.RetryQueueSize, cfg.Audit.RetryMaxAttempts)
+		serviceOpts = append(serviceOpts, service.WithAuditRetryQueue(auditRetries))
+		expvar.Publish("audit_retry_queue_depth", expvar.Func(func() any { return auditRetries.Depth() }))
+		expvar.Publish("audit_retry_dropped_total", expvar.Func(func() any { return auditRetries.Dropped() }))
+	}
+	flagService := service.NewFlagService(flagRepo, auditRepo, log, serviceOpts...)
 
 	// Initialize controllers
 	flagController := controller.NewFlagController(flagService, log)
 
-	// Initialize Echo server
+	// Initialize Echo server. It listens while migrations run, answering 503 until they finish.
+	ready := handler.NewReadiness()
 	e := echo.New()
 	e.HideBanner = true
 
 	// Register routes
-	handler.RegisterRoutes(e, flagController, cfg, log)
+	handler.RegisterRoutes(e, flagController, db, cfg, log, ready)
 
 	// Start server in a goroutine
 	serverAddr := fmt.Sprintf(":%d", cfg.HTTPServer.Port)
@@ -93,12 +147,57 @@ func main() {
 		}
 	}()
 
+	// Run migrations
+	if err := migrations.RunMigrations(db.DB, "./migrations"); err != nil {
+		log.Fatalw("Failed to run database migrations", "error", err)
+	}
+	ready.MarkReady()
+
+	log.Infow("Database migrations completed successfully")
+
+	// Start background worker
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	defer stopWorker()
+	worker := service.NewWorker(cfg.Worker.Interval, log)
+	worker.Register("pending_enables", flagService.ProcessPendingEnables)
+	worker.Register("pending_cascades", flagService.ProcessPendingCascades)
+	worker.Register("evaluations", flagService.FlushEvaluations)
+	worker.Register("audit_retries", flagService.FlushAuditRetries)
+	go worker.Start(workerCtx)
+
+	// The drift scan reads every dependency edge, so it runs on its own, slower schedule
+	if cfg.Drift.ScanInterval > 0 {
+		driftWorker := service.NewWorker(cfg.Drift.ScanInterval, log)
+		driftWorker.Register("dependency_drift", flagService.ScanDependencyDrift)
+		go driftWorker.Start(workerCtx)
+	}
+
+	// Scheduled changes and rollout steps only need minute resolution, independent of the main
+	// worker interval
+	scheduleWorker := service.NewWorker(cfg.Schedule.PollInterval, log)
+	scheduleWorker.Register("scheduled_changes", flagService.ProcessScheduledChanges)
+	scheduleWorker.Register("rollout_schedules", flagService.ProcessRolloutSchedules)
+	go scheduleWorker.Start(workerCtx)
+
+	if cfg.Expiry.SweepInterval > 0 {
+		expiryWorker := service.NewWorker(cfg.Expiry.SweepInterval, log)
+		expiryWorker.Register("flag_expiry", flagService.ExpireFlags)
+		go expiryWorker.Start(workerCtx)
+	}
+
+	if cfg.Idempotency.SweepInterval > 0 {
+		idempotencyWorker := service.NewWorker(cfg.Idempotency.SweepInterval, log)
+		idempotencyWorker.Register("idempotency_keys", flagService.PurgeIdempotencyKeys)
+		go idempotencyWorker.Start(workerCtx)
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 
 	log.Infow("Shutting down server gracefully...")
+	stopWorker()
 
 	// Create a deadline for graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.Application.GracefulShutdownTimeout)
@@ -110,48 +209,52 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Persist evaluations buffered since the last worker pass
+	if err := flagService.FlushEvaluations(ctx); err != nil {
+		log.Warnw("Failed to flush flag evaluations", "error", err)
+	}
+	if err := flagService.FlushAuditRetries(ctx); err != nil {
+		log.Warnw("Failed to flush queued audit entries", "error", err)
+	}
+
 	log.Infow("Server shutdown completed successfully")
 }
 
-func connectDB(cfg *config.Config) (*sqlx.DB, error) {
-	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Database.Host,
-		cfg.Database.Port,
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.Name,
-		cfg.Database.SSLMode,
-	)
+// maxConnectDelay caps the wait between database connection attempts
+const maxConnectDelay = 30 * time.Second
+
+// connectDB connects to the database, retrying with exponential backoff while it is not yet
+// accepting connections
+func connectDB(cfg *config.Config, log *logger.Logger) (*sqlx.DB, error) {
+	connStr := cfg.Database.DSN()
+	attempts := max(cfg.Database.ConnectMaxAttempts, 1)
+	delay := cfg.Database.ConnectBaseDelay
 
 	var db *sqlx.DB
 	var err error
-
-	// Retry connection up to 30 times with 1 second delay
-	for i := 0; i < 30; i++ {
+	for attempt := 1; ; attempt++ {
 		db, err = sqlx.Connect("postgres", connStr)
 		if err == nil {
-			// Test connection
-			if err = db.Ping(); err == nil {
-				break
-			}
-			db.Close()
+			break
 		}
-		
-		if i == 0 {
-			fmt.Printf("Waiting for database to be ready...\n")
+		if attempt == attempts {
+			return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", attempts, err)
 		}
-		fmt.Printf("Database connection attempt %d/30 failed: %v\n", i+1, err)
-		time.Sleep(1 * time.Second)
-	}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database after 30 attempts: %w", err)
+		log.Warnw("Database connection attempt failed; retrying",
+			"attempt", attempt,
+			"max_attempts", attempts,
+			"retry_in", delay.String(),
+			"error", err,
+		)
+		time.Sleep(delay)
+		delay = min(delay*2, maxConnectDelay)
 	}
 
 	// Configure connection pool
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
 
 	return db, nil
-} 
\ No newline at end of file
+}
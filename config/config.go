@@ -1,9 +1,15 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"featureflags/validator"
 )
 
 type Application struct {
@@ -11,7 +17,14 @@ type Application struct {
 }
 
 type HTTPServer struct {
-	Port int
+	Port       int
+	PrettyJSON bool // indent JSON responses; meant for local debugging
+	// ReadOnlyProbeInterval is how often a write is attempted while in read-only mode
+	ReadOnlyProbeInterval time.Duration
+	// HealthCheckTimeout bounds the database ping made by /health and /readyz
+	HealthCheckTimeout time.Duration
+	// DebugVars serves runtime and service metrics as JSON at /debug/vars
+	DebugVars bool
 }
 
 type Database struct {
@@ -21,6 +34,43 @@ type Database struct {
 	Password string
 	Name     string
 	SSLMode  string
+	// StatementTimeout makes Postgres abort any statement running longer than this; 0 disables it
+	StatementTimeout time.Duration
+	// Connection pool limits, applied as in database/sql
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	// ConnectMaxAttempts is how often startup tries to reach the database before giving up.
+	// The wait between attempts starts at ConnectBaseDelay and doubles each time.
+	ConnectMaxAttempts int
+	ConnectBaseDelay   time.Duration
+	// URL is set from DATABASE_URL and then used as the connection string. The component
+	// fields above are filled in from it so they can still be logged.
+	URL *url.URL
+}
+
+// DSN returns the lib/pq connection string for the database
+func (d Database) DSN() string {
+	if d.URL != nil {
+		u := *d.URL
+		q := u.Query()
+		if q.Get("sslmode") == "" {
+			q.Set("sslmode", d.SSLMode)
+		}
+		if d.StatementTimeout > 0 && q.Get("statement_timeout") == "" {
+			q.Set("statement_timeout", strconv.FormatInt(d.StatementTimeout.Milliseconds(), 10))
+		}
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		d.Host, d.Port, d.User, d.Password, d.Name, d.SSLMode)
+	if d.StatementTimeout > 0 {
+		// Sent as a startup parameter so it applies to every pooled connection
+		dsn += fmt.Sprintf(" statement_timeout=%d", d.StatementTimeout.Milliseconds())
+	}
+	return dsn
 }
 
 type Logger struct {
@@ -28,16 +78,118 @@ type Logger struct {
 	Mode  string // development or production
 }
 
+type Confirmation struct {
+	Secret string
+	TTL    time.Duration
+}
+
+type Naming struct {
+	FlagNamePattern string // regex new flag names must fully match; empty allows any valid name
+}
+
+// Reasons is the change-management policy on the reasons given for changes
+type Reasons struct {
+	MinLengths    map[string]int // audit action -> fewest characters its reason must have
+	TicketPattern string         // regex every disable reason must contain a match of; empty allows any
+}
+
+type Actors struct {
+	Allowlist []string // when set, only these actors may make changes
+	Denylist  []string // these actors may never make changes
+}
+
+// Auth maps static bearer tokens to the actor each one authenticates
+type Auth struct {
+	Tokens map[string]string // token -> actor
+}
+
+type Delegation struct {
+	ServiceAccounts []string // actors allowed to send X-On-Behalf-Of
+}
+
+type Admin struct {
+	Actors []string // actors allowed to call admin operations; none leaves them unavailable
+}
+
+type Sync struct {
+	Timeout time.Duration // how long a sync waits for the source instance's export
+}
+
+type Audit struct {
+	RetryQueueSize   int  // failed audit writes kept for retry; 0 drops them
+	RetryMaxAttempts int  // retries before a queued audit entry is dropped
+	Strict           bool // roll back status changes whose audit entry cannot be written
+}
+
+type Worker struct {
+	Interval time.Duration
+}
+
+type Dependencies struct {
+	MaxDepth int // longest dependency chain a new dependency may create
+}
+
+type Cascade struct {
+	GracePeriod time.Duration // delay before dependents are cascade-disabled; 0 is immediate
+}
+
+type Expiry struct {
+	SweepInterval time.Duration // how often enabled flags past their expiry are disabled; 0 disables the sweep
+}
+
+type Schedule struct {
+	PollInterval time.Duration // how often due scheduled enables and disables are applied
+}
+
+type Idempotency struct {
+	KeyTTL        time.Duration // how long a toggle's idempotency key is honoured
+	SweepInterval time.Duration // how often expired idempotency keys are deleted
+}
+
+type FlagCache struct {
+	Enabled bool          // cache flags and their dependencies in memory
+	Size    int           // most entries kept
+	TTL     time.Duration // how long an entry is served; bounds staleness across instances
+}
+
+type RateLimit struct {
+	WritesPerMinute int // toggles and creates allowed per actor per minute; 0 disables the limit
+}
+
+type Drift struct {
+	ScanInterval time.Duration // how often to scan for enabled flags with disabled dependencies; 0, the default, disables the scan
+	AutoCorrect  bool          // disable drifted flags instead of only reporting them
+}
+
 type Swagger struct {
-	Enabled bool `json:"enabled"`
+	UIEnabled   bool `json:"ui_enabled"`   // interactive UI under /swagger/
+	SpecEnabled bool `json:"spec_enabled"` // raw OpenAPI document at /swagger/doc.json
 }
 
 type Config struct {
-	Application Application
-	HTTPServer  HTTPServer
-	Database    Database
-	Logger      Logger
-	Swagger     Swagger
+	Application  Application
+	HTTPServer   HTTPServer
+	Database     Database
+	Logger       Logger
+	Swagger      Swagger
+	Worker       Worker
+	Confirmation Confirmation
+	Auth         Auth
+	Delegation   Delegation
+	Admin        Admin
+	Sync         Sync
+	Actors       Actors
+	Cascade      Cascade
+	Dependencies Dependencies
+	Naming       Naming
+	Reasons      Reasons
+	Drift        Drift
+	Schedule     Schedule
+	Expiry       Expiry
+	Idempotency  Idempotency
+	RateLimit    RateLimit
+	FlagCache    FlagCache
+	Audit        Audit
 }
 
 func Load() (*Config, error) {
@@ -46,7 +198,10 @@ func Load() (*Config, error) {
 			GracefulShutdownTimeout: parseDurationWithDefault("APPLICATION_GRACEFUL_SHUTDOWN_TIMEOUT", 30*time.Second),
 		},
 		HTTPServer: HTTPServer{
-			Port: parseIntWithDefault("HTTP_SERVER_PORT", 8080),
+			Port:                  parseIntWithDefault("HTTP_SERVER_PORT", 8080),
+			ReadOnlyProbeInterval: parseDurationWithDefault("READ_ONLY_PROBE_INTERVAL", 5*time.Second),
+			HealthCheckTimeout:    parseDurationWithDefault("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			DebugVars:             getEnvBoolWithDefault("DEBUG_VARS_ENABLED", false),
 		},
 		Database: Database{
 			Host:     getEnvWithDefault("DATABASE_HOST", "db"),
@@ -55,16 +210,100 @@ func Load() (*Config, error) {
 			Password: getEnvWithDefault("DATABASE_PASSWORD", "featureflags"),
 			Name:     getEnvWithDefault("DATABASE_NAME", "featureflags"),
 			SSLMode:  getEnvWithDefault("DATABASE_SSL_MODE", "disable"),
+
+			StatementTimeout: parseDurationWithDefault("DATABASE_STATEMENT_TIMEOUT", 30*time.Second),
+			MaxOpenConns:     parseIntWithDefault("DATABASE_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:     parseIntWithDefault("DATABASE_MAX_IDLE_CONNS", 5),
+			ConnMaxLifetime:  parseDurationWithDefault("DATABASE_CONN_MAX_LIFETIME", 5*time.Minute),
+
+			ConnectMaxAttempts: parseIntWithDefault("DATABASE_CONNECT_MAX_ATTEMPTS", 10),
+			ConnectBaseDelay:   parseDurationWithDefault("DATABASE_CONNECT_BASE_DELAY", 500*time.Millisecond),
 		},
 		Logger: Logger{
 			Level: getEnvWithDefault("LOGGER_LEVEL", "info"),
 			Mode:  getEnvWithDefault("LOGGER_MODE", "production"),
 		},
+		Audit: Audit{
+			RetryQueueSize:   parseIntWithDefault("AUDIT_RETRY_QUEUE_SIZE", 1000),
+			RetryMaxAttempts: parseIntWithDefault("AUDIT_RETRY_MAX_ATTEMPTS", 30),
+			Strict:           getEnvBoolWithDefault("AUDIT_STRICT", false),
+		},
+		Worker: Worker{
+			Interval: parseDurationWithDefault("WORKER_INTERVAL", 10*time.Second),
+		},
+		Cascade: Cascade{
+			GracePeriod: parseDurationWithDefault("CASCADE_GRACE_PERIOD", 0),
+		},
+		Dependencies: Dependencies{
+			MaxDepth: parseIntWithDefault("DEPENDENCY_MAX_DEPTH", 100),
+		},
+		Confirmation: Confirmation{
+			Secret: getEnvWithDefault("CONFIRMATION_SECRET", ""),
+			TTL:    parseDurationWithDefault("CONFIRMATION_TTL", 5*time.Minute),
+		},
+		Actors: Actors{
+			Allowlist: parseListWithDefault("ACTOR_ALLOWLIST", nil),
+			Denylist:  parseListWithDefault("ACTOR_DENYLIST", nil),
+		},
+		Delegation: Delegation{
+			ServiceAccounts: parseListWithDefault("DELEGATION_SERVICE_ACCOUNTS", nil),
+		},
+		Admin: Admin{
+			Actors: parseListWithDefault("ADMIN_ACTORS", nil),
+		},
+		Sync: Sync{
+			Timeout: parseDurationWithDefault("SYNC_TIMEOUT", 30*time.Second),
+		},
+		Drift: Drift{
+			ScanInterval: parseDurationWithDefault("DRIFT_SCAN_INTERVAL", 0),
+			AutoCorrect:  getEnvBoolWithDefault("DRIFT_AUTO_CORRECT", false),
+		},
+		Schedule: Schedule{
+			PollInterval: parseDurationWithDefault("SCHEDULE_POLL_INTERVAL", time.Minute),
+		},
+		Expiry: Expiry{
+			SweepInterval: parseDurationWithDefault("EXPIRY_SWEEP_INTERVAL", time.Minute),
+		},
+		Idempotency: Idempotency{
+			KeyTTL:        parseDurationWithDefault("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+			SweepInterval: parseDurationWithDefault("IDEMPOTENCY_SWEEP_INTERVAL", time.Hour),
+		},
+		FlagCache: FlagCache{
+			Enabled: getEnvBoolWithDefault("FLAG_CACHE_ENABLED", false),
+			Size:    parseIntWithDefault("FLAG_CACHE_SIZE", 1000),
+			TTL:     parseDurationWithDefault("FLAG_CACHE_TTL", 30*time.Second),
+		},
+		RateLimit: RateLimit{
+			WritesPerMinute: parseIntWithDefault("RATE_LIMIT_PER_MIN", 10),
+		},
+		Naming: Naming{
+			FlagNamePattern: getEnvWithDefault("FLAG_NAME_PATTERN", ""),
+		},
 	}
 
-	// Set Swagger defaults
+	minLengths, err := parseReasonMinLengths("REASON_MIN_LENGTHS")
+	if err != nil {
+		return nil, err
+	}
+	cfg.Reasons = Reasons{
+		MinLengths:    minLengths,
+		TicketPattern: getEnvWithDefault("REASON_TICKET_PATTERN", ""),
+	}
+
+	tokens, err := parseAuthTokens("AUTH_TOKENS")
+	if err != nil {
+		return nil, err
+	}
+	cfg.Auth.Tokens = tokens
+
+	// Pretty JSON follows the logger mode unless set explicitly
+	cfg.HTTPServer.PrettyJSON = getEnvBoolWithDefault("JSON_PRETTY", cfg.Logger.Mode == "development")
+
+	// Set Swagger defaults; SWAGGER_ENABLED is the default for both parts
+	swaggerEnabled := getEnvBoolWithDefault("SWAGGER_ENABLED", true)
 	cfg.Swagger = Swagger{
-		Enabled: getEnvBoolWithDefault("SWAGGER_ENABLED", true),
+		UIEnabled:   getEnvBoolWithDefault("SWAGGER_UI_ENABLED", swaggerEnabled),
+		SpecEnabled: getEnvBoolWithDefault("SWAGGER_SPEC_ENABLED", swaggerEnabled),
 	}
 
 	// Support legacy environment variables
@@ -86,9 +325,53 @@ func Load() (*Config, error) {
 		cfg.Database.Name = name
 	}
 
+	// A single DATABASE_URL, as Heroku-style platforms provide, overrides the component fields
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		if err := cfg.Database.applyURL(databaseURL); err != nil {
+			return nil, err
+		}
+	}
+
 	return cfg, nil
 }
 
+// applyURL validates a postgres:// URL and takes the connection settings from it. Errors
+// never include the URL, since it usually carries the password.
+func (d *Database) applyURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("invalid DATABASE_URL: %v", err)
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return fmt.Errorf("invalid DATABASE_URL: unsupported scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return errors.New("invalid DATABASE_URL: missing host")
+	}
+
+	d.Host = u.Hostname()
+	d.Port = 5432
+	if port := u.Port(); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return fmt.Errorf("invalid DATABASE_URL: invalid port %q", port)
+		}
+		d.Port = p
+	}
+	d.User = u.User.Username()
+	d.Password, _ = u.User.Password()
+	d.Name = strings.TrimPrefix(u.Path, "/")
+	if sslMode := u.Query().Get("sslmode"); sslMode != "" {
+		d.SSLMode = sslMode
+	}
+	d.URL = u
+	return nil
+}
+
 func getEnvWithDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -121,4 +404,54 @@ func getEnvBoolWithDefault(key string, defaultValue bool) bool {
 		}
 	}
 	return defaultValue
-} 
\ No newline at end of file
+}
+
+// parseAuthTokens reads comma-separated actor:token pairs. Errors never include a token.
+func parseAuthTokens(key string) (map[string]string, error) {
+	tokens := make(map[string]string)
+	for i, pair := range parseListWithDefault(key, nil) {
+		actor, token, ok := strings.Cut(pair, ":")
+		actor, token = strings.TrimSpace(actor), strings.TrimSpace(token)
+		if !ok || actor == "" || token == "" {
+			return nil, fmt.Errorf("%s entry %d must be actor:token", key, i+1)
+		}
+		if actor == validator.SystemActor {
+			return nil, fmt.Errorf("%s entry %d: actor %q is reserved", key, i+1, actor)
+		}
+		if _, exists := tokens[token]; exists {
+			return nil, fmt.Errorf("%s entry %d reuses the token of an earlier entry", key, i+1)
+		}
+		tokens[token] = actor
+	}
+	return tokens, nil
+}
+
+// parseReasonMinLengths reads comma-separated action:length pairs, such as "disable:20"
+func parseReasonMinLengths(key string) (map[string]int, error) {
+	minLengths := make(map[string]int)
+	for i, pair := range parseListWithDefault(key, nil) {
+		action, length, ok := strings.Cut(pair, ":")
+		action = strings.TrimSpace(action)
+		n, err := strconv.Atoi(strings.TrimSpace(length))
+		if !ok || action == "" || err != nil || n < 0 {
+			return nil, fmt.Errorf("%s entry %d must be action:length", key, i+1)
+		}
+		minLengths[action] = n
+	}
+	return minLengths, nil
+}
+
+func parseListWithDefault(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
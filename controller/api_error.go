@@ -0,0 +1,65 @@
+package controller
+
+import "github.com/labstack/echo/v4"
+
+// Error codes returned in APIError.Code. Clients should switch on these rather than on the
+// message, which is meant for people and may change.
+const (
+	CodeInvalidRequest         = "INVALID_REQUEST"
+	CodeUnauthorized           = "UNAUTHORIZED"
+	CodeUnsupportedMediaType   = "UNSUPPORTED_MEDIA_TYPE"
+	CodeValidationFailed       = "VALIDATION_FAILED"
+	CodeActorNotAllowed        = "ACTOR_NOT_ALLOWED"
+	CodeFlagNotFound           = "FLAG_NOT_FOUND"
+	CodeFlagAlreadyExists      = "FLAG_ALREADY_EXISTS"
+	CodeBulkCreateRejected     = "BULK_CREATE_REJECTED"
+	CodeImportRejected         = "IMPORT_REJECTED"
+	CodeBulkToggleRejected     = "BULK_TOGGLE_REJECTED"
+	CodeMissingDependencies    = "MISSING_DEPENDENCIES"
+	CodeUnknownDependencies    = "UNKNOWN_DEPENDENCIES"
+	CodeSelfDependency         = "SELF_DEPENDENCY"
+	CodeCircularDependency     = "CIRCULAR_DEPENDENCY"
+	CodeDependencyTooDeep      = "DEPENDENCY_TOO_DEEP"
+	CodeDependencyNotFound     = "DEPENDENCY_NOT_FOUND"
+	CodeDependencyUnavailable  = "DEPENDENCY_UNAVAILABLE"
+	CodeEnabledDependents      = "ENABLED_DEPENDENTS"
+	CodeHasDependents          = "HAS_DEPENDENTS"
+	CodeConfirmationRequired   = "CONFIRMATION_REQUIRED"
+	CodeFlagLocked             = "FLAG_LOCKED"
+	CodeFlagArchived           = "FLAG_ARCHIVED"
+	CodeFlagNotArchived        = "FLAG_NOT_ARCHIVED"
+	CodeFlagInMaintenance      = "FLAG_IN_MAINTENANCE"
+	CodeFlagNotInMaintenance   = "FLAG_NOT_IN_MAINTENANCE"
+	CodeConcurrentModification = "CONCURRENT_MODIFICATION"
+	CodeUnknownEnvironment     = "UNKNOWN_ENVIRONMENT"
+	CodeEnvironmentExists      = "ENVIRONMENT_EXISTS"
+	CodeIdempotencyKeyReused   = "IDEMPOTENCY_KEY_REUSED"
+	CodeSyncSourceFailed       = "SYNC_SOURCE_FAILED"
+	CodeRateLimited            = "RATE_LIMITED"
+	CodeCascadeNotFound        = "CASCADE_NOT_FOUND"
+	CodeRolloutNotScheduled    = "ROLLOUT_NOT_SCHEDULED"
+	CodeRolloutFinished        = "ROLLOUT_FINISHED"
+	CodeCascadeAlreadyRestored = "CASCADE_ALREADY_RESTORED"
+	CodePendingEnableNotFound  = "PENDING_ENABLE_NOT_FOUND"
+	CodeFeatureNotConfigured   = "FEATURE_NOT_CONFIGURED"
+	CodeRequestCancelled       = "REQUEST_CANCELLED"
+	CodeServiceStarting        = "SERVICE_STARTING"
+	CodeInternal               = "INTERNAL_ERROR"
+)
+
+// APIError is the body of every error response
+type APIError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// NewAPIError creates an error body without details
+func NewAPIError(code, message string) APIError {
+	return APIError{Code: code, Message: message}
+}
+
+// respondError writes an APIError with the given status; details may be nil
+func respondError(c echo.Context, status int, code, message string, details map[string]interface{}) error {
+	return c.JSON(status, APIError{Code: code, Message: message, Details: details})
+}
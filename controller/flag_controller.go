@@ -2,196 +2,1674 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"featureflags/entity"
 	"featureflags/pkg/logger"
+	"featureflags/repository"
 	"featureflags/service"
 	"featureflags/validator"
 
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	// ActorContextKey is the Echo context key holding the authenticated actor
+	ActorContextKey = "actor"
+	// WriteUnavailableContextKey is set when a request failed because the database refused writes
+	WriteUnavailableContextKey = "write_unavailable"
+	// ReadOnlyErrorCode is returned with 503 while the database does not accept writes
+	ReadOnlyErrorCode = "READ_ONLY"
+)
+
 type FlagController struct {
 	flagService service.FlagService
 	logger      *logger.Logger
 }
 
-func NewFlagController(fs service.FlagService, log *logger.Logger) *FlagController {
-	return &FlagController{
-		flagService: fs,
-		logger:      log,
+func NewFlagController(fs service.FlagService, log *logger.Logger) *FlagController {
+	return &FlagController{
+		flagService: fs,
+		logger:      log,
+	}
+}
+
+// CreateFlag handles POST /flags
+func (fc *FlagController) CreateFlag(c echo.Context) error {
+	var req validator.FlagCreateRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind create flag request", "error", err)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+
+	// Get actor from context (in a real app, this would come from auth middleware)
+	actor := getActorFromContext(c)
+
+	flag, err := fc.flagService.CreateFlag(c.Request().Context(), req, actor)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Flag created via API", "flagID", flag.ID, "name", flag.Name, "actor", actor)
+	return c.JSON(http.StatusCreated, flag)
+}
+
+// BulkCreateFlags handles POST /flags/bulk
+func (fc *FlagController) BulkCreateFlags(c echo.Context) error {
+	var req validator.FlagBulkCreateRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind bulk create request", "error", err)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+
+	actor := getActorFromContext(c)
+
+	flags, err := fc.flagService.BulkCreateFlags(c.Request().Context(), req, actor)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Flags created in bulk via API", "count", len(flags), "actor", actor)
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"flags": flags,
+		"count": len(flags),
+	})
+}
+
+// BulkToggleFlags handles POST /flags/toggle-bulk
+func (fc *FlagController) BulkToggleFlags(c echo.Context) error {
+	var req validator.FlagBulkToggleRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind bulk toggle request", "error", err)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+
+	actor := getActorFromContext(c)
+
+	results, err := fc.flagService.BulkToggleFlags(c.Request().Context(), req, actor)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Flags toggled in bulk via API", "count", len(results), "enable", req.Enable, "actor", actor)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"results": results,
+		"count":   len(results),
+	})
+}
+
+// UpdateFlag handles PUT /flags/:id
+func (fc *FlagController) UpdateFlag(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	var req validator.FlagUpdateRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind update flag request", "error", err, "flagID", id)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+
+	actor := getActorFromContext(c)
+
+	flag, err := fc.flagService.UpdateFlag(c.Request().Context(), id, req, actor)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Flag updated via API", "flagID", id, "dependencies", flag.Dependencies, "actor", actor)
+	return c.JSON(http.StatusOK, flag)
+}
+
+// RenameFlag handles PATCH /flags/:id/name
+func (fc *FlagController) RenameFlag(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	var req validator.FlagRenameRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind rename flag request", "error", err, "flagID", id)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+
+	actor := getActorFromContext(c)
+
+	flag, err := fc.flagService.RenameFlag(c.Request().Context(), id, req, actor)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Flag renamed via API", "flagID", id, "name", flag.Name, "actor", actor)
+	return c.JSON(http.StatusOK, flag)
+}
+
+// AddDependency handles POST /flags/:id/dependencies
+func (fc *FlagController) AddDependency(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	var req validator.FlagDependencyRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind add dependency request", "error", err, "flagID", id)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+
+	actor := getActorFromContext(c)
+
+	flag, err := fc.flagService.AddDependency(c.Request().Context(), id, req, actor)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Dependency added via API", "flagID", id, "depID", req.DependsOnID, "actor", actor)
+	return c.JSON(http.StatusOK, flag)
+}
+
+// RemoveDependency handles DELETE /flags/:id/dependencies/:depId
+func (fc *FlagController) RemoveDependency(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+	depID, err := strconv.ParseInt(c.Param("depId"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid dependency ID", nil)
+	}
+
+	actor := getActorFromContext(c)
+
+	flag, err := fc.flagService.RemoveDependency(c.Request().Context(), id, depID, actor)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Dependency removed via API", "flagID", id, "depID", depID, "actor", actor)
+	return c.JSON(http.StatusOK, flag)
+}
+
+// DeleteFlag handles DELETE /flags/:id
+func (fc *FlagController) DeleteFlag(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	var req validator.FlagReasonRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind delete flag request", "error", err, "flagID", id)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+	if err := validator.ValidateFlagReasonRequest(req); err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	actor := getActorFromContext(c)
+
+	if err := fc.flagService.DeleteFlag(c.Request().Context(), id, actor, req.Reason); err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Flag deleted via API", "flagID", id, "actor", actor)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Flag deleted successfully",
+		"flag_id": id,
+	})
+}
+
+// ToggleFlag handles POST /flags/:id/toggle
+func (fc *FlagController) ToggleFlag(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	var req validator.FlagToggleRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind toggle flag request", "error", err, "flagID", id)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+
+	actor := getActorFromContext(c)
+	env := c.QueryParam("env")
+	req.IdempotencyKey = c.Request().Header.Get("Idempotency-Key")
+
+	change, err := fc.flagService.ToggleFlagInEnvironment(c.Request().Context(), id, env, req, actor)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	status := string(change.Status)
+	message := "Flag " + status + " successfully"
+	if !change.Changed {
+		message = "Flag already " + status
+	}
+
+	fc.log(c).Infow("Flag toggled via API", "flagID", id, "environment", env, "status", status, "changed", change.Changed, "actor", actor)
+	response := map[string]interface{}{
+		"message":         message,
+		"flag_id":         id,
+		"status":          status,
+		"changed":         change.Changed,
+		"previous_status": change.PreviousStatus,
+	}
+	if len(change.CascadeEnabled) > 0 {
+		response["cascade_enabled"] = change.CascadeEnabled
+	}
+	if env != "" {
+		response["environment"] = env
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// ListFlags handles GET /flags
+func (fc *FlagController) ListFlags(c echo.Context) error {
+	expand, err := parseExpandDependencies(c)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, err.Error(), nil)
+	}
+
+	limit, offset := service.DefaultFlagPageSize, 0
+	if raw := c.QueryParam("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid limit", nil)
+		}
+		limit = parsed
+	}
+	if raw := c.QueryParam("offset"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid offset", nil)
+		}
+		offset = parsed
+	}
+
+	filter := entity.FlagFilter{Status: entity.FlagStatus(c.QueryParam("status"))}
+	for _, raw := range c.QueryParams()["tag"] {
+		key, value, err := validator.ParseTag(raw)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, err.Error(), nil)
+		}
+		filter.Tags = append(filter.Tags, entity.Tag{Key: key, Value: value})
+	}
+	if raw := c.QueryParam("expiring_before"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid expiring_before time, expected RFC3339", nil)
+		}
+		filter.ExpiringBefore = &parsed
+	}
+	if raw := c.QueryParam("include_archived"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid include_archived, expected true or false", nil)
+		}
+		filter.IncludeArchived = parsed
+	}
+
+	flags, total, err := fc.flagService.ListFlagsPaginated(c.Request().Context(), filter, limit, offset)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidFlagPage) || errors.Is(err, service.ErrInvalidFlagStatus) {
+			return fc.handleServiceError(c, err)
+		}
+		fc.log(c).Errorw("Failed to list flags via API", "error", err)
+		return respondError(c, http.StatusInternalServerError, CodeInternal, "Failed to retrieve flags", nil)
+	}
+
+	if expand {
+		if err := fc.flagService.ExpandDependencies(c.Request().Context(), flags...); err != nil {
+			return fc.handleServiceError(c, err)
+		}
+	}
+
+	if notModified(c, flagsETag(flags, total)) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"flags":  flags,
+		"count":  len(flags),
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// SatisfyDependencies handles POST /flags/:id/satisfy-dependencies
+func (fc *FlagController) SatisfyDependencies(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	var req validator.FlagReasonRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind satisfy-dependencies request", "error", err, "flagID", id)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+	if err := validator.ValidateFlagReasonRequest(req); err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	actor := getActorFromContext(c)
+
+	enabled, err := fc.flagService.SatisfyDependencies(c.Request().Context(), id, actor, req.Reason)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Flag dependencies satisfied via API", "flagID", id, "enabled", len(enabled), "actor", actor)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"flag_id": id,
+		"enabled": enabled,
+		"count":   len(enabled),
+	})
+}
+
+// GetDependencyGraph handles GET /flags/graph?root=&depth=
+func (fc *FlagController) GetDependencyGraph(c echo.Context) error {
+	var rootID int64
+	if root := c.QueryParam("root"); root != "" {
+		parsed, err := strconv.ParseInt(root, 10, 64)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid root flag ID", nil)
+		}
+		rootID = parsed
+	}
+
+	depth := service.DefaultGraphDepth
+	if d := c.QueryParam("depth"); d != "" {
+		if rootID == 0 {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "depth requires a root flag", nil)
+		}
+		parsed, err := strconv.Atoi(d)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid depth", nil)
+		}
+		depth = parsed
+	}
+
+	graph, err := fc.flagService.GetDependencyGraph(c.Request().Context(), rootID, depth)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, graph)
+}
+
+// GetEnablePlan handles GET /flags/:id/enable-plan
+func (fc *FlagController) GetEnablePlan(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	plan, err := fc.flagService.GetEnablePlan(c.Request().Context(), id)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, plan)
+}
+
+// GetFlagDependents handles GET /flags/:id/dependents
+func (fc *FlagController) GetFlagDependents(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	dependents, err := fc.flagService.GetFlagDependents(c.Request().Context(), id)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"dependents": dependents,
+		"count":      len(dependents),
+	})
+}
+
+// ListFlagEnvironments handles GET /flags/:id/environments
+func (fc *FlagController) ListFlagEnvironments(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	environments, err := fc.flagService.ListFlagEnvironments(c.Request().Context(), id)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"environments": environments,
+		"count":        len(environments),
+	})
+}
+
+// GetFlagStates handles GET /flags/:id/states
+func (fc *FlagController) GetFlagStates(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	states, err := fc.flagService.GetFlagStates(c.Request().Context(), id)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, states)
+}
+
+// ListEnvironments handles GET /environments
+func (fc *FlagController) ListEnvironments(c echo.Context) error {
+	environments, err := fc.flagService.ListEnvironments(c.Request().Context())
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"environments": environments,
+		"count":        len(environments),
+	})
+}
+
+// DiffEnvironments handles GET /environments/diff
+func (fc *FlagController) DiffEnvironments(c echo.Context) error {
+	diff, err := fc.flagService.DiffEnvironments(c.Request().Context(), c.QueryParam("from"), c.QueryParam("to"))
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, diff)
+}
+
+// CreateEnvironment handles POST /environments
+func (fc *FlagController) CreateEnvironment(c echo.Context) error {
+	var req validator.EnvironmentCreateRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind environment request", "error", err)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+
+	actor := getActorFromContext(c)
+
+	if err := fc.flagService.CreateEnvironment(c.Request().Context(), req, actor); err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Environment created via API", "environment", req.Name, "actor", actor)
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"message":     "Environment created successfully",
+		"environment": req.Name,
+	})
+}
+
+// DeleteEnvironment handles DELETE /environments/:name
+func (fc *FlagController) DeleteEnvironment(c echo.Context) error {
+	name := c.Param("name")
+	actor := getActorFromContext(c)
+
+	if err := fc.flagService.DeleteEnvironment(c.Request().Context(), name, actor); err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Environment deleted via API", "environment", name, "actor", actor)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":     "Environment deleted successfully",
+		"environment": name,
+	})
+}
+
+// PreviewDisable handles POST /flags/:id/disable/preview
+func (fc *FlagController) PreviewDisable(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	flags, err := fc.flagService.PreviewCascadeDisable(c.Request().Context(), id)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"flags": flags,
+		"count": len(flags),
+	})
+}
+
+// GetFlagDetail handles GET /flags/:id/detail
+func (fc *FlagController) GetFlagDetail(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	include, err := parseDetailInclude(c)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, err.Error(), nil)
+	}
+
+	detail, err := fc.flagService.GetFlagDetail(c.Request().Context(), id, include)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, detail)
+}
+
+// GetFlag handles GET /flags/:id
+func (fc *FlagController) GetFlag(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	expand, err := parseExpandDependencies(c)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, err.Error(), nil)
+	}
+
+	flag, err := fc.flagService.GetFlag(c.Request().Context(), id)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	if expand {
+		if err := fc.flagService.ExpandDependencies(c.Request().Context(), flag); err != nil {
+			return fc.handleServiceError(c, err)
+		}
+	}
+
+	if notModified(c, flagsETag([]*entity.Flag{flag}, 1)) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.JSON(http.StatusOK, flag)
+}
+
+// GetFlagByName handles GET /flags/by-name/:name
+func (fc *FlagController) GetFlagByName(c echo.Context) error {
+	expand, err := parseExpandDependencies(c)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, err.Error(), nil)
+	}
+
+	flag, err := fc.flagService.GetFlagByName(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	if expand {
+		if err := fc.flagService.ExpandDependencies(c.Request().Context(), flag); err != nil {
+			return fc.handleServiceError(c, err)
+		}
+	}
+
+	if notModified(c, flagsETag([]*entity.Flag{flag}, 1)) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.JSON(http.StatusOK, flag)
+}
+
+// flagsETag derives a weak ETag for a response of flags from their updated_at, which every
+// change to a flag, its tags or its dependencies moves, along with the fields that change
+// without it: the last evaluation, the status of expanded dependencies and the total.
+func flagsETag(flags []*entity.Flag, total int) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d", total)
+	for _, flag := range flags {
+		fmt.Fprintf(h, ";%d@%d", flag.ID, flag.UpdatedAt.UnixNano())
+		if flag.LastEvaluatedAt != nil {
+			fmt.Fprintf(h, "/%d", flag.LastEvaluatedAt.UnixNano())
+		}
+		for _, dep := range flag.DependenciesDetail {
+			fmt.Fprintf(h, ",%d=%s", dep.ID, dep.Status)
+		}
+	}
+	return fmt.Sprintf(`W/"%x"`, h.Sum64())
+}
+
+// notModified sets the response's ETag and reports whether the request's If-None-Match
+// already names it, in which case the caller responds 304 without a body
+func notModified(c echo.Context, etag string) bool {
+	c.Response().Header().Set("ETag", etag)
+	for _, candidate := range strings.Split(c.Request().Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == etag || candidate == "*" || "W/"+candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDetailInclude reads the include query parameter of the detail endpoint. Without it,
+// every section is included.
+func parseDetailInclude(c echo.Context) (entity.FlagDetailInclude, error) {
+	raw := c.QueryParam("include")
+	if strings.TrimSpace(raw) == "" {
+		return entity.FlagDetailInclude{Dependencies: true, Dependents: true, Audit: true}, nil
+	}
+
+	var include entity.FlagDetailInclude
+	for _, field := range strings.Split(raw, ",") {
+		switch strings.TrimSpace(field) {
+		case "":
+		case "dependencies":
+			include.Dependencies = true
+		case "dependents":
+			include.Dependents = true
+		case "audit":
+			include.Audit = true
+		default:
+			return include, fmt.Errorf("unsupported include value %q", field)
+		}
+	}
+	return include, nil
+}
+
+// parseExpandDependencies reads the comma-separated ?expand= parameter. Only "dependencies"
+// is supported; without it flags keep the lean list of dependency IDs.
+func parseExpandDependencies(c echo.Context) (bool, error) {
+	expand := false
+	for _, field := range strings.Split(c.QueryParam("expand"), ",") {
+		switch strings.TrimSpace(field) {
+		case "":
+		case "dependencies":
+			expand = true
+		default:
+			return false, fmt.Errorf("unsupported expand value %q", field)
+		}
+	}
+	return expand, nil
+}
+
+// evaluationMaxAge is how long clients and proxies may cache an evaluation result
+const evaluationMaxAge = 5 * time.Second
+
+// GetFlagEnabled handles GET /flags/:id/enabled, where :id may also be a flag name.
+// Responds with {"enabled":bool}, or a bare true/false for Accept: text/plain or ?format=text.
+func (fc *FlagController) GetFlagEnabled(c echo.Context) error {
+	enabled, err := fc.flagService.IsFlagEnabled(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	etag := fmt.Sprintf(`W/"%t"`, enabled)
+	c.Response().Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(evaluationMaxAge.Seconds())))
+	if notModified(c, etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	if c.QueryParam("format") == "text" || c.Request().Header.Get(echo.HeaderAccept) == echo.MIMETextPlain {
+		return c.String(http.StatusOK, strconv.FormatBool(enabled))
+	}
+	return c.JSON(http.StatusOK, map[string]bool{
+		"enabled": enabled,
+	})
+}
+
+// EvaluateFlag handles GET /flags/:id/evaluate, where :id may also be a flag name. Responds
+// with {"name":...,"enabled":bool}; an optional ?user_id= applies the rollout percentage.
+func (fc *FlagController) EvaluateFlag(c echo.Context) error {
+	evaluation, err := fc.flagService.EvaluateFlag(c.Request().Context(), c.Param("id"), c.QueryParam("user_id"))
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, evaluation)
+}
+
+// EvaluateFlags handles POST /flags/evaluate
+func (fc *FlagController) EvaluateFlags(c echo.Context) error {
+	var req validator.FlagEvaluateRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+
+	result, err := fc.flagService.EvaluateFlags(c.Request().Context(), req)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// GetClosureSize handles GET /flags/:id/closure-size
+func (fc *FlagController) GetClosureSize(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	closure, err := fc.flagService.GetClosureSize(c.Request().Context(), id)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, closure)
+}
+
+// ExportFlag handles GET /flags/:id/export
+func (fc *FlagController) ExportFlag(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	export, err := fc.flagService.ExportFlag(c.Request().Context(), id)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="%s.json"`, export.Flags[0].Name))
+	return c.JSON(http.StatusOK, export)
+}
+
+// ExportFlags handles GET /flags/export
+func (fc *FlagController) ExportFlags(c echo.Context) error {
+	export, err := fc.flagService.ExportFlags(c.Request().Context())
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="flags.json"`)
+	return c.JSON(http.StatusOK, export)
+}
+
+// ImportFlags handles POST /flags/import
+func (fc *FlagController) ImportFlags(c echo.Context) error {
+	var req validator.FlagImportRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind import request", "error", err)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+
+	actor := getActorFromContext(c)
+
+	result, err := fc.flagService.ImportFlags(c.Request().Context(), req, actor)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Flags imported via API", "created", len(result.Created), "updated", len(result.Updated),
+		"skipped", len(result.Skipped), "actor", actor)
+	return c.JSON(http.StatusOK, result)
+}
+
+// SyncFlags handles POST /flags/sync-from
+func (fc *FlagController) SyncFlags(c echo.Context) error {
+	var req validator.FlagSyncRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind sync request", "error", err)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+
+	actor := getActorFromContext(c)
+
+	result, err := fc.flagService.SyncFlags(c.Request().Context(), req, actor)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Flags synced via API", "source", req.SourceURL, "created", len(result.Created),
+		"updated", len(result.Updated), "skipped", len(result.Skipped), "actor", actor)
+	return c.JSON(http.StatusOK, result)
+}
+
+// GetFlagAudit handles GET /flags/:id/audit
+func (fc *FlagController) GetFlagAudit(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	filter := entity.AuditFilter{
+		Action: entity.AuditAction(c.QueryParam("action")),
+		Actor:  c.QueryParam("actor"),
+	}
+	if raw := c.QueryParam("from"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid from time, expected RFC3339", nil)
+		}
+		filter.From = parsed
+	}
+	if raw := c.QueryParam("to"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid to time, expected RFC3339", nil)
+		}
+		filter.To = parsed
+	}
+
+	logs, err := fc.flagService.GetFlagAuditLogs(c.Request().Context(), id, filter)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"audit_logs": logs,
+		"count":      len(logs),
+	})
+}
+
+// streamHeartbeat is how often an SSE comment is sent to keep idle connections open
+const streamHeartbeat = 30 * time.Second
+
+// openEventStream sends the headers of a server-sent events response
+func openEventStream(c echo.Context) *echo.Response {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+	return res
+}
+
+// StreamAuditLogs handles GET /audit/stream as a server-sent events feed
+func (fc *FlagController) StreamAuditLogs(c echo.Context) error {
+	filter := entity.AuditFilter{
+		Action: entity.AuditAction(c.QueryParam("action")),
+		Actor:  c.QueryParam("actor"),
+	}
+
+	ctx := c.Request().Context()
+	logs, err := fc.flagService.StreamAuditLogs(ctx, filter)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	res := openEventStream(c)
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case log, ok := <-logs:
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(log)
+			if err != nil {
+				fc.log(c).Errorw("Failed to encode audit event", "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(res, "event: audit\nid: %d\ndata: %s\n\n", log.ID, data); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
+
+// StreamFlagChanges handles GET /flags/stream as a server-sent events feed of status changes
+func (fc *FlagController) StreamFlagChanges(c echo.Context) error {
+	ctx := c.Request().Context()
+	changes := fc.flagService.StreamFlagChanges(ctx)
+
+	res := openEventStream(c)
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case change, ok := <-changes:
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(change)
+			if err != nil {
+				fc.log(c).Errorw("Failed to encode flag status event", "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(res, "event: flag_status\ndata: %s\n\n", data); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
+
+// GetAuditReport handles GET /audit/report
+func (fc *FlagController) GetAuditReport(c echo.Context) error {
+	groupBy := entity.AuditReportGroupBy(c.QueryParam("group_by"))
+	if groupBy == "" {
+		groupBy = entity.AuditReportByActor
+	}
+
+	to := time.Now()
+	if raw := c.QueryParam("to"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid to time, expected RFC3339", nil)
+		}
+		to = parsed
+	}
+
+	from := to.Add(-service.DefaultAuditReportWindow)
+	if raw := c.QueryParam("from"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid from time, expected RFC3339", nil)
+		}
+		from = parsed
+	}
+
+	report, err := fc.flagService.GetAuditReport(c.Request().Context(), groupBy, from, to)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// ListAuditLogs handles GET /audit. A limit above the maximum page size is lowered to it.
+func (fc *FlagController) ListAuditLogs(c echo.Context) error {
+	limit, offset := service.DefaultAuditPageSize, 0
+	if raw := c.QueryParam("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid limit", nil)
+		}
+		limit = min(parsed, service.MaxAuditPageSize)
+	}
+	if raw := c.QueryParam("offset"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid offset", nil)
+		}
+		offset = parsed
+	}
+
+	logs, err := fc.flagService.ListAuditLogs(c.Request().Context(), limit, offset)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"audit_logs": logs,
+		"count":      len(logs),
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
+
+// ListFlappyFlags handles GET /flags/flappy
+func (fc *FlagController) ListFlappyFlags(c echo.Context) error {
+	windowDays, minToggles := 7, 5
+	if raw := c.QueryParam("window_days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid window_days", nil)
+		}
+		windowDays = parsed
+	}
+	if raw := c.QueryParam("min_toggles"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid min_toggles", nil)
+		}
+		minToggles = parsed
+	}
+
+	flags, err := fc.flagService.ListFlappyFlags(c.Request().Context(), windowDays, minToggles)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"flags": flags,
+		"count": len(flags),
+	})
+}
+
+// GetFlagStats handles GET /flags/stats
+func (fc *FlagController) GetFlagStats(c echo.Context) error {
+	stats, err := fc.flagService.GetFlagStats(c.Request().Context())
+	if err != nil {
+		return fc.handleServiceError(c, err)
 	}
+	return c.JSON(http.StatusOK, stats)
 }
 
-// CreateFlag handles POST /flags
-func (fc *FlagController) CreateFlag(c echo.Context) error {
-	var req validator.FlagCreateRequest
+// ListUnusedFlags handles GET /flags/unused
+func (fc *FlagController) ListUnusedFlags(c echo.Context) error {
+	days := 90
+	if raw := c.QueryParam("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid days", nil)
+		}
+		days = parsed
+	}
+
+	flags, err := fc.flagService.ListUnusedFlags(c.Request().Context(), days)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"flags": flags,
+		"count": len(flags),
+	})
+}
+
+// RestoreCascade handles POST /flags/:id/restore-cascade
+func (fc *FlagController) RestoreCascade(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	var req validator.FlagReasonRequest
 	if err := c.Bind(&req); err != nil {
-		fc.logger.Warnw("Failed to bind create flag request", "error", err)
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request body",
-		})
+		fc.log(c).Warnw("Failed to bind restore-cascade request", "error", err, "flagID", id)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+	if err := validator.ValidateFlagReasonRequest(req); err != nil {
+		return fc.handleServiceError(c, err)
 	}
 
-	// Get actor from context (in a real app, this would come from auth middleware)
 	actor := getActorFromContext(c)
 
-	flag, err := fc.flagService.CreateFlag(context.Background(), req, actor)
+	result, err := fc.flagService.RestoreCascade(c.Request().Context(), id, actor, req.Reason)
 	if err != nil {
 		return fc.handleServiceError(c, err)
 	}
 
-	fc.logger.Infow("Flag created via API", "flagID", flag.ID, "name", flag.Name, "actor", actor)
-	return c.JSON(http.StatusCreated, flag)
+	fc.log(c).Infow("Cascade restored via API", "flagID", id, "restored", len(result.Restored), "actor", actor)
+	return c.JSON(http.StatusOK, result)
 }
 
-// ToggleFlag handles POST /flags/:id/toggle
-func (fc *FlagController) ToggleFlag(c echo.Context) error {
+// RestoreSubtree handles POST /flags/:id/restore-subtree
+func (fc *FlagController) RestoreSubtree(c echo.Context) error {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid flag ID",
-		})
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
 	}
 
-	var req validator.FlagToggleRequest
+	var req validator.FlagRestoreSubtreeRequest
 	if err := c.Bind(&req); err != nil {
-		fc.logger.Warnw("Failed to bind toggle flag request", "error", err, "flagID", id)
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request body",
-		})
+		fc.log(c).Warnw("Failed to bind restore-subtree request", "error", err, "flagID", id)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
 	}
 
 	actor := getActorFromContext(c)
 
-	err = fc.flagService.ToggleFlag(context.Background(), id, req, actor)
+	result, err := fc.flagService.RestoreSubtree(c.Request().Context(), id, req, actor)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Subtree restored via API", "flagID", id, "restored", len(result.Restored), "actor", actor)
+	return c.JSON(http.StatusOK, result)
+}
+
+// LockFlag handles POST /flags/:id/lock
+func (fc *FlagController) LockFlag(c echo.Context) error {
+	return fc.setFlagLocked(c, true)
+}
+
+// UnlockFlag handles POST /flags/:id/unlock
+func (fc *FlagController) UnlockFlag(c echo.Context) error {
+	return fc.setFlagLocked(c, false)
+}
+
+func (fc *FlagController) setFlagLocked(c echo.Context, locked bool) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	var req validator.FlagReasonRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind lock request", "error", err, "flagID", id)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+	if err := validator.ValidateFlagReasonRequest(req); err != nil {
 		return fc.handleServiceError(c, err)
 	}
 
-	status := "disabled"
-	if req.Enable {
-		status = "enabled"
+	actor := getActorFromContext(c)
+
+	lock, message := fc.flagService.UnlockFlag, "Flag unlocked successfully"
+	if locked {
+		lock, message = fc.flagService.LockFlag, "Flag locked successfully"
+	}
+	if err := lock(c.Request().Context(), id, actor, req.Reason); err != nil {
+		return fc.handleServiceError(c, err)
 	}
 
-	fc.logger.Infow("Flag toggled via API", "flagID", id, "status", status, "actor", actor)
+	fc.log(c).Infow("Flag lock updated via API", "flagID", id, "locked", locked, "actor", actor)
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"message": "Flag " + status + " successfully",
+		"message": message,
 		"flag_id": id,
-		"status":  status,
+		"locked":  locked,
 	})
 }
 
-// ListFlags handles GET /flags
-func (fc *FlagController) ListFlags(c echo.Context) error {
-	flags, err := fc.flagService.ListFlags(context.Background())
+// ArchiveFlag handles POST /flags/:id/archive
+func (fc *FlagController) ArchiveFlag(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		fc.logger.Errorw("Failed to list flags via API", "error", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to retrieve flags",
-		})
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	var req validator.FlagReasonRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind archive request", "error", err, "flagID", id)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+	if err := validator.ValidateFlagReasonRequest(req); err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	actor := getActorFromContext(c)
+	if err := fc.flagService.ArchiveFlag(c.Request().Context(), id, actor, req.Reason); err != nil {
+		return fc.handleServiceError(c, err)
 	}
 
+	fc.log(c).Infow("Flag archived via API", "flagID", id, "actor", actor)
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"flags": flags,
-		"count": len(flags),
+		"message":  "Flag archived successfully",
+		"flag_id":  id,
+		"archived": true,
 	})
 }
 
-// GetFlag handles GET /flags/:id
-func (fc *FlagController) GetFlag(c echo.Context) error {
+// RestoreFlag handles POST /flags/:id/restore
+func (fc *FlagController) RestoreFlag(c echo.Context) error {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid flag ID",
-		})
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	var req validator.FlagReasonRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind restore request", "error", err, "flagID", id)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+	if err := validator.ValidateFlagReasonRequest(req); err != nil {
+		return fc.handleServiceError(c, err)
 	}
 
-	flag, err := fc.flagService.GetFlag(context.Background(), id)
+	actor := getActorFromContext(c)
+	flag, err := fc.flagService.RestoreFlag(c.Request().Context(), id, actor, req.Reason)
 	if err != nil {
 		return fc.handleServiceError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, flag)
+	fc.log(c).Infow("Flag restored via API", "flagID", id, "actor", actor)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Flag restored successfully",
+		"flag":    flag,
+	})
 }
 
-// GetFlagAudit handles GET /flags/:id/audit
-func (fc *FlagController) GetFlagAudit(c echo.Context) error {
+// SetMaintenance handles POST /flags/:id/maintenance
+func (fc *FlagController) SetMaintenance(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	var req validator.FlagReasonRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind maintenance request", "error", err, "flagID", id)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+	if err := validator.ValidateFlagReasonRequest(req); err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	actor := getActorFromContext(c)
+
+	if err := fc.flagService.SetMaintenance(c.Request().Context(), id, actor, req.Reason); err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Flag put into maintenance via API", "flagID", id, "actor", actor)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Flag put into maintenance successfully",
+		"flag_id": id,
+		"status":  "maintenance",
+	})
+}
+
+// ResumeFlag handles POST /flags/:id/resume
+func (fc *FlagController) ResumeFlag(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	var req validator.FlagResumeRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind resume request", "error", err, "flagID", id)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+
+	actor := getActorFromContext(c)
+
+	if err := fc.flagService.ResumeFlag(c.Request().Context(), id, req, actor); err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Flag resumed from maintenance via API", "flagID", id, "actor", actor)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Flag resumed successfully",
+		"flag_id": id,
+		"status":  "enabled",
+	})
+}
+
+// EnableWhenReady handles POST /flags/:id/enable-when-ready
+func (fc *FlagController) EnableWhenReady(c echo.Context) error {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid flag ID",
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	var req validator.FlagReasonRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind enable-when-ready request", "error", err, "flagID", id)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+	if err := validator.ValidateFlagReasonRequest(req); err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	actor := getActorFromContext(c)
+
+	pending, err := fc.flagService.EnableWhenReady(c.Request().Context(), id, actor, req.Reason)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	if pending == nil {
+		fc.log(c).Infow("Flag enabled via enable-when-ready", "flagID", id, "actor", actor)
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": "Flag enabled successfully",
+			"flag_id": id,
+			"status":  "enabled",
 		})
 	}
 
-	logs, err := fc.flagService.GetFlagAuditLogs(context.Background(), id)
+	fc.log(c).Infow("Flag enable deferred until dependencies are ready", "flagID", id, "pendingID", pending.ID, "actor", actor)
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"message":    "Flag will be enabled once its dependencies are enabled",
+		"flag_id":    id,
+		"pending_id": pending.ID,
+		"pending":    pending,
+	})
+}
+
+// CancelPendingEnable handles DELETE /flags/:id/enable-when-ready/:pendingId
+func (fc *FlagController) CancelPendingEnable(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+	pendingID, err := strconv.ParseInt(c.Param("pendingId"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid pending enable ID", nil)
+	}
+
+	actor := getActorFromContext(c)
+
+	if err := fc.flagService.CancelPendingEnable(c.Request().Context(), id, pendingID, actor); err != nil {
 		return fc.handleServiceError(c, err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"audit_logs": logs,
-		"count":      len(logs),
+		"message":    "Pending enable cancelled",
+		"flag_id":    id,
+		"pending_id": pendingID,
 	})
 }
 
+// ScheduleFlagChange handles POST /flags/:id/schedule
+func (fc *FlagController) ScheduleFlagChange(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	var req validator.FlagScheduleRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind schedule request", "error", err, "flagID", id)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body; scheduled_at must be an RFC3339 timestamp", nil)
+	}
+	if err := validator.ValidateFlagScheduleRequest(req); err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	actor := getActorFromContext(c)
+
+	change, err := fc.flagService.ScheduleChange(c.Request().Context(), id, req, actor)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Flag change scheduled via API", "flagID", id, "scheduleID", change.ID, "actor", actor)
+	return c.JSON(http.StatusCreated, change)
+}
+
+// ScheduleRollout handles POST /flags/:id/rollout/schedule
+func (fc *FlagController) ScheduleRollout(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	var req validator.FlagRolloutScheduleRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind rollout schedule request", "error", err, "flagID", id)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+
+	actor := getActorFromContext(c)
+
+	schedule, err := fc.flagService.ScheduleRollout(c.Request().Context(), id, req, actor)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Rollout scheduled via API", "flagID", id, "actor", actor)
+	return c.JSON(http.StatusCreated, schedule)
+}
+
+// GetRolloutSchedule handles GET /flags/:id/rollout/schedule
+func (fc *FlagController) GetRolloutSchedule(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	schedule, err := fc.flagService.GetRolloutSchedule(c.Request().Context(), id)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+	return c.JSON(http.StatusOK, schedule)
+}
+
+// PauseRollout handles POST /flags/:id/rollout/schedule/pause
+func (fc *FlagController) PauseRollout(c echo.Context) error {
+	return fc.changeRollout(c, "pause", fc.flagService.PauseRollout)
+}
+
+// ResumeRollout handles POST /flags/:id/rollout/schedule/resume
+func (fc *FlagController) ResumeRollout(c echo.Context) error {
+	return fc.changeRollout(c, "resume", fc.flagService.ResumeRollout)
+}
+
+// AbortRollout handles POST /flags/:id/rollout/schedule/abort
+func (fc *FlagController) AbortRollout(c echo.Context) error {
+	return fc.changeRollout(c, "abort", fc.flagService.AbortRollout)
+}
+
+// RollbackRollout handles POST /flags/:id/rollout/rollback
+func (fc *FlagController) RollbackRollout(c echo.Context) error {
+	return fc.changeRollout(c, "rollback", fc.flagService.RollbackRollout)
+}
+
+func (fc *FlagController) changeRollout(c echo.Context, operation string,
+	change func(ctx context.Context, flagID int64, actor, reason string) (*entity.RolloutSchedule, error)) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid flag ID", nil)
+	}
+
+	var req validator.FlagReasonRequest
+	if err := c.Bind(&req); err != nil {
+		fc.log(c).Warnw("Failed to bind rollout request", "error", err, "flagID", id, "operation", operation)
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+	}
+	if err := validator.ValidateFlagReasonRequest(req); err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	actor := getActorFromContext(c)
+
+	schedule, err := change(c.Request().Context(), id, actor, req.Reason)
+	if err != nil {
+		return fc.handleServiceError(c, err)
+	}
+
+	fc.log(c).Infow("Rollout schedule changed via API", "flagID", id, "operation", operation, "actor", actor)
+	return c.JSON(http.StatusOK, schedule)
+}
+
+// log returns the controller logger, tagged with the ID of the request being served
+func (fc *FlagController) log(c echo.Context) *logger.Logger {
+	return fc.logger.WithContext(c.Request().Context())
+}
+
 // handleServiceError converts service errors to appropriate HTTP responses
 func (fc *FlagController) handleServiceError(c echo.Context, err error) error {
 	// Handle validation errors
 	if validationErr, ok := err.(validator.ValidationErrors); ok {
-		fc.logger.Warnw("Validation error in API", "error", err)
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"error":            "Validation failed",
+		fc.log(c).Warnw("Validation error in API", "error", err)
+		return respondError(c, http.StatusBadRequest, CodeValidationFailed, "Validation failed", map[string]interface{}{
 			"validation_errors": validationErr.Errors,
 		})
 	}
 
 	// Handle dependency errors (matching task requirements)
 	if depErr, ok := err.(service.DependencyError); ok {
-		fc.logger.Warnw("Dependency error in API", "error", err)
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"error":                depErr.Message,
+		fc.log(c).Warnw("Dependency error in API", "error", err)
+		details := map[string]interface{}{
 			"missing_dependencies": depErr.MissingDependencies,
+		}
+		if len(depErr.ScheduledEnables) > 0 {
+			details["scheduled_enables"] = depErr.ScheduledEnables
+		}
+		// Every missing dependency is scheduled to be enabled, so the enable can be retried then
+		if depErr.ReadyAt != nil {
+			details["ready_at"] = depErr.ReadyAt
+			retryAfter := max(1, int(math.Ceil(time.Until(*depErr.ReadyAt).Seconds())))
+			c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(retryAfter))
+		}
+		return respondError(c, http.StatusBadRequest, CodeMissingDependencies, depErr.Message, details)
+	}
+
+	// Handle dependencies given by names or IDs that do not exist
+	if unknownErr, ok := err.(service.UnknownDependenciesError); ok {
+		fc.log(c).Warnw("Unknown dependencies in API", "error", err,
+			"unknown", unknownErr.UnknownDependencies, "unknownIDs", unknownErr.UnknownDependencyIDs)
+		details := map[string]interface{}{}
+		if len(unknownErr.UnknownDependencies) > 0 {
+			details["unknown_dependencies"] = unknownErr.UnknownDependencies
+		}
+		if len(unknownErr.UnknownDependencyIDs) > 0 {
+			details["unknown_dependency_ids"] = unknownErr.UnknownDependencyIDs
+		}
+		return respondError(c, http.StatusBadRequest, CodeUnknownDependencies, unknownErr.Message, details)
+	}
+
+	// Handle rejected bulk creates, reporting every rejected flag
+	if bulkErr, ok := err.(service.BulkCreateError); ok {
+		fc.log(c).Warnw("Bulk create rejected", "error", err, "rejected", len(bulkErr.Items))
+		return respondError(c, http.StatusBadRequest, CodeBulkCreateRejected, bulkErr.Message, map[string]interface{}{
+			"errors": bulkErr.Items,
+		})
+	}
+
+	// Handle rejected bulk toggles, reporting every rejected flag
+	if bulkErr, ok := err.(service.BulkToggleError); ok {
+		fc.log(c).Warnw("Bulk toggle rejected", "error", err, "rejected", len(bulkErr.Items))
+		return respondError(c, http.StatusConflict, CodeBulkToggleRejected, bulkErr.Message, map[string]interface{}{
+			"errors": bulkErr.Items,
+		})
+	}
+
+	// Handle rejected imports, reporting every rejected flag
+	if importErr, ok := err.(service.ImportError); ok {
+		fc.log(c).Warnw("Import rejected", "error", err, "rejected", len(importErr.Items))
+		return respondError(c, http.StatusBadRequest, CodeImportRejected, importErr.Message, map[string]interface{}{
+			"errors": importErr.Items,
+		})
+	}
+
+	// Handle cycles found in stored dependencies
+	var cycleErr service.CycleError
+	if errors.As(err, &cycleErr) {
+		fc.log(c).Warnw("Dependency cycle in API", "error", err)
+		return respondError(c, http.StatusBadRequest, CodeCircularDependency, cycleErr.Message, map[string]interface{}{
+			"cycle": cycleErr.Cycle,
+		})
+	}
+
+	// Handle dependencies that were archived or deleted
+	var unavailableErr service.UnavailableDependencyError
+	if errors.As(err, &unavailableErr) {
+		fc.log(c).Warnw("Dependency unavailable in API", "error", err)
+		return respondError(c, http.StatusConflict, CodeDependencyUnavailable, unavailableErr.Error(), map[string]interface{}{
+			"dependency": unavailableErr.Dependency,
+			"reason":     unavailableErr.Reason,
+		})
+	}
+
+	// Handle block disable policy
+	if blockErr, ok := err.(service.EnabledDependentsError); ok {
+		fc.log(c).Warnw("Disable blocked by enabled dependents", "error", err)
+		return respondError(c, http.StatusConflict, CodeEnabledDependents, blockErr.Message, map[string]interface{}{
+			"enabled_dependents": blockErr.EnabledDependents,
+		})
+	}
+
+	// Handle deletion of a flag that others still depend on
+	if depsErr, ok := err.(service.HasDependentsError); ok {
+		fc.log(c).Warnw("Delete blocked by dependents", "error", err)
+		return respondError(c, http.StatusConflict, CodeHasDependents, depsErr.Message, map[string]interface{}{
+			"dependents": depsErr.Dependents,
+		})
+	}
+
+	// Handle high-risk enable confirmation
+	if confirmErr, ok := err.(service.ConfirmationRequiredError); ok {
+		return respondError(c, http.StatusPreconditionRequired, CodeConfirmationRequired, confirmErr.Message, map[string]interface{}{
+			"confirmation_token": confirmErr.Token,
+			"expires_at":         confirmErr.ExpiresAt,
 		})
 	}
 
 	// Handle specific service errors
 	switch {
+	case errors.Is(err, validator.ErrActorNotAllowed):
+		return respondError(c, http.StatusForbidden, CodeActorNotAllowed, "Actor is not allowed to make changes", nil)
 	case errors.Is(err, service.ErrFlagNotFound):
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "Flag not found",
-		})
+		return respondError(c, http.StatusNotFound, CodeFlagNotFound, "Flag not found", nil)
 	case errors.Is(err, service.ErrFlagAlreadyExists):
-		return c.JSON(http.StatusConflict, map[string]string{
-			"error": "Flag with this name already exists",
-		})
+		return respondError(c, http.StatusConflict, CodeFlagAlreadyExists, "Flag with this name already exists", nil)
+	case errors.Is(err, service.ErrSelfDependency):
+		return respondError(c, http.StatusBadRequest, CodeSelfDependency, "Flag cannot depend on itself", nil)
 	case errors.Is(err, service.ErrCircularDependency):
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Circular dependency detected",
-		})
+		return respondError(c, http.StatusBadRequest, CodeCircularDependency, "Circular dependency detected", nil)
+	case errors.Is(err, service.ErrDependencyTooDeep):
+		return respondError(c, http.StatusBadRequest, CodeDependencyTooDeep, err.Error(), nil)
+	case errors.Is(err, service.ErrFlagInMaintenance):
+		return respondError(c, http.StatusConflict, CodeFlagInMaintenance, "Flag is in maintenance and must be resumed explicitly", nil)
+	case errors.Is(err, service.ErrDependencyNotFound):
+		return respondError(c, http.StatusNotFound, CodeDependencyNotFound, "Flag does not depend on this flag", nil)
+	case errors.Is(err, service.ErrFlagLocked):
+		return respondError(c, http.StatusLocked, CodeFlagLocked, "Flag is locked and must be unlocked before it can be changed", nil)
+	case errors.Is(err, service.ErrConfirmationRequired):
+		return respondError(c, http.StatusPreconditionRequired, CodeConfirmationRequired, err.Error(), nil)
+	case errors.Is(err, service.ErrFlagArchived):
+		return respondError(c, http.StatusConflict, CodeFlagArchived, "Flag is archived and must be restored first", nil)
+	case errors.Is(err, service.ErrConcurrentModification):
+		return respondError(c, http.StatusConflict, CodeConcurrentModification, "Flag was changed by another request; reload it and try again", nil)
+	case errors.Is(err, service.ErrFlagNotInMaintenance):
+		return respondError(c, http.StatusBadRequest, CodeFlagNotInMaintenance, "Flag is not in maintenance", nil)
+	case errors.Is(err, service.ErrEnvironmentNotFound):
+		return respondError(c, http.StatusBadRequest, CodeUnknownEnvironment, "Unknown environment", nil)
+	case errors.Is(err, service.ErrEnvironmentCascade), errors.Is(err, service.ErrGlobalEnvironment),
+		errors.Is(err, service.ErrInvalidEnvironmentDiff):
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, err.Error(), nil)
+	case errors.Is(err, service.ErrEnvironmentExists):
+		return respondError(c, http.StatusConflict, CodeEnvironmentExists, "Environment already exists", nil)
+	case errors.Is(err, service.ErrIdempotencyKeyReused):
+		return respondError(c, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, "Idempotency-Key was already used with a different request", nil)
+	case errors.Is(err, service.ErrFlagNotArchived):
+		return respondError(c, http.StatusBadRequest, CodeFlagNotArchived, "Flag is not archived", nil)
+	case errors.Is(err, service.ErrUnsupportedExportVersion):
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, err.Error(), nil)
+	case errors.Is(err, service.ErrSyncSourceUnreachable), errors.Is(err, service.ErrSyncSourceInvalid):
+		return respondError(c, http.StatusBadGateway, CodeSyncSourceFailed, err.Error(), nil)
+	case errors.Is(err, service.ErrInvalidGraphDepth):
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, err.Error(), nil)
+	case errors.Is(err, service.ErrInvalidReportGrouping), errors.Is(err, service.ErrInvalidReportWindow),
+		errors.Is(err, service.ErrInvalidFlappinessQuery), errors.Is(err, service.ErrInvalidUnusedWindow),
+		errors.Is(err, service.ErrInvalidAuditPage), errors.Is(err, service.ErrInvalidFlagPage),
+		errors.Is(err, service.ErrInvalidAuditWindow),
+		errors.Is(err, service.ErrInvalidFlagStatus), errors.Is(err, service.ErrScheduleInPast),
+		errors.Is(err, service.ErrExpiryInPast):
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, err.Error(), nil)
+	case errors.Is(err, service.ErrInvalidAuditAction):
+		return respondError(c, http.StatusBadRequest, CodeInvalidRequest, err.Error(), nil)
+	case errors.Is(err, service.ErrRolloutScheduleNotFound):
+		return respondError(c, http.StatusNotFound, CodeRolloutNotScheduled, "Flag has no rollout schedule", nil)
+	case errors.Is(err, service.ErrRolloutScheduleFinished):
+		return respondError(c, http.StatusConflict, CodeRolloutFinished, "Rollout schedule already finished", nil)
+	case errors.Is(err, service.ErrCascadeNotFound):
+		return respondError(c, http.StatusNotFound, CodeCascadeNotFound, "No cascade recorded for this flag", nil)
+	case errors.Is(err, service.ErrCascadeAlreadyRestored):
+		return respondError(c, http.StatusConflict, CodeCascadeAlreadyRestored, "The most recent cascade from this flag was already restored", nil)
+	case errors.Is(err, service.ErrPendingEnableNotFound):
+		return respondError(c, http.StatusNotFound, CodePendingEnableNotFound, "Pending enable not found", nil)
+	case errors.Is(err, service.ErrFeatureNotConfigured):
+		return respondError(c, http.StatusNotImplemented, CodeFeatureNotConfigured, "Feature not configured on this server", nil)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		// Usually the client went away; nobody may be left to read the response
+		fc.log(c).Warnw("Request cancelled before completion", "error", err)
+		return respondError(c, http.StatusServiceUnavailable, CodeRequestCancelled, "Request was cancelled before it completed", nil)
+	case repository.IsUnavailableError(err):
+		fc.log(c).Errorw("Database unavailable for request", "error", err)
+		c.Set(WriteUnavailableContextKey, true)
+		return respondError(c, http.StatusServiceUnavailable, ReadOnlyErrorCode, "Database is not accepting writes", nil)
 	default:
-		fc.logger.Errorw("Internal error in API", "error", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Internal server error",
-		})
+		fc.log(c).Errorw("Internal error in API", "error", err)
+		return respondError(c, http.StatusInternalServerError, CodeInternal, "Internal server error", nil)
 	}
 }
 
-// getActorFromContext extracts the actor from the request context
-// In a real application, this would be populated by authentication middleware
+// getActorFromContext returns the actor the authentication middleware (and delegation)
+// resolved for the request. Client-supplied headers and parameters are never trusted.
 func getActorFromContext(c echo.Context) string {
-	// Check for actor in headers first
-	if actor := c.Request().Header.Get("X-Actor"); actor != "" {
-		return actor
-	}
-	
-	// Check for actor in query params
-	if actor := c.QueryParam("actor"); actor != "" {
+	if actor, ok := c.Get(ActorContextKey).(string); ok && actor != "" {
 		return actor
 	}
-	
-	// Default to anonymous user
 	return "anonymous"
-} 
\ No newline at end of file
+}
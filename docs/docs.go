@@ -43,6 +43,23 @@ const docTemplate = `{
                                 },
                                 "status": {
                                     "type": "string"
+                                },
+                                "database": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Migrations pending or database unreachable",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "status": {
+                                    "type": "string"
+                                },
+                                "database": {
+                                    "type": "string"
                                 }
                             }
                         }
@@ -50,6 +67,43 @@ const docTemplate = `{
                 }
             }
         },
+        "/livez": {
+            "get": {
+                "description": "Report that the process is up, without checking the database",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Report whether database migrations have finished and the database is reachable",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "503": {
+                        "description": "Database unreachable"
+                    }
+                }
+            }
+        },
         "/api/v1/flags": {
             "get": {
                 "description": "Get all feature flags with their dependencies",
@@ -110,9 +164,10 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Actor performing the action",
-                        "name": "X-Actor",
-                        "in": "header"
+                        "description": "Bearer token identifying the actor",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -210,9 +265,22 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Actor performing the action",
-                        "name": "X-Actor",
+                        "description": "Environment to toggle the flag in (defaults to global)",
+                        "name": "env",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key making retries of this toggle return the first response instead of toggling again",
+                        "name": "Idempotency-Key",
                         "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bearer token identifying the actor",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -331,6 +399,32 @@ const docTemplate = `{
                         "type": "integer"
                     }
                 },
+                "description": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "rollout_percentage": {
+                    "type": "integer"
+                },
+                "expires_at": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "archived_at": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "created_by": {
+                    "type": "string"
+                },
+                "updated_by": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
@@ -357,6 +451,34 @@ const docTemplate = `{
                         "type": "integer"
                     }
                 },
+                "dependency_names": {
+                    "type": "array",
+                    "description": "Dependencies by name; cannot be combined with dependencies",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "description": {
+                    "type": "string",
+                    "maxLength": 1000
+                },
+                "rollout_percentage": {
+                    "type": "integer",
+                    "minimum": 0,
+                    "maximum": 100
+                },
+                "expires_at": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "tags": {
+                    "type": "object",
+                    "maxProperties": 50,
+                    "additionalProperties": {
+                        "type": "string",
+                        "maxLength": 100
+                    }
+                },
                 "name": {
                     "type": "string",
                     "maxLength": 100,
@@ -377,27 +499,46 @@ const docTemplate = `{
                     "type": "string",
                     "maxLength": 500,
                     "minLength": 3
+                },
+                "cascade": {
+                    "type": "boolean"
                 }
             }
         },
         "ErrorResponse": {
             "type": "object",
             "properties": {
-                "error": {
+                "code": {
+                    "type": "string",
+                    "example": "FLAG_NOT_FOUND"
+                },
+                "message": {
                     "type": "string"
+                },
+                "details": {
+                    "type": "object"
                 }
             }
         },
         "DependencyError": {
             "type": "object",
             "properties": {
-                "error": {
+                "code": {
+                    "type": "string",
+                    "example": "MISSING_DEPENDENCIES"
+                },
+                "message": {
                     "type": "string"
                 },
-                "missing_dependencies": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
+                "details": {
+                    "type": "object",
+                    "properties": {
+                        "missing_dependencies": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
                     }
                 }
             }
@@ -8,22 +8,80 @@ import (
 type AuditAction string
 
 const (
-	ActionCreate         AuditAction = "create"
-	ActionEnable         AuditAction = "enable"
-	ActionDisable        AuditAction = "disable"
-	ActionCascadeDisable AuditAction = "cascade_disable"
-	ActionUpdate         AuditAction = "update"
-	ActionDelete         AuditAction = "delete"
+	ActionCreate             AuditAction = "create"
+	ActionEnable             AuditAction = "enable"
+	ActionDisable            AuditAction = "disable"
+	ActionCascadeDisable     AuditAction = "cascade_disable"
+	ActionCascadeEnable      AuditAction = "cascade_enable"
+	ActionCascadeMaintenance AuditAction = "cascade_maintenance"
+	ActionUpdate             AuditAction = "update"
+	ActionDelete             AuditAction = "delete"
+	ActionMaintenance        AuditAction = "maintenance"
+	ActionResume             AuditAction = "resume"
+	ActionAddDependency      AuditAction = "add_dependency"
+	ActionRemoveDependency   AuditAction = "remove_dependency"
+	ActionDriftDetected      AuditAction = "drift_detected"
+	ActionLock               AuditAction = "lock"
+	ActionUnlock             AuditAction = "unlock"
+	ActionExpire             AuditAction = "expire"
+	ActionArchive            AuditAction = "archive"
+	ActionRestore            AuditAction = "restore"
 )
 
+// KnownAuditActions lists every audit action the service writes
+var KnownAuditActions = []AuditAction{
+	ActionCreate,
+	ActionEnable,
+	ActionDisable,
+	ActionCascadeDisable,
+	ActionCascadeEnable,
+	ActionCascadeMaintenance,
+	ActionUpdate,
+	ActionDelete,
+	ActionMaintenance,
+	ActionResume,
+	ActionAddDependency,
+	ActionRemoveDependency,
+	ActionDriftDetected,
+	ActionLock,
+	ActionUnlock,
+	ActionExpire,
+	ActionArchive,
+	ActionRestore,
+}
+
+// StatusChangeActions lists the audit actions that record a change of flag status
+var StatusChangeActions = []AuditAction{
+	ActionEnable,
+	ActionDisable,
+	ActionCascadeDisable,
+	ActionCascadeEnable,
+	ActionCascadeMaintenance,
+	ActionMaintenance,
+	ActionResume,
+	ActionExpire,
+}
+
+// IsValid returns true if the action is one of the known audit actions
+func (a AuditAction) IsValid() bool {
+	for _, known := range KnownAuditActions {
+		if a == known {
+			return true
+		}
+	}
+	return false
+}
+
 // AuditLog represents a record of an action taken on a flag
 type AuditLog struct {
-	ID        int64       `json:"id" db:"id"`
-	FlagID    int64       `json:"flag_id" db:"flag_id"`
-	Action    AuditAction `json:"action" db:"action"`
-	Actor     string      `json:"actor" db:"actor"`
-	Reason    string      `json:"reason" db:"reason"`
-	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+	ID          int64       `json:"id" db:"id"`
+	FlagID      int64       `json:"flag_id" db:"flag_id"`
+	Action      AuditAction `json:"action" db:"action"`
+	Actor       string      `json:"actor" db:"actor"`
+	Reason      string      `json:"reason" db:"reason"`
+	Environment string      `json:"environment,omitempty" db:"environment"` // empty for changes to the global status
+	RequestID   string      `json:"request_id,omitempty" db:"request_id"`   // shared by every entry written while serving one request
+	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
 }
 
 // NewAuditLog creates a new audit log entry
@@ -37,7 +95,32 @@ func NewAuditLog(flagID int64, action AuditAction, actor, reason string) *AuditL
 	}
 }
 
-// IsCascadeAction returns true if the action is a cascade disable
+// AuditFilter selects audit log entries; empty fields match everything
+type AuditFilter struct {
+	Action AuditAction
+	Actor  string
+	From   time.Time // inclusive; zero means unbounded
+	To     time.Time // exclusive; zero means unbounded
+}
+
+// Matches returns true if the audit log satisfies the filter
+func (f AuditFilter) Matches(log *AuditLog) bool {
+	if f.Action != "" && log.Action != f.Action {
+		return false
+	}
+	if f.Actor != "" && log.Actor != f.Actor {
+		return false
+	}
+	if !f.From.IsZero() && log.CreatedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !log.CreatedAt.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// IsCascadeAction returns true if the action was triggered by a cascade
 func (a *AuditLog) IsCascadeAction() bool {
-	return a.Action == ActionCascadeDisable
-} 
\ No newline at end of file
+	return a.Action == ActionCascadeDisable || a.Action == ActionCascadeMaintenance
+}
@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"time"
+)
+
+// AuditReportGroupBy selects the dimension an audit report is grouped by
+type AuditReportGroupBy string
+
+const (
+	AuditReportByActor  AuditReportGroupBy = "actor"
+	AuditReportByFlag   AuditReportGroupBy = "flag"
+	AuditReportByAction AuditReportGroupBy = "action"
+)
+
+// IsValid returns true if the grouping is supported
+func (g AuditReportGroupBy) IsValid() bool {
+	switch g {
+	case AuditReportByActor, AuditReportByFlag, AuditReportByAction:
+		return true
+	}
+	return false
+}
+
+// AuditReportGroup summarises the changes for one group key
+type AuditReportGroup struct {
+	Key     string              `json:"key"`
+	Total   int                 `json:"total"`
+	Actions map[AuditAction]int `json:"actions"`
+	Flags   []string            `json:"flags"`
+}
+
+// AuditReport summarises audit activity in a time window
+type AuditReport struct {
+	From    time.Time           `json:"from"`
+	To      time.Time           `json:"to"`
+	GroupBy AuditReportGroupBy  `json:"group_by"`
+	Groups  []*AuditReportGroup `json:"groups"`
+}
+
+// FlappyFlag is a flag together with how often its status changed in a time window
+type FlappyFlag struct {
+	ID          int64      `json:"id" db:"id"`
+	Name        string     `json:"name" db:"name"`
+	Status      FlagStatus `json:"status" db:"status"`
+	ToggleCount int        `json:"toggle_count" db:"toggle_count"`
+}
@@ -0,0 +1,39 @@
+package entity
+
+import (
+	"time"
+)
+
+// CascadeEvent records which flags one cascade disabled, so exactly those can be restored
+type CascadeEvent struct {
+	ID         int64      `json:"id" db:"id"`
+	RootFlagID int64      `json:"root_flag_id" db:"root_flag_id"`
+	FlagIDs    []int64    `json:"flag_ids"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	RestoredAt *time.Time `json:"restored_at,omitempty" db:"restored_at"`
+}
+
+// IsRestored returns true if the cascade has already been restored
+func (e *CascadeEvent) IsRestored() bool {
+	return e.RestoredAt != nil
+}
+
+// SkippedFlag is a flag left untouched by a bulk operation, with the reason why
+type SkippedFlag struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// SubtreeRestoreResult reports the outcome of restoring a flag's subtree. Restored lists the
+// root first if it was enabled, then its dependents in the order they were enabled.
+type SubtreeRestoreResult struct {
+	Restored []string      `json:"restored"`
+	Skipped  []SkippedFlag `json:"skipped"`
+}
+
+// CascadeRestoreResult reports the outcome of restoring a cascade
+type CascadeRestoreResult struct {
+	CascadeEventID int64         `json:"cascade_event_id"`
+	Restored       []string      `json:"restored"`
+	Skipped        []SkippedFlag `json:"skipped"`
+}
@@ -0,0 +1,9 @@
+package entity
+
+// DependencyDrift is an enabled flag with dependencies that are not enabled. The service never
+// produces this state itself; it comes from out-of-band database edits or partial failures.
+type DependencyDrift struct {
+	FlagID                  int64    `json:"flag_id"`
+	FlagName                string   `json:"flag_name"`
+	UnsatisfiedDependencies []string `json:"unsatisfied_dependencies"`
+}
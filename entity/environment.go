@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"time"
+)
+
+// GlobalEnvironment is the environment of the flag's own status. Requests that name no
+// environment act on it.
+const GlobalEnvironment = "global"
+
+// FlagEnvironmentStatus is a flag's status in one environment. Flags are disabled in every
+// environment they have not been changed in.
+type FlagEnvironmentStatus struct {
+	FlagID      int64      `json:"flag_id" db:"flag_id"`
+	Environment string     `json:"environment" db:"environment"`
+	Status      FlagStatus `json:"status" db:"status"`
+	Version     int64      `json:"version" db:"version"` // zero until the flag is first changed in the environment
+	UpdatedBy   string     `json:"updated_by,omitempty" db:"updated_by"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// IsEnabled returns true if the flag is enabled in the environment
+func (s *FlagEnvironmentStatus) IsEnabled() bool {
+	return s.Status == FlagEnabled
+}
+
+// StatusChange reports a transition from the current status to status
+func (s *FlagEnvironmentStatus) StatusChange(status FlagStatus) *StatusChange {
+	return &StatusChange{
+		Changed:        s.Status != status,
+		PreviousStatus: s.Status,
+		Status:         status,
+	}
+}
+
+// EnvironmentDiff lists the flags whose status differs between two environments
+type EnvironmentDiff struct {
+	From  string           `json:"from"`
+	To    string           `json:"to"`
+	Flags []*FlagStateDiff `json:"flags"`
+}
+
+// FlagStateDiff is a flag's status in the two environments of an EnvironmentDiff
+type FlagStateDiff struct {
+	FlagID     int64      `json:"flag_id" db:"flag_id"`
+	Name       string     `json:"name" db:"name"`
+	FromStatus FlagStatus `json:"from_status" db:"from_status"`
+	ToStatus   FlagStatus `json:"to_status" db:"to_status"`
+}
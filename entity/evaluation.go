@@ -0,0 +1,15 @@
+package entity
+
+// FlagEvaluation is the effective state of one flag as seen by a client
+type FlagEvaluation struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// EvaluationResult maps the flags of a batch request by name to whether they are enabled.
+// References that matched no flag are listed in Unknown.
+type EvaluationResult struct {
+	Key     string          `json:"key,omitempty"`
+	Flags   map[string]bool `json:"flags"`
+	Unknown []string        `json:"unknown,omitempty"`
+}
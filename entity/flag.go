@@ -7,18 +7,105 @@ import (
 type FlagStatus string
 
 const (
-	FlagEnabled  FlagStatus = "enabled"
-	FlagDisabled FlagStatus = "disabled"
+	FlagEnabled     FlagStatus = "enabled"
+	FlagDisabled    FlagStatus = "disabled"
+	FlagMaintenance FlagStatus = "maintenance"
+)
+
+// CascadeStrategy controls what happens to a flag when one of its dependencies is disabled
+type CascadeStrategy string
+
+const (
+	CascadeDisable     CascadeStrategy = "disable"
+	CascadeMaintenance CascadeStrategy = "maintenance"
+)
+
+// IsValid reports whether s is one of the known flag statuses
+func (s FlagStatus) IsValid() bool {
+	switch s {
+	case FlagEnabled, FlagDisabled, FlagMaintenance:
+		return true
+	}
+	return false
+}
+
+// DisablePolicy controls whether a flag may be disabled while dependents are still enabled
+type DisablePolicy string
+
+const (
+	DisablePolicyCascade DisablePolicy = "cascade"
+	DisablePolicyBlock   DisablePolicy = "block"
 )
 
 // Flag represents the main feature flag entity with business logic
 type Flag struct {
-	ID           int64       `json:"id" db:"id"`
-	Name         string      `json:"name" db:"name"`
-	Status       FlagStatus  `json:"status" db:"status"`
-	Dependencies []int64     `json:"dependencies,omitempty"`
-	CreatedAt    time.Time   `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time   `json:"updated_at" db:"updated_at"`
+	ID                int64             `json:"id" db:"id"`
+	Name              string            `json:"name" db:"name"`
+	Description       string            `json:"description" db:"description"`
+	Status            FlagStatus        `json:"status" db:"status"`
+	CascadeStrategy   CascadeStrategy   `json:"cascade_strategy" db:"cascade_strategy"`
+	DisablePolicy     DisablePolicy     `json:"disable_policy" db:"disable_policy"`
+	HighRisk          bool              `json:"high_risk" db:"high_risk"`
+	Locked            bool              `json:"locked" db:"locked"`
+	RolloutPercentage int               `json:"rollout_percentage" db:"rollout_percentage"` // share of users (0-100) an enabled flag is on for
+	Version           int64             `json:"version" db:"version"`                       // bumped by every status or lock change
+	ExpiresAt         *time.Time        `json:"expires_at,omitempty" db:"expires_at"`       // enabled flags are disabled once this passes
+	ArchivedAt        *time.Time        `json:"archived_at,omitempty" db:"archived_at"`     // archived flags stay disabled and are hidden from listings
+	Dependencies      []int64           `json:"dependencies,omitempty"`
+	Tags              map[string]string `json:"tags,omitempty" db:"-"`
+	CreatedBy         string            `json:"created_by,omitempty" db:"created_by"` // empty for flags created before actors were recorded
+	UpdatedBy         string            `json:"updated_by,omitempty" db:"updated_by"` // actor of the latest status, lock or settings change
+	CreatedAt         time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at" db:"updated_at"`
+	LastEvaluatedAt   *time.Time        `json:"last_evaluated_at,omitempty" db:"last_evaluated_at"`
+
+	// DependenciesDetail is only filled on request (?expand=dependencies)
+	DependenciesDetail []GraphNode `json:"dependencies_detail,omitempty" db:"-"`
+}
+
+// FullRollout is the rollout percentage of a flag that is on for every user
+const FullRollout = 100
+
+// Tag is a single key/value label on a flag
+type Tag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// FlagFilter selects flags when listing; empty fields match everything except archived flags
+type FlagFilter struct {
+	Status FlagStatus
+	Tags   []Tag // a flag must carry every one of these tags
+	// ExpiringBefore matches flags with an expiry earlier than this time
+	ExpiringBefore *time.Time
+	// IncludeArchived lists archived flags alongside the others
+	IncludeArchived bool
+}
+
+// StatusChange describes the outcome of an enable or disable request. Changed is false when
+// the flag was already in the requested status and nothing was written.
+type StatusChange struct {
+	Changed        bool       `json:"changed"`
+	PreviousStatus FlagStatus `json:"previous_status"`
+	Status         FlagStatus `json:"status"`
+	// CascadeEnabled lists the dependencies a cascading enable turned on first
+	CascadeEnabled []GraphNode `json:"cascade_enabled,omitempty"`
+}
+
+// BulkToggleItem is the outcome of one flag of a bulk toggle
+type BulkToggleItem struct {
+	FlagID int64  `json:"flag_id"`
+	Name   string `json:"name"`
+	*StatusChange
+}
+
+// NewStatusChange reports a transition of flag from its current status to status
+func NewStatusChange(flag *Flag, status FlagStatus) *StatusChange {
+	return &StatusChange{
+		Changed:        flag.Status != status,
+		PreviousStatus: flag.Status,
+		Status:         status,
+	}
 }
 
 // IsEnabled returns true if the flag is enabled
@@ -31,6 +118,22 @@ func (f *Flag) IsDisabled() bool {
 	return f.Status == FlagDisabled
 }
 
+// IsInMaintenance returns true if the flag is temporarily down for maintenance
+func (f *Flag) IsInMaintenance() bool {
+	return f.Status == FlagMaintenance
+}
+
+// IsArchived returns true if the flag has been archived
+func (f *Flag) IsArchived() bool {
+	return f.ArchivedAt != nil
+}
+
+// SatisfiesDependents returns true if flags depending on this one may be enabled.
+// A flag in maintenance counts as unavailable, exactly like a disabled one.
+func (f *Flag) SatisfiesDependents() bool {
+	return f.IsEnabled()
+}
+
 // Enable sets the flag status to enabled
 func (f *Flag) Enable() {
 	f.Status = FlagEnabled
@@ -43,6 +146,25 @@ func (f *Flag) Disable() {
 	f.UpdatedAt = time.Now()
 }
 
+// EnterMaintenance sets the flag status to maintenance
+func (f *Flag) EnterMaintenance() {
+	f.Status = FlagMaintenance
+	f.UpdatedAt = time.Now()
+}
+
+// CascadeStatus returns the status the flag is moved to when a dependency is disabled
+func (f *Flag) CascadeStatus() FlagStatus {
+	if f.CascadeStrategy == CascadeMaintenance {
+		return FlagMaintenance
+	}
+	return FlagDisabled
+}
+
+// BlocksDisable returns true if the flag must not be disabled while any dependent is enabled
+func (f *Flag) BlocksDisable() bool {
+	return f.DisablePolicy == DisablePolicyBlock
+}
+
 // HasDependencies returns true if the flag has dependencies
 func (f *Flag) HasDependencies() bool {
 	return len(f.Dependencies) > 0
@@ -66,4 +188,4 @@ func (f *Flag) RemoveDependency(dependencyID int64) {
 			return
 		}
 	}
-} 
\ No newline at end of file
+}
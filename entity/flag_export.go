@@ -0,0 +1,47 @@
+package entity
+
+// FlagExportVersion is the schema version of exported flag documents
+const FlagExportVersion = 1
+
+// FlagDefinition is the portable form of a flag. Dependencies are referenced by name so the
+// definition can be applied to another database.
+type FlagDefinition struct {
+	Name            string            `json:"name"`
+	Status          FlagStatus        `json:"status"`
+	CascadeStrategy CascadeStrategy   `json:"cascade_strategy"`
+	DisablePolicy   DisablePolicy     `json:"disable_policy"`
+	HighRisk        bool              `json:"high_risk"`
+	Description     string            `json:"description,omitempty"`
+	Tags            map[string]string `json:"tags,omitempty"`
+	Dependencies    []string          `json:"dependencies"`
+}
+
+// FlagExport is a standalone document of flag definitions
+type FlagExport struct {
+	Version int               `json:"version"`
+	Flags   []*FlagDefinition `json:"flags"`
+}
+
+// NewFlagDefinition creates the portable definition of a flag given its dependency names
+func NewFlagDefinition(flag *Flag, dependencyNames []string) *FlagDefinition {
+	if dependencyNames == nil {
+		dependencyNames = []string{}
+	}
+	return &FlagDefinition{
+		Name:            flag.Name,
+		Status:          flag.Status,
+		CascadeStrategy: flag.CascadeStrategy,
+		DisablePolicy:   flag.DisablePolicy,
+		HighRisk:        flag.HighRisk,
+		Description:     flag.Description,
+		Tags:            flag.Tags,
+		Dependencies:    dependencyNames,
+	}
+}
+
+// FlagImportResult reports, by name, what an import did with each flag of the document
+type FlagImportResult struct {
+	Created []string      `json:"created"`
+	Updated []string      `json:"updated"`
+	Skipped []SkippedFlag `json:"skipped"`
+}
@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+// FlagStats summarises the flags that are not archived
+type FlagStats struct {
+	Total            int `json:"total" db:"total"`
+	Enabled          int `json:"enabled" db:"enabled"`
+	Disabled         int `json:"disabled" db:"disabled"`
+	Maintenance      int `json:"maintenance" db:"maintenance"`
+	WithDependencies int `json:"with_dependencies" db:"with_dependencies"`
+	// MaxDependencyDepth is the length of the longest dependency chain; 0 without dependencies
+	MaxDependencyDepth int `json:"max_dependency_depth" db:"max_dependency_depth"`
+	// LeafFlags counts the flags no other flag depends on
+	LeafFlags   int       `json:"leaf_flags" db:"leaf_flags"`
+	GeneratedAt time.Time `json:"generated_at" db:"-"`
+}
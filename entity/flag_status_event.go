@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// FlagStatusEvent announces that a flag's status changed in one environment
+type FlagStatusEvent struct {
+	FlagID      int64      `json:"flag_id"`
+	Name        string     `json:"name"`
+	Status      FlagStatus `json:"status"`
+	Environment string     `json:"environment"`
+	Version     int64      `json:"version"`
+	ChangedBy   string     `json:"changed_by"`
+	ChangedAt   time.Time  `json:"changed_at"`
+}
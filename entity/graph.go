@@ -0,0 +1,72 @@
+package entity
+
+// DependencyEdge represents a single "flag depends on flag" relationship
+type DependencyEdge struct {
+	FlagID      int64 `json:"flag_id" db:"flag_id"`
+	DependsOnID int64 `json:"depends_on_id" db:"depends_on_id"`
+}
+
+// GraphNode is the lightweight representation of a flag inside a dependency graph
+type GraphNode struct {
+	ID     int64      `json:"id"`
+	Name   string     `json:"name"`
+	Status FlagStatus `json:"status"`
+}
+
+// DependencyGraph represents flags and their dependency edges
+type DependencyGraph struct {
+	Nodes      []GraphNode      `json:"nodes"`
+	Edges      []DependencyEdge `json:"edges"`
+	Truncated  bool             `json:"truncated"`
+	TotalNodes int              `json:"total_nodes"`
+	// Cycles lists dependency cycles found among Edges, each as flag IDs in dependency order.
+	// Validation prevents new cycles, so these come from data written around it.
+	Cycles [][]int64 `json:"cycles,omitempty"`
+}
+
+// EnablePlan lists a flag's transitive dependencies so that enabling them front to back, and
+// then the flag itself, never fails a dependency check. Steps that are already enabled can be
+// skipped.
+type EnablePlan struct {
+	FlagID int64       `json:"flag_id"`
+	Steps  []GraphNode `json:"steps"`
+}
+
+// ClosureStats describes the flags reachable from a flag in one direction
+type ClosureStats struct {
+	Count     int  `json:"count"`
+	MaxDepth  int  `json:"max_depth"`
+	Truncated bool `json:"truncated"` // the walk stopped at the node limit, so the counts are lower bounds
+}
+
+// ClosureSize reports how strongly a flag is coupled to the rest of the dependency graph
+type ClosureSize struct {
+	FlagID       int64        `json:"flag_id"`
+	Dependencies ClosureStats `json:"dependencies"` // everything the flag transitively depends on
+	Dependents   ClosureStats `json:"dependents"`   // everything that transitively depends on the flag
+}
+
+// NewGraphNode creates a graph node from a flag
+func NewGraphNode(flag *Flag) GraphNode {
+	return GraphNode{
+		ID:     flag.ID,
+		Name:   flag.Name,
+		Status: flag.Status,
+	}
+}
+
+// FlagDetailInclude selects the sections of a FlagDetail to load
+type FlagDetailInclude struct {
+	Dependencies bool
+	Dependents   bool
+	Audit        bool
+}
+
+// FlagDetail aggregates what a flag page shows. Sections that were not requested, or are
+// empty, are omitted.
+type FlagDetail struct {
+	Flag         *Flag       `json:"flag"`
+	Dependencies []GraphNode `json:"dependencies,omitempty"`
+	Dependents   []GraphNode `json:"dependents,omitempty"`
+	Audit        []*AuditLog `json:"audit,omitempty"`
+}
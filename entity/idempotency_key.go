@@ -0,0 +1,16 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// IdempotencyKey records the outcome of a request sent with an Idempotency-Key header, so a
+// retry of the same request is answered with it instead of being applied again
+type IdempotencyKey struct {
+	Key         string          `db:"key"`
+	FlagID      int64           `db:"flag_id"`
+	RequestHash string          `db:"request_hash"` // tells a retry apart from another request reusing the key
+	Response    json.RawMessage `db:"response"`
+	CreatedAt   time.Time       `db:"created_at"`
+}
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"time"
+)
+
+// PendingCascadeStatus represents the lifecycle state of a deferred cascade
+type PendingCascadeStatus string
+
+const (
+	PendingCascadePending   PendingCascadeStatus = "pending"
+	PendingCascadeCompleted PendingCascadeStatus = "completed"
+	PendingCascadeCancelled PendingCascadeStatus = "cancelled"
+)
+
+// PendingCascade is a cascade-disable of a flag's dependents scheduled for after a grace period
+type PendingCascade struct {
+	ID         int64                `json:"id" db:"id"`
+	FlagID     int64                `json:"flag_id" db:"flag_id"`
+	Status     PendingCascadeStatus `json:"status" db:"status"`
+	DueAt      time.Time            `json:"due_at" db:"due_at"`
+	CreatedAt  time.Time            `json:"created_at" db:"created_at"`
+	ResolvedAt *time.Time           `json:"resolved_at,omitempty" db:"resolved_at"`
+}
+
+// NewPendingCascade schedules a cascade for the flag once the grace period after now has passed
+func NewPendingCascade(flagID int64, grace time.Duration, now time.Time) *PendingCascade {
+	return &PendingCascade{
+		FlagID:    flagID,
+		Status:    PendingCascadePending,
+		DueAt:     now.Add(grace),
+		CreatedAt: now,
+	}
+}
@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"time"
+)
+
+// PendingEnableStatus represents the lifecycle state of an enable-when-ready intent
+type PendingEnableStatus string
+
+const (
+	PendingEnablePending   PendingEnableStatus = "pending"
+	PendingEnableCompleted PendingEnableStatus = "completed"
+	PendingEnableCancelled PendingEnableStatus = "cancelled"
+)
+
+// PendingEnable represents a request to enable a flag as soon as its dependencies are satisfied
+type PendingEnable struct {
+	ID            int64               `json:"id" db:"id"`
+	FlagID        int64               `json:"flag_id" db:"flag_id"`
+	Actor         string              `json:"actor" db:"actor"`
+	Reason        string              `json:"reason" db:"reason"`
+	Status        PendingEnableStatus `json:"status" db:"status"`
+	Attempts      int                 `json:"attempts" db:"attempts"`
+	LastAttemptAt *time.Time          `json:"last_attempt_at,omitempty" db:"last_attempt_at"`
+	CreatedAt     time.Time           `json:"created_at" db:"created_at"`
+	ResolvedAt    *time.Time          `json:"resolved_at,omitempty" db:"resolved_at"`
+}
+
+// NewPendingEnable creates a new pending enable intent
+func NewPendingEnable(flagID int64, actor, reason string) *PendingEnable {
+	return &PendingEnable{
+		FlagID:    flagID,
+		Actor:     actor,
+		Reason:    reason,
+		Status:    PendingEnablePending,
+		CreatedAt: time.Now(),
+	}
+}
+
+// IsPending returns true if the intent is still waiting for its dependencies
+func (p *PendingEnable) IsPending() bool {
+	return p.Status == PendingEnablePending
+}
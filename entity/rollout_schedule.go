@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"time"
+)
+
+// RolloutScheduleStatus represents the lifecycle state of a rollout schedule
+type RolloutScheduleStatus string
+
+const (
+	RolloutScheduleActive    RolloutScheduleStatus = "active"
+	RolloutSchedulePaused    RolloutScheduleStatus = "paused"
+	RolloutScheduleCompleted RolloutScheduleStatus = "completed"
+	RolloutScheduleAborted   RolloutScheduleStatus = "aborted"
+)
+
+// RolloutSchedule raises a flag's rollout percentage by StepPercentage every step interval,
+// starting at StartPercentage, until the flag is on for every user
+type RolloutSchedule struct {
+	FlagID              int64                 `json:"flag_id" db:"flag_id"`
+	StartPercentage     int                   `json:"start_percentage" db:"start_percentage"`
+	StepPercentage      int                   `json:"step_percentage" db:"step_percentage"`
+	StepIntervalMinutes int                   `json:"step_interval_minutes" db:"step_interval_minutes"`
+	Status              RolloutScheduleStatus `json:"status" db:"status"`
+	NextStepAt          *time.Time            `json:"next_step_at,omitempty" db:"next_step_at"` // set while active
+	Actor               string                `json:"actor" db:"actor"`
+	CreatedAt           time.Time             `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time             `json:"updated_at" db:"updated_at"`
+}
+
+// NewRolloutSchedule starts a schedule for the flag whose first step is due one interval after now
+func NewRolloutSchedule(flagID int64, start, step, intervalMinutes int, actor string, now time.Time) *RolloutSchedule {
+	schedule := &RolloutSchedule{
+		FlagID:              flagID,
+		StartPercentage:     start,
+		StepPercentage:      step,
+		StepIntervalMinutes: intervalMinutes,
+		Actor:               actor,
+		CreatedAt:           now,
+	}
+	schedule.Resume(now)
+	return schedule
+}
+
+// StepInterval returns the time between two steps
+func (s *RolloutSchedule) StepInterval() time.Duration {
+	return time.Duration(s.StepIntervalMinutes) * time.Minute
+}
+
+// IsFinished returns true once the schedule completed or was aborted
+func (s *RolloutSchedule) IsFinished() bool {
+	return s.Status == RolloutScheduleCompleted || s.Status == RolloutScheduleAborted
+}
+
+// NextPercentage returns the rollout percentage that follows current
+func (s *RolloutSchedule) NextPercentage(current int) int {
+	return min(current+s.StepPercentage, FullRollout)
+}
+
+// Resume makes the schedule active with its next step one interval after now
+func (s *RolloutSchedule) Resume(now time.Time) {
+	next := now.Add(s.StepInterval())
+	s.Status = RolloutScheduleActive
+	s.NextStepAt = &next
+	s.UpdatedAt = now
+}
+
+// Stop moves the schedule to status, which leaves it without a next step
+func (s *RolloutSchedule) Stop(status RolloutScheduleStatus, now time.Time) {
+	s.Status = status
+	s.NextStepAt = nil
+	s.UpdatedAt = now
+}
@@ -0,0 +1,41 @@
+package entity
+
+import (
+	"time"
+)
+
+// ScheduledChangeStatus represents the lifecycle state of a scheduled status change
+type ScheduledChangeStatus string
+
+const (
+	ScheduledChangePending ScheduledChangeStatus = "pending"
+	ScheduledChangeApplied ScheduledChangeStatus = "applied"
+	ScheduledChangeFailed  ScheduledChangeStatus = "failed"
+)
+
+// ScheduledChange is an enable or disable of a flag that is applied once ScheduledAt has passed
+type ScheduledChange struct {
+	ID            int64                 `json:"id" db:"id"`
+	FlagID        int64                 `json:"flag_id" db:"flag_id"`
+	TargetStatus  FlagStatus            `json:"target_status" db:"target_status"`
+	ScheduledAt   time.Time             `json:"scheduled_at" db:"scheduled_at"`
+	Actor         string                `json:"actor" db:"actor"`
+	Reason        string                `json:"reason" db:"reason"`
+	Status        ScheduledChangeStatus `json:"status" db:"status"`
+	FailureReason string                `json:"failure_reason,omitempty" db:"failure_reason"`
+	CreatedAt     time.Time             `json:"created_at" db:"created_at"`
+	ResolvedAt    *time.Time            `json:"resolved_at,omitempty" db:"resolved_at"`
+}
+
+// NewScheduledChange schedules the flag to move to target at the given time
+func NewScheduledChange(flagID int64, target FlagStatus, at time.Time, actor, reason string) *ScheduledChange {
+	return &ScheduledChange{
+		FlagID:       flagID,
+		TargetStatus: target,
+		ScheduledAt:  at,
+		Actor:        actor,
+		Reason:       reason,
+		Status:       ScheduledChangePending,
+		CreatedAt:    time.Now(),
+	}
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+
+	"featureflags/controller"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequireAdmin only lets the given actors through, answering everyone else with 403. It must
+// run after AuthMiddleware and DelegationMiddleware, so a service account acting on behalf of
+// a user is not an admin. With no admins configured, nobody can call the route.
+func RequireAdmin(admins []string) echo.MiddlewareFunc {
+	allowed := make(map[string]bool, len(admins))
+	for _, admin := range admins {
+		allowed[admin] = true
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			actor, _ := c.Get(controller.ActorContextKey).(string)
+			if !allowed[actor] {
+				return c.JSON(http.StatusForbidden,
+					controller.NewAPIError(controller.CodeActorNotAllowed, "Only admins can perform this operation"))
+			}
+			return next(c)
+		}
+	}
+}
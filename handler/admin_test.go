@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"featureflags/controller"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	serve := func(admins []string, actor string) *httptest.ResponseRecorder {
+		e := echo.New()
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				c.Set(controller.ActorContextKey, actor)
+				return next(c)
+			}
+		})
+		e.POST("/admin", func(c echo.Context) error {
+			return c.NoContent(http.StatusNoContent)
+		}, RequireAdmin(admins))
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin", nil))
+		return rec
+	}
+
+	t.Run("admin is let through", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve([]string{"alice"}, "alice").Code)
+	})
+
+	t.Run("other actors are rejected", func(t *testing.T) {
+		rec := serve([]string{"alice"}, "mallory")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), controller.CodeActorNotAllowed)
+	})
+
+	t.Run("delegated actor is not the admin", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve([]string{"deploy-bot"}, "deploy-bot (on behalf of alice)").Code)
+	})
+
+	t.Run("no admins rejects everyone", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve(nil, "alice").Code)
+	})
+}
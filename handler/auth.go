@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"featureflags/controller"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AuthMiddleware authenticates requests by their "Authorization: Bearer <token>" header
+// against tokens, a map of static tokens to actors, and records the actor for the
+// controller. Requests without a known token are rejected with 401.
+func AuthMiddleware(tokens map[string]string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			actor, ok := authenticate(tokens, c.Request().Header.Get(echo.HeaderAuthorization))
+			if !ok {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+				return c.JSON(http.StatusUnauthorized,
+					controller.NewAPIError(controller.CodeUnauthorized, "Missing or invalid bearer token"))
+			}
+
+			c.Set(controller.ActorContextKey, actor)
+			return next(c)
+		}
+	}
+}
+
+// authenticate returns the actor of the bearer token in header. Every configured token is
+// compared in constant time so response timing does not reveal how much of a token matched.
+func authenticate(tokens map[string]string, header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", false
+	}
+
+	var actor string
+	for candidate, candidateActor := range tokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			actor = candidateActor
+		}
+	}
+	return actor, actor != ""
+}
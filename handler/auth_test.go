@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"featureflags/controller"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	e := echo.New()
+	e.Use(AuthMiddleware(map[string]string{"alice-token": "alice"}))
+	e.GET("/flags", func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Get(controller.ActorContextKey).(string))
+	})
+
+	serve := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/flags", nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("valid token sets the actor", func(t *testing.T) {
+		rec := serve(map[string]string{"Authorization": "Bearer alice-token"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "alice", rec.Body.String())
+	})
+
+	t.Run("missing token is rejected", func(t *testing.T) {
+		rec := serve(nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "Bearer", rec.Header().Get(echo.HeaderWWWAuthenticate))
+		assert.Contains(t, rec.Body.String(), controller.CodeUnauthorized)
+	})
+
+	t.Run("unknown token is rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serve(map[string]string{"Authorization": "Bearer bob-token"}).Code)
+		assert.Equal(t, http.StatusUnauthorized, serve(map[string]string{"Authorization": "Basic alice-token"}).Code)
+	})
+
+	t.Run("X-Actor header is not trusted", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serve(map[string]string{"X-Actor": "admin_user"}).Code)
+
+		rec := serve(map[string]string{"Authorization": "Bearer alice-token", "X-Actor": "system"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "alice", rec.Body.String())
+	})
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"mime"
+	"net/http"
+
+	"featureflags/controller"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequireJSONContentType rejects POST, PUT and PATCH requests whose Content-Type is not
+// application/json with 415. Without it, binding a form or text body silently yields a
+// zero-valued request (e.g. Enable: false). Requests without a body, such as a disable
+// preview, have nothing to bind and are let through.
+func RequireJSONContentType() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			switch req.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch:
+			default:
+				return next(c)
+			}
+			if req.ContentLength == 0 && len(req.TransferEncoding) == 0 {
+				return next(c)
+			}
+
+			mediaType, _, err := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+			if err != nil || mediaType != echo.MIMEApplicationJSON {
+				return c.JSON(http.StatusUnsupportedMediaType,
+					controller.NewAPIError(controller.CodeUnsupportedMediaType, "Content-Type must be application/json"))
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireJSONContentType(t *testing.T) {
+	e := echo.New()
+	e.Use(RequireJSONContentType())
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }
+	e.POST("/flags", ok)
+	e.GET("/flags", ok)
+
+	serve := func(method, contentType string) int {
+		req := httptest.NewRequest(method, "/flags", strings.NewReader(`enable=true`))
+		if contentType != "" {
+			req.Header.Set(echo.HeaderContentType, contentType)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	t.Run("form body is rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnsupportedMediaType, serve(http.MethodPost, echo.MIMEApplicationForm))
+	})
+
+	t.Run("missing content type is rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnsupportedMediaType, serve(http.MethodPost, ""))
+	})
+
+	t.Run("empty body is not checked", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/flags", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+
+	t.Run("json with charset is accepted", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodPost, echo.MIMEApplicationJSONCharsetUTF8))
+	})
+
+	t.Run("reads are not checked", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodGet, ""))
+	})
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"featureflags/controller"
+	"featureflags/validator"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DelegationMiddleware honours the X-On-Behalf-Of header for the given service accounts,
+// recording the actor as "<account> (on behalf of <user>)". It must run after
+// AuthMiddleware, whose actor is the account. Anyone else sending the header, or naming the
+// system actor in it, is rejected with 403.
+func DelegationMiddleware(serviceAccounts []string) echo.MiddlewareFunc {
+	allowed := make(map[string]bool, len(serviceAccounts))
+	for _, account := range serviceAccounts {
+		allowed[account] = true
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			onBehalfOf := c.Request().Header.Get("X-On-Behalf-Of")
+			if onBehalfOf == "" {
+				return next(c)
+			}
+
+			account, _ := c.Get(controller.ActorContextKey).(string)
+			if !allowed[account] || onBehalfOf == validator.SystemActor {
+				return c.JSON(http.StatusForbidden,
+					controller.NewAPIError(controller.CodeActorNotAllowed, "Actor is not allowed to act on behalf of other users"))
+			}
+
+			actor := fmt.Sprintf("%s (on behalf of %s)", account, onBehalfOf)
+			if len(actor) > validator.MaxActorLength {
+				return c.JSON(http.StatusBadRequest,
+					controller.NewAPIError(controller.CodeInvalidRequest, "X-On-Behalf-Of is too long"))
+			}
+
+			c.Set(controller.ActorContextKey, actor)
+			return next(c)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"expvar"
+
 	"featureflags/config"
 	"featureflags/controller"
 	_ "featureflags/docs" // Import for swagger docs
@@ -8,17 +10,22 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
-	echoSwagger "github.com/swaggo/echo-swagger"
 )
 
-func RegisterRoutes(e *echo.Echo, fc *controller.FlagController, cfg *config.Config, log *logger.Logger) {
+// RegisterRoutes registers every route on e. Until ready is marked ready, /readyz and the API
+// answer 503; pass nil when startup has already finished.
+func RegisterRoutes(e *echo.Echo, fc *controller.FlagController, db Pinger, cfg *config.Config, log *logger.Logger, ready *Readiness) {
+	e.JSONSerializer = NewJSONSerializer(cfg.HTTPServer.PrettyJSON)
+
 	// Add middleware
+	e.Use(RequestIDMiddleware())
 	e.Use(middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
 		LogURI:    true,
 		LogStatus: true,
 		LogMethod: true,
 		LogError:  true,
 		LogValuesFunc: func(c echo.Context, values middleware.RequestLoggerValues) error {
+			log := log.WithContext(c.Request().Context())
 			if values.Error != nil {
 				log.Errorw("Request failed",
 					"method", values.Method,
@@ -40,27 +47,89 @@ func RegisterRoutes(e *echo.Echo, fc *controller.FlagController, cfg *config.Con
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
 
-	// Health check endpoint
-	e.GET("/health", func(c echo.Context) error {
-		return c.JSON(200, map[string]string{
-			"status": "healthy",
-			"service": "featureflags",
-		})
-	})
+	writeMode := NewWriteMode(cfg.HTTPServer.ReadOnlyProbeInterval)
 
-	// Swagger documentation (if enabled)
-	if cfg.Swagger.Enabled {
-		log.Infow("Swagger documentation enabled", "path", "/swagger/*")
-		e.GET("/swagger/*", echoSwagger.WrapHandler)
+	// Health check endpoints
+	registerHealthRoutes(e, db, writeMode, ready, cfg.HTTPServer.HealthCheckTimeout)
+
+	// Runtime and service metrics (if enabled)
+	if cfg.HTTPServer.DebugVars {
+		e.GET("/debug/vars", echo.WrapHandler(expvar.Handler()))
 	}
 
+	// Swagger documentation (if enabled)
+	RegisterSwagger(e, cfg.Swagger, log)
+
 	// API routes
 	api := e.Group("/api/v1")
-	
+	api.Use(ReadinessMiddleware(ready))
+	api.Use(AuthMiddleware(cfg.Auth.Tokens))
+	api.Use(ReadOnlyMiddleware(writeMode, "/api/v1/flags/evaluate", "/api/v1/flags/:id/disable/preview"))
+	api.Use(RequireJSONContentType())
+	api.Use(DelegationMiddleware(cfg.Delegation.ServiceAccounts))
+
+	// Creates and toggles are rate limited per actor
+	var writeLimit []echo.MiddlewareFunc
+	if cfg.RateLimit.WritesPerMinute > 0 {
+		writeLimit = append(writeLimit, RateLimitMiddleware(NewTokenBucketLimiter(cfg.RateLimit.WritesPerMinute)))
+	}
+
 	// Flag routes
-	api.POST("/flags", fc.CreateFlag)
-	api.POST("/flags/:id/toggle", fc.ToggleFlag)
+	api.POST("/flags", fc.CreateFlag, writeLimit...)
+	api.POST("/flags/bulk", fc.BulkCreateFlags, writeLimit...)
+	api.POST("/flags/:id/toggle", fc.ToggleFlag, writeLimit...)
+	api.POST("/flags/toggle-bulk", fc.BulkToggleFlags, writeLimit...)
+	api.POST("/flags/import", fc.ImportFlags)
+	api.POST("/flags/sync-from", fc.SyncFlags, RequireAdmin(cfg.Admin.Actors))
+	api.POST("/flags/:id/disable/preview", fc.PreviewDisable)
 	api.GET("/flags", fc.ListFlags)
+	api.GET("/flags/graph", fc.GetDependencyGraph)
+	api.GET("/flags/flappy", fc.ListFlappyFlags)
+	api.GET("/flags/unused", fc.ListUnusedFlags)
+	api.GET("/flags/stats", fc.GetFlagStats)
+	api.GET("/flags/stream", fc.StreamFlagChanges)
+	api.GET("/flags/export", fc.ExportFlags)
+	api.GET("/flags/by-name/:name", fc.GetFlagByName)
+	api.POST("/flags/evaluate", fc.EvaluateFlags)
 	api.GET("/flags/:id", fc.GetFlag)
+	api.PUT("/flags/:id", fc.UpdateFlag)
+	api.DELETE("/flags/:id", fc.DeleteFlag)
+	api.PATCH("/flags/:id/name", fc.RenameFlag)
+	api.POST("/flags/:id/dependencies", fc.AddDependency)
+	api.DELETE("/flags/:id/dependencies/:depId", fc.RemoveDependency)
 	api.GET("/flags/:id/audit", fc.GetFlagAudit)
-} 
\ No newline at end of file
+	api.GET("/flags/:id/detail", fc.GetFlagDetail)
+	api.GET("/flags/:id/dependents", fc.GetFlagDependents)
+	api.GET("/flags/:id/enabled", fc.GetFlagEnabled)
+	api.GET("/flags/:id/evaluate", fc.EvaluateFlag)
+	api.GET("/flags/:id/export", fc.ExportFlag)
+	api.GET("/flags/:id/closure-size", fc.GetClosureSize)
+	api.GET("/flags/:id/enable-plan", fc.GetEnablePlan)
+	api.GET("/flags/:id/environments", fc.ListFlagEnvironments)
+	api.GET("/flags/:id/states", fc.GetFlagStates)
+	api.POST("/flags/:id/maintenance", fc.SetMaintenance)
+	api.POST("/flags/:id/resume", fc.ResumeFlag)
+	api.POST("/flags/:id/lock", fc.LockFlag)
+	api.POST("/flags/:id/unlock", fc.UnlockFlag)
+	api.POST("/flags/:id/archive", fc.ArchiveFlag)
+	api.POST("/flags/:id/restore", fc.RestoreFlag)
+	api.POST("/flags/:id/enable-when-ready", fc.EnableWhenReady)
+	api.DELETE("/flags/:id/enable-when-ready/:pendingId", fc.CancelPendingEnable)
+	api.POST("/flags/:id/schedule", fc.ScheduleFlagChange)
+	api.POST("/flags/:id/rollout/schedule", fc.ScheduleRollout)
+	api.GET("/flags/:id/rollout/schedule", fc.GetRolloutSchedule)
+	api.POST("/flags/:id/rollout/schedule/pause", fc.PauseRollout)
+	api.POST("/flags/:id/rollout/schedule/resume", fc.ResumeRollout)
+	api.POST("/flags/:id/rollout/schedule/abort", fc.AbortRollout)
+	api.POST("/flags/:id/rollout/rollback", fc.RollbackRollout)
+	api.POST("/flags/:id/satisfy-dependencies", fc.SatisfyDependencies)
+	api.POST("/flags/:id/restore-cascade", fc.RestoreCascade)
+	api.POST("/flags/:id/restore-subtree", fc.RestoreSubtree)
+	api.GET("/environments", fc.ListEnvironments)
+	api.GET("/environments/diff", fc.DiffEnvironments)
+	api.POST("/environments", fc.CreateEnvironment, RequireAdmin(cfg.Admin.Actors))
+	api.DELETE("/environments/:name", fc.DeleteEnvironment, RequireAdmin(cfg.Admin.Actors))
+	api.GET("/audit", fc.ListAuditLogs)
+	api.GET("/audit/stream", fc.StreamAuditLogs)
+	api.GET("/audit/report", fc.GetAuditReport)
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultHealthCheckTimeout bounds the database ping when no timeout is configured
+const DefaultHealthCheckTimeout = 2 * time.Second
+
+// Pinger checks that the database is reachable; *sqlx.DB satisfies it
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// registerHealthRoutes serves the health endpoints:
+//   - /livez reports that the process is up, without touching the database
+//   - /readyz reports whether migrations have finished and the database is reachable, for
+//     readiness probes
+//   - /health reports both, together with the write mode
+func registerHealthRoutes(e *echo.Echo, db Pinger, writeMode *WriteMode, ready *Readiness, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultHealthCheckTimeout
+	}
+	ping := func(c echo.Context) error {
+		ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+		defer cancel()
+		return db.PingContext(ctx)
+	}
+
+	e.GET("/livez", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	e.GET("/readyz", func(c echo.Context) error {
+		if !ready.Ready() {
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{
+				"status":     "starting",
+				"migrations": "pending",
+			})
+		}
+		if err := ping(c); err != nil {
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{
+				"status":     "unavailable",
+				"database":   "down",
+				"migrations": "complete",
+			})
+		}
+		return c.JSON(http.StatusOK, map[string]string{
+			"status":     "ready",
+			"database":   "up",
+			"migrations": "complete",
+		})
+	})
+
+	e.GET("/health", func(c echo.Context) error {
+		if err := ping(c); err != nil {
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{
+				"status":   "unhealthy",
+				"service":  "featureflags",
+				"database": "down",
+			})
+		}
+		if readOnly, since := writeMode.ReadOnly(); readOnly {
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"status":          "degraded",
+				"service":         "featureflags",
+				"database":        "up",
+				"mode":            "read_only",
+				"read_only_since": since,
+			})
+		}
+		return c.JSON(http.StatusOK, map[string]string{
+			"status":   "healthy",
+			"service":  "featureflags",
+			"database": "up",
+			"mode":     "read_write",
+		})
+	})
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePinger struct {
+	err error
+}
+
+func (p *fakePinger) PingContext(ctx context.Context) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("ping without deadline")
+	}
+	return p.err
+}
+
+func TestHealthRoutes(t *testing.T) {
+	db := &fakePinger{}
+	mode := NewWriteMode(time.Minute)
+	e := echo.New()
+	ready := NewReadiness()
+	registerHealthRoutes(e, db, mode, ready, 0)
+
+	serve := func(path string) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return rec.Code, body
+	}
+
+	t.Run("migrations pending", func(t *testing.T) {
+		code, body := serve("/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "starting", body["status"])
+		assert.Equal(t, "pending", body["migrations"])
+
+		code, _ = serve("/livez")
+		assert.Equal(t, http.StatusOK, code)
+		ready.MarkReady()
+	})
+
+	t.Run("database up", func(t *testing.T) {
+		code, body := serve("/health")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "healthy", body["status"])
+		assert.Equal(t, "up", body["database"])
+
+		code, body = serve("/readyz")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ready", body["status"])
+	})
+
+	t.Run("read-only mode is degraded", func(t *testing.T) {
+		mode.MarkUnavailable()
+		defer mode.MarkAvailable()
+
+		code, body := serve("/health")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "degraded", body["status"])
+		assert.Equal(t, "read_only", body["mode"])
+	})
+
+	t.Run("database down", func(t *testing.T) {
+		db.err = errors.New("connection refused")
+		defer func() { db.err = nil }()
+
+		code, body := serve("/health")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "unhealthy", body["status"])
+		assert.Equal(t, "down", body["database"])
+
+		code, body = serve("/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "down", body["database"])
+
+		code, body = serve("/livez")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ok", body["status"])
+	})
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+// prettyJSONIndent is the indentation used when pretty-printing responses
+const prettyJSONIndent = "  "
+
+// JSONSerializer wraps Echo's default serializer and optionally indents every response
+type JSONSerializer struct {
+	echo.DefaultJSONSerializer
+	Pretty bool
+}
+
+// NewJSONSerializer creates a JSON serializer; pretty enables indented output for all responses
+func NewJSONSerializer(pretty bool) *JSONSerializer {
+	return &JSONSerializer{Pretty: pretty}
+}
+
+// Serialize writes i as JSON, indenting it when pretty output is enabled
+func (s *JSONSerializer) Serialize(c echo.Context, i interface{}, indent string) error {
+	if s.Pretty && indent == "" {
+		indent = prettyJSONIndent
+	}
+	return s.DefaultJSONSerializer.Serialize(c, i, indent)
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSerializer(t *testing.T) {
+	render := func(t *testing.T, pretty bool) string {
+		e := echo.New()
+		e.JSONSerializer = NewJSONSerializer(pretty)
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+		require.NoError(t, c.JSON(http.StatusOK, map[string]string{"status": "healthy"}))
+		return rec.Body.String()
+	}
+
+	t.Run("development output is indented", func(t *testing.T) {
+		assert.Equal(t, "{\n  \"status\": \"healthy\"\n}\n", render(t, true))
+	})
+
+	t.Run("production output is compact", func(t *testing.T) {
+		assert.Equal(t, "{\"status\":\"healthy\"}\n", render(t, false))
+	})
+}
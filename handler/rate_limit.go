@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"featureflags/controller"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RateLimiter decides whether a request counted against key may proceed. When it may not,
+// it also reports how long until it would.
+type RateLimiter interface {
+	Allow(key string) (bool, time.Duration)
+}
+
+// TokenBucketLimiter is an in-memory RateLimiter giving each key a bucket of perMinute
+// tokens that refills continuously. Limits are per process.
+type TokenBucketLimiter struct {
+	mu       sync.Mutex
+	capacity float64
+	rate     float64 // tokens per second
+	buckets  map[string]*tokenBucket
+	now      func() time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucketLimiter creates a limiter allowing perMinute requests per key, in bursts of
+// up to perMinute
+func NewTokenBucketLimiter(perMinute int) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		capacity: float64(perMinute),
+		rate:     float64(perMinute) / 60,
+		buckets:  make(map[string]*tokenBucket),
+		now:      time.Now,
+	}
+}
+
+// Allow takes a token from key's bucket if one is available
+func (l *TokenBucketLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.capacity, last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(l.capacity, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// RateLimitMiddleware limits requests per authenticated actor, rejecting those over the
+// limit with 429 and a Retry-After header in whole seconds. It must run after
+// AuthMiddleware.
+func RateLimitMiddleware(limiter RateLimiter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			actor, _ := c.Get(controller.ActorContextKey).(string)
+			allowed, wait := limiter.Allow(actor)
+			if !allowed {
+				retryAfter := int(math.Ceil(wait.Seconds()))
+				if retryAfter < 1 {
+					retryAfter = 1
+				}
+				c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(retryAfter))
+				return c.JSON(http.StatusTooManyRequests,
+					controller.NewAPIError(controller.CodeRateLimited, "Too many changes; retry later"))
+			}
+			return next(c)
+		}
+	}
+}
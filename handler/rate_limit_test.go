@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"featureflags/controller"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucketLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := NewTokenBucketLimiter(2)
+	limiter.now = func() time.Time { return now }
+
+	t.Run("burst up to the limit", func(t *testing.T) {
+		allowed, _ := limiter.Allow("alice")
+		assert.True(t, allowed)
+		allowed, _ = limiter.Allow("alice")
+		assert.True(t, allowed)
+
+		allowed, wait := limiter.Allow("alice")
+		assert.False(t, allowed)
+		assert.Equal(t, 30*time.Second, wait)
+	})
+
+	t.Run("actors have separate buckets", func(t *testing.T) {
+		allowed, _ := limiter.Allow("bob")
+		assert.True(t, allowed)
+	})
+
+	t.Run("tokens refill over time", func(t *testing.T) {
+		now = now.Add(29 * time.Second)
+		allowed, wait := limiter.Allow("alice")
+		assert.False(t, allowed)
+		assert.Equal(t, time.Second, wait.Round(time.Millisecond))
+
+		now = now.Add(time.Second)
+		allowed, _ = limiter.Allow("alice")
+		assert.True(t, allowed)
+		allowed, _ = limiter.Allow("alice")
+		assert.False(t, allowed)
+	})
+
+	t.Run("refill stops at the limit", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		for i := 0; i < 2; i++ {
+			allowed, _ := limiter.Allow("alice")
+			assert.True(t, allowed)
+		}
+		allowed, _ := limiter.Allow("alice")
+		assert.False(t, allowed)
+	})
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	now := time.Now()
+	limiter := NewTokenBucketLimiter(1)
+	limiter.now = func() time.Time { return now }
+
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(controller.ActorContextKey, "alice")
+			return next(c)
+		}
+	})
+	e.POST("/flags", func(c echo.Context) error { return c.NoContent(http.StatusCreated) }, RateLimitMiddleware(limiter))
+
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/flags", nil))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusCreated, serve().Code)
+
+	rec := serve()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get(echo.HeaderRetryAfter))
+	assert.Contains(t, rec.Body.String(), controller.CodeRateLimited)
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, http.StatusCreated, serve().Code)
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"featureflags/controller"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultReadOnlyProbeInterval is how often a write is let through to test recovery
+const DefaultReadOnlyProbeInterval = 5 * time.Second
+
+// WriteMode tracks whether the database currently accepts writes. After a write fails for
+// connectivity reasons the service is read-only: reads are served, writes get 503, and one
+// write per probe interval is let through to detect recovery.
+type WriteMode struct {
+	mu            sync.Mutex
+	readOnly      bool
+	since         time.Time
+	lastProbe     time.Time
+	probeInterval time.Duration
+	now           func() time.Time
+}
+
+// NewWriteMode creates a write mode tracker in read-write mode
+func NewWriteMode(probeInterval time.Duration) *WriteMode {
+	if probeInterval <= 0 {
+		probeInterval = DefaultReadOnlyProbeInterval
+	}
+	return &WriteMode{probeInterval: probeInterval, now: time.Now}
+}
+
+// AllowWrite reports whether a write request may proceed
+func (m *WriteMode) AllowWrite() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.readOnly {
+		return true
+	}
+	if now := m.now(); now.Sub(m.lastProbe) >= m.probeInterval {
+		m.lastProbe = now
+		return true
+	}
+	return false
+}
+
+// MarkUnavailable switches to read-only mode
+func (m *WriteMode) MarkUnavailable() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.readOnly {
+		m.readOnly = true
+		m.since = m.now()
+		m.lastProbe = m.since
+	}
+}
+
+// MarkAvailable switches back to read-write mode
+func (m *WriteMode) MarkAvailable() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.readOnly = false
+}
+
+// ReadOnly reports whether writes are currently refused and since when
+func (m *WriteMode) ReadOnly() (bool, time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.readOnly, m.since
+}
+
+// ReadOnlyMiddleware refuses writes with 503 READ_ONLY while the database is unavailable
+// for writes, and updates the mode from the outcome of each write request. readPaths lists
+// routes that use a write method but only read, which are always let through.
+func ReadOnlyMiddleware(mode *WriteMode, readPaths ...string) echo.MiddlewareFunc {
+	reads := make(map[string]bool, len(readPaths))
+	for _, path := range readPaths {
+		reads[path] = true
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			switch c.Request().Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+				if reads[c.Path()] {
+					return next(c)
+				}
+			default:
+				return next(c)
+			}
+
+			if !mode.AllowWrite() {
+				return c.JSON(http.StatusServiceUnavailable, controller.NewAPIError(controller.ReadOnlyErrorCode,
+					"Service is in read-only mode because the database is not accepting writes"))
+			}
+
+			err := next(c)
+			if unavailable, _ := c.Get(controller.WriteUnavailableContextKey).(bool); unavailable {
+				mode.MarkUnavailable()
+			} else if status := c.Response().Status; status >= 200 && status < 300 {
+				mode.MarkAvailable()
+			}
+			return err
+		}
+	}
+}
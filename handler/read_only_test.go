@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"featureflags/controller"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	now := time.Now()
+	mode := NewWriteMode(time.Minute)
+	mode.now = func() time.Time { return now }
+
+	// The write handler simulates the database refusing writes until writesFail is cleared
+	writesFail := true
+	e := echo.New()
+	e.Use(ReadOnlyMiddleware(mode, "/flags/evaluate"))
+	e.POST("/flags", func(c echo.Context) error {
+		if writesFail {
+			c.Set(controller.WriteUnavailableContextKey, true)
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{"code": controller.ReadOnlyErrorCode})
+		}
+		return c.NoContent(http.StatusCreated)
+	})
+	e.GET("/flags", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	e.POST("/flags/evaluate", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	serve := func(method string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(method, "/flags", nil))
+		return rec
+	}
+
+	t.Run("write failure enters read-only mode", func(t *testing.T) {
+		assert.Equal(t, http.StatusServiceUnavailable, serve(http.MethodPost).Code)
+
+		readOnly, _ := mode.ReadOnly()
+		assert.True(t, readOnly)
+	})
+
+	t.Run("writes are refused and reads served", func(t *testing.T) {
+		rec := serve(http.MethodPost)
+		require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		var body controller.APIError
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, "READ_ONLY", body.Code)
+		assert.NotEmpty(t, body.Message)
+
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet).Code)
+	})
+
+	t.Run("read-only POST routes are served", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/flags/evaluate", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		// A successful read says nothing about writes
+		readOnly, _ := mode.ReadOnly()
+		assert.True(t, readOnly)
+	})
+
+	t.Run("successful probe restores read-write mode", func(t *testing.T) {
+		writesFail = false
+		now = now.Add(time.Minute)
+
+		assert.Equal(t, http.StatusCreated, serve(http.MethodPost).Code)
+
+		readOnly, _ := mode.ReadOnly()
+		assert.False(t, readOnly)
+		assert.Equal(t, http.StatusCreated, serve(http.MethodPost).Code)
+	})
+}
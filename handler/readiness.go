@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"featureflags/controller"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Readiness records whether startup has finished, so the server can listen while database
+// migrations still run. A nil *Readiness is always ready.
+type Readiness struct {
+	ready atomic.Bool
+}
+
+// NewReadiness creates a gate that is not ready until MarkReady is called
+func NewReadiness() *Readiness {
+	return &Readiness{}
+}
+
+// MarkReady records that startup has finished
+func (r *Readiness) MarkReady() {
+	r.ready.Store(true)
+}
+
+// Ready reports whether startup has finished
+func (r *Readiness) Ready() bool {
+	return r == nil || r.ready.Load()
+}
+
+// ReadinessMiddleware refuses requests with 503 SERVICE_STARTING until startup has finished
+func ReadinessMiddleware(ready *Readiness) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !ready.Ready() {
+				return c.JSON(http.StatusServiceUnavailable, controller.NewAPIError(controller.CodeServiceStarting,
+					"Service is starting; retry shortly"))
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"featureflags/controller"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadinessMiddleware(t *testing.T) {
+	ready := NewReadiness()
+	e := echo.New()
+	e.GET("/flags", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, ReadinessMiddleware(ready))
+
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/flags", nil))
+		return rec
+	}
+
+	rec := serve()
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), controller.CodeServiceStarting)
+
+	ready.MarkReady()
+	assert.Equal(t, http.StatusOK, serve().Code)
+
+	var always *Readiness
+	assert.True(t, always.Ready())
+}
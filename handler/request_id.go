@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"featureflags/pkg/logger"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxRequestIDLength bounds request IDs accepted from clients, matching the audit column
+const maxRequestIDLength = 64
+
+// RequestIDMiddleware gives every request an ID: the client's X-Request-ID when it is a
+// usable one, otherwise a generated one. The ID is echoed in the response header and stored
+// in the request context, where loggers and audit entries pick it up.
+func RequestIDMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			requestID := c.Request().Header.Get(echo.HeaderXRequestID)
+			if !validRequestID(requestID) {
+				requestID = newRequestID()
+			}
+
+			c.Response().Header().Set(echo.HeaderXRequestID, requestID)
+			c.SetRequest(c.Request().WithContext(logger.ContextWithRequestID(c.Request().Context(), requestID)))
+			return next(c)
+		}
+	}
+}
+
+// validRequestID reports whether a client-supplied ID is short and free of characters that
+// could garble log lines
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.' || r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
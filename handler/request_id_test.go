@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"featureflags/pkg/logger"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	e := echo.New()
+	e.Use(RequestIDMiddleware())
+	e.GET("/flags", func(c echo.Context) error {
+		return c.String(http.StatusOK, logger.RequestIDFromContext(c.Request().Context()))
+	})
+
+	serve := func(requestID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/flags", nil)
+		if requestID != "" {
+			req.Header.Set(echo.HeaderXRequestID, requestID)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("client ID is kept", func(t *testing.T) {
+		rec := serve("deploy-42")
+		assert.Equal(t, "deploy-42", rec.Body.String())
+		assert.Equal(t, "deploy-42", rec.Header().Get(echo.HeaderXRequestID))
+	})
+
+	t.Run("missing ID is generated", func(t *testing.T) {
+		rec := serve("")
+		assert.Len(t, rec.Body.String(), 32)
+		assert.Equal(t, rec.Body.String(), rec.Header().Get(echo.HeaderXRequestID))
+		assert.NotEqual(t, rec.Body.String(), serve("").Body.String())
+	})
+
+	t.Run("unusable client ID is replaced", func(t *testing.T) {
+		for _, requestID := range []string{strings.Repeat("a", 65), "two words", "line\tbreak"} {
+			rec := serve(requestID)
+			assert.NotEqual(t, requestID, rec.Body.String())
+			assert.Len(t, rec.Body.String(), 32)
+		}
+	})
+}
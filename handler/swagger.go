@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"net/http"
+
+	"featureflags/config"
+	"featureflags/controller"
+	"featureflags/pkg/logger"
+
+	"github.com/labstack/echo/v4"
+	echoSwagger "github.com/swaggo/echo-swagger"
+	"github.com/swaggo/swag"
+)
+
+// swaggerSpecPath is where both the UI and tooling load the OpenAPI document from
+const swaggerSpecPath = "/swagger/doc.json"
+
+// RegisterSwagger serves the interactive UI and the raw OpenAPI spec according to cfg. The
+// spec can be published for tooling while the UI stays off.
+func RegisterSwagger(e *echo.Echo, cfg config.Swagger, log *logger.Logger) {
+	if cfg.SpecEnabled {
+		log.Infow("Swagger spec enabled", "path", swaggerSpecPath)
+		e.GET(swaggerSpecPath, serveSwaggerSpec)
+	} else if cfg.UIEnabled {
+		// The UI's wildcard route would serve the spec otherwise
+		e.GET(swaggerSpecPath, func(c echo.Context) error {
+			return echo.ErrNotFound
+		})
+	}
+
+	if cfg.UIEnabled {
+		log.Infow("Swagger UI enabled", "path", "/swagger/*")
+		e.GET("/swagger/*", echoSwagger.WrapHandler)
+	}
+}
+
+func serveSwaggerSpec(c echo.Context) error {
+	doc, err := swag.ReadDoc()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			controller.NewAPIError(controller.CodeInternal, "Failed to load API specification"))
+	}
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, []byte(doc))
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"featureflags/config"
+	"featureflags/pkg/logger"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterSwagger(t *testing.T) {
+	log, err := logger.New("error", "production")
+	require.NoError(t, err)
+
+	serve := func(cfg config.Swagger, path string) *httptest.ResponseRecorder {
+		e := echo.New()
+		RegisterSwagger(e, cfg, log)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	t.Run("spec without UI", func(t *testing.T) {
+		cfg := config.Swagger{UIEnabled: false, SpecEnabled: true}
+
+		rec := serve(cfg, "/swagger/doc.json")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+		assert.Contains(t, rec.Body.String(), `"swagger"`)
+
+		assert.Equal(t, http.StatusNotFound, serve(cfg, "/swagger/index.html").Code)
+	})
+
+	t.Run("UI without spec", func(t *testing.T) {
+		cfg := config.Swagger{UIEnabled: true, SpecEnabled: false}
+
+		assert.Equal(t, http.StatusOK, serve(cfg, "/swagger/index.html").Code)
+		assert.Equal(t, http.StatusNotFound, serve(cfg, "/swagger/doc.json").Code)
+	})
+
+	t.Run("both disabled", func(t *testing.T) {
+		cfg := config.Swagger{}
+
+		assert.Equal(t, http.StatusNotFound, serve(cfg, "/swagger/index.html").Code)
+		assert.Equal(t, http.StatusNotFound, serve(cfg, "/swagger/doc.json").Code)
+	})
+}
@@ -0,0 +1 @@
+ALTER TABLE flags DROP CONSTRAINT IF EXISTS chk_flags_status;
@@ -0,0 +1,2 @@
+ALTER TABLE flags DROP CONSTRAINT IF EXISTS chk_flags_status;
+ALTER TABLE flags ADD CONSTRAINT chk_flags_status CHECK (status IN ('enabled', 'disabled', 'maintenance'));
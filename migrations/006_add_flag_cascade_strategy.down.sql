@@ -0,0 +1,2 @@
+ALTER TABLE flags DROP CONSTRAINT IF EXISTS chk_flags_cascade_strategy;
+ALTER TABLE flags DROP COLUMN IF EXISTS cascade_strategy;
@@ -0,0 +1,3 @@
+ALTER TABLE flags ADD COLUMN IF NOT EXISTS cascade_strategy VARCHAR(50) NOT NULL DEFAULT 'disable';
+ALTER TABLE flags DROP CONSTRAINT IF EXISTS chk_flags_cascade_strategy;
+ALTER TABLE flags ADD CONSTRAINT chk_flags_cascade_strategy CHECK (cascade_strategy IN ('disable', 'maintenance'));
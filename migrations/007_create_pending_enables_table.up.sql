@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS pending_enables (
+    id BIGSERIAL PRIMARY KEY,
+    flag_id BIGINT NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    reason TEXT,
+    status VARCHAR(50) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_attempt_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ,
+    FOREIGN KEY (flag_id) REFERENCES flags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_pending_enables_status ON pending_enables(status);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_pending_enables_flag_pending ON pending_enables(flag_id) WHERE status = 'pending';
@@ -0,0 +1 @@
+ALTER TABLE flags ADD COLUMN IF NOT EXISTS high_risk BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+ALTER TABLE flags DROP CONSTRAINT IF EXISTS chk_flags_disable_policy;
+ALTER TABLE flags DROP COLUMN IF EXISTS disable_policy;
@@ -0,0 +1,3 @@
+ALTER TABLE flags ADD COLUMN IF NOT EXISTS disable_policy VARCHAR(50) NOT NULL DEFAULT 'cascade';
+ALTER TABLE flags DROP CONSTRAINT IF EXISTS chk_flags_disable_policy;
+ALTER TABLE flags ADD CONSTRAINT chk_flags_disable_policy CHECK (disable_policy IN ('cascade', 'block'));
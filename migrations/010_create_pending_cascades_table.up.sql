@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS pending_cascades (
+    id BIGSERIAL PRIMARY KEY,
+    flag_id BIGINT NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'pending',
+    due_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ,
+    FOREIGN KEY (flag_id) REFERENCES flags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_pending_cascades_status_due_at ON pending_cascades(status, due_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_pending_cascades_flag_pending ON pending_cascades(flag_id) WHERE status = 'pending';
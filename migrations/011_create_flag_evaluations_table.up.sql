@@ -0,0 +1,8 @@
+-- Kept out of flags so recording an evaluation does not bump flags.updated_at
+CREATE TABLE IF NOT EXISTS flag_evaluations (
+    flag_id BIGINT PRIMARY KEY,
+    last_evaluated_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (flag_id) REFERENCES flags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_flag_evaluations_last_evaluated_at ON flag_evaluations(last_evaluated_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS cascade_event_flags;
+DROP TABLE IF EXISTS cascade_events;
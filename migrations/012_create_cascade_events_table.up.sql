@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS cascade_events (
+    id BIGSERIAL PRIMARY KEY,
+    root_flag_id BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    restored_at TIMESTAMPTZ,
+    FOREIGN KEY (root_flag_id) REFERENCES flags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_cascade_events_root_flag_id ON cascade_events(root_flag_id, created_at);
+
+CREATE TABLE IF NOT EXISTS cascade_event_flags (
+    cascade_event_id BIGINT NOT NULL,
+    flag_id BIGINT NOT NULL,
+    PRIMARY KEY (cascade_event_id, flag_id),
+    FOREIGN KEY (cascade_event_id) REFERENCES cascade_events(id) ON DELETE CASCADE,
+    FOREIGN KEY (flag_id) REFERENCES flags(id) ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+ALTER TABLE flags ADD COLUMN IF NOT EXISTS locked BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+DELETE FROM audit_logs WHERE flag_id NOT IN (SELECT id FROM flags);
+ALTER TABLE audit_logs ADD CONSTRAINT audit_logs_flag_id_fkey FOREIGN KEY (flag_id) REFERENCES flags(id) ON DELETE CASCADE;
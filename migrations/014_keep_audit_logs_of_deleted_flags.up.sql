@@ -0,0 +1,2 @@
+-- The audit trail must outlive the flags it describes, including the final delete entry
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS audit_logs_flag_id_fkey;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS flag_tags (
+    flag_id BIGINT NOT NULL,
+    key VARCHAR(50) NOT NULL,
+    value VARCHAR(100) NOT NULL,
+    PRIMARY KEY (flag_id, key),
+    FOREIGN KEY (flag_id) REFERENCES flags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_flag_tags_key_value ON flag_tags(key, value);
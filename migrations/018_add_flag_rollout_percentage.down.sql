@@ -0,0 +1,2 @@
+ALTER TABLE flags DROP CONSTRAINT IF EXISTS chk_flags_rollout_percentage;
+ALTER TABLE flags DROP COLUMN IF EXISTS rollout_percentage;
@@ -0,0 +1,3 @@
+ALTER TABLE flags ADD COLUMN IF NOT EXISTS rollout_percentage INTEGER NOT NULL DEFAULT 100;
+ALTER TABLE flags DROP CONSTRAINT IF EXISTS chk_flags_rollout_percentage;
+ALTER TABLE flags ADD CONSTRAINT chk_flags_rollout_percentage CHECK (rollout_percentage BETWEEN 0 AND 100);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS scheduled_changes (
+    id BIGSERIAL PRIMARY KEY,
+    flag_id BIGINT NOT NULL,
+    target_status VARCHAR(50) NOT NULL,
+    scheduled_at TIMESTAMPTZ NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    reason TEXT,
+    status VARCHAR(50) NOT NULL DEFAULT 'pending',
+    failure_reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ,
+    FOREIGN KEY (flag_id) REFERENCES flags(id) ON DELETE CASCADE,
+    CONSTRAINT chk_scheduled_changes_target_status CHECK (target_status IN ('enabled', 'disabled'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_changes_due ON scheduled_changes(scheduled_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_scheduled_changes_flag_id ON scheduled_changes(flag_id);
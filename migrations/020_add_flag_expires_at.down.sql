@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_flags_expires_at;
+ALTER TABLE flags DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,3 @@
+ALTER TABLE flags ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_flags_expires_at ON flags(expires_at) WHERE expires_at IS NOT NULL;
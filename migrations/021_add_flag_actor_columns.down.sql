@@ -0,0 +1,2 @@
+ALTER TABLE flags DROP COLUMN IF EXISTS updated_by;
+ALTER TABLE flags DROP COLUMN IF EXISTS created_by;
@@ -0,0 +1,2 @@
+ALTER TABLE flags ADD COLUMN IF NOT EXISTS created_by VARCHAR(255);
+ALTER TABLE flags ADD COLUMN IF NOT EXISTS updated_by VARCHAR(255);
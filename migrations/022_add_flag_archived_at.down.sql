@@ -0,0 +1 @@
+ALTER TABLE flags DROP COLUMN IF EXISTS archived_at;
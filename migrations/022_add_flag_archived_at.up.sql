@@ -0,0 +1 @@
+ALTER TABLE flags ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
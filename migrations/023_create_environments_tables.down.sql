@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS flag_environment_status;
+DROP TABLE IF EXISTS environments;
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS environments (
+    name VARCHAR(64) PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO environments (name) VALUES ('global'), ('dev'), ('staging'), ('prod') ON CONFLICT DO NOTHING;
+
+-- A flag without a row for an environment is disabled there. The global environment is the
+-- flag's own status and never has rows here.
+CREATE TABLE IF NOT EXISTS flag_environment_status (
+    flag_id BIGINT NOT NULL,
+    environment VARCHAR(64) NOT NULL,
+    status VARCHAR(50) NOT NULL,
+    version BIGINT NOT NULL DEFAULT 1,
+    updated_by VARCHAR(255),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (flag_id, environment),
+    FOREIGN KEY (flag_id) REFERENCES flags(id) ON DELETE CASCADE,
+    FOREIGN KEY (environment) REFERENCES environments(name) ON DELETE CASCADE,
+    CONSTRAINT chk_flag_environment_status_status CHECK (status IN ('enabled', 'disabled', 'maintenance')),
+    CONSTRAINT chk_flag_environment_status_not_global CHECK (environment <> 'global')
+);
+
+CREATE INDEX IF NOT EXISTS idx_flag_environment_status_environment ON flag_environment_status(environment);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS rollout_schedules;
@@ -0,0 +1,19 @@
+-- A flag has at most one rollout schedule; defining a new one replaces it
+CREATE TABLE IF NOT EXISTS rollout_schedules (
+    flag_id BIGINT PRIMARY KEY,
+    start_percentage INTEGER NOT NULL,
+    step_percentage INTEGER NOT NULL,
+    step_interval_minutes INTEGER NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'active',
+    next_step_at TIMESTAMPTZ,
+    actor VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (flag_id) REFERENCES flags(id) ON DELETE CASCADE,
+    CONSTRAINT chk_rollout_schedules_start CHECK (start_percentage BETWEEN 0 AND 100),
+    CONSTRAINT chk_rollout_schedules_step CHECK (step_percentage BETWEEN 1 AND 100),
+    CONSTRAINT chk_rollout_schedules_interval CHECK (step_interval_minutes > 0),
+    CONSTRAINT chk_rollout_schedules_status CHECK (status IN ('active', 'paused', 'completed', 'aborted'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_rollout_schedules_due ON rollout_schedules(next_step_at) WHERE status = 'active';
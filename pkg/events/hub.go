@@ -0,0 +1,76 @@
+package events
+
+import (
+	"sync"
+)
+
+// Event types published on the hub
+const (
+	TypeAudit      = "audit"
+	TypeFlagStatus = "flag_status"
+)
+
+// Event is a message delivered to hub subscribers
+type Event struct {
+	Type string
+	Data interface{}
+}
+
+// Hub is an in-process publish/subscribe hub. Publishing never blocks: events are dropped for
+// subscribers whose buffer is full.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+	bufferSize  int
+}
+
+// DefaultBufferSize is the number of events buffered per subscriber
+const DefaultBufferSize = 64
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[chan Event]struct{}),
+		bufferSize:  DefaultBufferSize,
+	}
+}
+
+// Subscribe registers a new subscriber. The returned function unsubscribes and closes the channel.
+func (h *Hub) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, h.bufferSize)
+
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers, ch)
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+// Publish delivers the event to every current subscriber
+func (h *Hub) Publish(event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Slow subscriber, drop the event rather than blocking the publisher
+		}
+	}
+}
+
+// SubscriberCount returns the number of active subscribers
+func (h *Hub) SubscriberCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers)
+}
@@ -0,0 +1,45 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub_PublishSubscribe(t *testing.T) {
+	hub := NewHub()
+
+	ch1, unsubscribe1 := hub.Subscribe()
+	ch2, unsubscribe2 := hub.Subscribe()
+	defer unsubscribe2()
+	assert.Equal(t, 2, hub.SubscriberCount())
+
+	hub.Publish(Event{Type: TypeAudit, Data: "first"})
+
+	assert.Equal(t, "first", (<-ch1).Data)
+	assert.Equal(t, "first", (<-ch2).Data)
+
+	unsubscribe1()
+	unsubscribe1() // safe to call twice
+	assert.Equal(t, 1, hub.SubscriberCount())
+
+	_, open := <-ch1
+	assert.False(t, open, "channel should be closed after unsubscribe")
+
+	hub.Publish(Event{Type: TypeAudit, Data: "second"})
+	assert.Equal(t, "second", (<-ch2).Data)
+}
+
+func TestHub_SlowSubscriberDoesNotBlock(t *testing.T) {
+	hub := NewHub()
+	ch, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+
+	for i := 0; i < DefaultBufferSize+10; i++ {
+		hub.Publish(Event{Type: TypeAudit, Data: i})
+	}
+
+	require.Len(t, ch, DefaultBufferSize)
+	assert.Equal(t, 0, (<-ch).Data)
+}
@@ -0,0 +1,26 @@
+package logger
+
+import "context"
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a context carrying the ID of the request it serves
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or "" outside a request
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// WithContext returns a logger that adds the request ID carried by ctx to every entry, so
+// all lines logged while serving one request can be tied together
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" {
+		return l
+	}
+	return &Logger{SugaredLogger: l.With("request_id", requestID)}
+}
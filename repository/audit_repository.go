@@ -3,16 +3,38 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"featureflags/entity"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type AuditRepository interface {
 	CreateAuditLog(ctx context.Context, log *entity.AuditLog) error
+	CreateAuditLogAt(ctx context.Context, log *entity.AuditLog) error
 	ListAuditLogsByFlagID(ctx context.Context, flagID int64) ([]*entity.AuditLog, error)
+	ListAuditLogsByFlagIDFiltered(ctx context.Context, flagID int64, filter entity.AuditFilter) ([]*entity.AuditLog, error)
 	ListAllAuditLogs(ctx context.Context, limit, offset int) ([]*entity.AuditLog, error)
+	AggregateAuditLogs(ctx context.Context, groupBy entity.AuditReportGroupBy, from, to time.Time) ([]*AuditReportRow, error)
+	ListFlappyFlags(ctx context.Context, since time.Time, minToggles int) ([]*entity.FlappyFlag, error)
+}
+
+// AuditReportRow is one aggregated (group key, action) pair of an audit report
+type AuditReportRow struct {
+	Key    string             `db:"key"`
+	Action entity.AuditAction `db:"action"`
+	Count  int                `db:"count"`
+	Flags  pq.StringArray     `db:"flags"`
+}
+
+// auditReportKeys maps each grouping to the column it aggregates on
+var auditReportKeys = map[entity.AuditReportGroupBy]string{
+	entity.AuditReportByActor:  "al.actor",
+	entity.AuditReportByFlag:   "f.name",
+	entity.AuditReportByAction: "al.action",
 }
 
 type pgAuditRepository struct {
@@ -23,9 +45,29 @@ func NewAuditRepository(db *sqlx.DB) AuditRepository {
 	return &pgAuditRepository{db: db}
 }
 
+// conn returns the transaction carried by ctx, or the shared connection pool
+func (r *pgAuditRepository) conn(ctx context.Context) queryer {
+	return connFromContext(ctx, r.db)
+}
+
 func (r *pgAuditRepository) CreateAuditLog(ctx context.Context, log *entity.AuditLog) error {
-	query := `INSERT INTO audit_logs (flag_id, action, actor, reason) VALUES ($1, $2, $3, $4)`
-	_, err := r.db.ExecContext(ctx, query, log.FlagID, log.Action, log.Actor, log.Reason)
+	query := `INSERT INTO audit_logs (flag_id, action, actor, reason, environment, request_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, '')) RETURNING id, created_at`
+	err := r.conn(ctx).QueryRowContext(ctx, query, log.FlagID, log.Action, log.Actor, log.Reason, log.Environment,
+		log.RequestID).Scan(&log.ID, &log.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+	return nil
+}
+
+// CreateAuditLogAt writes an audit entry keeping its CreatedAt instead of the insert time, so
+// an entry written late (e.g. on retry) keeps its place in the history
+func (r *pgAuditRepository) CreateAuditLogAt(ctx context.Context, log *entity.AuditLog) error {
+	query := `INSERT INTO audit_logs (flag_id, action, actor, reason, environment, request_id, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7) RETURNING id`
+	err := r.conn(ctx).QueryRowContext(ctx, query, log.FlagID, log.Action, log.Actor, log.Reason, log.Environment,
+		log.RequestID, log.CreatedAt).Scan(&log.ID)
 	if err != nil {
 		return fmt.Errorf("failed to create audit log: %w", err)
 	}
@@ -35,29 +77,112 @@ func (r *pgAuditRepository) CreateAuditLog(ctx context.Context, log *entity.Audi
 func (r *pgAuditRepository) ListAuditLogsByFlagID(ctx context.Context, flagID int64) ([]*entity.AuditLog, error) {
 	var logs []*entity.AuditLog
 	query := `
-		SELECT id, flag_id, action, actor, reason, created_at 
+		SELECT id, flag_id, action, actor, reason, COALESCE(environment, '') AS environment,
+			COALESCE(request_id, '') AS request_id, created_at
 		FROM audit_logs 
 		WHERE flag_id = $1 
 		ORDER BY created_at DESC
 	`
-	err := r.db.SelectContext(ctx, &logs, query, flagID)
+	err := r.conn(ctx).SelectContext(ctx, &logs, query, flagID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list audit logs by flag ID: %w", err)
 	}
 	return logs, nil
 }
 
+// ListAuditLogsByFlagIDFiltered returns the flag's audit entries matching filter, newest first
+func (r *pgAuditRepository) ListAuditLogsByFlagIDFiltered(ctx context.Context, flagID int64, filter entity.AuditFilter) ([]*entity.AuditLog, error) {
+	conditions := []string{"flag_id = $1"}
+	args := []interface{}{flagID}
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		conditions = append(conditions, fmt.Sprintf("action = $%d", len(args)))
+	}
+	if filter.Actor != "" {
+		args = append(args, filter.Actor)
+		conditions = append(conditions, fmt.Sprintf("actor = $%d", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	var logs []*entity.AuditLog
+	query := `SELECT id, flag_id, action, actor, reason, COALESCE(environment, '') AS environment,
+		COALESCE(request_id, '') AS request_id, created_at
+		FROM audit_logs WHERE ` +
+		strings.Join(conditions, " AND ") + ` ORDER BY created_at DESC`
+	err := r.conn(ctx).SelectContext(ctx, &logs, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list filtered audit logs: %w", err)
+	}
+	return logs, nil
+}
+
 func (r *pgAuditRepository) ListAllAuditLogs(ctx context.Context, limit, offset int) ([]*entity.AuditLog, error) {
 	var logs []*entity.AuditLog
 	query := `
-		SELECT al.id, al.flag_id, al.action, al.actor, al.reason, al.created_at
+		SELECT al.id, al.flag_id, al.action, al.actor, al.reason, COALESCE(al.environment, '') AS environment,
+			COALESCE(al.request_id, '') AS request_id, al.created_at
 		FROM audit_logs al
 		ORDER BY al.created_at DESC
 		LIMIT $1 OFFSET $2
 	`
-	err := r.db.SelectContext(ctx, &logs, query, limit, offset)
+	err := r.conn(ctx).SelectContext(ctx, &logs, query, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list all audit logs: %w", err)
 	}
 	return logs, nil
-} 
\ No newline at end of file
+}
+
+// AggregateAuditLogs counts audit entries in [from, to) per group key and action, ordered by key
+func (r *pgAuditRepository) AggregateAuditLogs(ctx context.Context, groupBy entity.AuditReportGroupBy, from, to time.Time) ([]*AuditReportRow, error) {
+	key, ok := auditReportKeys[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported audit report grouping: %s", groupBy)
+	}
+
+	var rows []*AuditReportRow
+	query := `
+		SELECT ` + key + ` AS key, al.action, COUNT(*) AS count, array_agg(DISTINCT f.name ORDER BY f.name) AS flags
+		FROM audit_logs al
+		JOIN flags f ON f.id = al.flag_id
+		WHERE al.created_at >= $1 AND al.created_at < $2
+		GROUP BY 1, al.action
+		ORDER BY 1, al.action
+	`
+	err := r.conn(ctx).SelectContext(ctx, &rows, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate audit logs: %w", err)
+	}
+	return rows, nil
+}
+
+// ListFlappyFlags returns flags with at least minToggles status changes since the given
+// time, most frequently changed first
+func (r *pgAuditRepository) ListFlappyFlags(ctx context.Context, since time.Time, minToggles int) ([]*entity.FlappyFlag, error) {
+	actions := make([]string, len(entity.StatusChangeActions))
+	for i, action := range entity.StatusChangeActions {
+		actions[i] = string(action)
+	}
+
+	var flags []*entity.FlappyFlag
+	query := `
+		SELECT f.id, f.name, f.status, COUNT(*) AS toggle_count
+		FROM audit_logs al
+		JOIN flags f ON f.id = al.flag_id
+		WHERE al.created_at >= $1 AND al.action = ANY($2)
+		GROUP BY f.id, f.name, f.status
+		HAVING COUNT(*) >= $3
+		ORDER BY toggle_count DESC, f.name
+	`
+	err := r.conn(ctx).SelectContext(ctx, &flags, query, since, pq.Array(actions), minToggles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flappy flags: %w", err)
+	}
+	return flags, nil
+}
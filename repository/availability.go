@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// IsUnavailableError reports whether err means the database could not accept the statement
+// (lost connection, server shutting down, read-only replica) rather than rejecting it.
+func IsUnavailableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code.Class() == "08": // connection_exception
+			return true
+		case pqErr.Code == "25006": // read_only_sql_transaction
+			return true
+		case pqErr.Code.Class() == "57" && pqErr.Code != "57014": // operator intervention, except query_canceled
+			return true
+		}
+	}
+
+	// lib/pq reports a closed connection as a plain error
+	return strings.Contains(err.Error(), "connection refused")
+}
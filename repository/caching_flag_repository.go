@@ -0,0 +1,315 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"featureflags/entity"
+)
+
+// Defaults for the flag cache when no size or TTL is configured
+const (
+	DefaultFlagCacheSize = 1000
+	DefaultFlagCacheTTL  = 30 * time.Second
+)
+
+// cachingFlagRepository decorates a FlagRepository with an in-memory LRU cache of flags by ID
+// and of their dependencies. Every write through it invalidates the entries it affects, so
+// reads in this process never see a change undone. Other instances keep their entries until
+// the TTL runs out.
+//
+// Reads inside a transaction bypass the cache, since they may see uncommitted changes.
+// Entries written inside a transaction are invalidated again once it ends, so a concurrent
+// read made before the commit cannot leave the old value cached.
+type cachingFlagRepository struct {
+	FlagRepository
+	cache *flagCache
+}
+
+// NewCachingFlagRepository wraps repo with a cache holding up to size entries for ttl each
+func NewCachingFlagRepository(repo FlagRepository, size int, ttl time.Duration) FlagRepository {
+	return &cachingFlagRepository{FlagRepository: repo, cache: newFlagCache(size, ttl)}
+}
+
+type cacheKind int
+
+const (
+	cachedFlag cacheKind = iota
+	cachedDependencies
+)
+
+type cacheKey struct {
+	kind cacheKind
+	id   int64
+}
+
+type pendingInvalidationsKey struct{}
+
+// pendingInvalidations collects what was written inside a transaction
+type pendingInvalidations struct {
+	mu    sync.Mutex
+	keys  []cacheKey
+	purge bool
+}
+
+func (r *cachingFlagRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return r.FlagRepository.WithinTx(ctx, fn)
+	}
+
+	pending := &pendingInvalidations{}
+	err := r.FlagRepository.WithinTx(context.WithValue(ctx, pendingInvalidationsKey{}, pending), fn)
+	pending.mu.Lock()
+	defer pending.mu.Unlock()
+	if pending.purge {
+		r.cache.purge()
+	} else {
+		r.cache.remove(pending.keys...)
+	}
+	return err
+}
+
+func (r *cachingFlagRepository) GetFlagByID(ctx context.Context, id int64) (*entity.Flag, error) {
+	if _, ok := TxFromContext(ctx); ok {
+		return r.FlagRepository.GetFlagByID(ctx, id)
+	}
+
+	key := cacheKey{kind: cachedFlag, id: id}
+	value, gen, ok := r.cache.get(key)
+	if ok {
+		return cloneFlag(value.(*entity.Flag)), nil
+	}
+	flag, err := r.FlagRepository.GetFlagByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	r.cache.put(key, cloneFlag(flag), gen)
+	return flag, nil
+}
+
+func (r *cachingFlagRepository) GetDependencies(ctx context.Context, flagID int64) ([]int64, error) {
+	if _, ok := TxFromContext(ctx); ok {
+		return r.FlagRepository.GetDependencies(ctx, flagID)
+	}
+
+	key := cacheKey{kind: cachedDependencies, id: flagID}
+	value, gen, ok := r.cache.get(key)
+	if ok {
+		return append([]int64(nil), value.([]int64)...), nil
+	}
+	deps, err := r.FlagRepository.GetDependencies(ctx, flagID)
+	if err != nil {
+		return nil, err
+	}
+	r.cache.put(key, append([]int64(nil), deps...), gen)
+	return deps, nil
+}
+
+// UpdateFlagStatus also invalidates the flag's dependents, whose evaluation depends on it
+func (r *cachingFlagRepository) UpdateFlagStatus(ctx context.Context, id int64, status entity.FlagStatus, expectedVersion int64, actor string) error {
+	dependents, err := r.FlagRepository.GetDependents(ctx, id)
+	if err != nil {
+		return err
+	}
+	keys := []cacheKey{{kind: cachedFlag, id: id}}
+	for _, dependentID := range dependents {
+		keys = append(keys, cacheKey{kind: cachedFlag, id: dependentID})
+	}
+
+	err = r.FlagRepository.UpdateFlagStatus(ctx, id, status, expectedVersion, actor)
+	r.invalidate(ctx, keys...)
+	return err
+}
+
+func (r *cachingFlagRepository) SetFlagLocked(ctx context.Context, id int64, locked bool, actor string) error {
+	err := r.FlagRepository.SetFlagLocked(ctx, id, locked, actor)
+	r.invalidate(ctx, cacheKey{kind: cachedFlag, id: id})
+	return err
+}
+
+func (r *cachingFlagRepository) UpdateFlagDescription(ctx context.Context, id int64, description, actor string) error {
+	err := r.FlagRepository.UpdateFlagDescription(ctx, id, description, actor)
+	r.invalidate(ctx, cacheKey{kind: cachedFlag, id: id})
+	return err
+}
+
+func (r *cachingFlagRepository) RenameFlag(ctx context.Context, id int64, name, actor string) error {
+	err := r.FlagRepository.RenameFlag(ctx, id, name, actor)
+	r.invalidate(ctx, cacheKey{kind: cachedFlag, id: id})
+	return err
+}
+
+func (r *cachingFlagRepository) UpdateFlagRollout(ctx context.Context, id int64, percentage int, actor string) error {
+	err := r.FlagRepository.UpdateFlagRollout(ctx, id, percentage, actor)
+	r.invalidate(ctx, cacheKey{kind: cachedFlag, id: id})
+	return err
+}
+
+func (r *cachingFlagRepository) SetFlagArchived(ctx context.Context, id int64, archived bool, actor string) error {
+	err := r.FlagRepository.SetFlagArchived(ctx, id, archived, actor)
+	r.invalidate(ctx, cacheKey{kind: cachedFlag, id: id})
+	return err
+}
+
+func (r *cachingFlagRepository) SetTags(ctx context.Context, flagID int64, tags map[string]string) error {
+	err := r.FlagRepository.SetTags(ctx, flagID, tags)
+	r.invalidate(ctx, cacheKey{kind: cachedFlag, id: flagID})
+	return err
+}
+
+// DeleteFlag clears the whole cache, since the deletion also drops the flag from its
+// dependents' dependency lists
+func (r *cachingFlagRepository) DeleteFlag(ctx context.Context, id int64) error {
+	err := r.FlagRepository.DeleteFlag(ctx, id)
+	r.cache.purge()
+	if pending, ok := ctx.Value(pendingInvalidationsKey{}).(*pendingInvalidations); ok {
+		pending.mu.Lock()
+		defer pending.mu.Unlock()
+		pending.purge = true
+	}
+	return err
+}
+
+func (r *cachingFlagRepository) AddDependency(ctx context.Context, flagID, dependsOnID int64) error {
+	err := r.FlagRepository.AddDependency(ctx, flagID, dependsOnID)
+	r.invalidate(ctx, cacheKey{kind: cachedFlag, id: flagID}, cacheKey{kind: cachedDependencies, id: flagID})
+	return err
+}
+
+func (r *cachingFlagRepository) RemoveDependency(ctx context.Context, flagID, dependsOnID int64) error {
+	err := r.FlagRepository.RemoveDependency(ctx, flagID, dependsOnID)
+	r.invalidate(ctx, cacheKey{kind: cachedFlag, id: flagID}, cacheKey{kind: cachedDependencies, id: flagID})
+	return err
+}
+
+// RecordEvaluations invalidates the flags so their last_evaluated_at is reloaded
+func (r *cachingFlagRepository) RecordEvaluations(ctx context.Context, evaluations map[int64]time.Time) error {
+	err := r.FlagRepository.RecordEvaluations(ctx, evaluations)
+	keys := make([]cacheKey, 0, len(evaluations))
+	for id := range evaluations {
+		keys = append(keys, cacheKey{kind: cachedFlag, id: id})
+	}
+	r.invalidate(ctx, keys...)
+	return err
+}
+
+// invalidate removes keys now and, inside a transaction, again once it ends
+func (r *cachingFlagRepository) invalidate(ctx context.Context, keys ...cacheKey) {
+	r.cache.remove(keys...)
+	if pending, ok := ctx.Value(pendingInvalidationsKey{}).(*pendingInvalidations); ok {
+		pending.mu.Lock()
+		defer pending.mu.Unlock()
+		pending.keys = append(pending.keys, keys...)
+	}
+}
+
+// cloneFlag copies a flag so callers cannot modify the cached one
+func cloneFlag(flag *entity.Flag) *entity.Flag {
+	clone := *flag
+	clone.Dependencies = append([]int64(nil), flag.Dependencies...)
+	if flag.Tags != nil {
+		clone.Tags = make(map[string]string, len(flag.Tags))
+		for key, value := range flag.Tags {
+			clone.Tags[key] = value
+		}
+	}
+	return &clone
+}
+
+// flagCache is an LRU cache whose entries expire after a TTL. Every removal bumps a
+// generation counter; a value loaded before a removal is not stored, so a read racing a
+// write cannot cache what the write replaced.
+type flagCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	gen     uint64
+	entries map[cacheKey]*list.Element
+	order   *list.List // most recently used first
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	value   interface{}
+	expires time.Time
+}
+
+func newFlagCache(size int, ttl time.Duration) *flagCache {
+	if size <= 0 {
+		size = DefaultFlagCacheSize
+	}
+	if ttl <= 0 {
+		ttl = DefaultFlagCacheTTL
+	}
+	return &flagCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[cacheKey]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// get returns the live value for key. On a miss it returns the generation to pass to put.
+func (c *flagCache) get(key cacheKey) (interface{}, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if c.now().Before(entry.expires) {
+			c.order.MoveToFront(elem)
+			return entry.value, c.gen, true
+		}
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+	return nil, c.gen, false
+}
+
+// put stores value unless anything was removed since gen was read
+func (c *flagCache) put(key cacheKey, value interface{}, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+	entry := &cacheEntry{key: key, value: value, expires: c.now().Add(c.ttl)}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *flagCache) remove(keys ...cacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for _, key := range keys {
+		if elem, ok := c.entries[key]; ok {
+			c.order.Remove(elem)
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *flagCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.entries = make(map[cacheKey]*list.Element)
+	c.order.Init()
+}
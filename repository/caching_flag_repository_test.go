@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"featureflags/entity"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memFlagRepository is an in-memory FlagRepository that counts the flag loads reaching it
+type memFlagRepository struct {
+	FlagRepository
+	flags map[int64]*entity.Flag
+	loads int
+	// beforeLoad, if set, runs after a load has read its flag and before it returns
+	beforeLoad func()
+}
+
+func (r *memFlagRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ContextWithTx(ctx, &sqlx.Tx{}))
+}
+
+func (r *memFlagRepository) GetFlagByID(ctx context.Context, id int64) (*entity.Flag, error) {
+	r.loads++
+	flag, ok := r.flags[id]
+	if !ok {
+		return nil, ErrFlagNotFound
+	}
+	loaded := cloneFlag(flag)
+	if r.beforeLoad != nil {
+		r.beforeLoad()
+	}
+	return loaded, nil
+}
+
+func (r *memFlagRepository) GetDependents(ctx context.Context, flagID int64) ([]int64, error) {
+	var dependents []int64
+	for id, flag := range r.flags {
+		for _, depID := range flag.Dependencies {
+			if depID == flagID {
+				dependents = append(dependents, id)
+			}
+		}
+	}
+	return dependents, nil
+}
+
+func (r *memFlagRepository) UpdateFlagStatus(ctx context.Context, id int64, status entity.FlagStatus, expectedVersion int64, actor string) error {
+	r.flags[id].Status = status
+	r.flags[id].Version++
+	return nil
+}
+
+func TestCachingFlagRepository(t *testing.T) {
+	ctx := context.Background()
+	newRepo := func() (*memFlagRepository, FlagRepository) {
+		inner := &memFlagRepository{flags: map[int64]*entity.Flag{
+			1: {ID: 1, Name: "auth_v2", Status: entity.FlagEnabled},
+			2: {ID: 2, Name: "checkout_v2", Status: entity.FlagEnabled, Dependencies: []int64{1}},
+		}}
+		return inner, NewCachingFlagRepository(inner, 10, time.Minute)
+	}
+
+	t.Run("repeated reads are served from the cache", func(t *testing.T) {
+		inner, repo := newRepo()
+		for i := 0; i < 3; i++ {
+			flag, err := repo.GetFlagByID(ctx, 1)
+			require.NoError(t, err)
+			assert.Equal(t, entity.FlagEnabled, flag.Status)
+		}
+		assert.Equal(t, 1, inner.loads)
+	})
+
+	t.Run("no stale read after a toggle", func(t *testing.T) {
+		_, repo := newRepo()
+		_, err := repo.GetFlagByID(ctx, 1)
+		require.NoError(t, err)
+
+		require.NoError(t, repo.UpdateFlagStatus(ctx, 1, entity.FlagDisabled, 0, "test_user"))
+
+		flag, err := repo.GetFlagByID(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, entity.FlagDisabled, flag.Status)
+	})
+
+	t.Run("toggle inside a transaction invalidates dependents", func(t *testing.T) {
+		inner, repo := newRepo()
+		_, err := repo.GetFlagByID(ctx, 2)
+		require.NoError(t, err)
+
+		require.NoError(t, repo.WithinTx(ctx, func(ctx context.Context) error {
+			return repo.UpdateFlagStatus(ctx, 1, entity.FlagDisabled, 0, "test_user")
+		}))
+
+		_, err = repo.GetFlagByID(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, 2, inner.loads)
+	})
+
+	t.Run("a load racing a toggle is not cached", func(t *testing.T) {
+		inner, repo := newRepo()
+		inner.beforeLoad = func() {
+			inner.beforeLoad = nil
+			require.NoError(t, repo.UpdateFlagStatus(ctx, 1, entity.FlagDisabled, 0, "test_user"))
+		}
+		flag, err := repo.GetFlagByID(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, entity.FlagEnabled, flag.Status)
+
+		flag, err = repo.GetFlagByID(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, entity.FlagDisabled, flag.Status)
+	})
+
+	t.Run("callers cannot modify the cached flag", func(t *testing.T) {
+		_, repo := newRepo()
+		flag, err := repo.GetFlagByID(ctx, 2)
+		require.NoError(t, err)
+		flag.Status = entity.FlagDisabled
+		flag.Dependencies[0] = 99
+
+		flag, err = repo.GetFlagByID(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, entity.FlagEnabled, flag.Status)
+		assert.Equal(t, []int64{1}, flag.Dependencies)
+	})
+}
+
+func TestFlagCache(t *testing.T) {
+	now := time.Now()
+	cache := newFlagCache(2, time.Minute)
+	cache.now = func() time.Time { return now }
+	put := func(id int64) {
+		_, gen, _ := cache.get(cacheKey{id: id})
+		cache.put(cacheKey{id: id}, id, gen)
+	}
+	cached := func(id int64) bool {
+		_, _, ok := cache.get(cacheKey{id: id})
+		return ok
+	}
+
+	t.Run("least recently used entry is evicted", func(t *testing.T) {
+		put(1)
+		put(2)
+		assert.True(t, cached(1))
+		put(3)
+
+		assert.True(t, cached(1))
+		assert.False(t, cached(2))
+		assert.True(t, cached(3))
+	})
+
+	t.Run("entries expire after the TTL", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		assert.False(t, cached(1))
+		assert.False(t, cached(3))
+	})
+}
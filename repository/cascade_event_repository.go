@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"featureflags/entity"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var ErrCascadeEventNotFound = errors.New("cascade event not found")
+
+// CascadeEventRepository stores which flags each cascade disabled
+type CascadeEventRepository interface {
+	CreateCascadeEvent(ctx context.Context, rootFlagID int64, flagIDs []int64) (int64, error)
+	// GetLatestCascadeEvent returns the most recent cascade triggered by the flag, with its flag IDs
+	GetLatestCascadeEvent(ctx context.Context, rootFlagID int64) (*entity.CascadeEvent, error)
+	MarkCascadeEventRestored(ctx context.Context, id int64) error
+}
+
+type pgCascadeEventRepository struct {
+	db *sqlx.DB
+}
+
+func NewCascadeEventRepository(db *sqlx.DB) CascadeEventRepository {
+	return &pgCascadeEventRepository{db: db}
+}
+
+// conn returns the transaction carried by ctx, or the shared connection pool
+func (r *pgCascadeEventRepository) conn(ctx context.Context) queryer {
+	return connFromContext(ctx, r.db)
+}
+
+func (r *pgCascadeEventRepository) CreateCascadeEvent(ctx context.Context, rootFlagID int64, flagIDs []int64) (int64, error) {
+	var id int64
+	err := withinTx(ctx, r.db, func(ctx context.Context) error {
+		query := `INSERT INTO cascade_events (root_flag_id) VALUES ($1) RETURNING id`
+		if err := r.conn(ctx).QueryRowContext(ctx, query, rootFlagID).Scan(&id); err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO cascade_event_flags (cascade_event_id, flag_id)
+			SELECT $1, flag_id FROM unnest($2::bigint[]) AS flag_id
+			ON CONFLICT DO NOTHING
+		`
+		_, err := r.conn(ctx).ExecContext(ctx, query, id, pq.Array(flagIDs))
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create cascade event: %w", err)
+	}
+	return id, nil
+}
+
+func (r *pgCascadeEventRepository) GetLatestCascadeEvent(ctx context.Context, rootFlagID int64) (*entity.CascadeEvent, error) {
+	var event entity.CascadeEvent
+	query := `
+		SELECT id, root_flag_id, created_at, restored_at FROM cascade_events
+		WHERE root_flag_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`
+	err := r.conn(ctx).GetContext(ctx, &event, query, rootFlagID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCascadeEventNotFound
+		}
+		return nil, fmt.Errorf("failed to get cascade event: %w", err)
+	}
+
+	query = `SELECT flag_id FROM cascade_event_flags WHERE cascade_event_id = $1 ORDER BY flag_id`
+	if err := r.conn(ctx).SelectContext(ctx, &event.FlagIDs, query, event.ID); err != nil {
+		return nil, fmt.Errorf("failed to get cascade event flags: %w", err)
+	}
+	return &event, nil
+}
+
+func (r *pgCascadeEventRepository) MarkCascadeEventRestored(ctx context.Context, id int64) error {
+	query := `UPDATE cascade_events SET restored_at = NOW() WHERE id = $1`
+	_, err := r.conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark cascade event restored: %w", err)
+	}
+	return nil
+}
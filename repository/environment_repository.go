@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"featureflags/entity"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrEnvironmentNotFound = errors.New("environment not found")
+	ErrEnvironmentExists   = errors.New("environment already exists")
+)
+
+// EnvironmentRepository stores the status of flags per environment. The global environment's
+// status is the flag's own and lives in the flags table; this repository never stores it.
+type EnvironmentRepository interface {
+	ListEnvironments(ctx context.Context) ([]string, error)
+	// CreateEnvironment returns ErrEnvironmentExists if the name is taken
+	CreateEnvironment(ctx context.Context, name string) error
+	// DeleteEnvironment drops the environment and the flag statuses stored for it
+	DeleteEnvironment(ctx context.Context, name string) error
+	// GetFlagStates returns the flag's status in every environment, global included, or
+	// ErrFlagNotFound
+	GetFlagStates(ctx context.Context, flagID int64) (map[string]entity.FlagStatus, error)
+	// DiffEnvironments returns the flags that are not archived and whose status differs between
+	// from and to, ordered by name, or ErrEnvironmentNotFound if either is unknown
+	DiffEnvironments(ctx context.Context, from, to string) ([]*entity.FlagStateDiff, error)
+	// GetFlagEnvironmentStatus returns the flag's status in env, disabled if it was never
+	// changed there, or ErrEnvironmentNotFound for an unknown environment
+	GetFlagEnvironmentStatus(ctx context.Context, flagID int64, env string) (*entity.FlagEnvironmentStatus, error)
+	// ListFlagEnvironmentStatuses returns the flag's status in every environment but global,
+	// ordered by environment
+	ListFlagEnvironmentStatuses(ctx context.Context, flagID int64) ([]*entity.FlagEnvironmentStatus, error)
+	// UpdateFlagEnvironmentStatus only writes if the flag's status in env is still at
+	// expectedVersion, returning ErrConcurrentModification otherwise
+	UpdateFlagEnvironmentStatus(ctx context.Context, flagID int64, env string, status entity.FlagStatus, expectedVersion int64, actor string) error
+}
+
+type pgEnvironmentRepository struct {
+	db *sqlx.DB
+}
+
+func NewEnvironmentRepository(db *sqlx.DB) EnvironmentRepository {
+	return &pgEnvironmentRepository{db: db}
+}
+
+// conn returns the transaction carried by ctx, or the shared connection pool
+func (r *pgEnvironmentRepository) conn(ctx context.Context) queryer {
+	return connFromContext(ctx, r.db)
+}
+
+// flagEnvironmentStatusQuery selects a flag's status ($1) in the known environments, filling
+// in the default for environments it has no row in
+const flagEnvironmentStatusQuery = `SELECT $1::BIGINT AS flag_id, e.name AS environment,
+	COALESCE(fes.status, 'disabled') AS status, COALESCE(fes.version, 0) AS version,
+	COALESCE(fes.updated_by, '') AS updated_by, fes.updated_at
+	FROM environments e
+	LEFT JOIN flag_environment_status fes ON fes.environment = e.name AND fes.flag_id = $1
+	WHERE e.name <> 'global'`
+
+func (r *pgEnvironmentRepository) ListEnvironments(ctx context.Context) ([]string, error) {
+	var names []string
+	if err := r.conn(ctx).SelectContext(ctx, &names, `SELECT name FROM environments ORDER BY name`); err != nil {
+		return nil, fmt.Errorf("failed to list environments: %w", err)
+	}
+	return names, nil
+}
+
+func (r *pgEnvironmentRepository) CreateEnvironment(ctx context.Context, name string) error {
+	_, err := r.conn(ctx).ExecContext(ctx, `INSERT INTO environments (name) VALUES ($1)`, name)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+			return ErrEnvironmentExists
+		}
+		return fmt.Errorf("failed to create environment: %w", err)
+	}
+	return nil
+}
+
+func (r *pgEnvironmentRepository) DeleteEnvironment(ctx context.Context, name string) error {
+	result, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM environments WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete environment: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrEnvironmentNotFound
+	}
+	return nil
+}
+
+func (r *pgEnvironmentRepository) GetFlagStates(ctx context.Context, flagID int64) (map[string]entity.FlagStatus, error) {
+	// The global status is the flag's own; elsewhere a missing row means disabled
+	query := `SELECT e.name AS environment,
+		CASE WHEN e.name = 'global' THEN f.status ELSE COALESCE(fes.status, 'disabled') END AS status
+		FROM flags f
+		CROSS JOIN environments e
+		LEFT JOIN flag_environment_status fes ON fes.flag_id = f.id AND fes.environment = e.name
+		WHERE f.id = $1`
+	var rows []struct {
+		Environment string            `db:"environment"`
+		Status      entity.FlagStatus `db:"status"`
+	}
+	if err := r.conn(ctx).SelectContext(ctx, &rows, query, flagID); err != nil {
+		return nil, fmt.Errorf("failed to get flag states: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, ErrFlagNotFound
+	}
+
+	states := make(map[string]entity.FlagStatus, len(rows))
+	for _, row := range rows {
+		states[row.Environment] = row.Status
+	}
+	return states, nil
+}
+
+func (r *pgEnvironmentRepository) DiffEnvironments(ctx context.Context, from, to string) ([]*entity.FlagStateDiff, error) {
+	var known int
+	err := r.conn(ctx).GetContext(ctx, &known, `SELECT COUNT(DISTINCT name) FROM environments WHERE name IN ($1, $2)`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check environments: %w", err)
+	}
+	if (from == to && known != 1) || (from != to && known != 2) {
+		return nil, ErrEnvironmentNotFound
+	}
+
+	// Each flag's status in both environments, joined with itself on the flag
+	query := `WITH states AS (
+			SELECT f.id AS flag_id, f.name, e.name AS environment,
+				CASE WHEN e.name = 'global' THEN f.status ELSE COALESCE(fes.status, 'disabled') END AS status
+			FROM flags f
+			CROSS JOIN environments e
+			LEFT JOIN flag_environment_status fes ON fes.flag_id = f.id AND fes.environment = e.name
+			WHERE f.archived_at IS NULL AND e.name IN ($1, $2)
+		)
+		SELECT a.flag_id, a.name, a.status AS from_status, b.status AS to_status
+		FROM states a
+		JOIN states b ON b.flag_id = a.flag_id AND b.environment = $2
+		WHERE a.environment = $1 AND a.status <> b.status
+		ORDER BY a.name`
+	diffs := []*entity.FlagStateDiff{}
+	if err := r.conn(ctx).SelectContext(ctx, &diffs, query, from, to); err != nil {
+		return nil, fmt.Errorf("failed to diff environments: %w", err)
+	}
+	return diffs, nil
+}
+
+func (r *pgEnvironmentRepository) GetFlagEnvironmentStatus(ctx context.Context, flagID int64, env string) (*entity.FlagEnvironmentStatus, error) {
+	var status entity.FlagEnvironmentStatus
+	err := r.conn(ctx).GetContext(ctx, &status, flagEnvironmentStatusQuery+` AND e.name = $2`, flagID, env)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrEnvironmentNotFound
+		}
+		return nil, fmt.Errorf("failed to get flag environment status: %w", err)
+	}
+	return &status, nil
+}
+
+func (r *pgEnvironmentRepository) ListFlagEnvironmentStatuses(ctx context.Context, flagID int64) ([]*entity.FlagEnvironmentStatus, error) {
+	var statuses []*entity.FlagEnvironmentStatus
+	err := r.conn(ctx).SelectContext(ctx, &statuses, flagEnvironmentStatusQuery+` ORDER BY e.name`, flagID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flag environment statuses: %w", err)
+	}
+	return statuses, nil
+}
+
+func (r *pgEnvironmentRepository) UpdateFlagEnvironmentStatus(ctx context.Context, flagID int64, env string, status entity.FlagStatus, expectedVersion int64, actor string) error {
+	// The first change in an environment creates the row, expecting version zero
+	query := `INSERT INTO flag_environment_status (flag_id, environment, status, updated_by)
+		VALUES ($1, $2, $3, NULLIF($5, ''))
+		ON CONFLICT (flag_id, environment) DO UPDATE
+		SET status = EXCLUDED.status, version = flag_environment_status.version + 1,
+			updated_by = EXCLUDED.updated_by, updated_at = NOW()
+		WHERE flag_environment_status.version = $4`
+	result, err := r.conn(ctx).ExecContext(ctx, query, flagID, env, status, expectedVersion, actor)
+	if err != nil {
+		return fmt.Errorf("failed to update flag environment status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrConcurrentModification
+	}
+	return nil
+}
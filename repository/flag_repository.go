@@ -5,44 +5,123 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"featureflags/entity"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 var (
-	ErrFlagNotFound      = errors.New("flag not found")
-	ErrFlagAlreadyExists = errors.New("flag already exists")
-	ErrCircularDependency = errors.New("circular dependency detected")
+	ErrFlagNotFound           = errors.New("flag not found")
+	ErrFlagAlreadyExists      = errors.New("flag already exists")
+	ErrCircularDependency     = errors.New("circular dependency detected")
+	ErrDependencyNotFound     = errors.New("dependency not found")
+	ErrConcurrentModification = errors.New("flag was modified concurrently")
+	ErrFlagArchived           = errors.New("flag is archived")
+	ErrDependencyTooDeep      = errors.New("dependency chain too deep")
 )
 
 // FlagRepository defines the interface for interacting with flag data
 type FlagRepository interface {
+	// WithinTx runs fn inside a database transaction. Repository calls made with the context
+	// passed to fn, including audit writes, are part of the transaction.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+
 	CreateFlag(ctx context.Context, flag *entity.Flag) (int64, error)
 	GetFlagByID(ctx context.Context, id int64) (*entity.Flag, error)
 	GetFlagByName(ctx context.Context, name string) (*entity.Flag, error)
 	ListFlags(ctx context.Context) ([]*entity.Flag, error)
-	UpdateFlagStatus(ctx context.Context, id int64, status entity.FlagStatus) error
+	ListFlagsPaginated(ctx context.Context, filter entity.FlagFilter, limit, offset int) ([]*entity.Flag, error)
+	CountFlags(ctx context.Context, filter entity.FlagFilter) (int, error)
+	// UpdateFlagStatus only writes if the flag is still at expectedVersion, returning
+	// ErrConcurrentModification otherwise. Every update records actor as the flag's updated_by
+	UpdateFlagStatus(ctx context.Context, id int64, status entity.FlagStatus, expectedVersion int64, actor string) error
+	SetFlagLocked(ctx context.Context, id int64, locked bool, actor string) error
+	UpdateFlagDescription(ctx context.Context, id int64, description, actor string) error
+	// RenameFlag returns ErrFlagAlreadyExists if another flag has the name
+	RenameFlag(ctx context.Context, id int64, name, actor string) error
+	UpdateFlagRollout(ctx context.Context, id int64, percentage int, actor string) error
+	// SetFlagArchived stamps archived_at when archived is true and clears it otherwise
+	SetFlagArchived(ctx context.Context, id int64, archived bool, actor string) error
+	// SetTags replaces all of the flag's tags
+	SetTags(ctx context.Context, flagID int64, tags map[string]string) error
+	GetTags(ctx context.Context, flagID int64) (map[string]string, error)
+	DeleteFlag(ctx context.Context, id int64) error
 	AddDependency(ctx context.Context, flagID, dependsOnID int64) error
+	RemoveDependency(ctx context.Context, flagID, dependsOnID int64) error
 	GetDependencies(ctx context.Context, flagID int64) ([]int64, error)
 	GetDependents(ctx context.Context, flagID int64) ([]int64, error)
 	HasCircularDependency(ctx context.Context, flagID int64, dependencyIDs []int64) (bool, error)
 	GetFlagsWithDependencies(ctx context.Context) ([]*entity.Flag, error)
+	GetFlagsByIDs(ctx context.Context, ids []int64) ([]*entity.Flag, error)
+	ListDependencyEdges(ctx context.Context) ([]*entity.DependencyEdge, error)
+	GetDependencyEdgesForFlags(ctx context.Context, ids []int64) ([]*entity.DependencyEdge, error)
+	// GetDependencyClosure returns the flags matching any of the IDs or names, along with
+	// everything they transitively depend on
+	GetDependencyClosure(ctx context.Context, ids []int64, names []string) ([]*entity.Flag, error)
+	RecordEvaluations(ctx context.Context, evaluations map[int64]time.Time) error
+	ListUnusedFlags(ctx context.Context, since time.Time) ([]*entity.Flag, error)
+	// GetFlagStats counts flags that are not archived; GeneratedAt is left for the caller
+	GetFlagStats(ctx context.Context) (*entity.FlagStats, error)
+	ListDependencyDrift(ctx context.Context) ([]*entity.DependencyDrift, error)
+	// ListExpiredFlags returns the enabled flags whose expiry is at or before now
+	ListExpiredFlags(ctx context.Context, now time.Time) ([]*entity.Flag, error)
 }
 
+// flagColumns lists the columns selected when loading a flag row
+const flagColumns = `id, name, COALESCE(description, '') AS description, status, cascade_strategy, disable_policy, high_risk, locked, rollout_percentage, version, expires_at, archived_at,
+	COALESCE(created_by, '') AS created_by, COALESCE(updated_by, '') AS updated_by, created_at, updated_at`
+
+// evaluatedFlagColumns adds the flag's last evaluation to flagColumns. It costs a lookup per
+// row, so only queries whose flags are returned to clients select it.
+const evaluatedFlagColumns = flagColumns + `,
+	(SELECT fe.last_evaluated_at FROM flag_evaluations fe WHERE fe.flag_id = flags.id) AS last_evaluated_at`
+
+// DefaultMaxDependencyDepth bounds how long a dependency chain may grow
+const DefaultMaxDependencyDepth = 100
+
 type pgFlagRepository struct {
-	db *sqlx.DB
+	db       *sqlx.DB
+	maxDepth int
 }
 
-func NewFlagRepository(db *sqlx.DB) FlagRepository {
-	return &pgFlagRepository{db: db}
+// FlagRepositoryOption configures optional flag repository behaviour
+type FlagRepositoryOption func(*pgFlagRepository)
+
+// WithMaxDependencyDepth sets the longest dependency chain a new dependency may create.
+// Adding a dependency that would make a longer chain fails with ErrDependencyTooDeep.
+func WithMaxDependencyDepth(depth int) FlagRepositoryOption {
+	return func(r *pgFlagRepository) {
+		if depth > 0 {
+			r.maxDepth = depth
+		}
+	}
+}
+
+func NewFlagRepository(db *sqlx.DB, opts ...FlagRepositoryOption) FlagRepository {
+	r := &pgFlagRepository{db: db, maxDepth: DefaultMaxDependencyDepth}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// conn returns the transaction carried by ctx, or the shared connection pool
+func (r *pgFlagRepository) conn(ctx context.Context) queryer {
+	return connFromContext(ctx, r.db)
+}
+
+func (r *pgFlagRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, r.db, fn)
 }
 
 func (r *pgFlagRepository) CreateFlag(ctx context.Context, flag *entity.Flag) (int64, error) {
 	// Check if flag with same name already exists
 	var count int
-	err := r.db.GetContext(ctx, &count, "SELECT COUNT(*) FROM flags WHERE name = $1", flag.Name)
+	err := r.conn(ctx).GetContext(ctx, &count, "SELECT COUNT(*) FROM flags WHERE name = $1", flag.Name)
 	if err != nil {
 		return 0, fmt.Errorf("failed to check flag existence: %w", err)
 	}
@@ -50,9 +129,21 @@ func (r *pgFlagRepository) CreateFlag(ctx context.Context, flag *entity.Flag) (i
 		return 0, ErrFlagAlreadyExists
 	}
 
-	query := `INSERT INTO flags (name, status) VALUES ($1, $2) RETURNING id`
+	cascadeStrategy := flag.CascadeStrategy
+	if cascadeStrategy == "" {
+		cascadeStrategy = entity.CascadeDisable
+	}
+
+	disablePolicy := flag.DisablePolicy
+	if disablePolicy == "" {
+		disablePolicy = entity.DisablePolicyCascade
+	}
+
+	query := `INSERT INTO flags (name, description, status, cascade_strategy, disable_policy, high_risk, rollout_percentage, expires_at, created_by, updated_by)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, '')) RETURNING id`
 	var flagID int64
-	err = r.db.QueryRowContext(ctx, query, flag.Name, flag.Status).Scan(&flagID)
+	err = r.conn(ctx).QueryRowContext(ctx, query, flag.Name, flag.Description, flag.Status, cascadeStrategy, disablePolicy,
+		flag.HighRisk, flag.RolloutPercentage, flag.ExpiresAt, flag.CreatedBy, flag.UpdatedBy).Scan(&flagID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create flag: %w", err)
 	}
@@ -61,8 +152,8 @@ func (r *pgFlagRepository) CreateFlag(ctx context.Context, flag *entity.Flag) (i
 
 func (r *pgFlagRepository) GetFlagByID(ctx context.Context, id int64) (*entity.Flag, error) {
 	var flag entity.Flag
-	query := `SELECT id, name, status, created_at, updated_at FROM flags WHERE id = $1`
-	err := r.db.GetContext(ctx, &flag, query, id)
+	query := `SELECT ` + evaluatedFlagColumns + ` FROM flags WHERE id = $1`
+	err := r.conn(ctx).GetContext(ctx, &flag, query, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrFlagNotFound
@@ -76,14 +167,18 @@ func (r *pgFlagRepository) GetFlagByID(ctx context.Context, id int64) (*entity.F
 		return nil, fmt.Errorf("failed to load dependencies: %w", err)
 	}
 	flag.Dependencies = dependencies
-	
+
+	if flag.Tags, err = r.GetTags(ctx, flag.ID); err != nil {
+		return nil, err
+	}
+
 	return &flag, nil
 }
 
 func (r *pgFlagRepository) GetFlagByName(ctx context.Context, name string) (*entity.Flag, error) {
 	var flag entity.Flag
-	query := `SELECT id, name, status, created_at, updated_at FROM flags WHERE name = $1`
-	err := r.db.GetContext(ctx, &flag, query, name)
+	query := `SELECT ` + evaluatedFlagColumns + ` FROM flags WHERE name = $1`
+	err := r.conn(ctx).GetContext(ctx, &flag, query, name)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrFlagNotFound
@@ -97,45 +192,244 @@ func (r *pgFlagRepository) GetFlagByName(ctx context.Context, name string) (*ent
 		return nil, fmt.Errorf("failed to load dependencies: %w", err)
 	}
 	flag.Dependencies = dependencies
-	
+
+	if flag.Tags, err = r.GetTags(ctx, flag.ID); err != nil {
+		return nil, err
+	}
+
 	return &flag, nil
 }
 
 func (r *pgFlagRepository) ListFlags(ctx context.Context) ([]*entity.Flag, error) {
 	var flags []*entity.Flag
-	query := `SELECT id, name, status, created_at, updated_at FROM flags ORDER BY name`
-	err := r.db.SelectContext(ctx, &flags, query)
+	query := `SELECT ` + flagColumns + ` FROM flags ORDER BY name`
+	err := r.conn(ctx).SelectContext(ctx, &flags, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list flags: %w", err)
 	}
 	return flags, nil
 }
 
-func (r *pgFlagRepository) GetFlagsWithDependencies(ctx context.Context) ([]*entity.Flag, error) {
-	flags, err := r.ListFlags(ctx)
+// ListFlagsPaginated returns one page of the flags matching filter, ordered by name, with
+// dependencies and tags loaded
+func (r *pgFlagRepository) ListFlagsPaginated(ctx context.Context, filter entity.FlagFilter, limit, offset int) ([]*entity.Flag, error) {
+	var flags []*entity.Flag
+	where, args := flagFilterClause(filter)
+	query := fmt.Sprintf(`SELECT %s FROM flags%s ORDER BY name LIMIT $%d OFFSET $%d`,
+		evaluatedFlagColumns, where, len(args)+1, len(args)+2)
+	err := r.conn(ctx).SelectContext(ctx, &flags, query, append(args, limit, offset)...)
 	if err != nil {
+		return nil, fmt.Errorf("failed to list flags: %w", err)
+	}
+	if err := r.loadDependencies(ctx, flags); err != nil {
 		return nil, err
 	}
-	
-	// Load dependencies for each flag
+	return flags, r.loadTags(ctx, flags)
+}
+
+// flagFilterClause builds the WHERE clause selecting the flags that match filter, with
+// placeholders numbered from $1
+func flagFilterClause(filter entity.FlagFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if !filter.IncludeArchived {
+		conditions = append(conditions, "archived_at IS NULL")
+	}
+	if filter.ExpiringBefore != nil {
+		args = append(args, *filter.ExpiringBefore)
+		conditions = append(conditions, fmt.Sprintf("expires_at < $%d", len(args)))
+	}
+	for _, tag := range filter.Tags {
+		args = append(args, tag.Key, tag.Value)
+		conditions = append(conditions, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM flag_tags ft WHERE ft.flag_id = flags.id AND ft.key = $%d AND ft.value = $%d)",
+			len(args)-1, len(args)))
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// loadDependencies fills in the dependencies of the given flags with a single query
+func (r *pgFlagRepository) loadDependencies(ctx context.Context, flags []*entity.Flag) error {
+	if len(flags) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(flags))
+	for i, flag := range flags {
+		ids[i] = flag.ID
+	}
+
+	var edges []*entity.DependencyEdge
+	query := `
+		SELECT flag_id, depends_on_id
+		FROM flag_dependencies
+		WHERE flag_id = ANY($1)
+		ORDER BY flag_id, depends_on_id
+	`
+	if err := r.conn(ctx).SelectContext(ctx, &edges, query, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to load dependencies: %w", err)
+	}
+	attachDependencies(flags, edges)
+	return nil
+}
+
+// attachDependencies sets each flag's dependencies from edges, keeping the edges' order
+func attachDependencies(flags []*entity.Flag, edges []*entity.DependencyEdge) {
+	byFlag := make(map[int64][]int64, len(flags))
+	for _, edge := range edges {
+		byFlag[edge.FlagID] = append(byFlag[edge.FlagID], edge.DependsOnID)
+	}
 	for _, flag := range flags {
-		dependencies, err := r.GetDependencies(ctx, flag.ID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load dependencies for flag %d: %w", flag.ID, err)
+		flag.Dependencies = byFlag[flag.ID]
+	}
+}
+
+func (r *pgFlagRepository) CountFlags(ctx context.Context, filter entity.FlagFilter) (int, error) {
+	var count int
+	where, args := flagFilterClause(filter)
+	if err := r.conn(ctx).GetContext(ctx, &count, `SELECT COUNT(*) FROM flags`+where, args...); err != nil {
+		return 0, fmt.Errorf("failed to count flags: %w", err)
+	}
+	return count, nil
+}
+
+func (r *pgFlagRepository) SetTags(ctx context.Context, flagID int64, tags map[string]string) error {
+	return r.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM flag_tags WHERE flag_id = $1`, flagID); err != nil {
+			return fmt.Errorf("failed to clear tags: %w", err)
+		}
+		for key, value := range tags {
+			query := `INSERT INTO flag_tags (flag_id, key, value) VALUES ($1, $2, $3)`
+			if _, err := r.conn(ctx).ExecContext(ctx, query, flagID, key, value); err != nil {
+				return fmt.Errorf("failed to add tag %s: %w", key, err)
+			}
 		}
-		flag.Dependencies = dependencies
+		return r.touchFlags(ctx, []int64{flagID})
+	})
+}
+
+// touchFlags moves the updated_at of flags whose tags or dependencies changed, since those
+// live in their own tables
+func (r *pgFlagRepository) touchFlags(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
 	}
-	
+	query := `UPDATE flags SET updated_at = NOW() WHERE id = ANY($1)`
+	if _, err := r.conn(ctx).ExecContext(ctx, query, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to update flag timestamps: %w", err)
+	}
+	return nil
+}
+
+func (r *pgFlagRepository) GetTags(ctx context.Context, flagID int64) (map[string]string, error) {
+	var tags []*flagTagRow
+	query := `SELECT flag_id, key, value FROM flag_tags WHERE flag_id = $1`
+	if err := r.conn(ctx).SelectContext(ctx, &tags, query, flagID); err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	return tagsByFlag(tags)[flagID], nil
+}
+
+// flagTagRow is one row of flag_tags
+type flagTagRow struct {
+	FlagID int64  `db:"flag_id"`
+	Key    string `db:"key"`
+	Value  string `db:"value"`
+}
+
+func tagsByFlag(rows []*flagTagRow) map[int64]map[string]string {
+	byFlag := make(map[int64]map[string]string)
+	for _, row := range rows {
+		if byFlag[row.FlagID] == nil {
+			byFlag[row.FlagID] = make(map[string]string)
+		}
+		byFlag[row.FlagID][row.Key] = row.Value
+	}
+	return byFlag
+}
+
+// loadTags fills in the tags of the given flags with a single query
+func (r *pgFlagRepository) loadTags(ctx context.Context, flags []*entity.Flag) error {
+	if len(flags) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(flags))
+	for i, flag := range flags {
+		ids[i] = flag.ID
+	}
+
+	var rows []*flagTagRow
+	query := `SELECT flag_id, key, value FROM flag_tags WHERE flag_id = ANY($1)`
+	if err := r.conn(ctx).SelectContext(ctx, &rows, query, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to load tags: %w", err)
+	}
+	byFlag := tagsByFlag(rows)
+	for _, flag := range flags {
+		flag.Tags = byFlag[flag.ID]
+	}
+	return nil
+}
+
+func (r *pgFlagRepository) GetFlagsWithDependencies(ctx context.Context) ([]*entity.Flag, error) {
+	flags, err := r.ListFlags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every flag is listed, so load all edges at once rather than one query per flag
+	edges, err := r.ListDependencyEdges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	attachDependencies(flags, edges)
+
 	return flags, nil
 }
 
-func (r *pgFlagRepository) UpdateFlagStatus(ctx context.Context, id int64, status entity.FlagStatus) error {
-	query := `UPDATE flags SET status = $1, updated_at = NOW() WHERE id = $2`
-	result, err := r.db.ExecContext(ctx, query, status, id)
+func (r *pgFlagRepository) UpdateFlagStatus(ctx context.Context, id int64, status entity.FlagStatus, expectedVersion int64, actor string) error {
+	// An archived flag stays disabled until it is restored
+	query := `UPDATE flags SET status = $1, version = version + 1, updated_at = NOW(), updated_by = NULLIF($4, '')
+		WHERE id = $2 AND version = $3 AND (archived_at IS NULL OR $1 = $5)`
+	result, err := r.conn(ctx).ExecContext(ctx, query, status, id, expectedVersion, actor, entity.FlagDisabled)
 	if err != nil {
 		return fmt.Errorf("failed to update flag status: %w", err)
 	}
 	
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		// Tell a deleted or archived flag apart from one changed since it was read
+		var archived []bool
+		if err := r.conn(ctx).SelectContext(ctx, &archived, `SELECT archived_at IS NOT NULL FROM flags WHERE id = $1`, id); err != nil {
+			return fmt.Errorf("failed to check flag existence: %w", err)
+		}
+		if len(archived) == 0 {
+			return ErrFlagNotFound
+		}
+		if archived[0] && status != entity.FlagDisabled {
+			return ErrFlagArchived
+		}
+		return ErrConcurrentModification
+	}
+
+	return nil
+}
+
+func (r *pgFlagRepository) UpdateFlagDescription(ctx context.Context, id int64, description, actor string) error {
+	query := `UPDATE flags SET description = NULLIF($1, ''), updated_at = NOW(), updated_by = NULLIF($3, '') WHERE id = $2`
+	result, err := r.conn(ctx).ExecContext(ctx, query, description, id, actor)
+	if err != nil {
+		return fmt.Errorf("failed to update flag description: %w", err)
+	}
+
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to check rows affected: %w", err)
@@ -143,23 +437,152 @@ func (r *pgFlagRepository) UpdateFlagStatus(ctx context.Context, id int64, statu
 	if rowsAffected == 0 {
 		return ErrFlagNotFound
 	}
-	
+
 	return nil
 }
 
-func (r *pgFlagRepository) AddDependency(ctx context.Context, flagID, dependsOnID int64) error {
-	query := `INSERT INTO flag_dependencies (flag_id, depends_on_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
-	_, err := r.db.ExecContext(ctx, query, flagID, dependsOnID)
+func (r *pgFlagRepository) RenameFlag(ctx context.Context, id int64, name, actor string) error {
+	query := `UPDATE flags SET name = $1, updated_at = NOW(), updated_by = NULLIF($3, '') WHERE id = $2`
+	result, err := r.conn(ctx).ExecContext(ctx, query, name, id, actor)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+			return ErrFlagAlreadyExists
+		}
+		return fmt.Errorf("failed to rename flag: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to add dependency: %w", err)
+		return fmt.Errorf("failed to check rows affected: %w", err)
 	}
+	if rowsAffected == 0 {
+		return ErrFlagNotFound
+	}
+
 	return nil
 }
 
+func (r *pgFlagRepository) UpdateFlagRollout(ctx context.Context, id int64, percentage int, actor string) error {
+	query := `UPDATE flags SET rollout_percentage = $1, updated_at = NOW(), updated_by = NULLIF($3, '') WHERE id = $2`
+	result, err := r.conn(ctx).ExecContext(ctx, query, percentage, id, actor)
+	if err != nil {
+		return fmt.Errorf("failed to update flag rollout: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrFlagNotFound
+	}
+
+	return nil
+}
+
+func (r *pgFlagRepository) SetFlagLocked(ctx context.Context, id int64, locked bool, actor string) error {
+	query := `UPDATE flags SET locked = $1, version = version + 1, updated_at = NOW(), updated_by = NULLIF($3, '') WHERE id = $2`
+	result, err := r.conn(ctx).ExecContext(ctx, query, locked, id, actor)
+	if err != nil {
+		return fmt.Errorf("failed to update flag lock: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrFlagNotFound
+	}
+
+	return nil
+}
+
+func (r *pgFlagRepository) SetFlagArchived(ctx context.Context, id int64, archived bool, actor string) error {
+	query := `UPDATE flags SET archived_at = CASE WHEN $1 THEN NOW() END, version = version + 1, updated_at = NOW(),
+		updated_by = NULLIF($3, '') WHERE id = $2`
+	result, err := r.conn(ctx).ExecContext(ctx, query, archived, id, actor)
+	if err != nil {
+		return fmt.Errorf("failed to update flag archive state: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrFlagNotFound
+	}
+
+	return nil
+}
+
+// DeleteFlag removes a flag together with its dependency edges in both directions, moving the
+// updated_at of the flags that depended on it
+func (r *pgFlagRepository) DeleteFlag(ctx context.Context, id int64) error {
+	dependents, err := r.GetDependents(ctx, id)
+	if err != nil {
+		return err
+	}
+	query := `DELETE FROM flag_dependencies WHERE flag_id = $1 OR depends_on_id = $1`
+	if _, err := r.conn(ctx).ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to delete flag dependencies: %w", err)
+	}
+	if err := r.touchFlags(ctx, dependents); err != nil {
+		return err
+	}
+
+	result, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM flags WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete flag: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrFlagNotFound
+	}
+	
+	return nil
+}
+
+func (r *pgFlagRepository) AddDependency(ctx context.Context, flagID, dependsOnID int64) error {
+	return r.WithinTx(ctx, func(ctx context.Context) error {
+		query := `INSERT INTO flag_dependencies (flag_id, depends_on_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+		_, err := r.conn(ctx).ExecContext(ctx, query, flagID, dependsOnID)
+		if err != nil {
+			return fmt.Errorf("failed to add dependency: %w", err)
+		}
+		return r.touchFlags(ctx, []int64{flagID})
+	})
+}
+
+// RemoveDependency deletes a single dependency edge, returning ErrDependencyNotFound if it does not exist
+func (r *pgFlagRepository) RemoveDependency(ctx context.Context, flagID, dependsOnID int64) error {
+	return r.WithinTx(ctx, func(ctx context.Context) error {
+		query := `DELETE FROM flag_dependencies WHERE flag_id = $1 AND depends_on_id = $2`
+		result, err := r.conn(ctx).ExecContext(ctx, query, flagID, dependsOnID)
+		if err != nil {
+			return fmt.Errorf("failed to remove dependency: %w", err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to remove dependency: %w", err)
+		}
+		if rows == 0 {
+			return ErrDependencyNotFound
+		}
+		return r.touchFlags(ctx, []int64{flagID})
+	})
+}
+
 func (r *pgFlagRepository) GetDependencies(ctx context.Context, flagID int64) ([]int64, error) {
 	var dependencyIDs []int64
 	query := `SELECT depends_on_id FROM flag_dependencies WHERE flag_id = $1 ORDER BY depends_on_id`
-	err := r.db.SelectContext(ctx, &dependencyIDs, query, flagID)
+	err := r.conn(ctx).SelectContext(ctx, &dependencyIDs, query, flagID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get dependencies: %w", err)
 	}
@@ -169,44 +592,266 @@ func (r *pgFlagRepository) GetDependencies(ctx context.Context, flagID int64) ([
 func (r *pgFlagRepository) GetDependents(ctx context.Context, flagID int64) ([]int64, error) {
 	var dependentIDs []int64
 	query := `SELECT flag_id FROM flag_dependencies WHERE depends_on_id = $1 ORDER BY flag_id`
-	err := r.db.SelectContext(ctx, &dependentIDs, query, flagID)
+	err := r.conn(ctx).SelectContext(ctx, &dependentIDs, query, flagID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get dependents: %w", err)
 	}
 	return dependentIDs, nil
 }
 
+// HasCircularDependency reports whether depending on dependencyIDs would make flagID reachable
+// from itself. It fails with ErrDependencyTooDeep if the new dependencies would create a chain
+// longer than the configured maximum depth.
 func (r *pgFlagRepository) HasCircularDependency(ctx context.Context, flagID int64, dependencyIDs []int64) (bool, error) {
-	// For each proposed dependency, check if it would create a cycle
-	for _, depID := range dependencyIDs {
-		// Use recursive CTE to check if flagID is reachable from depID
-		query := `
-			WITH RECURSIVE dependency_path AS (
-				-- Base case: direct dependencies of depID
-				SELECT depends_on_id as id, 1 as depth
-				FROM flag_dependencies 
-				WHERE flag_id = $1
-				
-				UNION ALL
-				
-				-- Recursive case: follow the dependency chain
-				SELECT fd.depends_on_id, dp.depth + 1
-				FROM flag_dependencies fd
-				JOIN dependency_path dp ON fd.flag_id = dp.id
-				WHERE dp.depth < 10 -- Prevent infinite recursion
-			)
-			SELECT 1 FROM dependency_path WHERE id = $2 LIMIT 1
-		`
-		
-		var exists int
-		err := r.db.QueryRowContext(ctx, query, depID, flagID).Scan(&exists)
-		if err != nil && !errors.Is(err, sql.ErrNoRows) {
-			return false, fmt.Errorf("failed to check circular dependency: %w", err)
-		}
-		if exists == 1 {
-			return true, nil
-		}
+	if len(dependencyIDs) == 0 {
+		return false, nil
+	}
+
+	// Collect every flag reachable from the proposed dependencies. UNION keeps each flag once,
+	// so the walk visits every flag at most once and ends even on a cycle already stored.
+	cycleQuery := `
+		WITH RECURSIVE reachable AS (
+			SELECT id FROM unnest($1::bigint[]) AS id
+
+			UNION
+
+			SELECT fd.depends_on_id
+			FROM flag_dependencies fd
+			JOIN reachable r ON fd.flag_id = r.id
+		)
+		SELECT EXISTS(SELECT 1 FROM reachable WHERE id = $2)
+	`
+	var circular bool
+	if err := r.conn(ctx).GetContext(ctx, &circular, cycleQuery, pq.Array(dependencyIDs), flagID); err != nil {
+		return false, fmt.Errorf("failed to check circular dependency: %w", err)
+	}
+	if circular {
+		return true, nil
+	}
+
+	// The flag sits one level above its dependencies. Each (flag, depth) pair is kept once
+	// and the depth is bounded, so the walk stays small however many paths reach a flag.
+	depthQuery := `
+		WITH RECURSIVE chain AS (
+			SELECT id, 1 AS depth FROM unnest($1::bigint[]) AS id
+
+			UNION
+
+			SELECT fd.depends_on_id, c.depth + 1
+			FROM flag_dependencies fd
+			JOIN chain c ON fd.flag_id = c.id
+			WHERE c.depth <= $2
+		)
+		SELECT COALESCE(MAX(depth), 0) FROM chain
+	`
+	var depth int
+	if err := r.conn(ctx).GetContext(ctx, &depth, depthQuery, pq.Array(dependencyIDs), r.maxDepth); err != nil {
+		return false, fmt.Errorf("failed to check dependency depth: %w", err)
+	}
+	if depth > r.maxDepth {
+		return false, fmt.Errorf("%w: longer than %d levels", ErrDependencyTooDeep, r.maxDepth)
 	}
-	
 	return false, nil
-} 
\ No newline at end of file
+}
+
+// GetFlagsByIDs loads the given flags in a single query, ordered by name. Dependencies are not loaded.
+func (r *pgFlagRepository) GetFlagsByIDs(ctx context.Context, ids []int64) ([]*entity.Flag, error) {
+	var flags []*entity.Flag
+	if len(ids) == 0 {
+		return flags, nil
+	}
+	query := `SELECT ` + flagColumns + ` FROM flags WHERE id = ANY($1) ORDER BY name`
+	err := r.conn(ctx).SelectContext(ctx, &flags, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get flags by IDs: %w", err)
+	}
+	return flags, nil
+}
+
+// ListDependencyEdges returns every dependency edge in a single scan
+func (r *pgFlagRepository) ListDependencyEdges(ctx context.Context) ([]*entity.DependencyEdge, error) {
+	var edges []*entity.DependencyEdge
+	query := `SELECT flag_id, depends_on_id FROM flag_dependencies ORDER BY flag_id, depends_on_id`
+	err := r.conn(ctx).SelectContext(ctx, &edges, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dependency edges: %w", err)
+	}
+	return edges, nil
+}
+
+// GetDependencyEdgesForFlags returns all edges touching any of the given flags, in either direction
+func (r *pgFlagRepository) GetDependencyEdgesForFlags(ctx context.Context, ids []int64) ([]*entity.DependencyEdge, error) {
+	var edges []*entity.DependencyEdge
+	if len(ids) == 0 {
+		return edges, nil
+	}
+	query := `
+		SELECT flag_id, depends_on_id
+		FROM flag_dependencies
+		WHERE flag_id = ANY($1) OR depends_on_id = ANY($1)
+		ORDER BY flag_id, depends_on_id
+	`
+	err := r.conn(ctx).SelectContext(ctx, &edges, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dependency edges: %w", err)
+	}
+	return edges, nil
+}
+
+func (r *pgFlagRepository) GetDependencyClosure(ctx context.Context, ids []int64, names []string) ([]*entity.Flag, error) {
+	var flags []*entity.Flag
+	if len(ids) == 0 && len(names) == 0 {
+		return flags, nil
+	}
+	// UNION keeps each flag once, so the walk ends even on a cycle already stored
+	query := `
+		WITH RECURSIVE closure AS (
+			SELECT id FROM flags WHERE id = ANY($1) OR name = ANY($2)
+
+			UNION
+
+			SELECT fd.depends_on_id
+			FROM flag_dependencies fd
+			JOIN closure c ON fd.flag_id = c.id
+		)
+		SELECT ` + flagColumns + ` FROM flags WHERE id IN (SELECT id FROM closure) ORDER BY name
+	`
+	err := r.conn(ctx).SelectContext(ctx, &flags, query, pq.Array(ids), pq.Array(names))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dependency closure: %w", err)
+	}
+	return flags, nil
+}
+
+// RecordEvaluations stores the last evaluation time of each flag in one statement. Flags
+// deleted in the meantime are skipped and timestamps never move backwards.
+func (r *pgFlagRepository) RecordEvaluations(ctx context.Context, evaluations map[int64]time.Time) error {
+	if len(evaluations) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(evaluations))
+	times := make([]string, 0, len(evaluations))
+	for id, at := range evaluations {
+		ids = append(ids, id)
+		times = append(times, at.UTC().Format(time.RFC3339Nano))
+	}
+
+	query := `
+		INSERT INTO flag_evaluations (flag_id, last_evaluated_at)
+		SELECT e.flag_id, e.evaluated_at
+		FROM unnest($1::bigint[], $2::timestamptz[]) AS e(flag_id, evaluated_at)
+		WHERE EXISTS (SELECT 1 FROM flags WHERE id = e.flag_id)
+		ON CONFLICT (flag_id) DO UPDATE
+		SET last_evaluated_at = GREATEST(flag_evaluations.last_evaluated_at, EXCLUDED.last_evaluated_at)
+	`
+	_, err := r.conn(ctx).ExecContext(ctx, query, pq.Array(ids), pq.Array(times))
+	if err != nil {
+		return fmt.Errorf("failed to record evaluations: %w", err)
+	}
+	return nil
+}
+
+// ListExpiredFlags returns enabled flags whose expiry is at or before now, oldest expiry first
+func (r *pgFlagRepository) ListExpiredFlags(ctx context.Context, now time.Time) ([]*entity.Flag, error) {
+	var flags []*entity.Flag
+	query := `SELECT ` + flagColumns + ` FROM flags WHERE status = $1 AND expires_at <= $2 ORDER BY expires_at, id`
+	err := r.conn(ctx).SelectContext(ctx, &flags, query, entity.FlagEnabled, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired flags: %w", err)
+	}
+	return flags, nil
+}
+
+// ListUnusedFlags returns flags created before since that have not been evaluated since then,
+// ordered by name. Dependencies are not loaded.
+func (r *pgFlagRepository) ListUnusedFlags(ctx context.Context, since time.Time) ([]*entity.Flag, error) {
+	var flags []*entity.Flag
+	query := `
+		SELECT ` + evaluatedFlagColumns + ` FROM flags
+		WHERE created_at < $1
+		AND NOT EXISTS (
+			SELECT 1 FROM flag_evaluations fe WHERE fe.flag_id = flags.id AND fe.last_evaluated_at >= $1
+		)
+		ORDER BY name
+	`
+	err := r.conn(ctx).SelectContext(ctx, &flags, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unused flags: %w", err)
+	}
+	return flags, nil
+}
+
+func (r *pgFlagRepository) GetFlagStats(ctx context.Context) (*entity.FlagStats, error) {
+	var stats entity.FlagStats
+	query := `
+		SELECT
+			COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE f.status = $1) AS enabled,
+			COUNT(*) FILTER (WHERE f.status = $2) AS disabled,
+			COUNT(*) FILTER (WHERE f.status = $3) AS maintenance,
+			COUNT(*) FILTER (WHERE EXISTS (SELECT 1 FROM flag_dependencies fd WHERE fd.flag_id = f.id)) AS with_dependencies,
+			COUNT(*) FILTER (WHERE NOT EXISTS (SELECT 1 FROM flag_dependencies fd WHERE fd.depends_on_id = f.id)) AS leaf_flags
+		FROM flags f
+		WHERE f.archived_at IS NULL
+	`
+	err := r.conn(ctx).GetContext(ctx, &stats, query, entity.FlagEnabled, entity.FlagDisabled, entity.FlagMaintenance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count flags: %w", err)
+	}
+
+	// UNION rather than UNION ALL keeps one row per (flag, depth), so shared dependencies
+	// are not walked once per path
+	depthQuery := `
+		WITH RECURSIVE chain AS (
+			SELECT fd.depends_on_id AS id, 1 AS depth
+			FROM flag_dependencies fd
+			JOIN flags f ON f.id = fd.flag_id
+			WHERE f.archived_at IS NULL
+
+			UNION
+
+			SELECT fd.depends_on_id, c.depth + 1
+			FROM flag_dependencies fd
+			JOIN chain c ON fd.flag_id = c.id
+			WHERE c.depth < $1
+		)
+		SELECT COALESCE(MAX(depth), 0) FROM chain
+	`
+	if err := r.conn(ctx).GetContext(ctx, &stats.MaxDependencyDepth, depthQuery, r.maxDepth); err != nil {
+		return nil, fmt.Errorf("failed to measure dependency depth: %w", err)
+	}
+	return &stats, nil
+}
+
+// ListDependencyDrift returns enabled, unarchived flags that have at least one dependency
+// which is not enabled, ordered by flag name
+func (r *pgFlagRepository) ListDependencyDrift(ctx context.Context) ([]*entity.DependencyDrift, error) {
+	var rows []struct {
+		FlagID       int64          `db:"flag_id"`
+		FlagName     string         `db:"flag_name"`
+		Dependencies pq.StringArray `db:"dependencies"`
+	}
+	query := `
+		SELECT f.id AS flag_id, f.name AS flag_name, array_agg(d.name ORDER BY d.name) AS dependencies
+		FROM flags f
+		JOIN flag_dependencies fd ON fd.flag_id = f.id
+		JOIN flags d ON d.id = fd.depends_on_id
+		WHERE f.status = $1 AND d.status <> $1 AND f.archived_at IS NULL
+		GROUP BY f.id, f.name
+		ORDER BY f.name
+	`
+	if err := r.conn(ctx).SelectContext(ctx, &rows, query, entity.FlagEnabled); err != nil {
+		return nil, fmt.Errorf("failed to list dependency drift: %w", err)
+	}
+
+	drifts := make([]*entity.DependencyDrift, 0, len(rows))
+	for _, row := range rows {
+		drifts = append(drifts, &entity.DependencyDrift{
+			FlagID:                  row.FlagID,
+			FlagName:                row.FlagName,
+			UnsatisfiedDependencies: row.Dependencies,
+		})
+	}
+	return drifts, nil
+}
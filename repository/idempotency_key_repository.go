@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"featureflags/entity"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+	ErrIdempotencyKeyExists   = errors.New("idempotency key already exists")
+)
+
+// IdempotencyKeyRepository stores the outcome of requests sent with an idempotency key.
+// Keys created before the given cutoff are treated as expired.
+type IdempotencyKeyRepository interface {
+	GetIdempotencyKey(ctx context.Context, key string, notBefore time.Time) (*entity.IdempotencyKey, error)
+	// CreateIdempotencyKey stores key, replacing an expired entry, or returns
+	// ErrIdempotencyKeyExists if a live one is stored already
+	CreateIdempotencyKey(ctx context.Context, key *entity.IdempotencyKey, notBefore time.Time) error
+	DeleteIdempotencyKeysBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type pgIdempotencyKeyRepository struct {
+	db *sqlx.DB
+}
+
+func NewIdempotencyKeyRepository(db *sqlx.DB) IdempotencyKeyRepository {
+	return &pgIdempotencyKeyRepository{db: db}
+}
+
+// conn returns the transaction carried by ctx, or the shared connection pool
+func (r *pgIdempotencyKeyRepository) conn(ctx context.Context) queryer {
+	return connFromContext(ctx, r.db)
+}
+
+func (r *pgIdempotencyKeyRepository) GetIdempotencyKey(ctx context.Context, key string, notBefore time.Time) (*entity.IdempotencyKey, error) {
+	var stored entity.IdempotencyKey
+	query := `SELECT key, flag_id, request_hash, response, created_at FROM idempotency_keys WHERE key = $1 AND created_at >= $2`
+	err := r.conn(ctx).GetContext(ctx, &stored, query, key, notBefore)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrIdempotencyKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	return &stored, nil
+}
+
+func (r *pgIdempotencyKeyRepository) CreateIdempotencyKey(ctx context.Context, key *entity.IdempotencyKey, notBefore time.Time) error {
+	query := `INSERT INTO idempotency_keys (key, flag_id, request_hash, response) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE
+		SET flag_id = EXCLUDED.flag_id, request_hash = EXCLUDED.request_hash, response = EXCLUDED.response, created_at = NOW()
+		WHERE idempotency_keys.created_at < $5
+		RETURNING created_at`
+	err := r.conn(ctx).QueryRowContext(ctx, query, key.Key, key.FlagID, key.RequestHash, []byte(key.Response), notBefore).Scan(&key.CreatedAt)
+	if err != nil {
+		// The conflicting row was live, so nothing was written
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrIdempotencyKeyExists
+		}
+		return fmt.Errorf("failed to create idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (r *pgIdempotencyKeyRepository) DeleteIdempotencyKeysBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete idempotency keys: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"featureflags/entity"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PendingCascadeRepository stores cascades deferred by the cascade grace period
+type PendingCascadeRepository interface {
+	// CreatePendingCascade schedules a cascade; an existing pending cascade for the flag is kept
+	CreatePendingCascade(ctx context.Context, pending *entity.PendingCascade) error
+	ListDuePendingCascades(ctx context.Context, now time.Time) ([]*entity.PendingCascade, error)
+	ResolvePendingCascade(ctx context.Context, id int64, status entity.PendingCascadeStatus) error
+	// CancelPendingCascades cancels the flag's pending cascade and reports whether there was one
+	CancelPendingCascades(ctx context.Context, flagID int64) (bool, error)
+}
+
+type pgPendingCascadeRepository struct {
+	db *sqlx.DB
+}
+
+func NewPendingCascadeRepository(db *sqlx.DB) PendingCascadeRepository {
+	return &pgPendingCascadeRepository{db: db}
+}
+
+// conn returns the transaction carried by ctx, or the shared connection pool
+func (r *pgPendingCascadeRepository) conn(ctx context.Context) queryer {
+	return connFromContext(ctx, r.db)
+}
+
+const pendingCascadeColumns = `id, flag_id, status, due_at, created_at, resolved_at`
+
+func (r *pgPendingCascadeRepository) CreatePendingCascade(ctx context.Context, pending *entity.PendingCascade) error {
+	query := `
+		INSERT INTO pending_cascades (flag_id, status, due_at) VALUES ($1, $2, $3)
+		ON CONFLICT (flag_id) WHERE status = 'pending' DO NOTHING
+	`
+	_, err := r.conn(ctx).ExecContext(ctx, query, pending.FlagID, pending.Status, pending.DueAt)
+	if err != nil {
+		return fmt.Errorf("failed to create pending cascade: %w", err)
+	}
+	return nil
+}
+
+func (r *pgPendingCascadeRepository) ListDuePendingCascades(ctx context.Context, now time.Time) ([]*entity.PendingCascade, error) {
+	var pendings []*entity.PendingCascade
+	query := `SELECT ` + pendingCascadeColumns + ` FROM pending_cascades WHERE status = $1 AND due_at <= $2 ORDER BY due_at`
+	err := r.conn(ctx).SelectContext(ctx, &pendings, query, entity.PendingCascadePending, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due pending cascades: %w", err)
+	}
+	return pendings, nil
+}
+
+func (r *pgPendingCascadeRepository) ResolvePendingCascade(ctx context.Context, id int64, status entity.PendingCascadeStatus) error {
+	query := `UPDATE pending_cascades SET status = $1, resolved_at = NOW() WHERE id = $2 AND status = $3`
+	_, err := r.conn(ctx).ExecContext(ctx, query, status, id, entity.PendingCascadePending)
+	if err != nil {
+		return fmt.Errorf("failed to resolve pending cascade: %w", err)
+	}
+	return nil
+}
+
+func (r *pgPendingCascadeRepository) CancelPendingCascades(ctx context.Context, flagID int64) (bool, error) {
+	query := `UPDATE pending_cascades SET status = $1, resolved_at = NOW() WHERE flag_id = $2 AND status = $3`
+	result, err := r.conn(ctx).ExecContext(ctx, query, entity.PendingCascadeCancelled, flagID, entity.PendingCascadePending)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel pending cascades: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
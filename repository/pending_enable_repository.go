@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"featureflags/entity"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrPendingEnableNotFound = errors.New("pending enable not found")
+
+// PendingEnableRepository stores enable-when-ready intents
+type PendingEnableRepository interface {
+	CreatePendingEnable(ctx context.Context, pending *entity.PendingEnable) (int64, error)
+	GetPendingEnableByID(ctx context.Context, id int64) (*entity.PendingEnable, error)
+	GetActivePendingEnableByFlagID(ctx context.Context, flagID int64) (*entity.PendingEnable, error)
+	ListActivePendingEnables(ctx context.Context) ([]*entity.PendingEnable, error)
+	RecordPendingEnableAttempt(ctx context.Context, id int64) error
+	ResolvePendingEnable(ctx context.Context, id int64, status entity.PendingEnableStatus) error
+}
+
+type pgPendingEnableRepository struct {
+	db *sqlx.DB
+}
+
+func NewPendingEnableRepository(db *sqlx.DB) PendingEnableRepository {
+	return &pgPendingEnableRepository{db: db}
+}
+
+// conn returns the transaction carried by ctx, or the shared connection pool
+func (r *pgPendingEnableRepository) conn(ctx context.Context) queryer {
+	return connFromContext(ctx, r.db)
+}
+
+const pendingEnableColumns = `id, flag_id, actor, reason, status, attempts, last_attempt_at, created_at, resolved_at`
+
+func (r *pgPendingEnableRepository) CreatePendingEnable(ctx context.Context, pending *entity.PendingEnable) (int64, error) {
+	query := `INSERT INTO pending_enables (flag_id, actor, reason, status) VALUES ($1, $2, $3, $4) RETURNING id`
+	var id int64
+	err := r.conn(ctx).QueryRowContext(ctx, query, pending.FlagID, pending.Actor, pending.Reason, pending.Status).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create pending enable: %w", err)
+	}
+	return id, nil
+}
+
+func (r *pgPendingEnableRepository) GetPendingEnableByID(ctx context.Context, id int64) (*entity.PendingEnable, error) {
+	var pending entity.PendingEnable
+	query := `SELECT ` + pendingEnableColumns + ` FROM pending_enables WHERE id = $1`
+	err := r.conn(ctx).GetContext(ctx, &pending, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPendingEnableNotFound
+		}
+		return nil, fmt.Errorf("failed to get pending enable: %w", err)
+	}
+	return &pending, nil
+}
+
+func (r *pgPendingEnableRepository) GetActivePendingEnableByFlagID(ctx context.Context, flagID int64) (*entity.PendingEnable, error) {
+	var pending entity.PendingEnable
+	query := `SELECT ` + pendingEnableColumns + ` FROM pending_enables WHERE flag_id = $1 AND status = $2`
+	err := r.conn(ctx).GetContext(ctx, &pending, query, flagID, entity.PendingEnablePending)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPendingEnableNotFound
+		}
+		return nil, fmt.Errorf("failed to get pending enable by flag ID: %w", err)
+	}
+	return &pending, nil
+}
+
+func (r *pgPendingEnableRepository) ListActivePendingEnables(ctx context.Context) ([]*entity.PendingEnable, error) {
+	var pendings []*entity.PendingEnable
+	query := `SELECT ` + pendingEnableColumns + ` FROM pending_enables WHERE status = $1 ORDER BY created_at`
+	err := r.conn(ctx).SelectContext(ctx, &pendings, query, entity.PendingEnablePending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending enables: %w", err)
+	}
+	return pendings, nil
+}
+
+func (r *pgPendingEnableRepository) RecordPendingEnableAttempt(ctx context.Context, id int64) error {
+	query := `UPDATE pending_enables SET attempts = attempts + 1, last_attempt_at = NOW() WHERE id = $1`
+	_, err := r.conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to record pending enable attempt: %w", err)
+	}
+	return nil
+}
+
+func (r *pgPendingEnableRepository) ResolvePendingEnable(ctx context.Context, id int64, status entity.PendingEnableStatus) error {
+	query := `UPDATE pending_enables SET status = $1, resolved_at = NOW() WHERE id = $2 AND status = $3`
+	result, err := r.conn(ctx).ExecContext(ctx, query, status, id, entity.PendingEnablePending)
+	if err != nil {
+		return fmt.Errorf("failed to resolve pending enable: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrPendingEnableNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"featureflags/entity"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrRolloutScheduleNotFound = errors.New("rollout schedule not found")
+
+// RolloutScheduleRepository stores the rollout schedule of each flag
+type RolloutScheduleRepository interface {
+	// SaveRolloutSchedule creates the flag's schedule or replaces the one it has
+	SaveRolloutSchedule(ctx context.Context, schedule *entity.RolloutSchedule) error
+	GetRolloutSchedule(ctx context.Context, flagID int64) (*entity.RolloutSchedule, error)
+	// ListDueRolloutSchedules returns the active schedules whose next step is due at now
+	ListDueRolloutSchedules(ctx context.Context, now time.Time) ([]*entity.RolloutSchedule, error)
+}
+
+type pgRolloutScheduleRepository struct {
+	db *sqlx.DB
+}
+
+func NewRolloutScheduleRepository(db *sqlx.DB) RolloutScheduleRepository {
+	return &pgRolloutScheduleRepository{db: db}
+}
+
+// conn returns the transaction carried by ctx, or the shared connection pool
+func (r *pgRolloutScheduleRepository) conn(ctx context.Context) queryer {
+	return connFromContext(ctx, r.db)
+}
+
+const rolloutScheduleColumns = `flag_id, start_percentage, step_percentage, step_interval_minutes, status, next_step_at,
+	actor, created_at, updated_at`
+
+func (r *pgRolloutScheduleRepository) SaveRolloutSchedule(ctx context.Context, schedule *entity.RolloutSchedule) error {
+	query := `INSERT INTO rollout_schedules (flag_id, start_percentage, step_percentage, step_interval_minutes, status,
+			next_step_at, actor, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (flag_id) DO UPDATE
+		SET start_percentage = EXCLUDED.start_percentage, step_percentage = EXCLUDED.step_percentage,
+			step_interval_minutes = EXCLUDED.step_interval_minutes, status = EXCLUDED.status,
+			next_step_at = EXCLUDED.next_step_at, actor = EXCLUDED.actor, created_at = EXCLUDED.created_at,
+			updated_at = EXCLUDED.updated_at`
+	_, err := r.conn(ctx).ExecContext(ctx, query, schedule.FlagID, schedule.StartPercentage, schedule.StepPercentage,
+		schedule.StepIntervalMinutes, schedule.Status, schedule.NextStepAt, schedule.Actor, schedule.CreatedAt, schedule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save rollout schedule: %w", err)
+	}
+	return nil
+}
+
+func (r *pgRolloutScheduleRepository) GetRolloutSchedule(ctx context.Context, flagID int64) (*entity.RolloutSchedule, error) {
+	var schedule entity.RolloutSchedule
+	query := `SELECT ` + rolloutScheduleColumns + ` FROM rollout_schedules WHERE flag_id = $1`
+	err := r.conn(ctx).GetContext(ctx, &schedule, query, flagID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRolloutScheduleNotFound
+		}
+		return nil, fmt.Errorf("failed to get rollout schedule: %w", err)
+	}
+	return &schedule, nil
+}
+
+func (r *pgRolloutScheduleRepository) ListDueRolloutSchedules(ctx context.Context, now time.Time) ([]*entity.RolloutSchedule, error) {
+	var schedules []*entity.RolloutSchedule
+	query := `SELECT ` + rolloutScheduleColumns + ` FROM rollout_schedules
+		WHERE status = $1 AND next_step_at <= $2 ORDER BY next_step_at, flag_id`
+	err := r.conn(ctx).SelectContext(ctx, &schedules, query, entity.RolloutScheduleActive, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due rollout schedules: %w", err)
+	}
+	return schedules, nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"featureflags/entity"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var ErrScheduledChangeNotFound = errors.New("scheduled change not found")
+
+// ScheduledChangeRepository stores status changes to be applied at a later time
+type ScheduledChangeRepository interface {
+	CreateScheduledChange(ctx context.Context, change *entity.ScheduledChange) (int64, error)
+	GetScheduledChangeByID(ctx context.Context, id int64) (*entity.ScheduledChange, error)
+	ListDueScheduledChanges(ctx context.Context, now time.Time) ([]*entity.ScheduledChange, error)
+	// NextScheduledEnables returns, for each of the flags with a pending scheduled enable, when
+	// the earliest one is due
+	NextScheduledEnables(ctx context.Context, flagIDs []int64) (map[int64]time.Time, error)
+	// ResolveScheduledChange marks a pending change applied or failed. failureReason is stored
+	// only for failed changes.
+	ResolveScheduledChange(ctx context.Context, id int64, status entity.ScheduledChangeStatus, failureReason string) error
+}
+
+type pgScheduledChangeRepository struct {
+	db *sqlx.DB
+}
+
+func NewScheduledChangeRepository(db *sqlx.DB) ScheduledChangeRepository {
+	return &pgScheduledChangeRepository{db: db}
+}
+
+// conn returns the transaction carried by ctx, or the shared connection pool
+func (r *pgScheduledChangeRepository) conn(ctx context.Context) queryer {
+	return connFromContext(ctx, r.db)
+}
+
+const scheduledChangeColumns = `id, flag_id, target_status, scheduled_at, actor, COALESCE(reason, '') AS reason, status,
+	COALESCE(failure_reason, '') AS failure_reason, created_at, resolved_at`
+
+func (r *pgScheduledChangeRepository) CreateScheduledChange(ctx context.Context, change *entity.ScheduledChange) (int64, error) {
+	query := `INSERT INTO scheduled_changes (flag_id, target_status, scheduled_at, actor, reason, status)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	var id int64
+	err := r.conn(ctx).QueryRowContext(ctx, query, change.FlagID, change.TargetStatus, change.ScheduledAt,
+		change.Actor, change.Reason, change.Status).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create scheduled change: %w", err)
+	}
+	return id, nil
+}
+
+func (r *pgScheduledChangeRepository) GetScheduledChangeByID(ctx context.Context, id int64) (*entity.ScheduledChange, error) {
+	var change entity.ScheduledChange
+	query := `SELECT ` + scheduledChangeColumns + ` FROM scheduled_changes WHERE id = $1`
+	err := r.conn(ctx).GetContext(ctx, &change, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrScheduledChangeNotFound
+		}
+		return nil, fmt.Errorf("failed to get scheduled change: %w", err)
+	}
+	return &change, nil
+}
+
+func (r *pgScheduledChangeRepository) ListDueScheduledChanges(ctx context.Context, now time.Time) ([]*entity.ScheduledChange, error) {
+	var changes []*entity.ScheduledChange
+	query := `SELECT ` + scheduledChangeColumns + ` FROM scheduled_changes
+		WHERE status = $1 AND scheduled_at <= $2 ORDER BY scheduled_at, id`
+	err := r.conn(ctx).SelectContext(ctx, &changes, query, entity.ScheduledChangePending, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due scheduled changes: %w", err)
+	}
+	return changes, nil
+}
+
+func (r *pgScheduledChangeRepository) NextScheduledEnables(ctx context.Context, flagIDs []int64) (map[int64]time.Time, error) {
+	var rows []struct {
+		FlagID      int64     `db:"flag_id"`
+		ScheduledAt time.Time `db:"scheduled_at"`
+	}
+	query := `SELECT flag_id, MIN(scheduled_at) AS scheduled_at FROM scheduled_changes
+		WHERE status = $1 AND target_status = $2 AND flag_id = ANY($3)
+		GROUP BY flag_id`
+	err := r.conn(ctx).SelectContext(ctx, &rows, query, entity.ScheduledChangePending, entity.FlagEnabled, pq.Array(flagIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled enables: %w", err)
+	}
+
+	enables := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		enables[row.FlagID] = row.ScheduledAt
+	}
+	return enables, nil
+}
+
+func (r *pgScheduledChangeRepository) ResolveScheduledChange(ctx context.Context, id int64, status entity.ScheduledChangeStatus, failureReason string) error {
+	query := `UPDATE scheduled_changes SET status = $1, failure_reason = NULLIF($2, ''), resolved_at = NOW()
+		WHERE id = $3 AND status = $4`
+	result, err := r.conn(ctx).ExecContext(ctx, query, status, failureReason, id, entity.ScheduledChangePending)
+	if err != nil {
+		return fmt.Errorf("failed to resolve scheduled change: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrScheduledChangeNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"featureflags/entity"
+	"featureflags/pkg/events"
+)
+
+// AuditRetryQueue holds audit entries whose write failed so they can be retried by the
+// background worker instead of being lost. It is bounded: when full, the oldest entry is
+// dropped, and so is an entry that keeps failing for maxAttempts flushes.
+type AuditRetryQueue struct {
+	mu          sync.Mutex
+	entries     []*queuedAudit
+	capacity    int
+	maxAttempts int
+	dropped     int64
+}
+
+type queuedAudit struct {
+	log      *entity.AuditLog
+	attempts int
+}
+
+func NewAuditRetryQueue(capacity, maxAttempts int) *AuditRetryQueue {
+	return &AuditRetryQueue{capacity: capacity, maxAttempts: maxAttempts}
+}
+
+// Depth returns the number of entries waiting to be retried
+func (q *AuditRetryQueue) Depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// Dropped returns how many entries were given up on since startup
+func (q *AuditRetryQueue) Dropped() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// Push queues a failed entry
+func (q *AuditRetryQueue) Push(log *entity.AuditLog) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.entries = append(q.entries, &queuedAudit{log: log})
+	q.trim()
+}
+
+// drain removes and returns all queued entries, oldest first
+func (q *AuditRetryQueue) drain() []*queuedAudit {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	entries := q.entries
+	q.entries = nil
+	return entries
+}
+
+// requeue puts entries that could not be written back in front of anything queued meanwhile.
+// Entries out of attempts are dropped.
+func (q *AuditRetryQueue) requeue(entries []*queuedAudit) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	kept := make([]*queuedAudit, 0, len(entries)+len(q.entries))
+	for _, entry := range entries {
+		if entry.attempts >= q.maxAttempts {
+			q.dropped++
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	q.entries = append(kept, q.entries...)
+	q.trim()
+}
+
+// trim drops the oldest entries beyond capacity; callers hold mu
+func (q *AuditRetryQueue) trim() {
+	if excess := len(q.entries) - q.capacity; excess > 0 {
+		q.entries = q.entries[excess:]
+		q.dropped += int64(excess)
+	}
+}
+
+// FlushAuditRetries writes queued audit entries with their original timestamps. An entry that
+// fails counts an attempt and is requeued, and the rest are still flushed, so one bad entry
+// does not hold up the whole queue. The first failure is returned.
+func (s *flagService) FlushAuditRetries(ctx context.Context) error {
+	if s.auditRetries == nil {
+		return nil
+	}
+
+	entries := s.auditRetries.drain()
+	var failed []*queuedAudit
+	var firstErr error
+	for _, entry := range entries {
+		if err := s.auditRepo.CreateAuditLogAt(ctx, entry.log); err != nil {
+			entry.attempts++
+			failed = append(failed, entry)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		s.events.Publish(events.Event{Type: events.TypeAudit, Data: entry.log})
+	}
+
+	if len(failed) > 0 {
+		s.auditRetries.requeue(failed)
+		return fmt.Errorf("failed to flush audit retries (%d pending): %w", len(failed), firstErr)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"featureflags/entity"
+	"featureflags/pkg/events"
+	"featureflags/repository"
+	"featureflags/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyAuditRepository fails audit writes while failing is set and keeps the ones that succeed
+type flakyAuditRepository struct {
+	repository.AuditRepository
+	failing bool
+	written []*entity.AuditLog
+
+	// rejectFlagID fails only the writes for this flag, even while failing is unset
+	rejectFlagID int64
+}
+
+var errAuditUnavailable = errors.New("audit table unavailable")
+
+func (r *flakyAuditRepository) CreateAuditLog(ctx context.Context, log *entity.AuditLog) error {
+	return r.CreateAuditLogAt(ctx, log)
+}
+
+func (r *flakyAuditRepository) CreateAuditLogAt(ctx context.Context, log *entity.AuditLog) error {
+	if r.failing || (r.rejectFlagID != 0 && log.FlagID == r.rejectFlagID) {
+		return errAuditUnavailable
+	}
+	log.ID = int64(len(r.written) + 1)
+	r.written = append(r.written, log)
+	return nil
+}
+
+func TestFlagService_AuditRetries(t *testing.T) {
+	ctx := context.Background()
+	log := test.GetTestLogger()
+
+	newService := func(auditRepo repository.AuditRepository, queue *AuditRetryQueue) *flagService {
+		return NewFlagService(nil, auditRepo, log, WithAuditRetryQueue(queue)).(*flagService)
+	}
+
+	t.Run("failed writes are flushed once the store recovers", func(t *testing.T) {
+		auditRepo := &flakyAuditRepository{failing: true}
+		queue := NewAuditRetryQueue(10, 3)
+		service := newService(auditRepo, queue)
+		sub, unsubscribe := service.events.Subscribe()
+		defer unsubscribe()
+
+		first := entity.NewAuditLog(1, entity.ActionEnable, "alice", "launch")
+		second := entity.NewAuditLog(2, entity.ActionDisable, "bob", "rollback")
+		require.NoError(t, service.recordAudit(ctx, first))
+		require.NoError(t, service.recordAudit(ctx, second))
+		assert.Equal(t, 2, queue.Depth())
+
+		assert.ErrorIs(t, service.FlushAuditRetries(ctx), errAuditUnavailable)
+		assert.Equal(t, 2, queue.Depth())
+
+		auditRepo.failing = false
+		require.NoError(t, service.FlushAuditRetries(ctx))
+
+		assert.Equal(t, 0, queue.Depth())
+		assert.Equal(t, int64(0), queue.Dropped())
+		assert.Equal(t, []*entity.AuditLog{first, second}, auditRepo.written)
+		// Entries are published only once persisted
+		assert.Equal(t, events.Event{Type: events.TypeAudit, Data: first}, <-sub)
+		assert.Equal(t, events.Event{Type: events.TypeAudit, Data: second}, <-sub)
+	})
+
+	t.Run("a failing entry does not block the rest", func(t *testing.T) {
+		auditRepo := &flakyAuditRepository{failing: true, rejectFlagID: 1}
+		queue := NewAuditRetryQueue(10, 3)
+		service := newService(auditRepo, queue)
+
+		poisoned := entity.NewAuditLog(1, entity.ActionEnable, "alice", "launch")
+		healthy := entity.NewAuditLog(2, entity.ActionDisable, "bob", "rollback")
+		require.NoError(t, service.recordAudit(ctx, poisoned))
+		require.NoError(t, service.recordAudit(ctx, healthy))
+
+		auditRepo.failing = false
+		assert.ErrorIs(t, service.FlushAuditRetries(ctx), errAuditUnavailable)
+
+		assert.Equal(t, []*entity.AuditLog{healthy}, auditRepo.written)
+		assert.Equal(t, 1, queue.Depth())
+		assert.Equal(t, int64(0), queue.Dropped())
+	})
+
+	t.Run("entries are dropped after max attempts", func(t *testing.T) {
+		auditRepo := &flakyAuditRepository{failing: true}
+		queue := NewAuditRetryQueue(10, 2)
+		service := newService(auditRepo, queue)
+
+		require.NoError(t, service.recordAudit(ctx, entity.NewAuditLog(1, entity.ActionEnable, "alice", "launch")))
+		require.Error(t, service.FlushAuditRetries(ctx))
+		assert.Equal(t, 1, queue.Depth())
+		require.Error(t, service.FlushAuditRetries(ctx))
+
+		assert.Equal(t, 0, queue.Depth())
+		assert.Equal(t, int64(1), queue.Dropped())
+	})
+
+	t.Run("queue is bounded", func(t *testing.T) {
+		auditRepo := &flakyAuditRepository{failing: true}
+		queue := NewAuditRetryQueue(2, 5)
+		service := newService(auditRepo, queue)
+
+		for i := int64(1); i <= 3; i++ {
+			require.NoError(t, service.recordAudit(ctx, entity.NewAuditLog(i, entity.ActionEnable, "alice", "launch")))
+		}
+		assert.Equal(t, 2, queue.Depth())
+		assert.Equal(t, int64(1), queue.Dropped())
+
+		auditRepo.failing = false
+		require.NoError(t, service.FlushAuditRetries(ctx))
+		require.Len(t, auditRepo.written, 2)
+		assert.Equal(t, int64(2), auditRepo.written[0].FlagID, "the oldest entry is dropped")
+	})
+
+	t.Run("without a queue the error is returned", func(t *testing.T) {
+		service := NewFlagService(nil, &flakyAuditRepository{failing: true}, log).(*flagService)
+
+		err := service.recordAudit(ctx, entity.NewAuditLog(1, entity.ActionEnable, "alice", "launch"))
+		assert.ErrorIs(t, err, errAuditUnavailable)
+	})
+}
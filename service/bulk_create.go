@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"featureflags/entity"
+	"featureflags/repository"
+	"featureflags/validator"
+)
+
+// BulkCreateFlags creates every flag of the batch in one transaction, or none of them.
+// Dependencies may name other flags of the batch, so flags are created in dependency order.
+// All flags are checked before anything is written, and every rejected flag is reported in a
+// BulkCreateError.
+func (s *flagService) BulkCreateFlags(ctx context.Context, req validator.FlagBulkCreateRequest, actor string) ([]*entity.Flag, error) {
+	if err := validator.ValidateFlagBulkCreateRequest(req); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+
+	var failures []BulkItemError
+	fail := func(i int, err error) {
+		failures = append(failures, BulkItemError{Index: i, Name: req.Flags[i].Name, Error: err.Error()})
+	}
+
+	// Batch names take precedence over existing flags when resolving dependencies
+	positions := make(map[string]int, len(req.Flags))
+	for i, item := range req.Flags {
+		if _, dup := positions[item.Name]; dup {
+			fail(i, errors.New("flag name appears more than once in the batch"))
+			continue
+		}
+		positions[item.Name] = i
+	}
+
+	batchDeps := make([][]int, len(req.Flags))
+	existingDeps := make([][]int64, len(req.Flags))
+	for i, item := range req.Flags {
+		if err := s.validateFlagCreateRequest(item.FlagCreateRequest); err != nil {
+			fail(i, err)
+			continue
+		}
+		// Bulk dependencies already accept names, so there is no second list to merge
+		if len(item.DependencyNames) > 0 {
+			fail(i, errors.New("dependency_names is not supported in bulk creates; name dependencies under dependencies"))
+			continue
+		}
+
+		_, err := s.flagRepo.GetFlagByName(ctx, item.Name)
+		switch {
+		case err == nil:
+			fail(i, ErrFlagAlreadyExists)
+			continue
+		case !errors.Is(err, repository.ErrFlagNotFound):
+			return nil, fmt.Errorf("failed to check flag existence: %w", err)
+		}
+
+		for _, ref := range item.Dependencies {
+			if j, ok := positions[string(ref)]; ok {
+				if j == i {
+					fail(i, ErrSelfDependency)
+					continue
+				}
+				batchDeps[i] = append(batchDeps[i], j)
+				continue
+			}
+			dep, err := s.getFlagByRef(ctx, string(ref))
+			if err != nil {
+				if !errors.Is(err, ErrFlagNotFound) {
+					return nil, err
+				}
+				fail(i, fmt.Errorf("%w: %s", ErrDependencyNotFound, ref))
+				continue
+			}
+			existingDeps[i] = append(existingDeps[i], dep.ID)
+		}
+	}
+
+	// Existing flags cannot depend on new ones, so any cycle lies within the batch
+	order, cyclic := bulkCreateOrder(batchDeps)
+	for _, i := range cyclic {
+		fail(i, ErrCircularDependency)
+	}
+
+	if len(failures) > 0 {
+		sort.SliceStable(failures, func(a, b int) bool { return failures[a].Index < failures[b].Index })
+		s.log(ctx).Warnw("Bulk create rejected", "flags", len(req.Flags), "rejected", len(failures), "actor", actor)
+		return nil, BulkCreateError{Message: "Bulk create failed", Items: failures}
+	}
+
+	created := make([]*entity.Flag, len(req.Flags))
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		for _, i := range order {
+			createReq := req.Flags[i].FlagCreateRequest
+			createReq.Dependencies = existingDeps[i]
+			for _, j := range batchDeps[i] {
+				createReq.Dependencies = append(createReq.Dependencies, created[j].ID)
+			}
+
+			flag, err := s.CreateFlag(ctx, createReq, actor)
+			if err != nil {
+				// A flag created concurrently under the same name is still this item's fault
+				if errors.Is(err, ErrFlagAlreadyExists) || errors.Is(err, ErrCircularDependency) || errors.Is(err, ErrDependencyTooDeep) {
+					fail(i, err)
+					return BulkCreateError{Message: "Bulk create failed", Items: failures}
+				}
+				return err
+			}
+			created[i] = flag
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log(ctx).Infow("Flags created in bulk", "count", len(created), "actor", actor)
+	return created, nil
+}
+
+// bulkCreateOrder orders the batch so every flag comes after the batch flags it depends on,
+// using Kahn's algorithm. Flags on or behind a cycle cannot be ordered and are returned as
+// cyclic instead.
+func bulkCreateOrder(deps [][]int) (order, cyclic []int) {
+	pending := make([]int, len(deps))
+	dependents := make([][]int, len(deps))
+	for i, ds := range deps {
+		pending[i] = len(ds)
+		for _, j := range ds {
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	var ready []int
+	for i := range deps {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		order = append(order, i)
+		for _, dependent := range dependents[i] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	for i := range deps {
+		if pending[i] > 0 {
+			cyclic = append(cyclic, i)
+		}
+	}
+	return order, cyclic
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkCreateOrder(t *testing.T) {
+	t.Run("dependencies come first", func(t *testing.T) {
+		// 0 depends on 2, 2 depends on 1
+		order, cyclic := bulkCreateOrder([][]int{{2}, nil, {1}})
+		assert.Equal(t, []int{1, 2, 0}, order)
+		assert.Empty(t, cyclic)
+	})
+
+	t.Run("cycles and flags behind them are not ordered", func(t *testing.T) {
+		// 0 and 1 depend on each other, 2 depends on 1, 3 is independent
+		order, cyclic := bulkCreateOrder([][]int{{1}, {0}, {1}, nil})
+		assert.Equal(t, []int{3}, order)
+		assert.Equal(t, []int{0, 1, 2}, cyclic)
+	})
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"featureflags/entity"
+	"featureflags/validator"
+)
+
+// BulkToggleFlags enables or disables every flag of the batch in one transaction, or none of
+// them. Enables run in dependency order, so a flag may rely on another flag of the batch being
+// enabled first; disables run dependents first, so each flag gets its own disable rather than
+// a cascade, and cascade on to flags outside the batch as usual. Every flag is checked against
+// the state the batch has left so far, and every rejected flag is reported in a
+// BulkToggleError.
+func (s *flagService) BulkToggleFlags(ctx context.Context, req validator.FlagBulkToggleRequest, actor string) ([]*entity.BulkToggleItem, error) {
+	if err := validator.ValidateFlagBulkToggleRequest(req); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+	if err := s.reasonPolicy.Check(validator.ToggleAction(req.Enable), req.Reason); err != nil {
+		return nil, err
+	}
+
+	var failures []BulkItemError
+	fail := func(i int, name string, err error) {
+		failures = append(failures, BulkItemError{Index: i, Name: name, Error: bulkToggleItemMessage(err)})
+	}
+	rejected := func() error {
+		sort.SliceStable(failures, func(a, b int) bool { return failures[a].Index < failures[b].Index })
+		s.log(ctx).Warnw("Bulk toggle rejected", "flags", len(req.FlagIDs), "rejected", len(failures), "actor", actor)
+		return BulkToggleError{Message: "Bulk toggle failed", Items: failures}
+	}
+
+	flags := make([]*entity.Flag, len(req.FlagIDs))
+	positions := make(map[int64]int, len(req.FlagIDs))
+	for i, flagID := range req.FlagIDs {
+		if _, dup := positions[flagID]; dup {
+			fail(i, "", errors.New("flag appears more than once in the batch"))
+			continue
+		}
+		positions[flagID] = i
+
+		flag, err := s.GetFlag(ctx, flagID)
+		if err != nil {
+			if !errors.Is(err, ErrFlagNotFound) {
+				return nil, err
+			}
+			fail(i, "", fmt.Errorf("%w: %d", ErrFlagNotFound, flagID))
+			continue
+		}
+		// A confirmation token covers a single flag
+		if req.Enable && flag.HighRisk && !flag.IsEnabled() {
+			fail(i, flag.Name, errors.New("high-risk flags must be enabled on their own, with confirmation"))
+			continue
+		}
+		flags[i] = flag
+	}
+
+	batchDeps := make([][]int, len(flags))
+	for i, flag := range flags {
+		if flag == nil {
+			continue
+		}
+		for _, depID := range flag.Dependencies {
+			if j, ok := positions[depID]; ok && flags[j] != nil {
+				batchDeps[i] = append(batchDeps[i], j)
+			}
+		}
+	}
+	order, cyclic := bulkCreateOrder(batchDeps)
+	if req.Enable {
+		for _, i := range cyclic {
+			fail(i, flags[i].Name, ErrCircularDependency)
+		}
+	} else {
+		slices.Reverse(order)
+		order = append(order, cyclic...)
+	}
+
+	if len(failures) > 0 {
+		return nil, rejected()
+	}
+
+	results := make([]*entity.BulkToggleItem, len(flags))
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		for _, i := range order {
+			flag := flags[i]
+			var change *entity.StatusChange
+			var err error
+			if req.Enable {
+				change, err = s.EnableFlag(ctx, flag.ID, actor, req.Reason)
+			} else {
+				change, err = s.DisableFlag(ctx, flag.ID, actor, req.Reason)
+			}
+			if err != nil {
+				// Rejections are found before anything is written for the flag, so the
+				// transaction can go on to check the rest of the batch
+				if !isBulkToggleItemError(err) {
+					return err
+				}
+				fail(i, flag.Name, err)
+				continue
+			}
+			results[i] = &entity.BulkToggleItem{FlagID: flag.ID, Name: flag.Name, StatusChange: change}
+		}
+		if len(failures) > 0 {
+			return rejected()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log(ctx).Infow("Flags toggled in bulk", "count", len(results), "enable", req.Enable, "actor", actor)
+	return results, nil
+}
+
+// isBulkToggleItemError reports whether err rejects the toggled flag rather than the batch
+func isBulkToggleItemError(err error) bool {
+	var depErr DependencyError
+	var dependentsErr EnabledDependentsError
+	return errors.Is(err, ErrFlagLocked) || errors.Is(err, ErrFlagArchived) ||
+		errors.Is(err, ErrFlagInMaintenance) || errors.Is(err, ErrCircularDependency) ||
+		errors.Is(err, ErrDependencyUnavailable) || errors.Is(err, ErrConcurrentModification) ||
+		errors.Is(err, ErrConfirmationRequired) ||
+		errors.As(err, &depErr) || errors.As(err, &dependentsErr)
+}
+
+// bulkToggleItemMessage describes a rejected flag, naming the flags that blocked it
+func bulkToggleItemMessage(err error) string {
+	var depErr DependencyError
+	var dependentsErr EnabledDependentsError
+	switch {
+	case errors.As(err, &depErr):
+		return fmt.Sprintf("%s: %s", depErr.Message, strings.Join(depErr.MissingDependencies, ", "))
+	case errors.As(err, &dependentsErr):
+		return fmt.Sprintf("%s: %s", dependentsErr.Message, strings.Join(dependentsErr.EnabledDependents, ", "))
+	}
+	return err.Error()
+}
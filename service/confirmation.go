@@ -0,0 +1,75 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"featureflags/entity"
+)
+
+// DefaultConfirmationTTL is how long an enable confirmation token stays valid
+const DefaultConfirmationTTL = 5 * time.Minute
+
+// ConfirmationRequiredError is returned when enabling a high-risk flag needs a confirmation token
+type ConfirmationRequiredError struct {
+	Message   string    `json:"error"`
+	Token     string    `json:"confirmation_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (e ConfirmationRequiredError) Error() string {
+	return e.Message
+}
+
+// confirmationTokens issues and verifies stateless enable confirmation tokens. A token is bound
+// to the flag and its current version (updated_at), so any change to the flag invalidates it.
+type confirmationTokens struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+func newConfirmationTokens(secret []byte, ttl time.Duration) *confirmationTokens {
+	if len(secret) == 0 {
+		// Tokens then only verify on this instance, which is fine for a short-lived confirmation
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(fmt.Sprintf("failed to generate confirmation secret: %v", err))
+		}
+	}
+	if ttl <= 0 {
+		ttl = DefaultConfirmationTTL
+	}
+	return &confirmationTokens{secret: secret, ttl: ttl, now: time.Now}
+}
+
+// Issue creates a token for the flag's current version
+func (c *confirmationTokens) Issue(flag *entity.Flag) (string, time.Time) {
+	expiresAt := c.now().Add(c.ttl).Truncate(time.Second)
+	return c.sign(flag, expiresAt.Unix()), expiresAt
+}
+
+// Verify reports whether the token was issued for the flag's current version and has not expired
+func (c *confirmationTokens) Verify(flag *entity.Flag, token string) bool {
+	expiresPart, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	expires, err := strconv.ParseInt(expiresPart, 10, 64)
+	if err != nil || c.now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(c.sign(flag, expires)))
+}
+
+func (c *confirmationTokens) sign(flag *entity.Flag, expires int64) string {
+	mac := hmac.New(sha256.New, c.secret)
+	fmt.Fprintf(mac, "%d:%d:%d", flag.ID, flag.UpdatedAt.UnixMicro(), expires)
+	return fmt.Sprintf("%d.%s", expires, hex.EncodeToString(mac.Sum(nil)))
+}
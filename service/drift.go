@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"featureflags/entity"
+	"featureflags/validator"
+)
+
+// driftTracker remembers which drift has already been reported, so a drift that persists
+// across scans is audited once rather than on every pass
+type driftTracker struct {
+	mu       sync.Mutex
+	reported map[int64]string // flag ID -> unsatisfied dependencies last reported
+}
+
+func newDriftTracker() *driftTracker {
+	return &driftTracker{reported: make(map[int64]string)}
+}
+
+// Update records the drift found by a scan and returns the entries not reported before.
+// Flags that are no longer drifted are forgotten, so a later recurrence is reported again.
+func (t *driftTracker) Update(drifts []*entity.DependencyDrift) []*entity.DependencyDrift {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current := make(map[int64]string, len(drifts))
+	var fresh []*entity.DependencyDrift
+	for _, drift := range drifts {
+		key := strings.Join(drift.UnsatisfiedDependencies, ",")
+		current[drift.FlagID] = key
+		if t.reported[drift.FlagID] != key {
+			fresh = append(fresh, drift)
+		}
+	}
+	t.reported = current
+	return fresh
+}
+
+// ScanDependencyDrift finds enabled flags whose dependencies are not all enabled. Each newly
+// found drift is audited (and so published as an event). With auto-correct the flag is then
+// moved to its cascade status and its own dependents are cascaded, as if the dependency had
+// been disabled through the service.
+func (s *flagService) ScanDependencyDrift(ctx context.Context) error {
+	drifts, err := s.flagRepo.ListDependencyDrift(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to scan dependency drift: %w", err)
+	}
+
+	for _, drift := range s.drift.Update(drifts) {
+		dependencies := strings.Join(drift.UnsatisfiedDependencies, ", ")
+		s.log(ctx).Warnw("Dependency drift detected", "flagID", drift.FlagID, "dependencies", dependencies)
+
+		reason := fmt.Sprintf("Flag is enabled but its dependencies are not: %s", dependencies)
+		auditLog := entity.NewAuditLog(drift.FlagID, entity.ActionDriftDetected, validator.SystemActor, reason)
+		if err := s.recordAudit(ctx, auditLog); err != nil {
+			s.log(ctx).Warnw("Failed to create audit log", "error", err, "flagID", drift.FlagID)
+		}
+
+		if s.driftAutoCorrect {
+			if err := s.correctDrift(ctx, drift, dependencies); err != nil {
+				s.log(ctx).Errorw("Failed to correct dependency drift", "error", err, "flagID", drift.FlagID)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *flagService) correctDrift(ctx context.Context, drift *entity.DependencyDrift, dependencies string) error {
+	flag, err := s.flagRepo.GetFlagByID(ctx, drift.FlagID)
+	if err != nil {
+		return fmt.Errorf("failed to get flag: %w", err)
+	}
+	if !flag.IsEnabled() {
+		return nil // changed since the scan
+	}
+	if flag.Locked {
+		s.log(ctx).Warnw("Skipping drift correction for locked flag", "flagID", flag.ID)
+		return nil
+	}
+
+	targetStatus := flag.CascadeStatus()
+	if err := s.updateStatus(ctx, flag, targetStatus, validator.SystemActor); err != nil {
+		return fmt.Errorf("failed to update flag status: %w", err)
+	}
+
+	action := entity.ActionCascadeDisable
+	reason := fmt.Sprintf("Automatically disabled to correct dependency drift (%s not enabled)", dependencies)
+	if targetStatus == entity.FlagMaintenance {
+		action = entity.ActionCascadeMaintenance
+		reason = fmt.Sprintf("Automatically moved to maintenance (cascade strategy %q) to correct dependency drift (%s not enabled)",
+			flag.CascadeStrategy, dependencies)
+	}
+	auditLog := entity.NewAuditLog(flag.ID, action, validator.SystemActor, reason)
+	if err := s.recordAudit(ctx, auditLog); err != nil {
+		s.log(ctx).Warnw("Failed to create audit log", "error", err, "flagID", flag.ID)
+	}
+
+	s.log(ctx).Infow("Dependency drift corrected", "flagID", flag.ID, "status", targetStatus)
+	origin := cascadeOrigin{root: flag, event: fmt.Sprintf("was moved to %s to correct dependency drift", targetStatus)}
+	return s.cascadeDisableDependents(ctx, origin)
+}
@@ -0,0 +1,441 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"featureflags/entity"
+	"featureflags/pkg/events"
+	"featureflags/repository"
+	"featureflags/validator"
+)
+
+// ToggleFlagInEnvironment enables or disables the flag in env only. An empty env, or the
+// global one, toggles the flag's own status exactly like ToggleFlag. Elsewhere dependencies
+// are checked, and a disable cascades to dependents, within env; the grace period and
+// cascade enables apply to the global environment only. A toggle sent with an idempotency
+// key is applied at most once.
+func (s *flagService) ToggleFlagInEnvironment(ctx context.Context, flagID int64, env string, req validator.FlagToggleRequest, actor string) (*entity.StatusChange, error) {
+	if req.IdempotencyKey == "" || s.idempotencyRepo == nil {
+		return s.toggleInEnvironment(ctx, flagID, env, req, actor)
+	}
+	if err := validator.ValidateFlagToggleRequest(req); err != nil {
+		return nil, err
+	}
+	return s.toggleOnce(ctx, flagID, env, req, func(ctx context.Context) (*entity.StatusChange, error) {
+		return s.toggleInEnvironment(ctx, flagID, env, req, actor)
+	})
+}
+
+func (s *flagService) toggleInEnvironment(ctx context.Context, flagID int64, env string, req validator.FlagToggleRequest, actor string) (*entity.StatusChange, error) {
+	if env == "" || env == entity.GlobalEnvironment {
+		return s.ToggleFlag(ctx, flagID, req, actor)
+	}
+	if s.envRepo == nil {
+		return nil, ErrFeatureNotConfigured
+	}
+	if err := validator.ValidateFlagToggleRequest(req); err != nil {
+		return nil, err
+	}
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+	if err := s.reasonPolicy.Check(validator.ToggleAction(req.Enable), req.Reason); err != nil {
+		return nil, err
+	}
+
+	if req.Enable {
+		if req.Cascade {
+			return nil, ErrEnvironmentCascade
+		}
+		return s.enableInEnvironment(ctx, flagID, env, req.ConfirmationToken, actor, req.Reason)
+	}
+	return s.disableInEnvironment(ctx, flagID, env, actor, req.Reason)
+}
+
+// ListFlagEnvironments returns the flag's status in every known environment, global first
+func (s *flagService) ListFlagEnvironments(ctx context.Context, flagID int64) ([]*entity.FlagEnvironmentStatus, error) {
+	flag, err := s.GetFlag(ctx, flagID)
+	if err != nil {
+		return nil, err
+	}
+
+	global := &entity.FlagEnvironmentStatus{
+		FlagID:      flag.ID,
+		Environment: entity.GlobalEnvironment,
+		Status:      flag.Status,
+		Version:     flag.Version,
+		UpdatedBy:   flag.UpdatedBy,
+		UpdatedAt:   &flag.UpdatedAt,
+	}
+	if s.envRepo == nil {
+		return []*entity.FlagEnvironmentStatus{global}, nil
+	}
+
+	statuses, err := s.envRepo.ListFlagEnvironmentStatuses(ctx, flagID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flag environments: %w", err)
+	}
+	return append([]*entity.FlagEnvironmentStatus{global}, statuses...), nil
+}
+
+// GetFlagStates returns the flag's status in every known environment, keyed by environment
+func (s *flagService) GetFlagStates(ctx context.Context, flagID int64) (map[string]entity.FlagStatus, error) {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if s.envRepo == nil {
+		flag, err := s.GetFlag(ctx, flagID)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]entity.FlagStatus{entity.GlobalEnvironment: flag.Status}, nil
+	}
+
+	states, err := s.envRepo.GetFlagStates(ctx, flagID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return nil, ErrFlagNotFound
+		}
+		return nil, fmt.Errorf("failed to get flag states: %w", err)
+	}
+	return states, nil
+}
+
+// ListEnvironments returns the names of the known environments, ordered by name
+func (s *flagService) ListEnvironments(ctx context.Context) ([]string, error) {
+	if s.envRepo == nil {
+		return []string{entity.GlobalEnvironment}, nil
+	}
+	names, err := s.envRepo.ListEnvironments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list environments: %w", err)
+	}
+	return names, nil
+}
+
+// DiffEnvironments returns the flags whose status differs between the environments from and
+// to, with both statuses. A flag never changed in an environment counts as disabled there.
+func (s *flagService) DiffEnvironments(ctx context.Context, from, to string) (*entity.EnvironmentDiff, error) {
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("%w: from and to are required", ErrInvalidEnvironmentDiff)
+	}
+	if s.envRepo == nil {
+		return nil, ErrFeatureNotConfigured
+	}
+
+	flags, err := s.envRepo.DiffEnvironments(ctx, from, to)
+	if err != nil {
+		if errors.Is(err, repository.ErrEnvironmentNotFound) {
+			return nil, ErrEnvironmentNotFound
+		}
+		return nil, fmt.Errorf("failed to diff environments: %w", err)
+	}
+	return &entity.EnvironmentDiff{From: from, To: to, Flags: flags}, nil
+}
+
+// CreateEnvironment adds an environment, in which every flag starts out disabled
+func (s *flagService) CreateEnvironment(ctx context.Context, req validator.EnvironmentCreateRequest, actor string) error {
+	if s.envRepo == nil {
+		return ErrFeatureNotConfigured
+	}
+	if err := validator.ValidateEnvironmentCreateRequest(req); err != nil {
+		return err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return err
+	}
+
+	if err := s.envRepo.CreateEnvironment(ctx, req.Name); err != nil {
+		if errors.Is(err, repository.ErrEnvironmentExists) {
+			return ErrEnvironmentExists
+		}
+		return fmt.Errorf("failed to create environment: %w", err)
+	}
+
+	s.log(ctx).Infow("Environment created", "environment", req.Name, "actor", actor)
+	return nil
+}
+
+// DeleteEnvironment removes an environment together with the flag statuses set in it. The
+// global environment cannot be deleted.
+func (s *flagService) DeleteEnvironment(ctx context.Context, name, actor string) error {
+	if s.envRepo == nil {
+		return ErrFeatureNotConfigured
+	}
+	if name == entity.GlobalEnvironment {
+		return ErrGlobalEnvironment
+	}
+	if err := s.validateActor(actor); err != nil {
+		return err
+	}
+
+	if err := s.envRepo.DeleteEnvironment(ctx, name); err != nil {
+		if errors.Is(err, repository.ErrEnvironmentNotFound) {
+			return ErrEnvironmentNotFound
+		}
+		return fmt.Errorf("failed to delete environment: %w", err)
+	}
+
+	s.log(ctx).Infow("Environment deleted", "environment", name, "actor", actor)
+	return nil
+}
+
+func (s *flagService) enableInEnvironment(ctx context.Context, flagID int64, env, token, actor, reason string) (*entity.StatusChange, error) {
+	flag, err := s.GetFlag(ctx, flagID)
+	if err != nil {
+		return nil, err
+	}
+	if flag.Locked {
+		return nil, ErrFlagLocked
+	}
+	if flag.IsArchived() {
+		return nil, ErrFlagArchived
+	}
+
+	status, err := s.getEnvironmentStatus(ctx, flagID, env)
+	if err != nil {
+		return nil, err
+	}
+	change := status.StatusChange(entity.FlagEnabled)
+	if !change.Changed {
+		return change, nil
+	}
+	if status.Status == entity.FlagMaintenance {
+		return nil, ErrFlagInMaintenance
+	}
+	if flag.HighRisk {
+		if err := s.requireConfirmation(ctx, flag, token, actor); err != nil {
+			return nil, err
+		}
+	}
+
+	var missing []string
+	for _, depID := range flag.Dependencies {
+		depStatus, err := s.getEnvironmentStatus(ctx, depID, env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check dependency %d: %w", depID, err)
+		}
+		if !depStatus.IsEnabled() {
+			dep, err := s.flagRepo.GetFlagByID(ctx, depID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get dependency flag %d: %w", depID, err)
+			}
+			if dep.IsArchived() {
+				return nil, UnavailableDependencyError{Dependency: dep.Name, Reason: "archived"}
+			}
+			missing = append(missing, dep.Name)
+		}
+	}
+	if len(missing) > 0 {
+		s.log(ctx).Warnw("Cannot enable flag due to missing dependencies",
+			"flagID", flagID, "environment", env, "missingDeps", missing, "actor", actor)
+		return nil, DependencyError{
+			Message:             "Missing active dependencies",
+			MissingDependencies: missing,
+		}
+	}
+
+	auditLog := entity.NewAuditLog(flagID, entity.ActionEnable, actor, reason)
+	auditLog.Environment = env
+	err = s.changeStatus(ctx, auditLog, func(ctx context.Context) error {
+		return s.updateEnvironmentStatus(ctx, flag, status, entity.FlagEnabled, actor)
+	})
+	if err != nil {
+		s.log(ctx).Errorw("Failed to enable flag", "error", err, "flagID", flagID, "environment", env)
+		return nil, fmt.Errorf("failed to enable flag: %w", err)
+	}
+
+	s.log(ctx).Infow("Flag enabled successfully", "flagID", flagID, "environment", env, "actor", actor, "reason", reason)
+	return change, nil
+}
+
+func (s *flagService) disableInEnvironment(ctx context.Context, flagID int64, env, actor, reason string) (*entity.StatusChange, error) {
+	flag, err := s.GetFlag(ctx, flagID)
+	if err != nil {
+		return nil, err
+	}
+	if flag.Locked {
+		return nil, ErrFlagLocked
+	}
+
+	status, err := s.getEnvironmentStatus(ctx, flagID, env)
+	if err != nil {
+		return nil, err
+	}
+	change := status.StatusChange(entity.FlagDisabled)
+	if !change.Changed {
+		return change, nil
+	}
+
+	dependents, err := s.environmentDependents(ctx, flagID, env)
+	if err != nil {
+		return nil, err
+	}
+	if flag.BlocksDisable() {
+		var enabled []string
+		for _, dependent := range dependents {
+			if dependent.status.IsEnabled() {
+				enabled = append(enabled, dependent.flag.Name)
+			}
+		}
+		if len(enabled) > 0 {
+			return nil, EnabledDependentsError{
+				Message:           "Dependent flags must be disabled first",
+				EnabledDependents: enabled,
+			}
+		}
+	}
+
+	// The flag and its cascade commit together, as for the global status
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		if err := s.updateEnvironmentStatus(ctx, flag, status, entity.FlagDisabled, actor); err != nil {
+			return fmt.Errorf("failed to disable flag: %w", err)
+		}
+		auditLog := entity.NewAuditLog(flagID, entity.ActionDisable, actor, reason)
+		auditLog.Environment = env
+		if err := s.recordAudit(ctx, auditLog); err != nil {
+			return fmt.Errorf("failed to create audit log: %w", err)
+		}
+		origin := cascadeOrigin{root: flag, event: eventBy("was turned off in "+env, actor)}
+		return s.cascadeDisableInEnvironment(ctx, origin, flag, env, dependents, map[int64]bool{flagID: true})
+	})
+	if err != nil {
+		s.log(ctx).Errorw("Failed to disable flag", "error", err, "flagID", flagID, "environment", env)
+		return nil, err
+	}
+
+	s.log(ctx).Infow("Flag disabled successfully", "flagID", flagID, "environment", env, "actor", actor, "reason", reason)
+	return change, nil
+}
+
+// disableInAllEnvironments disables the flag in every environment but global that it is not
+// disabled in already
+func (s *flagService) disableInAllEnvironments(ctx context.Context, flagID int64, actor, reason string) error {
+	statuses, err := s.envRepo.ListFlagEnvironmentStatuses(ctx, flagID)
+	if err != nil {
+		return fmt.Errorf("failed to list flag environments: %w", err)
+	}
+	for _, status := range statuses {
+		if status.Status == entity.FlagDisabled {
+			continue
+		}
+		if _, err := s.disableInEnvironment(ctx, flagID, status.Environment, actor, reason); err != nil {
+			return fmt.Errorf("failed to disable flag in %s: %w", status.Environment, err)
+		}
+	}
+	return nil
+}
+
+// environmentDependent is a direct dependent of a flag together with its status in one
+// environment
+type environmentDependent struct {
+	flag   *entity.Flag
+	status *entity.FlagEnvironmentStatus
+}
+
+func (s *flagService) environmentDependents(ctx context.Context, flagID int64, env string) ([]environmentDependent, error) {
+	dependentIDs, err := s.flagRepo.GetDependents(ctx, flagID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dependents: %w", err)
+	}
+	if len(dependentIDs) == 0 {
+		return nil, nil
+	}
+	flags, err := s.flagRepo.GetFlagsByIDs(ctx, dependentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dependents: %w", err)
+	}
+
+	dependents := make([]environmentDependent, 0, len(flags))
+	for _, flag := range flags {
+		status, err := s.getEnvironmentStatus(ctx, flag.ID, env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dependent %d: %w", flag.ID, err)
+		}
+		dependents = append(dependents, environmentDependent{flag: flag, status: status})
+	}
+	return dependents, nil
+}
+
+// cascadeDisableInEnvironment moves the enabled dependents of parent in env to their cascade
+// status, and theirs in turn. Locked dependents are left alone, as in the global cascade.
+func (s *flagService) cascadeDisableInEnvironment(ctx context.Context, origin cascadeOrigin, parent *entity.Flag, env string, dependents []environmentDependent, visited map[int64]bool) error {
+	flagID := parent.ID
+	for _, dependent := range dependents {
+		depID := dependent.flag.ID
+		if visited[depID] || !dependent.status.IsEnabled() {
+			continue
+		}
+		visited[depID] = true
+		if dependent.flag.Locked {
+			s.log(ctx).Warnw("Skipping locked flag during cascade", "depID", depID, "parentFlagID", flagID, "environment", env)
+			continue
+		}
+
+		targetStatus := dependent.flag.CascadeStatus()
+		if err := s.updateEnvironmentStatus(ctx, dependent.flag, dependent.status, targetStatus, validator.SystemActor); err != nil {
+			return fmt.Errorf("failed to cascade disable dependent %d: %w", depID, err)
+		}
+
+		action := entity.ActionCascadeDisable
+		if targetStatus == entity.FlagMaintenance {
+			action = entity.ActionCascadeMaintenance
+		}
+		auditLog := entity.NewAuditLog(depID, action, validator.SystemActor, cascadeReason(origin, parent, dependent.flag, targetStatus))
+		auditLog.Environment = env
+		if err := s.recordAudit(ctx, auditLog); err != nil {
+			return fmt.Errorf("failed to create cascade audit log: %w", err)
+		}
+
+		next, err := s.environmentDependents(ctx, depID, env)
+		if err != nil {
+			return err
+		}
+		if err := s.cascadeDisableInEnvironment(ctx, origin, dependent.flag, env, next, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *flagService) getEnvironmentStatus(ctx context.Context, flagID int64, env string) (*entity.FlagEnvironmentStatus, error) {
+	status, err := s.envRepo.GetFlagEnvironmentStatus(ctx, flagID, env)
+	if err != nil {
+		if errors.Is(err, repository.ErrEnvironmentNotFound) {
+			return nil, ErrEnvironmentNotFound
+		}
+		return nil, fmt.Errorf("failed to get flag environment status: %w", err)
+	}
+	return status, nil
+}
+
+// updateEnvironmentStatus writes flag's new status on behalf of actor, failing with
+// ErrConcurrentModification if it changed since it was read
+func (s *flagService) updateEnvironmentStatus(ctx context.Context, flag *entity.Flag, status *entity.FlagEnvironmentStatus, target entity.FlagStatus, actor string) error {
+	err := s.envRepo.UpdateFlagEnvironmentStatus(ctx, status.FlagID, status.Environment, target, status.Version, actor)
+	if err != nil {
+		if errors.Is(err, repository.ErrConcurrentModification) {
+			return ErrConcurrentModification
+		}
+		return err
+	}
+	status.Status = target
+	status.Version++
+	status.UpdatedBy = actor
+	s.publish(ctx, events.Event{Type: events.TypeFlagStatus, Data: &entity.FlagStatusEvent{
+		FlagID:      flag.ID,
+		Name:        flag.Name,
+		Status:      target,
+		Environment: status.Environment,
+		Version:     status.Version,
+		ChangedBy:   actor,
+		ChangedAt:   time.Now(),
+	}})
+	return nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// evaluationTracker buffers flag evaluation times in memory so evaluations do not cost a
+// database write each. Buffered times are written in one batch by FlushEvaluations.
+type evaluationTracker struct {
+	mu      sync.Mutex
+	pending map[int64]time.Time
+	now     func() time.Time
+}
+
+func newEvaluationTracker() *evaluationTracker {
+	return &evaluationTracker{pending: make(map[int64]time.Time), now: time.Now}
+}
+
+// Record notes that the flag was evaluated now
+func (t *evaluationTracker) Record(flagID int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[flagID] = t.now()
+}
+
+// Drain returns the buffered evaluations and resets the buffer
+func (t *evaluationTracker) Drain() map[int64]time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	drained := t.pending
+	t.pending = make(map[int64]time.Time)
+	return drained
+}
+
+// Restore puts evaluations back after a failed flush, keeping newer times already buffered
+func (t *evaluationTracker) Restore(evaluations map[int64]time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, at := range evaluations {
+		if existing, ok := t.pending[id]; !ok || at.After(existing) {
+			t.pending[id] = at
+		}
+	}
+}
+
+// FlushEvaluations writes buffered evaluation times to the database
+func (s *flagService) FlushEvaluations(ctx context.Context) error {
+	evaluations := s.evaluations.Drain()
+	if len(evaluations) == 0 {
+		return nil
+	}
+	if err := s.flagRepo.RecordEvaluations(ctx, evaluations); err != nil {
+		s.evaluations.Restore(evaluations)
+		return err
+	}
+	return nil
+}
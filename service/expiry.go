@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"featureflags/entity"
+	"featureflags/validator"
+)
+
+// ExpireFlags disables every enabled flag whose expiry has passed, audited as an expire by
+// system. Dependents are cascaded exactly as for a manual disable. Flags that cannot be
+// disabled, such as locked flags, are logged and retried on the next sweep.
+func (s *flagService) ExpireFlags(ctx context.Context) error {
+	flags, err := s.flagRepo.ListExpiredFlags(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to list expired flags: %w", err)
+	}
+
+	for _, flag := range flags {
+		reason := fmt.Sprintf("Flag expired at %s", flag.ExpiresAt.UTC().Format(time.RFC3339))
+		if _, err := s.disableFlag(ctx, flag.ID, validator.SystemActor, reason, entity.ActionExpire); err != nil {
+			if errors.Is(err, ErrFlagLocked) {
+				s.log(ctx).Warnw("Skipping expiry of locked flag", "flagID", flag.ID)
+				continue
+			}
+			s.log(ctx).Errorw("Failed to expire flag", "error", err, "flagID", flag.ID)
+			continue
+		}
+		s.log(ctx).Infow("Flag expired", "flagID", flag.ID, "name", flag.Name, "expiresAt", flag.ExpiresAt)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"featureflags/entity"
+	"featureflags/repository"
+	"featureflags/validator"
+)
+
+// exportPageSize is how many flags ExportFlags loads per query
+const exportPageSize = 500
+
+// ExportFlags returns the portable definitions of every flag that is not archived, ordered by
+// name, with dependencies referenced by name
+func (s *flagService) ExportFlags(ctx context.Context) (*entity.FlagExport, error) {
+	var flags []*entity.Flag
+	for offset := 0; ; offset += exportPageSize {
+		page, err := s.flagRepo.ListFlagsPaginated(ctx, entity.FlagFilter{}, exportPageSize, offset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list flags: %w", err)
+		}
+		flags = append(flags, page...)
+		if len(page) < exportPageSize {
+			break
+		}
+	}
+
+	names := make(map[int64]string, len(flags))
+	for _, flag := range flags {
+		names[flag.ID] = flag.Name
+	}
+	// Dependencies on archived flags are still exported, by the archived flag's name
+	var archivedIDs []int64
+	for _, flag := range flags {
+		for _, depID := range flag.Dependencies {
+			if _, ok := names[depID]; !ok && !slices.Contains(archivedIDs, depID) {
+				archivedIDs = append(archivedIDs, depID)
+			}
+		}
+	}
+	if len(archivedIDs) > 0 {
+		archived, err := s.flagRepo.GetFlagsByIDs(ctx, archivedIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dependencies: %w", err)
+		}
+		for _, flag := range archived {
+			names[flag.ID] = flag.Name
+		}
+	}
+
+	export := &entity.FlagExport{Version: entity.FlagExportVersion, Flags: []*entity.FlagDefinition{}}
+	for _, flag := range flags {
+		dependencyNames := make([]string, 0, len(flag.Dependencies))
+		for _, depID := range flag.Dependencies {
+			dependencyNames = append(dependencyNames, names[depID])
+		}
+		sort.Strings(dependencyNames)
+		export.Flags = append(export.Flags, entity.NewFlagDefinition(flag, dependencyNames))
+	}
+	return export, nil
+}
+
+// ImportFlags applies an export document in one transaction. Missing flags are created, in
+// dependency order, and existing ones get the document's description, tags and dependencies.
+// Archived, locked and unchanged flags are skipped. All flags are checked before anything is
+// written, and every rejected flag is reported in an ImportError; nothing is imported then.
+func (s *flagService) ImportFlags(ctx context.Context, req validator.FlagImportRequest, actor string) (*entity.FlagImportResult, error) {
+	return s.importDocument(ctx, req, true, actor)
+}
+
+// importDocument applies an export document as ImportFlags describes. Without overwrite,
+// flags that already exist are skipped instead of updated.
+func (s *flagService) importDocument(ctx context.Context, req validator.FlagImportRequest, overwrite bool, actor string) (*entity.FlagImportResult, error) {
+	if err := validator.ValidateFlagImportRequest(req); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+	if req.Version != entity.FlagExportVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedExportVersion, req.Version)
+	}
+
+	if duplicates := duplicateImportNames(req.Flags); len(duplicates) > 0 {
+		s.log(ctx).Warnw("Import rejected: duplicate flag names", "flags", len(req.Flags), "duplicates", len(duplicates), "actor", actor)
+		return nil, ImportError{Message: "Import document names a flag more than once", Items: duplicates}
+	}
+
+	var failures []BulkItemError
+	fail := func(i int, err error) {
+		failures = append(failures, BulkItemError{Index: i, Name: req.Flags[i].Name, Error: err.Error()})
+	}
+
+	positions := make(map[string]int, len(req.Flags))
+	for i, item := range req.Flags {
+		positions[item.Name] = i
+	}
+
+	documentDeps := make([][]int, len(req.Flags))
+	for i, item := range req.Flags {
+		if err := s.validateFlagCreateRequest(item.FlagCreateRequest); err != nil {
+			fail(i, err)
+			continue
+		}
+		if len(item.DependencyNames) > 0 {
+			fail(i, errors.New("dependency_names is not supported in imports; name dependencies under dependencies"))
+			continue
+		}
+
+		for _, name := range item.Dependencies {
+			if j, ok := positions[name]; ok {
+				if j == i {
+					fail(i, ErrSelfDependency)
+					continue
+				}
+				documentDeps[i] = append(documentDeps[i], j)
+				continue
+			}
+			if _, err := s.flagRepo.GetFlagByName(ctx, name); err != nil {
+				if !errors.Is(err, repository.ErrFlagNotFound) {
+					return nil, fmt.Errorf("failed to resolve dependency %s: %w", name, err)
+				}
+				fail(i, fmt.Errorf("%w: %s", ErrDependencyNotFound, name))
+			}
+		}
+	}
+
+	// Flags of the document are applied after the document flags they depend on
+	order, cyclic := bulkCreateOrder(documentDeps)
+	for _, i := range cyclic {
+		fail(i, ErrCircularDependency)
+	}
+
+	rejected := func() error {
+		sort.SliceStable(failures, func(a, b int) bool { return failures[a].Index < failures[b].Index })
+		s.log(ctx).Warnw("Import rejected", "flags", len(req.Flags), "rejected", len(failures), "actor", actor)
+		return ImportError{Message: "Import failed", Items: failures}
+	}
+	if len(failures) > 0 {
+		return nil, rejected()
+	}
+
+	result := &entity.FlagImportResult{Created: []string{}, Updated: []string{}, Skipped: []entity.SkippedFlag{}}
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		for _, i := range order {
+			if err := s.importFlag(ctx, req.Flags[i], overwrite, actor, result); err != nil {
+				// Rejections that depend on the stored flags, such as a cycle through a flag
+				// outside the document, are still this flag's fault
+				if isImportItemError(err) {
+					fail(i, err)
+					return rejected()
+				}
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log(ctx).Infow("Flags imported", "created", len(result.Created), "updated", len(result.Updated),
+		"skipped", len(result.Skipped), "actor", actor)
+	return result, nil
+}
+
+// importFlag creates the flag of item or, with overwrite, brings the existing one in line with
+// it, recording the outcome in result
+func (s *flagService) importFlag(ctx context.Context, item validator.FlagImportItem, overwrite bool, actor string, result *entity.FlagImportResult) error {
+	dependencies := make([]int64, 0, len(item.Dependencies))
+	for _, name := range item.Dependencies {
+		dep, err := s.flagRepo.GetFlagByName(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to resolve dependency %s: %w", name, err)
+		}
+		dependencies = append(dependencies, dep.ID)
+	}
+
+	existing, err := s.flagRepo.GetFlagByName(ctx, item.Name)
+	if errors.Is(err, repository.ErrFlagNotFound) {
+		createReq := item.FlagCreateRequest
+		createReq.Dependencies = dependencies
+		if _, err := s.CreateFlag(ctx, createReq, actor); err != nil {
+			return err
+		}
+		result.Created = append(result.Created, item.Name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get flag: %w", err)
+	}
+
+	tags := item.Tags
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	switch {
+	case !overwrite:
+		result.Skipped = append(result.Skipped, entity.SkippedFlag{Name: item.Name, Reason: "exists"})
+		return nil
+	case existing.IsArchived():
+		result.Skipped = append(result.Skipped, entity.SkippedFlag{Name: item.Name, Reason: "archived"})
+		return nil
+	case existing.Description == item.Description && maps.Equal(existing.Tags, tags) &&
+		sameDependencies(existing.Dependencies, dependencies):
+		result.Skipped = append(result.Skipped, entity.SkippedFlag{Name: item.Name, Reason: "unchanged"})
+		return nil
+	case existing.Locked:
+		result.Skipped = append(result.Skipped, entity.SkippedFlag{Name: item.Name, Reason: "locked"})
+		return nil
+	}
+
+	_, err = s.UpdateFlag(ctx, existing.ID, validator.FlagUpdateRequest{
+		Description:  &item.Description,
+		Dependencies: dependencies,
+		Tags:         tags,
+	}, actor)
+	if err != nil {
+		return err
+	}
+	result.Updated = append(result.Updated, item.Name)
+	return nil
+}
+
+// duplicateImportNames reports every flag of an import whose name, ignoring case, an earlier
+// flag of the document already has
+func duplicateImportNames(items []validator.FlagImportItem) []BulkItemError {
+	var duplicates []BulkItemError
+	first := make(map[string]int, len(items))
+	for i, item := range items {
+		key := strings.ToLower(item.Name)
+		if j, dup := first[key]; dup {
+			duplicates = append(duplicates, BulkItemError{Index: i, Name: item.Name,
+				Error: fmt.Sprintf("flag name duplicates %q at index %d", items[j].Name, j)})
+			continue
+		}
+		first[key] = i
+	}
+	return duplicates
+}
+
+// sameDependencies reports whether a and b hold the same flag IDs, in any order
+func sameDependencies(a, b []int64) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
+// isImportItemError reports whether err rejects the imported flag rather than the import
+func isImportItemError(err error) bool {
+	var depErr DependencyError
+	var validationErr validator.ValidationErrors
+	return errors.Is(err, ErrFlagAlreadyExists) || errors.Is(err, ErrCircularDependency) ||
+		errors.Is(err, ErrDependencyTooDeep) || errors.Is(err, ErrDependencyUnavailable) || errors.Is(err, ErrExpiryInPast) ||
+		errors.As(err, &depErr) || errors.As(err, &validationErr)
+}
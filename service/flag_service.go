@@ -4,8 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"featureflags/entity"
+	"featureflags/pkg/events"
 	"featureflags/pkg/logger"
 	"featureflags/repository"
 	"featureflags/validator"
@@ -13,340 +20,3344 @@ import (
 
 var (
 	ErrMissingActiveDependencies = errors.New("missing active dependencies")
-	ErrCircularDependency       = errors.New("circular dependency detected")
-	ErrFlagNotFound            = errors.New("flag not found")
-	ErrFlagAlreadyExists       = errors.New("flag already exists")
+	ErrCircularDependency        = errors.New("circular dependency detected")
+	ErrSelfDependency            = fmt.Errorf("%w: flag cannot depend on itself", ErrCircularDependency)
+	ErrDependencyTooDeep         = errors.New("dependency chain too deep")
+	ErrFlagNotFound              = errors.New("flag not found")
+	ErrFlagAlreadyExists         = errors.New("flag already exists")
+	ErrFlagInMaintenance         = errors.New("flag is in maintenance")
+	ErrFlagNotInMaintenance      = errors.New("flag is not in maintenance")
+	ErrFlagLocked                = errors.New("flag is locked")
+	ErrFlagArchived              = errors.New("flag is archived")
+	ErrFlagNotArchived           = errors.New("flag is not archived")
+	ErrConcurrentModification    = errors.New("flag was modified concurrently")
+	ErrFlagHasDependents         = errors.New("flag has dependents")
+	ErrConfirmationRequired      = errors.New("high-risk flag can only be enabled with confirmation")
+	ErrDependencyNotFound        = errors.New("dependency not found")
+	ErrDependencyUnavailable     = errors.New("dependency can never be enabled")
+	ErrPendingEnableNotFound     = errors.New("pending enable not found")
+	ErrFeatureNotConfigured      = errors.New("feature not configured")
+	ErrInvalidGraphDepth         = errors.New("invalid graph depth")
+	ErrInvalidAuditAction        = errors.New("invalid audit action")
+	ErrInvalidAuditWindow        = errors.New("invalid audit time window")
+	ErrInvalidReportGrouping     = errors.New("invalid report grouping")
+	ErrInvalidReportWindow       = errors.New("invalid report time window")
+	ErrInvalidFlappinessQuery    = errors.New("invalid flappiness query")
+	ErrInvalidUnusedWindow       = errors.New("invalid unused flags window")
+	ErrInvalidAuditPage          = errors.New("invalid audit page")
+	ErrInvalidFlagPage           = errors.New("invalid flag page")
+	ErrInvalidFlagStatus         = errors.New("invalid flag status")
+	ErrCascadeNotFound           = errors.New("no cascade recorded for flag")
+	ErrCascadeAlreadyRestored    = errors.New("cascade already restored")
+	ErrScheduleInPast            = errors.New("scheduled time must be in the future")
+	ErrExpiryInPast              = errors.New("expiry must be in the future")
+	ErrEnvironmentNotFound       = errors.New("environment not found")
+	ErrEnvironmentCascade        = errors.New("cascade enable is only supported in the global environment")
+	ErrEnvironmentExists         = errors.New("environment already exists")
+	ErrGlobalEnvironment         = errors.New("the global environment cannot be deleted")
+	ErrInvalidEnvironmentDiff    = errors.New("invalid environment diff")
+	ErrIdempotencyKeyReused      = errors.New("idempotency key was used for a different request")
+	ErrUnsupportedExportVersion  = errors.New("unsupported export version")
+	ErrRolloutScheduleNotFound   = errors.New("rollout schedule not found")
+	ErrRolloutScheduleFinished   = errors.New("rollout schedule already finished")
+	ErrSyncSourceUnreachable     = errors.New("sync source is unreachable")
+	ErrSyncSourceInvalid         = errors.New("sync source returned an unusable export")
 )
 
-// DependencyError represents an error with missing dependencies
+const (
+	// DefaultGraphNodeLimit caps how many nodes the graph endpoint returns
+	DefaultGraphNodeLimit = 1000
+	// DefaultGraphDepth is the depth the API uses when a graph root is given without one
+	DefaultGraphDepth = 3
+	// MaxGraphDepth is the largest depth accepted for a rooted graph
+	MaxGraphDepth = 50
+	// DefaultAuditReportWindow is used when an audit report is requested without a start time
+	DefaultAuditReportWindow = 7 * 24 * time.Hour
+	// MaxAuditReportWindow is the longest time window an audit report may cover
+	MaxAuditReportWindow = 366 * 24 * time.Hour
+	// MaxFlappinessWindowDays is the longest window the flappy flags query may look back
+	MaxFlappinessWindowDays = 365
+	// MaxUnusedWindowDays is the longest window the unused flags query may look back
+	MaxUnusedWindowDays = 3650
+	// DefaultFlagPageSize is the page size of the flag listing when none is given
+	DefaultFlagPageSize = 50
+	// MaxFlagPageSize is the largest page of flags returned at once
+	MaxFlagPageSize = 200
+	// DefaultAuditPageSize is the page size of the audit log listing when none is given
+	DefaultAuditPageSize = 50
+	// MaxAuditPageSize is the largest page of audit logs returned at once
+	MaxAuditPageSize = 200
+	// DefaultIdempotencyKeyTTL is how long an idempotency key is honoured when none is configured
+	DefaultIdempotencyKeyTTL = 24 * time.Hour
+	// DefaultSyncTimeout bounds a sync's request to the source instance when no client is configured
+	DefaultSyncTimeout = 30 * time.Second
+)
+
+// DependencyError represents an error with missing dependencies. Missing dependencies with a
+// pending scheduled enable are listed in ScheduledEnables; once every one of them has one,
+// ReadyAt is when the last is due and the enable is worth retrying.
 type DependencyError struct {
-	Message             string   `json:"error"`
-	MissingDependencies []string `json:"missing_dependencies"`
+	Message             string               `json:"error"`
+	MissingDependencies []string             `json:"missing_dependencies"`
+	ScheduledEnables    map[string]time.Time `json:"scheduled_enables,omitempty"`
+	ReadyAt             *time.Time           `json:"ready_at,omitempty"`
 }
 
 func (e DependencyError) Error() string {
 	return e.Message
 }
 
-// FlagService defines the interface for flag business logic
-type FlagService interface {
-	CreateFlag(ctx context.Context, req validator.FlagCreateRequest, actor string) (*entity.Flag, error)
-	EnableFlag(ctx context.Context, flagID int64, actor, reason string) error
-	DisableFlag(ctx context.Context, flagID int64, actor, reason string) error
-	ToggleFlag(ctx context.Context, flagID int64, req validator.FlagToggleRequest, actor string) error
-	GetFlag(ctx context.Context, flagID int64) (*entity.Flag, error)
-	ListFlags(ctx context.Context) ([]*entity.Flag, error)
-	GetFlagAuditLogs(ctx context.Context, flagID int64) ([]*entity.AuditLog, error)
+// UnknownDependenciesError is returned when dependencies are given by names or IDs that no
+// flag has. Only the field matching how the dependencies were given is set.
+type UnknownDependenciesError struct {
+	Message              string
+	UnknownDependencies  []string
+	UnknownDependencyIDs []int64
+}
+
+func (e UnknownDependenciesError) Error() string {
+	return e.Message
+}
+
+func (e UnknownDependenciesError) Unwrap() error {
+	return ErrDependencyNotFound
+}
+
+// EnabledDependentsError is returned when a flag with the block disable policy
+// still has enabled dependents
+type EnabledDependentsError struct {
+	Message           string   `json:"error"`
+	EnabledDependents []string `json:"enabled_dependents"`
+}
+
+func (e EnabledDependentsError) Error() string {
+	return e.Message
+}
+
+// UnavailableDependencyError is returned when enabling a flag whose dependency has been archived
+// or deleted, so the flag could never be enabled by enabling its dependencies first
+type UnavailableDependencyError struct {
+	Dependency string // the dependency's name, or its ID once deleted
+	Reason     string // "archived" or "deleted"
+}
+
+func (e UnavailableDependencyError) Error() string {
+	return fmt.Sprintf("dependency %s has been %s", e.Dependency, e.Reason)
+}
+
+func (e UnavailableDependencyError) Unwrap() error {
+	return ErrDependencyUnavailable
+}
+
+// HasDependentsError is returned when a flag cannot be deleted because other flags depend on it
+type HasDependentsError struct {
+	Message    string
+	Dependents []string
+}
+
+func (e HasDependentsError) Error() string {
+	return e.Message
+}
+
+func (e HasDependentsError) Unwrap() error {
+	return ErrFlagHasDependents
+}
+
+// BulkItemError describes why one flag of a bulk create, import or bulk toggle was rejected
+type BulkItemError struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// BulkCreateError is returned when any flag of a bulk create is rejected. Nothing is created.
+type BulkCreateError struct {
+	Message string
+	Items   []BulkItemError
+}
+
+func (e BulkCreateError) Error() string {
+	return e.Message
+}
+
+// BulkToggleError is returned when any flag of a bulk toggle is rejected. No flag is changed.
+type BulkToggleError struct {
+	Message string
+	Items   []BulkItemError
+}
+
+func (e BulkToggleError) Error() string {
+	return e.Message
+}
+
+// ImportError is returned when any flag of an import is rejected. Nothing is imported.
+type ImportError struct {
+	Message string
+	Items   []BulkItemError
+}
+
+func (e ImportError) Error() string {
+	return e.Message
+}
+
+// CycleError is returned when a dependency walk runs into a cycle in the stored dependencies.
+// Cycle names the flags on it in dependency order.
+type CycleError struct {
+	Message string
+	Cycle   []string
+}
+
+func (e CycleError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Message, strings.Join(e.Cycle, " -> "))
+}
+
+func (e CycleError) Unwrap() error {
+	return ErrCircularDependency
+}
+
+// FlagService defines the interface for flag business logic
+type FlagService interface {
+	CreateFlag(ctx context.Context, req validator.FlagCreateRequest, actor string) (*entity.Flag, error)
+	BulkCreateFlags(ctx context.Context, req validator.FlagBulkCreateRequest, actor string) ([]*entity.Flag, error)
+	UpdateFlag(ctx context.Context, flagID int64, req validator.FlagUpdateRequest, actor string) (*entity.Flag, error)
+	AddDependency(ctx context.Context, flagID int64, req validator.FlagDependencyRequest, actor string) (*entity.Flag, error)
+	RemoveDependency(ctx context.Context, flagID, dependsOnID int64, actor string) (*entity.Flag, error)
+	DeleteFlag(ctx context.Context, flagID int64, actor, reason string) error
+	EnableFlag(ctx context.Context, flagID int64, actor, reason string) (*entity.StatusChange, error)
+	DisableFlag(ctx context.Context, flagID int64, actor, reason string) (*entity.StatusChange, error)
+	ToggleFlag(ctx context.Context, flagID int64, req validator.FlagToggleRequest, actor string) (*entity.StatusChange, error)
+	BulkToggleFlags(ctx context.Context, req validator.FlagBulkToggleRequest, actor string) ([]*entity.BulkToggleItem, error)
+	ToggleFlagInEnvironment(ctx context.Context, flagID int64, env string, req validator.FlagToggleRequest, actor string) (*entity.StatusChange, error)
+	ListFlagEnvironments(ctx context.Context, flagID int64) ([]*entity.FlagEnvironmentStatus, error)
+	GetFlagStates(ctx context.Context, flagID int64) (map[string]entity.FlagStatus, error)
+	ListEnvironments(ctx context.Context) ([]string, error)
+	DiffEnvironments(ctx context.Context, from, to string) (*entity.EnvironmentDiff, error)
+	CreateEnvironment(ctx context.Context, req validator.EnvironmentCreateRequest, actor string) error
+	DeleteEnvironment(ctx context.Context, name, actor string) error
+	GetFlag(ctx context.Context, flagID int64) (*entity.Flag, error)
+	GetFlagByName(ctx context.Context, name string) (*entity.Flag, error)
+	RenameFlag(ctx context.Context, flagID int64, req validator.FlagRenameRequest, actor string) (*entity.Flag, error)
+	GetFlagDependents(ctx context.Context, flagID int64) ([]*entity.Flag, error)
+	PreviewCascadeDisable(ctx context.Context, flagID int64) ([]*entity.Flag, error)
+	GetFlagDetail(ctx context.Context, flagID int64, include entity.FlagDetailInclude) (*entity.FlagDetail, error)
+	ListFlags(ctx context.Context) ([]*entity.Flag, error)
+	ListFlagsPaginated(ctx context.Context, filter entity.FlagFilter, limit, offset int) ([]*entity.Flag, int, error)
+	ExpandDependencies(ctx context.Context, flags ...*entity.Flag) error
+	GetFlagAuditLogs(ctx context.Context, flagID int64, filter entity.AuditFilter) ([]*entity.AuditLog, error)
+	ListAuditLogs(ctx context.Context, limit, offset int) ([]*entity.AuditLog, error)
+	SetMaintenance(ctx context.Context, flagID int64, actor, reason string) error
+	ResumeFlag(ctx context.Context, flagID int64, req validator.FlagResumeRequest, actor string) error
+	LockFlag(ctx context.Context, flagID int64, actor, reason string) error
+	UnlockFlag(ctx context.Context, flagID int64, actor, reason string) error
+	ArchiveFlag(ctx context.Context, flagID int64, actor, reason string) error
+	RestoreFlag(ctx context.Context, flagID int64, actor, reason string) (*entity.Flag, error)
+	EnableWhenReady(ctx context.Context, flagID int64, actor, reason string) (*entity.PendingEnable, error)
+	CancelPendingEnable(ctx context.Context, flagID, pendingID int64, actor string) error
+	ProcessPendingEnables(ctx context.Context) error
+	ProcessPendingCascades(ctx context.Context) error
+	ScheduleChange(ctx context.Context, flagID int64, req validator.FlagScheduleRequest, actor string) (*entity.ScheduledChange, error)
+	ProcessScheduledChanges(ctx context.Context) error
+	GetDependencyGraph(ctx context.Context, rootID int64, depth int) (*entity.DependencyGraph, error)
+	GetClosureSize(ctx context.Context, flagID int64) (*entity.ClosureSize, error)
+	GetEnablePlan(ctx context.Context, flagID int64) (*entity.EnablePlan, error)
+	SatisfyDependencies(ctx context.Context, flagID int64, actor, reason string) ([]*entity.Flag, error)
+	StreamAuditLogs(ctx context.Context, filter entity.AuditFilter) (<-chan *entity.AuditLog, error)
+	StreamFlagChanges(ctx context.Context) <-chan *entity.FlagStatusEvent
+	GetAuditReport(ctx context.Context, groupBy entity.AuditReportGroupBy, from, to time.Time) (*entity.AuditReport, error)
+	IsFlagEnabled(ctx context.Context, ref string) (bool, error)
+	EvaluateFlag(ctx context.Context, ref, userID string) (*entity.FlagEvaluation, error)
+	EvaluateFlags(ctx context.Context, req validator.FlagEvaluateRequest) (*entity.EvaluationResult, error)
+	ScheduleRollout(ctx context.Context, flagID int64, req validator.FlagRolloutScheduleRequest, actor string) (*entity.RolloutSchedule, error)
+	GetRolloutSchedule(ctx context.Context, flagID int64) (*entity.RolloutSchedule, error)
+	PauseRollout(ctx context.Context, flagID int64, actor, reason string) (*entity.RolloutSchedule, error)
+	ResumeRollout(ctx context.Context, flagID int64, actor, reason string) (*entity.RolloutSchedule, error)
+	AbortRollout(ctx context.Context, flagID int64, actor, reason string) (*entity.RolloutSchedule, error)
+	RollbackRollout(ctx context.Context, flagID int64, actor, reason string) (*entity.RolloutSchedule, error)
+	ProcessRolloutSchedules(ctx context.Context) error
+	ExportFlag(ctx context.Context, flagID int64) (*entity.FlagExport, error)
+	ExportFlags(ctx context.Context) (*entity.FlagExport, error)
+	ImportFlags(ctx context.Context, req validator.FlagImportRequest, actor string) (*entity.FlagImportResult, error)
+	SyncFlags(ctx context.Context, req validator.FlagSyncRequest, actor string) (*entity.FlagImportResult, error)
+	ListFlappyFlags(ctx context.Context, windowDays, minToggles int) ([]*entity.FlappyFlag, error)
+	ListUnusedFlags(ctx context.Context, days int) ([]*entity.Flag, error)
+	GetFlagStats(ctx context.Context) (*entity.FlagStats, error)
+	FlushEvaluations(ctx context.Context) error
+	FlushAuditRetries(ctx context.Context) error
+	ScanDependencyDrift(ctx context.Context) error
+	ExpireFlags(ctx context.Context) error
+	PurgeIdempotencyKeys(ctx context.Context) error
+	RestoreCascade(ctx context.Context, flagID int64, actor, reason string) (*entity.CascadeRestoreResult, error)
+	RestoreSubtree(ctx context.Context, flagID int64, req validator.FlagRestoreSubtreeRequest, actor string) (*entity.SubtreeRestoreResult, error)
+}
+
+type flagService struct {
+	flagRepo     repository.FlagRepository
+	auditRepo    repository.AuditRepository
+	pendingRepo  repository.PendingEnableRepository
+	cascadeRepo  repository.PendingCascadeRepository
+	scheduleRepo repository.ScheduledChangeRepository
+	envRepo      repository.EnvironmentRepository // nil limits status changes to the global environment
+	events       *events.Hub
+	logger       *logger.Logger
+
+	cascadeEventRepo repository.CascadeEventRepository // nil disables cascade restore
+
+	rolloutRepo repository.RolloutScheduleRepository // nil disables rollout schedules
+
+	idempotencyRepo repository.IdempotencyKeyRepository // nil ignores idempotency keys
+	idempotencyTTL  time.Duration
+
+	syncClient *http.Client // fetches the export of a sync source
+
+	graphNodeLimit int
+	confirmations  *confirmationTokens
+	cascadeGrace   time.Duration // zero cascades immediately
+	now            func() time.Time
+	evaluations    *evaluationTracker
+	drift          *driftTracker
+	auditRetries   *AuditRetryQueue // nil drops audit entries whose write failed
+	strictAudit    bool             // status changes roll back when their audit entry fails
+
+	driftAutoCorrect bool // cascade drifted flags instead of only reporting them
+
+	// Organisation rules on top of the built-in validation; nil policies enforce nothing
+	actorPolicy  *validator.ActorPolicy
+	namePolicy   *validator.NamePolicy
+	reasonPolicy *validator.ReasonPolicy
+}
+
+// Option configures optional collaborators of the flag service
+type Option func(*flagService)
+
+// WithPendingEnableRepository enables the enable-when-ready feature
+func WithPendingEnableRepository(repo repository.PendingEnableRepository) Option {
+	return func(s *flagService) {
+		s.pendingRepo = repo
+	}
+}
+
+// WithScheduledChangeRepository enables scheduling enables and disables for later
+func WithScheduledChangeRepository(repo repository.ScheduledChangeRepository) Option {
+	return func(s *flagService) {
+		s.scheduleRepo = repo
+	}
+}
+
+// WithRolloutScheduleRepository enables canary rollout schedules
+func WithRolloutScheduleRepository(repo repository.RolloutScheduleRepository) Option {
+	return func(s *flagService) {
+		s.rolloutRepo = repo
+	}
+}
+
+// WithEnvironmentRepository enables status changes scoped to an environment other than global
+func WithEnvironmentRepository(repo repository.EnvironmentRepository) Option {
+	return func(s *flagService) {
+		s.envRepo = repo
+	}
+}
+
+// WithIdempotencyKeys makes toggles sent with an idempotency key apply at most once while the
+// key is younger than ttl
+func WithIdempotencyKeys(repo repository.IdempotencyKeyRepository, ttl time.Duration) Option {
+	return func(s *flagService) {
+		if ttl <= 0 {
+			ttl = DefaultIdempotencyKeyTTL
+		}
+		s.idempotencyRepo = repo
+		s.idempotencyTTL = ttl
+	}
+}
+
+// WithCascadeGracePeriod defers cascade-disabling dependents until grace has passed after a
+// disable. Re-enabling the flag within the window cancels the cascade.
+func WithCascadeGracePeriod(repo repository.PendingCascadeRepository, grace time.Duration) Option {
+	return func(s *flagService) {
+		s.cascadeRepo = repo
+		s.cascadeGrace = grace
+	}
+}
+
+// WithCascadeEventRepository records which flags each cascade disabled, enabling RestoreCascade
+func WithCascadeEventRepository(repo repository.CascadeEventRepository) Option {
+	return func(s *flagService) {
+		s.cascadeEventRepo = repo
+	}
+}
+
+// WithDriftAutoCorrect makes ScanDependencyDrift disable drifted flags instead of only
+// reporting them
+func WithDriftAutoCorrect(enabled bool) Option {
+	return func(s *flagService) {
+		s.driftAutoCorrect = enabled
+	}
+}
+
+// WithAuditRetryQueue queues audit entries whose write failed for FlushAuditRetries to retry
+func WithAuditRetryQueue(queue *AuditRetryQueue) Option {
+	return func(s *flagService) {
+		s.auditRetries = queue
+	}
+}
+
+// WithStrictAudit makes a failed audit write fail the status change it records, rolling the
+// change back, instead of only being logged
+func WithStrictAudit(enabled bool) Option {
+	return func(s *flagService) {
+		s.strictAudit = enabled
+	}
+}
+
+// WithEventHub sets the hub that audit entries are published to. Without it a private hub is used.
+func WithEventHub(hub *events.Hub) Option {
+	return func(s *flagService) {
+		s.events = hub
+	}
+}
+
+// WithGraphNodeLimit overrides the maximum number of nodes returned by GetDependencyGraph
+func WithGraphNodeLimit(limit int) Option {
+	return func(s *flagService) {
+		s.graphNodeLimit = limit
+	}
+}
+
+// WithConfirmationSecret sets the secret and lifetime of enable confirmation tokens for
+// high-risk flags. Without it a random per-process secret is used.
+func WithConfirmationSecret(secret []byte, ttl time.Duration) Option {
+	return func(s *flagService) {
+		s.confirmations = newConfirmationTokens(secret, ttl)
+	}
+}
+
+// WithSyncClient sets the HTTP client that fetches a sync source's export
+func WithSyncClient(client *http.Client) Option {
+	return func(s *flagService) {
+		s.syncClient = client
+	}
+}
+
+// WithActorPolicy restricts which actors may make changes
+func WithActorPolicy(policy *validator.ActorPolicy) Option {
+	return func(s *flagService) {
+		s.actorPolicy = policy
+	}
+}
+
+// WithNamePolicy enforces a naming convention on created, imported and renamed flags
+func WithNamePolicy(policy *validator.NamePolicy) Option {
+	return func(s *flagService) {
+		s.namePolicy = policy
+	}
+}
+
+// WithReasonPolicy enforces change-management rules on the reasons given for changes
+func WithReasonPolicy(policy *validator.ReasonPolicy) Option {
+	return func(s *flagService) {
+		s.reasonPolicy = policy
+	}
+}
+
+// WithClock replaces time.Now when deciding whether a cascade grace period has passed
+func WithClock(now func() time.Time) Option {
+	return func(s *flagService) {
+		s.now = now
+	}
+}
+
+func NewFlagService(flagRepo repository.FlagRepository, auditRepo repository.AuditRepository, log *logger.Logger, opts ...Option) FlagService {
+	s := &flagService{
+		flagRepo:       flagRepo,
+		auditRepo:      auditRepo,
+		logger:         log,
+		graphNodeLimit: DefaultGraphNodeLimit,
+		now:            time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.confirmations == nil {
+		s.confirmations = newConfirmationTokens(nil, DefaultConfirmationTTL)
+	}
+	if s.events == nil {
+		s.events = events.NewHub()
+	}
+	if s.syncClient == nil {
+		s.syncClient = &http.Client{Timeout: DefaultSyncTimeout}
+	}
+	s.evaluations = newEvaluationTracker()
+	s.drift = newDriftTracker()
+	return s
+}
+
+func (s *flagService) CreateFlag(ctx context.Context, req validator.FlagCreateRequest, actor string) (*entity.Flag, error) {
+	// Validate request
+	if err := s.validateFlagCreateRequest(req); err != nil {
+		s.log(ctx).Warnw("Invalid flag creation request", "error", err, "actor", actor)
+		return nil, err
+	}
+
+	// Validate actor
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrExpiryInPast
+	}
+
+	if len(req.DependencyNames) > 0 {
+		dependencies, err := s.resolveDependencyNames(ctx, req.DependencyNames)
+		if err != nil {
+			return nil, err
+		}
+		req.Dependencies = dependencies
+	}
+
+	// Validate dependencies exist
+	if len(req.Dependencies) > 0 {
+		if err := s.validateDependenciesExist(ctx, req.Dependencies); err != nil {
+			return nil, err
+		}
+
+		// Check for circular dependencies
+		hasCircular, err := s.flagRepo.HasCircularDependency(ctx, 0, req.Dependencies)
+		if errors.Is(err, repository.ErrDependencyTooDeep) {
+			return nil, fmt.Errorf("%w: %v", ErrDependencyTooDeep, err)
+		}
+		if err != nil {
+			s.log(ctx).Errorw("Failed to check circular dependency", "error", err)
+			return nil, fmt.Errorf("failed to validate dependencies: %w", err)
+		}
+		if hasCircular {
+			s.log(ctx).Warnw("Circular dependency detected", "dependencies", req.Dependencies, "actor", actor)
+			return nil, ErrCircularDependency
+		}
+	}
+
+	// Create flag entity
+	cascadeStrategy := entity.CascadeStrategy(req.CascadeStrategy)
+	if cascadeStrategy == "" {
+		cascadeStrategy = entity.CascadeDisable
+	}
+
+	disablePolicy := entity.DisablePolicy(req.DisablePolicy)
+	if disablePolicy == "" {
+		disablePolicy = entity.DisablePolicyCascade
+	}
+
+	rollout := entity.FullRollout
+	if req.RolloutPercentage != nil {
+		rollout = *req.RolloutPercentage
+	}
+
+	flag := &entity.Flag{
+		Name:              req.Name,
+		Description:       req.Description,
+		Status:            entity.FlagDisabled, // Always start disabled
+		CascadeStrategy:   cascadeStrategy,
+		DisablePolicy:     disablePolicy,
+		HighRisk:          req.HighRisk,
+		RolloutPercentage: rollout,
+		ExpiresAt:         req.ExpiresAt,
+		CreatedBy:         actor,
+		UpdatedBy:         actor,
+	}
+
+	// Create the flag and its dependencies together
+	var flagID int64
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		flagID, err = s.flagRepo.CreateFlag(ctx, flag)
+		if err != nil {
+			if errors.Is(err, repository.ErrFlagAlreadyExists) {
+				return ErrFlagAlreadyExists
+			}
+			s.log(ctx).Errorw("Failed to create flag", "error", err, "name", req.Name)
+			return fmt.Errorf("failed to create flag: %w", err)
+		}
+
+		// The new ID is only known now; the existence check above cannot catch it
+		if slices.Contains(req.Dependencies, flagID) {
+			s.log(ctx).Warnw("Flag cannot depend on itself", "flagID", flagID, "actor", actor)
+			return ErrSelfDependency
+		}
+
+		for _, depID := range req.Dependencies {
+			if err := s.flagRepo.AddDependency(ctx, flagID, depID); err != nil {
+				s.log(ctx).Errorw("Failed to add dependency", "error", err, "flagID", flagID, "depID", depID)
+				return fmt.Errorf("failed to add dependency: %w", err)
+			}
+		}
+		if len(req.Tags) > 0 {
+			if err := s.flagRepo.SetTags(ctx, flagID, req.Tags); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	flag.ID = flagID
+
+	flag.Dependencies = req.Dependencies
+	flag.Tags = req.Tags
+
+	// Create audit log
+	auditLog := entity.NewAuditLog(flagID, entity.ActionCreate, actor, "Flag created")
+	if err := s.recordAudit(ctx, auditLog); err != nil {
+		s.log(ctx).Warnw("Failed to create audit log", "error", err, "flagID", flagID)
+	}
+	s.auditDependencyChanges(ctx, flagID, entity.ActionAddDependency, req.Dependencies, true, actor)
+
+	s.log(ctx).Infow("Flag created successfully", "flagID", flagID, "name", req.Name, "actor", actor)
+	return flag, nil
+}
+
+// UpdateFlag changes a flag's description, tags and rollout and replaces its dependency set. Only the
+// difference between the current and requested dependencies is written. An enabled flag may
+// only gain dependencies that are enabled.
+func (s *flagService) UpdateFlag(ctx context.Context, flagID int64, req validator.FlagUpdateRequest, actor string) (*entity.Flag, error) {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+	if err := validator.ValidateFlagUpdateRequest(req); err != nil {
+		s.log(ctx).Warnw("Invalid flag update request", "error", err, "actor", actor)
+		return nil, err
+	}
+
+	// The flag is read and every check runs in the transaction that writes the change, so a
+	// concurrent change cannot slip in between the checks and the write
+	var flag *entity.Flag
+	var added, removed []int64
+	var changed []string
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		flag, err = s.flagRepo.GetFlagByID(ctx, flagID)
+		if err != nil {
+			if errors.Is(err, repository.ErrFlagNotFound) {
+				return ErrFlagNotFound
+			}
+			return fmt.Errorf("failed to get flag: %w", err)
+		}
+		if flag.Locked {
+			return ErrFlagLocked
+		}
+
+		// Omitted dependencies are left alone; an empty list clears them
+		if req.Dependencies != nil {
+			requested := make(map[int64]bool, len(req.Dependencies))
+			for _, depID := range req.Dependencies {
+				if depID == flagID {
+					s.log(ctx).Warnw("Flag cannot depend on itself", "flagID", flagID, "actor", actor)
+					return ErrSelfDependency
+				}
+				requested[depID] = true
+			}
+			current := make(map[int64]bool, len(flag.Dependencies))
+			for _, depID := range flag.Dependencies {
+				current[depID] = true
+			}
+
+			for _, depID := range req.Dependencies {
+				if !current[depID] {
+					added = append(added, depID)
+					current[depID] = true // skip duplicates in the request
+				}
+			}
+			for _, depID := range flag.Dependencies {
+				if !requested[depID] {
+					removed = append(removed, depID)
+				}
+			}
+		}
+		descriptionChanged := req.Description != nil && *req.Description != flag.Description
+		if descriptionChanged {
+			changed = append(changed, "description")
+		}
+		tagsChanged := req.Tags != nil && !maps.Equal(req.Tags, flag.Tags)
+		if tagsChanged {
+			changed = append(changed, "tags")
+		}
+		rolloutChanged := req.RolloutPercentage != nil && *req.RolloutPercentage != flag.RolloutPercentage
+		if rolloutChanged {
+			changed = append(changed, fmt.Sprintf("rollout percentage from %d%% to %d%%", flag.RolloutPercentage, *req.RolloutPercentage))
+		}
+
+		if len(added) > 0 {
+			if err := s.validateDependenciesExist(ctx, added); err != nil {
+				return err
+			}
+
+			hasCircular, err := s.flagRepo.HasCircularDependency(ctx, flagID, added)
+			if errors.Is(err, repository.ErrDependencyTooDeep) {
+				return fmt.Errorf("%w: %v", ErrDependencyTooDeep, err)
+			}
+			if err != nil {
+				s.log(ctx).Errorw("Failed to check circular dependency", "error", err)
+				return fmt.Errorf("failed to validate dependencies: %w", err)
+			}
+			if hasCircular {
+				s.log(ctx).Warnw("Circular dependency detected", "flagID", flagID, "dependencies", added, "actor", actor)
+				return ErrCircularDependency
+			}
+
+			// An enabled flag must never be left depending on a flag that is not enabled
+			if flag.IsEnabled() {
+				if err := s.checkDependenciesActive(ctx, &entity.Flag{ID: flagID, Dependencies: added}, actor); err != nil {
+					return err
+				}
+			}
+		}
+
+		if descriptionChanged {
+			if err := s.flagRepo.UpdateFlagDescription(ctx, flagID, *req.Description, actor); err != nil {
+				return err
+			}
+		}
+		if tagsChanged {
+			if err := s.flagRepo.SetTags(ctx, flagID, req.Tags); err != nil {
+				return err
+			}
+		}
+		if rolloutChanged {
+			if err := s.flagRepo.UpdateFlagRollout(ctx, flagID, *req.RolloutPercentage, actor); err != nil {
+				return err
+			}
+		}
+		for _, depID := range removed {
+			if err := s.flagRepo.RemoveDependency(ctx, flagID, depID); err != nil {
+				return fmt.Errorf("failed to remove dependency %d: %w", depID, err)
+			}
+		}
+		for _, depID := range added {
+			if err := s.flagRepo.AddDependency(ctx, flagID, depID); err != nil {
+				return fmt.Errorf("failed to add dependency %d: %w", depID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.log(ctx).Errorw("Failed to update flag", "error", err, "flagID", flagID, "actor", actor)
+		return nil, err
+	}
+	if len(added) == 0 && len(removed) == 0 && len(changed) == 0 {
+		return flag, nil
+	}
+
+	if len(changed) > 0 {
+		reason := "Changed " + strings.Join(changed, " and ")
+		if err := s.recordAudit(ctx, entity.NewAuditLog(flagID, entity.ActionUpdate, actor, reason)); err != nil {
+			s.log(ctx).Warnw("Failed to create audit log", "error", err, "flagID", flagID)
+		}
+	}
+	s.auditDependencyChanges(ctx, flagID, entity.ActionUpdate, removed, false, actor)
+	s.auditDependencyChanges(ctx, flagID, entity.ActionUpdate, added, true, actor)
+
+	updated, err := s.flagRepo.GetFlagByID(ctx, flagID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get flag: %w", err)
+	}
+
+	s.log(ctx).Infow("Flag updated", "flagID", flagID, "added", added, "removed", removed,
+		"changed", changed, "actor", actor)
+	return updated, nil
+}
+
+// RenameFlag gives a flag a new name that no other flag has, returning the renamed flag.
+// Dependencies refer to flags by ID, so they are unaffected. Archived flags cannot be renamed.
+func (s *flagService) RenameFlag(ctx context.Context, flagID int64, req validator.FlagRenameRequest, actor string) (*entity.Flag, error) {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if err := validator.ValidateFlagRenameRequest(req); err != nil {
+		return nil, err
+	}
+	if err := s.namePolicy.Check(req.Name); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+
+	flag, err := s.GetFlag(ctx, flagID)
+	if err != nil {
+		return nil, err
+	}
+	if flag.Locked {
+		return nil, ErrFlagLocked
+	}
+	if flag.IsArchived() {
+		return nil, ErrFlagArchived
+	}
+	if flag.Name == req.Name {
+		return flag, nil
+	}
+
+	if err := s.flagRepo.RenameFlag(ctx, flagID, req.Name, actor); err != nil {
+		if errors.Is(err, repository.ErrFlagAlreadyExists) {
+			return nil, ErrFlagAlreadyExists
+		}
+		s.log(ctx).Errorw("Failed to rename flag", "error", err, "flagID", flagID, "actor", actor)
+		return nil, err
+	}
+
+	reason := fmt.Sprintf("Renamed from %s to %s", flag.Name, req.Name)
+	if err := s.recordAudit(ctx, entity.NewAuditLog(flagID, entity.ActionUpdate, actor, reason)); err != nil {
+		s.log(ctx).Warnw("Failed to create audit log", "error", err, "flagID", flagID)
+	}
+
+	renamed, err := s.flagRepo.GetFlagByID(ctx, flagID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get flag: %w", err)
+	}
+
+	s.log(ctx).Infow("Flag renamed", "flagID", flagID, "from", flag.Name, "to", req.Name, "actor", actor)
+	return renamed, nil
+}
+
+// AddDependency attaches a single dependency to a flag and returns the updated flag. An enabled
+// flag may only gain a dependency that is enabled. Attaching an existing dependency is a no-op.
+func (s *flagService) AddDependency(ctx context.Context, flagID int64, req validator.FlagDependencyRequest, actor string) (*entity.Flag, error) {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if err := validator.ValidateFlagDependencyRequest(req); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+	dependsOnID := req.DependsOnID
+	if dependsOnID == flagID {
+		s.log(ctx).Warnw("Flag cannot depend on itself", "flagID", flagID, "actor", actor)
+		return nil, ErrSelfDependency
+	}
+
+	// The checks run in the transaction that adds the edge, so the flag or the dependency
+	// cannot change between them and the write
+	var flag *entity.Flag
+	attached := false
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		flag, err = s.flagRepo.GetFlagByID(ctx, flagID)
+		if err != nil {
+			if errors.Is(err, repository.ErrFlagNotFound) {
+				return ErrFlagNotFound
+			}
+			return fmt.Errorf("failed to get flag: %w", err)
+		}
+		if flag.Locked {
+			return ErrFlagLocked
+		}
+		if slices.Contains(flag.Dependencies, dependsOnID) {
+			return nil
+		}
+
+		if _, err := s.flagRepo.GetFlagByID(ctx, dependsOnID); err != nil {
+			if errors.Is(err, repository.ErrFlagNotFound) {
+				return fmt.Errorf("%w: dependency %d", ErrFlagNotFound, dependsOnID)
+			}
+			return fmt.Errorf("failed to get dependency flag: %w", err)
+		}
+
+		hasCircular, err := s.flagRepo.HasCircularDependency(ctx, flagID, []int64{dependsOnID})
+		if errors.Is(err, repository.ErrDependencyTooDeep) {
+			return fmt.Errorf("%w: %v", ErrDependencyTooDeep, err)
+		}
+		if err != nil {
+			s.log(ctx).Errorw("Failed to check circular dependency", "error", err)
+			return fmt.Errorf("failed to validate dependencies: %w", err)
+		}
+		if hasCircular {
+			s.log(ctx).Warnw("Circular dependency detected", "flagID", flagID, "depID", dependsOnID, "actor", actor)
+			return ErrCircularDependency
+		}
+
+		// An enabled flag must never be left depending on a flag that is not enabled
+		if flag.IsEnabled() {
+			if err := s.checkDependenciesActive(ctx, &entity.Flag{ID: flagID, Dependencies: []int64{dependsOnID}}, actor); err != nil {
+				return err
+			}
+		}
+
+		if err := s.flagRepo.AddDependency(ctx, flagID, dependsOnID); err != nil {
+			s.log(ctx).Errorw("Failed to add dependency", "error", err, "flagID", flagID, "depID", dependsOnID)
+			return fmt.Errorf("failed to add dependency: %w", err)
+		}
+		attached = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !attached {
+		return flag, nil
+	}
+	s.auditDependencyChanges(ctx, flagID, entity.ActionUpdate, []int64{dependsOnID}, true, actor)
+
+	flag.AddDependency(dependsOnID)
+
+	s.log(ctx).Infow("Dependency added", "flagID", flagID, "depID", dependsOnID, "actor", actor)
+	return flag, nil
+}
+
+// RemoveDependency detaches a single dependency from a flag and returns the updated flag.
+// Removing a dependency never breaks an enabled flag, so no status check is needed.
+func (s *flagService) RemoveDependency(ctx context.Context, flagID, dependsOnID int64, actor string) (*entity.Flag, error) {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if err := validator.ValidateFlagID(dependsOnID); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+
+	var flag *entity.Flag
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		flag, err = s.flagRepo.GetFlagByID(ctx, flagID)
+		if err != nil {
+			if errors.Is(err, repository.ErrFlagNotFound) {
+				return ErrFlagNotFound
+			}
+			return fmt.Errorf("failed to get flag: %w", err)
+		}
+		if flag.Locked {
+			return ErrFlagLocked
+		}
+
+		if err := s.flagRepo.RemoveDependency(ctx, flagID, dependsOnID); err != nil {
+			if errors.Is(err, repository.ErrDependencyNotFound) {
+				return ErrDependencyNotFound
+			}
+			s.log(ctx).Errorw("Failed to remove dependency", "error", err, "flagID", flagID, "depID", dependsOnID)
+			return fmt.Errorf("failed to remove dependency: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.auditDependencyChanges(ctx, flagID, entity.ActionUpdate, []int64{dependsOnID}, false, actor)
+
+	flag.RemoveDependency(dependsOnID)
+
+	s.log(ctx).Infow("Dependency removed", "flagID", flagID, "depID", dependsOnID, "actor", actor)
+	return flag, nil
+}
+
+// DeleteFlag removes a flag that no other flag depends on. The audit trail of the flag is
+// kept and ends with a delete entry.
+func (s *flagService) DeleteFlag(ctx context.Context, flagID int64, actor, reason string) error {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return err
+	}
+	if err := s.reasonPolicy.Check(string(entity.ActionDelete), reason); err != nil {
+		return err
+	}
+
+	// Checking for dependents in the deleting transaction keeps a dependency added
+	// concurrently from being left pointing at a deleted flag
+	var flag *entity.Flag
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		flag, err = s.flagRepo.GetFlagByID(ctx, flagID)
+		if err != nil {
+			if errors.Is(err, repository.ErrFlagNotFound) {
+				return ErrFlagNotFound
+			}
+			return fmt.Errorf("failed to get flag: %w", err)
+		}
+		if flag.Locked {
+			return ErrFlagLocked
+		}
+
+		dependentIDs, err := s.flagRepo.GetDependents(ctx, flagID)
+		if err != nil {
+			return fmt.Errorf("failed to get dependents: %w", err)
+		}
+		if len(dependentIDs) > 0 {
+			dependents, err := s.flagRepo.GetFlagsByIDs(ctx, dependentIDs)
+			if err != nil {
+				return fmt.Errorf("failed to get dependents: %w", err)
+			}
+			names := make([]string, 0, len(dependents))
+			for _, dependent := range dependents {
+				names = append(names, dependent.Name)
+			}
+			s.log(ctx).Warnw("Cannot delete flag with dependents", "flagID", flagID, "dependents", names, "actor", actor)
+			return HasDependentsError{
+				Message:    "Flag has dependents",
+				Dependents: names,
+			}
+		}
+
+		if err := s.flagRepo.DeleteFlag(ctx, flagID); err != nil {
+			if errors.Is(err, repository.ErrFlagNotFound) {
+				return ErrFlagNotFound
+			}
+			return fmt.Errorf("failed to delete flag: %w", err)
+		}
+		if err := s.recordAudit(ctx, entity.NewAuditLog(flagID, entity.ActionDelete, actor, reason)); err != nil {
+			return fmt.Errorf("failed to delete flag: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		s.log(ctx).Errorw("Failed to delete flag", "error", err, "flagID", flagID)
+		return err
+	}
+
+	s.log(ctx).Infow("Flag deleted successfully", "flagID", flagID, "name", flag.Name, "actor", actor, "reason", reason)
+	return nil
+}
+
+// EnableFlag enables the flag once its dependencies are. A disabled high-risk flag is refused
+// with ErrConfirmationRequired; it can only be enabled through a confirmed toggle.
+func (s *flagService) EnableFlag(ctx context.Context, flagID int64, actor, reason string) (*entity.StatusChange, error) {
+	return s.enableFlag(ctx, flagID, actor, reason, false)
+}
+
+// enableFlag enables the flag; confirmed is set once a high-risk enable has been confirmed
+func (s *flagService) enableFlag(ctx context.Context, flagID int64, actor, reason string, confirmed bool) (*entity.StatusChange, error) {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+
+	// Get flag with dependencies
+	flag, err := s.flagRepo.GetFlagByID(ctx, flagID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return nil, ErrFlagNotFound
+		}
+		return nil, fmt.Errorf("failed to get flag: %w", err)
+	}
+
+	if flag.Locked {
+		return nil, ErrFlagLocked
+	}
+	if flag.IsArchived() {
+		return nil, ErrFlagArchived
+	}
+
+	// Check if already enabled
+	change := entity.NewStatusChange(flag, entity.FlagEnabled)
+	if !change.Changed {
+		return change, nil
+	}
+
+	// Flags in maintenance must be brought back explicitly via ResumeFlag
+	if flag.IsInMaintenance() {
+		return nil, ErrFlagInMaintenance
+	}
+	if flag.HighRisk && !confirmed {
+		return nil, fmt.Errorf("%w: %s", ErrConfirmationRequired, flag.Name)
+	}
+
+	// Repository-level edits can bypass cycle checks, so refuse to enable on top of a cycle
+	if flag.HasDependencies() {
+		if _, err := s.collectDependencyOrder(ctx, flagID); err != nil {
+			if errors.Is(err, ErrCircularDependency) {
+				s.log(ctx).Warnw("Cannot enable flag with cyclic dependencies", "flagID", flagID, "actor", actor)
+				return nil, err
+			}
+			return nil, fmt.Errorf("failed to check dependencies: %w", err)
+		}
+	}
+
+	// Validate dependencies are enabled
+	if err := s.checkDependenciesActive(ctx, flag, actor); err != nil {
+		return nil, err
+	}
+
+	// Enable flag
+	auditLog := entity.NewAuditLog(flagID, entity.ActionEnable, actor, reason)
+	err = s.changeStatus(ctx, auditLog, func(ctx context.Context) error {
+		return s.updateStatus(ctx, flag, entity.FlagEnabled, actor)
+	})
+	if err != nil {
+		s.log(ctx).Errorw("Failed to enable flag", "error", err, "flagID", flagID)
+		return nil, fmt.Errorf("failed to enable flag: %w", err)
+	}
+
+	// A re-enable within the grace period means the disable was a blip
+	s.cancelPendingCascade(ctx, flagID)
+
+	s.log(ctx).Infow("Flag enabled successfully", "flagID", flagID, "actor", actor, "reason", reason)
+	return change, nil
+}
+
+func (s *flagService) DisableFlag(ctx context.Context, flagID int64, actor, reason string) (*entity.StatusChange, error) {
+	return s.disableFlag(ctx, flagID, actor, reason, entity.ActionDisable)
+}
+
+// disableFlag disables the flag and cascades to its dependents, auditing the disable as action
+func (s *flagService) disableFlag(ctx context.Context, flagID int64, actor, reason string, action entity.AuditAction) (*entity.StatusChange, error) {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+
+	// Get flag
+	flag, err := s.flagRepo.GetFlagByID(ctx, flagID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return nil, ErrFlagNotFound
+		}
+		return nil, fmt.Errorf("failed to get flag: %w", err)
+	}
+
+	if flag.Locked {
+		return nil, ErrFlagLocked
+	}
+
+	// Check if already disabled
+	change := entity.NewStatusChange(flag, entity.FlagDisabled)
+	if !change.Changed {
+		return change, nil
+	}
+
+	// Block-policy flags refuse to disable instead of cascading
+	if flag.BlocksDisable() {
+		if err := s.checkNoEnabledDependents(ctx, flagID); err != nil {
+			return nil, err
+		}
+	}
+
+	// The flag and its cascade commit together, so a failure never leaves enabled dependents
+	// behind a disabled flag
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		if err := s.updateStatus(ctx, flag, entity.FlagDisabled, actor); err != nil {
+			return fmt.Errorf("failed to disable flag: %w", err)
+		}
+
+		auditLog := entity.NewAuditLog(flagID, action, actor, reason)
+		if err := s.recordAudit(ctx, auditLog); err != nil {
+			return fmt.Errorf("failed to create audit log: %w", err)
+		}
+
+		// Cascade disable dependents, deferred while a grace period is configured
+		if s.deferCascade(ctx, flagID) {
+			s.log(ctx).Infow("Cascade deferred for grace period", "flagID", flagID, "grace", s.cascadeGrace)
+			return nil
+		}
+		origin := cascadeOrigin{root: flag, event: eventBy("was turned off", actor)}
+		if err := s.cascadeDisableDependents(ctx, origin); err != nil {
+			if !errors.Is(err, ErrCircularDependency) {
+				return fmt.Errorf("failed to cascade disable dependents: %w", err)
+			}
+			// The rest of the cascade ran; the cycle itself is for an operator to fix
+			s.log(ctx).Errorw("Failed to cascade disable dependents", "error", err, "flagID", flagID)
+		}
+		return nil
+	})
+	if err != nil {
+		s.log(ctx).Errorw("Failed to disable flag", "error", err, "flagID", flagID)
+		return nil, err
+	}
+
+	s.log(ctx).Infow("Flag disabled successfully", "flagID", flagID, "actor", actor, "reason", reason)
+	return change, nil
+}
+
+func (s *flagService) ToggleFlag(ctx context.Context, flagID int64, req validator.FlagToggleRequest, actor string) (*entity.StatusChange, error) {
+	if err := validator.ValidateFlagToggleRequest(req); err != nil {
+		return nil, err
+	}
+	if err := s.reasonPolicy.Check(validator.ToggleAction(req.Enable), req.Reason); err != nil {
+		return nil, err
+	}
+
+	if req.Enable {
+		if err := s.checkEnableConfirmation(ctx, flagID, req.ConfirmationToken, actor); err != nil {
+			return nil, err
+		}
+		if req.Cascade {
+			return s.cascadeEnable(ctx, flagID, actor, req.Reason)
+		}
+		return s.enableFlag(ctx, flagID, actor, req.Reason, true)
+	}
+	return s.DisableFlag(ctx, flagID, actor, req.Reason)
+}
+
+// cascadeEnable enables the flag's disabled transitive dependencies, audited as cascade
+// enables by system, and then the flag itself. Everything is rolled back if any step fails.
+// The caller has confirmed the enable of the flag itself if it is high-risk.
+func (s *flagService) cascadeEnable(ctx context.Context, flagID int64, actor, reason string) (*entity.StatusChange, error) {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+
+	flag, err := s.flagRepo.GetFlagByID(ctx, flagID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return nil, ErrFlagNotFound
+		}
+		return nil, fmt.Errorf("failed to get flag: %w", err)
+	}
+
+	var change *entity.StatusChange
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		enabled, err := s.enablePrerequisites(ctx, flag, actor, func(dep *entity.Flag) *entity.AuditLog {
+			return entity.NewAuditLog(dep.ID, entity.ActionCascadeEnable, validator.SystemActor,
+				fmt.Sprintf("Enabled as a dependency of %s by %s: %s", flag.Name, actor, reason))
+		})
+		if err != nil {
+			return err
+		}
+
+		change, err = s.enableFlag(ctx, flagID, actor, reason, true)
+		if err != nil {
+			return err
+		}
+		for _, dep := range enabled {
+			change.CascadeEnabled = append(change.CascadeEnabled, entity.NewGraphNode(dep))
+		}
+		return nil
+	})
+	if err != nil {
+		s.log(ctx).Warnw("Failed to cascade enable flag", "error", err, "flagID", flagID, "actor", actor)
+		return nil, err
+	}
+
+	s.log(ctx).Infow("Flag cascade enabled", "flagID", flagID, "dependencies", len(change.CascadeEnabled), "actor", actor)
+	return change, nil
+}
+
+// IsFlagEnabled evaluates a flag referenced by ID or, if ref is not numeric, by name. A flag
+// is effectively enabled only if it and all of its transitive dependencies are enabled. The
+// rollout percentage is not applied; see EvaluateFlag.
+func (s *flagService) IsFlagEnabled(ctx context.Context, ref string) (bool, error) {
+	_, enabled, err := s.evaluateFlag(ctx, ref)
+	return enabled, err
+}
+
+// EvaluateFlag reports whether a flag is active, walking its transitive dependencies so a
+// flag left enabled behind a disabled dependency evaluates as off. With a user ID the rollout
+// is applied too: the flag is on for the user only if the user's bucket falls within the
+// rollout percentage.
+func (s *flagService) EvaluateFlag(ctx context.Context, ref, userID string) (*entity.FlagEvaluation, error) {
+	flag, enabled, err := s.evaluateFlag(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if enabled && userID != "" {
+		enabled = inRollout(flag, userID)
+	}
+	return &entity.FlagEvaluation{Name: flag.Name, Enabled: enabled}, nil
+}
+
+// getFlagByRef loads a flag referenced by ID or, if ref is not numeric, by name
+func (s *flagService) getFlagByRef(ctx context.Context, ref string) (*entity.Flag, error) {
+	var flag *entity.Flag
+	var err error
+	if id, parseErr := strconv.ParseInt(ref, 10, 64); parseErr == nil {
+		flag, err = s.flagRepo.GetFlagByID(ctx, id)
+	} else {
+		flag, err = s.flagRepo.GetFlagByName(ctx, ref)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return nil, ErrFlagNotFound
+		}
+		return nil, fmt.Errorf("failed to get flag: %w", err)
+	}
+	return flag, nil
+}
+
+// evaluateFlag loads the referenced flag and reports whether it is effectively enabled
+func (s *flagService) evaluateFlag(ctx context.Context, ref string) (*entity.Flag, bool, error) {
+	flag, err := s.getFlagByRef(ctx, ref)
+	if err != nil {
+		return nil, false, err
+	}
+	s.evaluations.Record(flag.ID)
+
+	if !flag.IsEnabled() {
+		return flag, false, nil
+	}
+	if !flag.HasDependencies() {
+		return flag, true, nil
+	}
+
+	dependencies, err := s.collectDependencyOrder(ctx, flag.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, dep := range dependencies {
+		if !dep.SatisfiesDependents() {
+			return flag, false, nil
+		}
+	}
+	return flag, true, nil
+}
+
+// EvaluateFlags evaluates many flags in one go with two queries however many flags are
+// requested: one for the flags, one for their dependency edges. When flags are named, only
+// those and their transitive dependencies are loaded; otherwise every flag is. A flag is
+// effectively enabled under the same rule as IsFlagEnabled, except that a flag on a
+// dependency cycle evaluates as disabled where IsFlagEnabled returns a CycleError. With a
+// key, rollout percentages are applied to it as the user ID, as in EvaluateFlag. Unknown
+// references are reported rather than failing the batch, so one stale name does not break
+// SDK startup.
+func (s *flagService) EvaluateFlags(ctx context.Context, req validator.FlagEvaluateRequest) (*entity.EvaluationResult, error) {
+	if err := validator.ValidateFlagEvaluateRequest(req); err != nil {
+		return nil, err
+	}
+
+	flags, edges, err := s.loadEvaluationGraph(ctx, req.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*entity.Flag, len(flags))
+	byName := make(map[string]*entity.Flag, len(flags))
+	for _, flag := range flags {
+		byID[flag.ID] = flag
+		byName[flag.Name] = flag
+	}
+	dependsOn := make(map[int64][]int64)
+	for _, edge := range edges {
+		if byID[edge.FlagID] != nil {
+			dependsOn[edge.FlagID] = append(dependsOn[edge.FlagID], edge.DependsOnID)
+		}
+	}
+
+	key := req.EvaluationKey()
+	result := &entity.EvaluationResult{Key: key, Flags: make(map[string]bool)}
+	selected := flags
+	if len(req.Flags) > 0 {
+		selected = make([]*entity.Flag, 0, len(req.Flags))
+		for _, ref := range req.Flags {
+			flag := byName[string(ref)]
+			if id, parseErr := strconv.ParseInt(string(ref), 10, 64); parseErr == nil {
+				flag = byID[id]
+			}
+			if flag == nil {
+				result.Unknown = append(result.Unknown, string(ref))
+				continue
+			}
+			selected = append(selected, flag)
+		}
+	}
+
+	effective := make(map[int64]bool)
+	onPath := make(map[int64]bool)
+	var isEffective func(id int64) bool
+	isEffective = func(id int64) bool {
+		if enabled, ok := effective[id]; ok {
+			return enabled
+		}
+		flag := byID[id]
+		if flag == nil || !flag.IsEnabled() || onPath[id] {
+			return false
+		}
+		onPath[id] = true
+		enabled := true
+		for _, depID := range dependsOn[id] {
+			if !isEffective(depID) {
+				enabled = false
+				break
+			}
+		}
+		delete(onPath, id)
+		effective[id] = enabled
+		return enabled
+	}
+
+	for _, flag := range selected {
+		s.evaluations.Record(flag.ID)
+		enabled := isEffective(flag.ID)
+		if enabled && key != "" {
+			enabled = inRollout(flag, key)
+		}
+		result.Flags[flag.Name] = enabled
+	}
+	return result, nil
+}
+
+// loadEvaluationGraph loads the flags EvaluateFlags needs along with their dependency edges:
+// every flag when refs is empty, otherwise the referenced flags and their transitive
+// dependencies. Numeric references are IDs, as in EvaluateFlags.
+func (s *flagService) loadEvaluationGraph(ctx context.Context, refs []validator.FlagRef) ([]*entity.Flag, []*entity.DependencyEdge, error) {
+	if len(refs) == 0 {
+		flags, err := s.flagRepo.ListFlags(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list flags: %w", err)
+		}
+		edges, err := s.flagRepo.ListDependencyEdges(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list dependency edges: %w", err)
+		}
+		return flags, edges, nil
+	}
+
+	var ids []int64
+	var names []string
+	for _, ref := range refs {
+		if id, err := strconv.ParseInt(string(ref), 10, 64); err == nil {
+			ids = append(ids, id)
+			continue
+		}
+		names = append(names, string(ref))
+	}
+
+	flags, err := s.flagRepo.GetDependencyClosure(ctx, ids, names)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load flags: %w", err)
+	}
+	closureIDs := make([]int64, 0, len(flags))
+	for _, flag := range flags {
+		closureIDs = append(closureIDs, flag.ID)
+	}
+	// Edges from flags outside the closure are dropped by the caller
+	edges, err := s.flagRepo.GetDependencyEdgesForFlags(ctx, closureIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load dependency edges: %w", err)
+	}
+	return flags, edges, nil
+}
+
+// ExportFlag returns a standalone document holding the portable definition of one flag
+func (s *flagService) ExportFlag(ctx context.Context, flagID int64) (*entity.FlagExport, error) {
+	flag, err := s.GetFlag(ctx, flagID)
+	if err != nil {
+		return nil, err
+	}
+
+	var dependencyNames []string
+	if flag.HasDependencies() {
+		dependencies, err := s.flagRepo.GetFlagsByIDs(ctx, flag.Dependencies)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dependencies: %w", err)
+		}
+		for _, dep := range dependencies {
+			dependencyNames = append(dependencyNames, dep.Name)
+		}
+	}
+
+	return &entity.FlagExport{
+		Version: entity.FlagExportVersion,
+		Flags:   []*entity.FlagDefinition{entity.NewFlagDefinition(flag, dependencyNames)},
+	}, nil
+}
+
+func (s *flagService) GetFlag(ctx context.Context, flagID int64) (*entity.Flag, error) {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+
+	flag, err := s.flagRepo.GetFlagByID(ctx, flagID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return nil, ErrFlagNotFound
+		}
+		return nil, fmt.Errorf("failed to get flag: %w", err)
+	}
+
+	return flag, nil
+}
+
+// GetFlagByName returns the flag with the given name, with its dependencies
+func (s *flagService) GetFlagByName(ctx context.Context, name string) (*entity.Flag, error) {
+	if err := validator.ValidateFlagName(name); err != nil {
+		return nil, err
+	}
+
+	flag, err := s.flagRepo.GetFlagByName(ctx, name)
+	if err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return nil, ErrFlagNotFound
+		}
+		return nil, fmt.Errorf("failed to get flag: %w", err)
+	}
+
+	return flag, nil
+}
+
+// GetFlagDependents returns the flags that directly depend on the flag, ordered by name.
+// These are the flags a disable would cascade to first.
+func (s *flagService) GetFlagDependents(ctx context.Context, flagID int64) ([]*entity.Flag, error) {
+	if _, err := s.GetFlag(ctx, flagID); err != nil {
+		return nil, err
+	}
+
+	dependentIDs, err := s.flagRepo.GetDependents(ctx, flagID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dependents: %w", err)
+	}
+	dependents, err := s.flagRepo.GetFlagsByIDs(ctx, dependentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dependents: %w", err)
+	}
+	if dependents == nil {
+		dependents = []*entity.Flag{}
+	}
+	return dependents, nil
+}
+
+// GetFlagDetail loads a flag together with the requested sections. Dependencies and dependents
+// are each loaded with a single query.
+func (s *flagService) GetFlagDetail(ctx context.Context, flagID int64, include entity.FlagDetailInclude) (*entity.FlagDetail, error) {
+	flag, err := s.GetFlag(ctx, flagID)
+	if err != nil {
+		return nil, err
+	}
+	detail := &entity.FlagDetail{Flag: flag}
+
+	if include.Dependencies {
+		dependencies, err := s.flagRepo.GetFlagsByIDs(ctx, flag.Dependencies)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dependencies: %w", err)
+		}
+		for _, dep := range dependencies {
+			detail.Dependencies = append(detail.Dependencies, entity.NewGraphNode(dep))
+		}
+	}
+
+	if include.Dependents {
+		dependentIDs, err := s.flagRepo.GetDependents(ctx, flagID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dependents: %w", err)
+		}
+		dependents, err := s.flagRepo.GetFlagsByIDs(ctx, dependentIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dependents: %w", err)
+		}
+		for _, dependent := range dependents {
+			detail.Dependents = append(detail.Dependents, entity.NewGraphNode(dependent))
+		}
+	}
+
+	if include.Audit {
+		detail.Audit, err = s.auditRepo.ListAuditLogsByFlagID(ctx, flagID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get audit logs: %w", err)
+		}
+	}
+
+	return detail, nil
+}
+
+// ListFlagsPaginated returns one page of the flags matching filter, ordered by name, and the
+// total number of matching flags.
+func (s *flagService) ListFlagsPaginated(ctx context.Context, filter entity.FlagFilter, limit, offset int) ([]*entity.Flag, int, error) {
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, 0, fmt.Errorf("%w: %s", ErrInvalidFlagStatus, filter.Status)
+	}
+	if limit < 1 || limit > MaxFlagPageSize {
+		return nil, 0, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidFlagPage, MaxFlagPageSize)
+	}
+	if offset < 0 {
+		return nil, 0, fmt.Errorf("%w: offset must not be negative", ErrInvalidFlagPage)
+	}
+
+	flags, err := s.flagRepo.ListFlagsPaginated(ctx, filter, limit, offset)
+	if err != nil {
+		s.log(ctx).Errorw("Failed to list flags", "error", err)
+		return nil, 0, fmt.Errorf("failed to list flags: %w", err)
+	}
+	if flags == nil {
+		flags = []*entity.Flag{}
+	}
+
+	total, err := s.flagRepo.CountFlags(ctx, filter)
+	if err != nil {
+		s.log(ctx).Errorw("Failed to count flags", "error", err)
+		return nil, 0, fmt.Errorf("failed to count flags: %w", err)
+	}
+
+	return flags, total, nil
+}
+
+func (s *flagService) ListFlags(ctx context.Context) ([]*entity.Flag, error) {
+	flags, err := s.flagRepo.GetFlagsWithDependencies(ctx)
+	if err != nil {
+		s.log(ctx).Errorw("Failed to list flags", "error", err)
+		return nil, fmt.Errorf("failed to list flags: %w", err)
+	}
+
+	return flags, nil
+}
+
+// ExpandDependencies fills DependenciesDetail of the given flags with the id, name and status
+// of each dependency, loading all of them in one query
+func (s *flagService) ExpandDependencies(ctx context.Context, flags ...*entity.Flag) error {
+	seen := make(map[int64]bool)
+	var dependencyIDs []int64
+	for _, flag := range flags {
+		for _, depID := range flag.Dependencies {
+			if !seen[depID] {
+				seen[depID] = true
+				dependencyIDs = append(dependencyIDs, depID)
+			}
+		}
+	}
+	if len(dependencyIDs) == 0 {
+		return nil
+	}
+
+	dependencies, err := s.flagRepo.GetFlagsByIDs(ctx, dependencyIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get dependencies: %w", err)
+	}
+	byID := make(map[int64]*entity.Flag, len(dependencies))
+	for _, dep := range dependencies {
+		byID[dep.ID] = dep
+	}
+
+	for _, flag := range flags {
+		flag.DependenciesDetail = make([]entity.GraphNode, 0, len(flag.Dependencies))
+		for _, depID := range flag.Dependencies {
+			if dep, ok := byID[depID]; ok {
+				flag.DependenciesDetail = append(flag.DependenciesDetail, entity.NewGraphNode(dep))
+			}
+		}
+	}
+	return nil
+}
+
+// GetFlagAuditLogs returns the flag's audit entries matching filter, newest first
+func (s *flagService) GetFlagAuditLogs(ctx context.Context, flagID int64, filter entity.AuditFilter) ([]*entity.AuditLog, error) {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if filter.Action != "" && !filter.Action.IsValid() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAuditAction, filter.Action)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidAuditWindow)
+	}
+
+	// Verify flag exists
+	_, err := s.flagRepo.GetFlagByID(ctx, flagID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return nil, ErrFlagNotFound
+		}
+		return nil, fmt.Errorf("failed to verify flag existence: %w", err)
+	}
+
+	logs, err := s.auditRepo.ListAuditLogsByFlagIDFiltered(ctx, flagID, filter)
+	if err != nil {
+		s.log(ctx).Errorw("Failed to get audit logs", "error", err, "flagID", flagID)
+		return nil, fmt.Errorf("failed to get audit logs: %w", err)
+	}
+
+	return logs, nil
+}
+
+// ListAuditLogs returns one page of the audit history of all flags, newest first
+func (s *flagService) ListAuditLogs(ctx context.Context, limit, offset int) ([]*entity.AuditLog, error) {
+	if limit < 1 || limit > MaxAuditPageSize {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidAuditPage, MaxAuditPageSize)
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("%w: offset must not be negative", ErrInvalidAuditPage)
+	}
+
+	logs, err := s.auditRepo.ListAllAuditLogs(ctx, limit, offset)
+	if err != nil {
+		s.log(ctx).Errorw("Failed to list audit logs", "error", err)
+		return nil, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+	if logs == nil {
+		logs = []*entity.AuditLog{}
+	}
+	return logs, nil
+}
+
+// GetAuditReport summarises the audit entries in [from, to) grouped by actor, flag or action
+func (s *flagService) GetAuditReport(ctx context.Context, groupBy entity.AuditReportGroupBy, from, to time.Time) (*entity.AuditReport, error) {
+	if !groupBy.IsValid() {
+		return nil, fmt.Errorf("%w: must be one of actor, flag, action", ErrInvalidReportGrouping)
+	}
+	if !to.After(from) {
+		return nil, fmt.Errorf("%w: to must be after from", ErrInvalidReportWindow)
+	}
+	if to.Sub(from) > MaxAuditReportWindow {
+		return nil, fmt.Errorf("%w: window may not exceed %d days", ErrInvalidReportWindow, int(MaxAuditReportWindow.Hours()/24))
+	}
+
+	rows, err := s.auditRepo.AggregateAuditLogs(ctx, groupBy, from, to)
+	if err != nil {
+		s.log(ctx).Errorw("Failed to aggregate audit logs", "error", err, "groupBy", groupBy)
+		return nil, fmt.Errorf("failed to build audit report: %w", err)
+	}
+
+	return buildAuditReport(from, to, groupBy, rows), nil
+}
+
+// ListFlappyFlags returns flags whose status changed at least minToggles times in the last
+// windowDays days, most unstable first
+func (s *flagService) ListFlappyFlags(ctx context.Context, windowDays, minToggles int) ([]*entity.FlappyFlag, error) {
+	if windowDays < 1 || windowDays > MaxFlappinessWindowDays {
+		return nil, fmt.Errorf("%w: window_days must be between 1 and %d", ErrInvalidFlappinessQuery, MaxFlappinessWindowDays)
+	}
+	if minToggles < 1 {
+		return nil, fmt.Errorf("%w: min_toggles must be at least 1", ErrInvalidFlappinessQuery)
+	}
+
+	since := time.Now().AddDate(0, 0, -windowDays)
+	flags, err := s.auditRepo.ListFlappyFlags(ctx, since, minToggles)
+	if err != nil {
+		s.log(ctx).Errorw("Failed to list flappy flags", "error", err)
+		return nil, fmt.Errorf("failed to list flappy flags: %w", err)
+	}
+	if flags == nil {
+		flags = []*entity.FlappyFlag{}
+	}
+	return flags, nil
+}
+
+// GetFlagStats returns aggregate counts over the flags that are not archived
+func (s *flagService) GetFlagStats(ctx context.Context) (*entity.FlagStats, error) {
+	stats, err := s.flagRepo.GetFlagStats(ctx)
+	if err != nil {
+		s.log(ctx).Errorw("Failed to get flag stats", "error", err)
+		return nil, fmt.Errorf("failed to get flag stats: %w", err)
+	}
+	stats.GeneratedAt = time.Now().UTC()
+	return stats, nil
+}
+
+// ListUnusedFlags returns flags older than the window that no client evaluated within it
+func (s *flagService) ListUnusedFlags(ctx context.Context, days int) ([]*entity.Flag, error) {
+	if days < 1 || days > MaxUnusedWindowDays {
+		return nil, fmt.Errorf("%w: days must be between 1 and %d", ErrInvalidUnusedWindow, MaxUnusedWindowDays)
+	}
+
+	flags, err := s.flagRepo.ListUnusedFlags(ctx, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		s.log(ctx).Errorw("Failed to list unused flags", "error", err)
+		return nil, fmt.Errorf("failed to list unused flags: %w", err)
+	}
+	if flags == nil {
+		flags = []*entity.Flag{}
+	}
+	return flags, nil
+}
+
+// buildAuditReport folds rows ordered by key into one group per key
+func buildAuditReport(from, to time.Time, groupBy entity.AuditReportGroupBy, rows []*repository.AuditReportRow) *entity.AuditReport {
+	report := &entity.AuditReport{From: from, To: to, GroupBy: groupBy, Groups: []*entity.AuditReportGroup{}}
+
+	var current *entity.AuditReportGroup
+	var seenFlags map[string]bool
+	for _, row := range rows {
+		if current == nil || current.Key != row.Key {
+			current = &entity.AuditReportGroup{Key: row.Key, Actions: map[entity.AuditAction]int{}, Flags: []string{}}
+			report.Groups = append(report.Groups, current)
+			seenFlags = map[string]bool{}
+		}
+		current.Total += row.Count
+		current.Actions[row.Action] += row.Count
+		for _, name := range row.Flags {
+			if !seenFlags[name] {
+				seenFlags[name] = true
+				current.Flags = append(current.Flags, name)
+			}
+		}
+	}
+	return report
+}
+
+func (s *flagService) SetMaintenance(ctx context.Context, flagID int64, actor, reason string) error {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return err
+	}
+	if err := s.reasonPolicy.Check(string(entity.ActionMaintenance), reason); err != nil {
+		return err
+	}
+
+	flag, err := s.flagRepo.GetFlagByID(ctx, flagID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return ErrFlagNotFound
+		}
+		return fmt.Errorf("failed to get flag: %w", err)
+	}
+
+	if flag.Locked {
+		return ErrFlagLocked
+	}
+	if flag.IsArchived() {
+		return ErrFlagArchived
+	}
+
+	// Check if already in maintenance
+	if flag.IsInMaintenance() {
+		return nil // Already in maintenance, no-op
+	}
+
+	wasEnabled := flag.IsEnabled()
+
+	// Dependents treat maintenance like disabled, so block-policy flags refuse it as they
+	// refuse a disable
+	if wasEnabled && flag.BlocksDisable() {
+		if err := s.checkNoEnabledDependents(ctx, flagID); err != nil {
+			return err
+		}
+	}
+
+	// The flag and its cascade commit together, as for a disable
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		if err := s.updateStatus(ctx, flag, entity.FlagMaintenance, actor); err != nil {
+			return fmt.Errorf("failed to put flag into maintenance: %w", err)
+		}
+
+		auditLog := entity.NewAuditLog(flagID, entity.ActionMaintenance, actor, reason)
+		if err := s.recordAudit(ctx, auditLog); err != nil {
+			return fmt.Errorf("failed to create audit log: %w", err)
+		}
+
+		if !wasEnabled {
+			return nil
+		}
+		origin := cascadeOrigin{root: flag, event: eventBy("was put into maintenance", actor)}
+		if err := s.cascadeDisableDependents(ctx, origin); err != nil {
+			if !errors.Is(err, ErrCircularDependency) {
+				return fmt.Errorf("failed to cascade disable dependents: %w", err)
+			}
+			s.log(ctx).Errorw("Failed to cascade disable dependents", "error", err, "flagID", flagID)
+		}
+		return nil
+	})
+	if err != nil {
+		s.log(ctx).Errorw("Failed to put flag into maintenance", "error", err, "flagID", flagID)
+		return err
+	}
+
+	s.log(ctx).Infow("Flag put into maintenance", "flagID", flagID, "actor", actor, "reason", reason)
+	return nil
+}
+
+// ResumeFlag brings a flag back from maintenance to enabled. Resuming a high-risk flag needs
+// a confirmation token, as enabling one does.
+func (s *flagService) ResumeFlag(ctx context.Context, flagID int64, req validator.FlagResumeRequest, actor string) error {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return err
+	}
+	if err := validator.ValidateFlagResumeRequest(req); err != nil {
+		return err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return err
+	}
+	if err := s.reasonPolicy.Check(string(entity.ActionResume), req.Reason); err != nil {
+		return err
+	}
+	reason := req.Reason
+
+	flag, err := s.flagRepo.GetFlagByID(ctx, flagID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return ErrFlagNotFound
+		}
+		return fmt.Errorf("failed to get flag: %w", err)
+	}
+
+	if flag.Locked {
+		return ErrFlagLocked
+	}
+	if flag.IsArchived() {
+		return ErrFlagArchived
+	}
+	if !flag.IsInMaintenance() {
+		return ErrFlagNotInMaintenance
+	}
+
+	if err := s.checkDependenciesActive(ctx, flag, actor); err != nil {
+		return err
+	}
+	if flag.HighRisk {
+		if err := s.requireConfirmation(ctx, flag, req.ConfirmationToken, actor); err != nil {
+			return err
+		}
+	}
+
+	auditLog := entity.NewAuditLog(flagID, entity.ActionResume, actor, reason)
+	err = s.changeStatus(ctx, auditLog, func(ctx context.Context) error {
+		return s.updateStatus(ctx, flag, entity.FlagEnabled, actor)
+	})
+	if err != nil {
+		s.log(ctx).Errorw("Failed to resume flag", "error", err, "flagID", flagID)
+		return fmt.Errorf("failed to resume flag: %w", err)
+	}
+
+	s.log(ctx).Infow("Flag resumed from maintenance", "flagID", flagID, "actor", actor, "reason", reason)
+	return nil
+}
+
+// LockFlag freezes a flag in its current state. Until it is unlocked, status and dependency
+// changes are rejected with ErrFlagLocked and cascades leave the flag alone.
+func (s *flagService) LockFlag(ctx context.Context, flagID int64, actor, reason string) error {
+	return s.setFlagLocked(ctx, flagID, true, actor, reason)
+}
+
+// UnlockFlag lifts a lock placed by LockFlag
+func (s *flagService) UnlockFlag(ctx context.Context, flagID int64, actor, reason string) error {
+	return s.setFlagLocked(ctx, flagID, false, actor, reason)
+}
+
+func (s *flagService) setFlagLocked(ctx context.Context, flagID int64, locked bool, actor, reason string) error {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return err
+	}
+	action := entity.ActionUnlock
+	if locked {
+		action = entity.ActionLock
+	}
+	if err := s.reasonPolicy.Check(string(action), reason); err != nil {
+		return err
+	}
+
+	flag, err := s.flagRepo.GetFlagByID(ctx, flagID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return ErrFlagNotFound
+		}
+		return fmt.Errorf("failed to get flag: %w", err)
+	}
+	if flag.Locked == locked {
+		return nil // no-op
+	}
+
+	if err := s.flagRepo.SetFlagLocked(ctx, flagID, locked, actor); err != nil {
+		s.log(ctx).Errorw("Failed to update flag lock", "error", err, "flagID", flagID, "locked", locked)
+		return fmt.Errorf("failed to update flag lock: %w", err)
+	}
+
+	auditLog := entity.NewAuditLog(flagID, action, actor, reason)
+	if err := s.recordAudit(ctx, auditLog); err != nil {
+		s.log(ctx).Warnw("Failed to create audit log", "error", err, "flagID", flagID)
+	}
+
+	s.log(ctx).Infow("Flag lock updated", "flagID", flagID, "locked", locked, "actor", actor, "reason", reason)
+	return nil
+}
+
+// ArchiveFlag retires a flag without deleting it. The flag is disabled first, in every
+// environment, cascading to its dependents as any disable does, and then kept with its audit
+// trail. Archived flags are hidden from listings, cannot be enabled and keep their name
+// reserved until restored.
+func (s *flagService) ArchiveFlag(ctx context.Context, flagID int64, actor, reason string) error {
+	return s.setFlagArchived(ctx, flagID, true, actor, reason)
+}
+
+// RestoreFlag brings back a flag archived by ArchiveFlag, returning it as restored. It stays
+// disabled until enabled. Archived flags keep their name reserved, so no other flag can have
+// taken it in the meantime.
+func (s *flagService) RestoreFlag(ctx context.Context, flagID int64, actor, reason string) (*entity.Flag, error) {
+	if err := s.setFlagArchived(ctx, flagID, false, actor, reason); err != nil {
+		return nil, err
+	}
+	return s.GetFlag(ctx, flagID)
+}
+
+func (s *flagService) setFlagArchived(ctx context.Context, flagID int64, archived bool, actor, reason string) error {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return err
+	}
+	action := entity.ActionRestore
+	if archived {
+		action = entity.ActionArchive
+	}
+	if err := s.reasonPolicy.Check(string(action), reason); err != nil {
+		return err
+	}
+
+	flag, err := s.flagRepo.GetFlagByID(ctx, flagID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return ErrFlagNotFound
+		}
+		return fmt.Errorf("failed to get flag: %w", err)
+	}
+	if !archived && !flag.IsArchived() {
+		return ErrFlagNotArchived
+	}
+	if flag.IsArchived() == archived {
+		return nil // no-op
+	}
+	if flag.Locked {
+		return ErrFlagLocked
+	}
+
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		if archived && !flag.IsDisabled() {
+			if _, err := s.DisableFlag(ctx, flagID, actor, reason); err != nil {
+				return err
+			}
+		}
+		if archived && s.envRepo != nil {
+			if err := s.disableInAllEnvironments(ctx, flagID, actor, reason); err != nil {
+				return err
+			}
+		}
+		if err := s.flagRepo.SetFlagArchived(ctx, flagID, archived, actor); err != nil {
+			return fmt.Errorf("failed to update flag archive state: %w", err)
+		}
+		if err := s.recordAudit(ctx, entity.NewAuditLog(flagID, action, actor, reason)); err != nil {
+			return fmt.Errorf("failed to create audit log: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		s.log(ctx).Errorw("Failed to update flag archive state", "error", err, "flagID", flagID, "archived", archived)
+		return err
+	}
+
+	s.log(ctx).Infow("Flag archive state updated", "flagID", flagID, "archived", archived, "actor", actor, "reason", reason)
+	return nil
+}
+
+// EnableWhenReady enables the flag right away if its dependencies are satisfied. Otherwise it
+// registers a pending intent that the background worker completes once they are. Repeated
+// calls while an intent is pending return the existing intent. A nil intent means the flag
+// is enabled.
+func (s *flagService) EnableWhenReady(ctx context.Context, flagID int64, actor, reason string) (*entity.PendingEnable, error) {
+	if s.pendingRepo == nil {
+		return nil, ErrFeatureNotConfigured
+	}
+	if err := s.reasonPolicy.Check(string(entity.ActionEnable), reason); err != nil {
+		return nil, err
+	}
+
+	_, err := s.EnableFlag(ctx, flagID, actor, reason)
+	if err == nil {
+		return nil, nil
+	}
+	var depErr DependencyError
+	if !errors.As(err, &depErr) {
+		return nil, err
+	}
+
+	existing, err := s.pendingRepo.GetActivePendingEnableByFlagID(ctx, flagID)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, repository.ErrPendingEnableNotFound) {
+		return nil, fmt.Errorf("failed to check pending enables: %w", err)
+	}
+
+	pending := entity.NewPendingEnable(flagID, actor, reason)
+	pendingID, err := s.pendingRepo.CreatePendingEnable(ctx, pending)
+	if err != nil {
+		s.log(ctx).Errorw("Failed to register pending enable", "error", err, "flagID", flagID)
+		return nil, fmt.Errorf("failed to register pending enable: %w", err)
+	}
+	pending.ID = pendingID
+
+	s.log(ctx).Infow("Registered pending enable", "flagID", flagID, "pendingID", pendingID,
+		"missingDeps", depErr.MissingDependencies, "actor", actor)
+	return pending, nil
+}
+
+func (s *flagService) CancelPendingEnable(ctx context.Context, flagID, pendingID int64, actor string) error {
+	if s.pendingRepo == nil {
+		return ErrFeatureNotConfigured
+	}
+	if err := s.validateActor(actor); err != nil {
+		return err
+	}
+
+	pending, err := s.pendingRepo.GetPendingEnableByID(ctx, pendingID)
+	if err != nil {
+		if errors.Is(err, repository.ErrPendingEnableNotFound) {
+			return ErrPendingEnableNotFound
+		}
+		return fmt.Errorf("failed to get pending enable: %w", err)
+	}
+	if pending.FlagID != flagID || !pending.IsPending() {
+		return ErrPendingEnableNotFound
+	}
+
+	if err := s.pendingRepo.ResolvePendingEnable(ctx, pendingID, entity.PendingEnableCancelled); err != nil {
+		if errors.Is(err, repository.ErrPendingEnableNotFound) {
+			return ErrPendingEnableNotFound
+		}
+		return fmt.Errorf("failed to cancel pending enable: %w", err)
+	}
+
+	s.log(ctx).Infow("Pending enable cancelled", "flagID", flagID, "pendingID", pendingID, "actor", actor)
+	return nil
+}
+
+// ProcessPendingCascades runs cascades whose grace period has passed. Flags that were
+// re-enabled in the meantime are skipped.
+func (s *flagService) ProcessPendingCascades(ctx context.Context) error {
+	if s.cascadeRepo == nil {
+		return nil
+	}
+
+	pendings, err := s.cascadeRepo.ListDuePendingCascades(ctx, s.now())
+	if err != nil {
+		return fmt.Errorf("failed to list pending cascades: %w", err)
+	}
+
+	for _, pending := range pendings {
+		status := entity.PendingCascadeCompleted
+		flag, err := s.flagRepo.GetFlagByID(ctx, pending.FlagID)
+		switch {
+		case errors.Is(err, repository.ErrFlagNotFound):
+			status = entity.PendingCascadeCancelled
+		case err != nil:
+			s.log(ctx).Errorw("Failed to get flag for pending cascade", "error", err, "pendingID", pending.ID)
+			continue
+		case flag.SatisfiesDependents():
+			status = entity.PendingCascadeCancelled
+		default:
+			// The flag's last status change is the disable the cascade was deferred for
+			origin := cascadeOrigin{root: flag, event: eventBy("was turned off", flag.UpdatedBy)}
+			if err := s.cascadeDisableDependents(ctx, origin); err != nil {
+				s.log(ctx).Errorw("Failed to cascade disable dependents", "error", err, "flagID", pending.FlagID)
+			}
+		}
+
+		if err := s.cascadeRepo.ResolvePendingCascade(ctx, pending.ID, status); err != nil {
+			s.log(ctx).Errorw("Failed to resolve pending cascade", "error", err, "pendingID", pending.ID)
+			continue
+		}
+		s.log(ctx).Infow("Pending cascade resolved", "flagID", pending.FlagID, "pendingID", pending.ID, "status", status)
+	}
+
+	return nil
+}
+
+// deferCascade schedules the flag's cascade for after the grace period. It returns false if
+// no grace period is configured or scheduling failed, in which case the caller cascades now.
+func (s *flagService) deferCascade(ctx context.Context, flagID int64) bool {
+	if s.cascadeRepo == nil || s.cascadeGrace <= 0 {
+		return false
+	}
+	if err := s.cascadeRepo.CreatePendingCascade(ctx, entity.NewPendingCascade(flagID, s.cascadeGrace, s.now())); err != nil {
+		s.log(ctx).Errorw("Failed to defer cascade, cascading immediately", "error", err, "flagID", flagID)
+		return false
+	}
+	return true
+}
+
+// cancelPendingCascade drops a deferred cascade for a flag that is available again
+func (s *flagService) cancelPendingCascade(ctx context.Context, flagID int64) {
+	if s.cascadeRepo == nil {
+		return
+	}
+	cancelled, err := s.cascadeRepo.CancelPendingCascades(ctx, flagID)
+	if err != nil {
+		s.log(ctx).Warnw("Failed to cancel pending cascade", "error", err, "flagID", flagID)
+		return
+	}
+	if cancelled {
+		s.log(ctx).Infow("Pending cascade cancelled by re-enable", "flagID", flagID)
+	}
+}
+
+// ProcessPendingEnables tries to complete every pending enable intent. It is run by the worker.
+func (s *flagService) ProcessPendingEnables(ctx context.Context) error {
+	if s.pendingRepo == nil {
+		return nil
+	}
+
+	pendings, err := s.pendingRepo.ListActivePendingEnables(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list pending enables: %w", err)
+	}
+
+	for _, pending := range pendings {
+		if err := s.pendingRepo.RecordPendingEnableAttempt(ctx, pending.ID); err != nil {
+			s.log(ctx).Warnw("Failed to record pending enable attempt", "error", err, "pendingID", pending.ID)
+		}
+
+		reason := fmt.Sprintf("%s (enabled automatically once dependencies were ready, pending enable %d)",
+			pending.Reason, pending.ID)
+		_, err := s.EnableFlag(ctx, pending.FlagID, pending.Actor, reason)
+		if errors.Is(err, ErrConfirmationRequired) {
+			// The flag became high-risk after the intent was registered; it will never pass
+			if err := s.pendingRepo.ResolvePendingEnable(ctx, pending.ID, entity.PendingEnableCancelled); err != nil {
+				s.log(ctx).Errorw("Failed to cancel pending enable", "error", err, "pendingID", pending.ID)
+			}
+			s.log(ctx).Warnw("Pending enable of high-risk flag cancelled", "flagID", pending.FlagID, "pendingID", pending.ID)
+			continue
+		}
+		if err != nil {
+			var depErr DependencyError
+			if !errors.As(err, &depErr) && !errors.Is(err, ErrFlagInMaintenance) && !errors.Is(err, ErrFlagLocked) {
+				s.log(ctx).Errorw("Failed to process pending enable", "error", err, "pendingID", pending.ID)
+			}
+			continue
+		}
+
+		if err := s.pendingRepo.ResolvePendingEnable(ctx, pending.ID, entity.PendingEnableCompleted); err != nil {
+			s.log(ctx).Errorw("Failed to complete pending enable", "error", err, "pendingID", pending.ID)
+			continue
+		}
+		s.log(ctx).Infow("Pending enable completed", "flagID", pending.FlagID, "pendingID", pending.ID)
+	}
+
+	return nil
+}
+
+// GetDependencyGraph returns the dependency graph. With a root flag, only the subgraph within
+// depth hops of the root (following edges in both directions) is returned; depth must then be
+// between 1 and MaxGraphDepth, and is ignored otherwise. The number of nodes is capped and
+// Truncated is set when nodes were left out.
+func (s *flagService) GetDependencyGraph(ctx context.Context, rootID int64, depth int) (*entity.DependencyGraph, error) {
+	if rootID == 0 {
+		return s.getFullDependencyGraph(ctx)
+	}
+
+	if err := validator.ValidateFlagID(rootID); err != nil {
+		return nil, err
+	}
+	if depth < 1 || depth > MaxGraphDepth {
+		return nil, fmt.Errorf("%w: must be between 1 and %d", ErrInvalidGraphDepth, MaxGraphDepth)
+	}
+
+	if _, err := s.flagRepo.GetFlagByID(ctx, rootID); err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return nil, ErrFlagNotFound
+		}
+		return nil, fmt.Errorf("failed to get flag: %w", err)
+	}
+
+	// Bounded BFS in both directions, one query per hop
+	visited := map[int64]bool{rootID: true}
+	order := []int64{rootID}
+	frontier := []int64{rootID}
+	for hop := 0; hop < depth && len(frontier) > 0; hop++ {
+		edges, err := s.flagRepo.GetDependencyEdgesForFlags(ctx, frontier)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load dependency edges: %w", err)
+		}
+
+		var next []int64
+		for _, edge := range edges {
+			for _, id := range []int64{edge.FlagID, edge.DependsOnID} {
+				if !visited[id] {
+					visited[id] = true
+					order = append(order, id)
+					next = append(next, id)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	graph := &entity.DependencyGraph{TotalNodes: len(order)}
+	if len(order) > s.graphNodeLimit {
+		// Keep the nodes closest to the root
+		graph.Truncated = true
+		order = order[:s.graphNodeLimit]
+	}
+	included := make(map[int64]bool, len(order))
+	for _, id := range order {
+		included[id] = true
+	}
+
+	flags, err := s.flagRepo.GetFlagsByIDs(ctx, order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load graph nodes: %w", err)
+	}
+	edges, err := s.flagRepo.GetDependencyEdgesForFlags(ctx, order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dependency edges: %w", err)
+	}
+
+	return buildDependencyGraph(graph, flags, edges, included), nil
+}
+
+// GetClosureSize counts the transitive dependencies and dependents of a flag, along with how
+// many hops deep each closure reaches
+func (s *flagService) GetClosureSize(ctx context.Context, flagID int64) (*entity.ClosureSize, error) {
+	if _, err := s.GetFlag(ctx, flagID); err != nil {
+		return nil, err
+	}
+
+	dependencies, err := s.walkClosure(ctx, flagID, false)
+	if err != nil {
+		return nil, err
+	}
+	dependents, err := s.walkClosure(ctx, flagID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.ClosureSize{
+		FlagID:       flagID,
+		Dependencies: dependencies,
+		Dependents:   dependents,
+	}, nil
+}
+
+// walkClosure runs a BFS from rootID towards dependencies, or towards dependents if reverse is
+// set, one query per hop. Visited flags are never expanded twice, so cycles terminate, and the
+// walk stops once the graph node limit is reached. MaxDepth is the longest path from rootID
+// over the edges the walk loaded, not the number of BFS hops.
+func (s *flagService) walkClosure(ctx context.Context, rootID int64, reverse bool) (entity.ClosureStats, error) {
+	var stats entity.ClosureStats
+	visited := map[int64]bool{rootID: true}
+	adjacency := make(map[int64][]int64)
+	frontier := []int64{rootID}
+	for len(frontier) > 0 {
+		edges, err := s.flagRepo.GetDependencyEdgesForFlags(ctx, frontier)
+		if err != nil {
+			return stats, fmt.Errorf("failed to load dependency edges: %w", err)
+		}
+
+		inFrontier := make(map[int64]bool, len(frontier))
+		for _, id := range frontier {
+			inFrontier[id] = true
+		}
+
+		var next []int64
+		for _, edge := range edges {
+			from, to := edge.FlagID, edge.DependsOnID
+			if reverse {
+				from, to = to, from
+			}
+			if !inFrontier[from] {
+				continue
+			}
+			adjacency[from] = append(adjacency[from], to)
+			if !visited[to] {
+				visited[to] = true
+				next = append(next, to)
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+
+		stats.Count += len(next)
+		if stats.Count >= s.graphNodeLimit {
+			stats.Truncated = true
+			break
+		}
+		frontier = next
+	}
+	stats.MaxDepth = longestPath(adjacency, rootID)
+	return stats, nil
+}
+
+// longestPath returns the number of edges on the longest path from rootID. Edges leading back
+// to a flag already on the current path are ignored, so cycles terminate.
+func longestPath(adjacency map[int64][]int64, rootID int64) int {
+	depth := make(map[int64]int)
+	onPath := make(map[int64]bool)
+
+	var visit func(id int64) int
+	visit = func(id int64) int {
+		if d, ok := depth[id]; ok {
+			return d
+		}
+		onPath[id] = true
+		longest := 0
+		for _, next := range adjacency[id] {
+			if onPath[next] {
+				continue
+			}
+			if d := visit(next) + 1; d > longest {
+				longest = d
+			}
+		}
+		onPath[id] = false
+		depth[id] = longest
+		return longest
+	}
+	return visit(rootID)
+}
+
+func (s *flagService) getFullDependencyGraph(ctx context.Context) (*entity.DependencyGraph, error) {
+	flags, err := s.flagRepo.ListFlags(ctx)
+	if err != nil {
+		s.log(ctx).Errorw("Failed to list flags for graph", "error", err)
+		return nil, fmt.Errorf("failed to list flags: %w", err)
+	}
+	edges, err := s.flagRepo.ListDependencyEdges(ctx)
+	if err != nil {
+		s.log(ctx).Errorw("Failed to list dependency edges for graph", "error", err)
+		return nil, fmt.Errorf("failed to list dependency edges: %w", err)
+	}
+
+	graph := &entity.DependencyGraph{TotalNodes: len(flags)}
+	if len(flags) > s.graphNodeLimit {
+		graph.Truncated = true
+		flags = flags[:s.graphNodeLimit]
+	}
+	included := make(map[int64]bool, len(flags))
+	for _, flag := range flags {
+		included[flag.ID] = true
+	}
+
+	return buildDependencyGraph(graph, flags, edges, included), nil
+}
+
+// buildDependencyGraph fills the graph with the given flags and the edges between included flags
+func buildDependencyGraph(graph *entity.DependencyGraph, flags []*entity.Flag, edges []*entity.DependencyEdge, included map[int64]bool) *entity.DependencyGraph {
+	graph.Nodes = make([]entity.GraphNode, 0, len(flags))
+	for _, flag := range flags {
+		graph.Nodes = append(graph.Nodes, entity.NewGraphNode(flag))
+	}
+
+	graph.Edges = make([]entity.DependencyEdge, 0, len(edges))
+	for _, edge := range edges {
+		if included[edge.FlagID] && included[edge.DependsOnID] {
+			graph.Edges = append(graph.Edges, *edge)
+		}
+	}
+
+	graph.Cycles = findCycles(graph.Edges)
+	return graph
+}
+
+// SatisfyDependencies enables every currently disabled transitive dependency of the flag, in
+// dependency order and within a single transaction. The flag itself is left untouched so the
+// operator can review before the final enable. It returns the flags that were enabled.
+func (s *flagService) SatisfyDependencies(ctx context.Context, flagID int64, actor, reason string) ([]*entity.Flag, error) {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+	if err := s.reasonPolicy.Check(string(entity.ActionEnable), reason); err != nil {
+		return nil, err
+	}
+
+	flag, err := s.flagRepo.GetFlagByID(ctx, flagID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return nil, ErrFlagNotFound
+		}
+		return nil, fmt.Errorf("failed to get flag: %w", err)
+	}
+
+	var enabled []*entity.Flag
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		enabled, err = s.enablePrerequisites(ctx, flag, actor, func(dep *entity.Flag) *entity.AuditLog {
+			return entity.NewAuditLog(dep.ID, entity.ActionEnable, actor,
+				fmt.Sprintf("%s (prerequisite of %s)", reason, flag.Name))
+		})
+		return err
+	})
+	if err != nil {
+		s.log(ctx).Warnw("Failed to satisfy dependencies", "error", err, "flagID", flagID, "actor", actor)
+		return nil, err
+	}
+
+	s.log(ctx).Infow("Dependencies satisfied", "flagID", flagID, "enabled", len(enabled), "actor", actor)
+	return enabled, nil
+}
+
+// enablePrerequisites enables the flag's disabled transitive dependencies in dependency order,
+// auditing each with the entry built by audit, and returns the flags it enabled. It does not
+// open a transaction of its own; callers run it inside one so a failure part way through
+// leaves nothing enabled.
+func (s *flagService) enablePrerequisites(ctx context.Context, flag *entity.Flag, actor string, audit func(dep *entity.Flag) *entity.AuditLog) ([]*entity.Flag, error) {
+	prerequisites, err := s.collectDependencyOrder(ctx, flag.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := []*entity.Flag{}
+	for _, dep := range prerequisites {
+		if dep.IsEnabled() {
+			continue
+		}
+		if dep.Locked {
+			return nil, fmt.Errorf("%w: dependency %s", ErrFlagLocked, dep.Name)
+		}
+		if dep.IsArchived() {
+			return nil, UnavailableDependencyError{Dependency: dep.Name, Reason: "archived"}
+		}
+		if dep.IsInMaintenance() {
+			return nil, fmt.Errorf("%w: dependency %s must be resumed explicitly", ErrFlagInMaintenance, dep.Name)
+		}
+		if dep.HighRisk {
+			return nil, fmt.Errorf("%w: dependency %s must be enabled on its own", ErrConfirmationRequired, dep.Name)
+		}
+		// Guard against data that changed underneath us; in a DAG this always passes
+		if err := s.checkDependenciesActive(ctx, dep, actor); err != nil {
+			return nil, err
+		}
+
+		auditLog := audit(dep)
+		err := s.changeStatus(ctx, auditLog, func(ctx context.Context) error {
+			return s.updateStatus(ctx, dep, entity.FlagEnabled, auditLog.Actor)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to enable dependency %s: %w", dep.Name, err)
+		}
+		dep.Enable()
+
+		enabled = append(enabled, dep)
+	}
+	return enabled, nil
+}
+
+// GetEnablePlan lists the flag's transitive dependencies in an order in which they can be
+// enabled one after another, each only after its own dependencies
+func (s *flagService) GetEnablePlan(ctx context.Context, flagID int64) (*entity.EnablePlan, error) {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if _, err := s.flagRepo.GetFlagByID(ctx, flagID); err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return nil, ErrFlagNotFound
+		}
+		return nil, fmt.Errorf("failed to get flag: %w", err)
+	}
+
+	dependencies, err := s.collectDependencyOrder(ctx, flagID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &entity.EnablePlan{FlagID: flagID, Steps: make([]entity.GraphNode, 0, len(dependencies))}
+	for _, dep := range dependencies {
+		plan.Steps = append(plan.Steps, entity.NewGraphNode(dep))
+	}
+	return plan, nil
+}
+
+// collectDependencyOrder returns the transitive dependencies of a flag (excluding the flag
+// itself) ordered so that every flag comes after all of its own dependencies. A cycle is
+// reported as a CycleError.
+func (s *flagService) collectDependencyOrder(ctx context.Context, flagID int64) ([]*entity.Flag, error) {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[int64]int)
+	var order []*entity.Flag
+	var path []*entity.Flag // flags currently being visited, outermost first
+
+	var visit func(id int64) error
+	visit = func(id int64) error {
+		switch state[id] {
+		case visiting:
+			var cycle []string
+			for i, flag := range path {
+				if flag.ID == id {
+					for _, onCycle := range path[i:] {
+						cycle = append(cycle, onCycle.Name)
+					}
+					break
+				}
+			}
+			return CycleError{Message: "Circular dependency detected", Cycle: cycle}
+		case done:
+			return nil
+		}
+		state[id] = visiting
+
+		flag, err := s.flagRepo.GetFlagByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to get dependency flag %d: %w", id, err)
+		}
+		path = append(path, flag)
+		for _, depID := range flag.Dependencies {
+			if err := visit(depID); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+
+		state[id] = done
+		if id != flagID {
+			order = append(order, flag)
+		}
+		return nil
+	}
+
+	if err := visit(flagID); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// checkEnableConfirmation requires a valid confirmation token before a disabled high-risk flag
+// can be enabled. Without a valid token a ConfirmationRequiredError carrying a fresh token is
+// returned, which the client resubmits to confirm.
+func (s *flagService) checkEnableConfirmation(ctx context.Context, flagID int64, token, actor string) error {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return err
+	}
+
+	flag, err := s.flagRepo.GetFlagByID(ctx, flagID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return ErrFlagNotFound
+		}
+		return fmt.Errorf("failed to get flag: %w", err)
+	}
+	if !flag.HighRisk || flag.IsEnabled() {
+		return nil
+	}
+	return s.requireConfirmation(ctx, flag, token, actor)
+}
+
+// requireConfirmation lets the enable of a high-risk flag through only with a valid token,
+// issuing a new one otherwise
+func (s *flagService) requireConfirmation(ctx context.Context, flag *entity.Flag, token, actor string) error {
+	if token != "" && s.confirmations.Verify(flag, token) {
+		return nil
+	}
+
+	message := "Confirmation required to enable high-risk flag"
+	if token != "" {
+		message = "Confirmation token is invalid or expired"
+	}
+	newToken, expiresAt := s.confirmations.Issue(flag)
+	s.log(ctx).Infow("Enable of high-risk flag requires confirmation", "flagID", flag.ID, "actor", actor, "tokenProvided", token != "")
+	return ConfirmationRequiredError{
+		Message:   message,
+		Token:     newToken,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// StreamAuditLogs returns a channel receiving every new audit entry matching the filter. The
+// channel is closed once ctx is cancelled.
+func (s *flagService) StreamAuditLogs(ctx context.Context, filter entity.AuditFilter) (<-chan *entity.AuditLog, error) {
+	if filter.Action != "" && !filter.Action.IsValid() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAuditAction, filter.Action)
+	}
+
+	events, unsubscribe := s.events.Subscribe()
+	out := make(chan *entity.AuditLog)
+
+	go func() {
+		defer close(out)
+		defer unsubscribe()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				auditLog, isAudit := event.Data.(*entity.AuditLog)
+				if !isAudit || !filter.Matches(auditLog) {
+					continue
+				}
+				select {
+				case out <- auditLog:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
 }
 
-type flagService struct {
-	flagRepo  repository.FlagRepository
-	auditRepo repository.AuditRepository
-	logger    *logger.Logger
+// StreamFlagChanges returns a channel receiving every committed flag status change, in any
+// environment. The channel is closed once ctx is cancelled.
+func (s *flagService) StreamFlagChanges(ctx context.Context) <-chan *entity.FlagStatusEvent {
+	events, unsubscribe := s.events.Subscribe()
+	out := make(chan *entity.FlagStatusEvent)
+
+	go func() {
+		defer close(out)
+		defer unsubscribe()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				change, isChange := event.Data.(*entity.FlagStatusEvent)
+				if !isChange {
+					continue
+				}
+				select {
+				case out <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out
 }
 
-func NewFlagService(flagRepo repository.FlagRepository, auditRepo repository.AuditRepository, log *logger.Logger) FlagService {
-	return &flagService{
-		flagRepo:  flagRepo,
-		auditRepo: auditRepo,
-		logger:    log,
+// recordAudit persists an audit entry, tagged with the request ID carried by ctx, and
+// publishes it to audit stream subscribers. With a retry queue, a failed write outside a
+// transaction is queued instead of returned.
+func (s *flagService) recordAudit(ctx context.Context, auditLog *entity.AuditLog) error {
+	if auditLog.RequestID == "" {
+		auditLog.RequestID = logger.RequestIDFromContext(ctx)
+	}
+	if err := s.auditRepo.CreateAuditLog(ctx, auditLog); err != nil {
+		// Inside a transaction the entry must commit or roll back together with the change
+		if inTx(ctx) || s.auditRetries == nil {
+			return err
+		}
+		s.auditRetries.Push(auditLog)
+		s.log(ctx).Warnw("Audit write failed, queued for retry", "error", err,
+			"flagID", auditLog.FlagID, "action", auditLog.Action)
+		return nil
 	}
+	s.publish(ctx, events.Event{Type: events.TypeAudit, Data: auditLog})
+	return nil
 }
 
-func (s *flagService) CreateFlag(ctx context.Context, req validator.FlagCreateRequest, actor string) (*entity.Flag, error) {
-	// Validate request
-	if err := validator.ValidateFlagCreateRequest(req); err != nil {
-		s.logger.Warnw("Invalid flag creation request", "error", err, "actor", actor)
-		return nil, err
+// changeStatus applies a status change and records auditLog for it. With strict audit both run
+// in one transaction, so a failed audit write rolls the change back; otherwise the change
+// stands and the failure is logged.
+func (s *flagService) changeStatus(ctx context.Context, auditLog *entity.AuditLog, change func(ctx context.Context) error) error {
+	if s.strictAudit {
+		return s.withinTx(ctx, func(ctx context.Context) error {
+			if err := change(ctx); err != nil {
+				return err
+			}
+			if err := s.recordAudit(ctx, auditLog); err != nil {
+				return fmt.Errorf("failed to create audit log: %w", err)
+			}
+			return nil
+		})
 	}
 
-	// Validate actor
-	if err := validator.ValidateActor(actor); err != nil {
-		return nil, err
+	if err := change(ctx); err != nil {
+		return err
 	}
+	if err := s.recordAudit(ctx, auditLog); err != nil {
+		s.log(ctx).Warnw("Failed to create audit log", "error", err, "flagID", auditLog.FlagID)
+	}
+	return nil
+}
 
-	// Validate dependencies exist
-	if len(req.Dependencies) > 0 {
-		if err := s.validateDependenciesExist(ctx, req.Dependencies); err != nil {
-			return nil, err
-		}
-
-		// Check for circular dependencies
-		hasCircular, err := s.flagRepo.HasCircularDependency(ctx, 0, req.Dependencies)
-		if err != nil {
-			s.logger.Errorw("Failed to check circular dependency", "error", err)
-			return nil, fmt.Errorf("failed to validate dependencies: %w", err)
+// updateStatus writes the flag's new status on behalf of actor, failing with
+// ErrConcurrentModification if the flag changed since it was read
+func (s *flagService) updateStatus(ctx context.Context, flag *entity.Flag, status entity.FlagStatus, actor string) error {
+	if err := s.flagRepo.UpdateFlagStatus(ctx, flag.ID, status, flag.Version, actor); err != nil {
+		if errors.Is(err, repository.ErrConcurrentModification) {
+			return ErrConcurrentModification
 		}
-		if hasCircular {
-			s.logger.Warnw("Circular dependency detected", "dependencies", req.Dependencies, "actor", actor)
-			return nil, ErrCircularDependency
+		if errors.Is(err, repository.ErrFlagArchived) {
+			return ErrFlagArchived
 		}
+		return err
 	}
+	flag.Version++
+	flag.UpdatedBy = actor
+	s.publish(ctx, events.Event{Type: events.TypeFlagStatus, Data: &entity.FlagStatusEvent{
+		FlagID:      flag.ID,
+		Name:        flag.Name,
+		Status:      status,
+		Environment: entity.GlobalEnvironment,
+		Version:     flag.Version,
+		ChangedBy:   actor,
+		ChangedAt:   time.Now(),
+	}})
+	return nil
+}
 
-	// Create flag entity
-	flag := &entity.Flag{
-		Name:   req.Name,
-		Status: entity.FlagDisabled, // Always start disabled
+type pendingEventsKey struct{}
+
+// withinTx runs fn in a transaction, holding back the events published inside it until it
+// commits so subscribers never hear of changes that were rolled back. Nested calls join the
+// outer transaction.
+func (s *flagService) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(pendingEventsKey{}).(*[]events.Event); ok {
+		return s.flagRepo.WithinTx(ctx, fn)
 	}
 
-	// Create flag in repository
-	flagID, err := s.flagRepo.CreateFlag(ctx, flag)
-	if err != nil {
-		if errors.Is(err, repository.ErrFlagAlreadyExists) {
-			return nil, ErrFlagAlreadyExists
-		}
-		s.logger.Errorw("Failed to create flag", "error", err, "name", req.Name)
-		return nil, fmt.Errorf("failed to create flag: %w", err)
+	var pending []events.Event
+	if err := s.flagRepo.WithinTx(context.WithValue(ctx, pendingEventsKey{}, &pending), fn); err != nil {
+		return err
+	}
+	for _, event := range pending {
+		s.events.Publish(event)
 	}
+	return nil
+}
 
-	flag.ID = flagID
+// log returns the service logger, tagged with the request ID carried by ctx
+func (s *flagService) log(ctx context.Context) *logger.Logger {
+	return s.logger.WithContext(ctx)
+}
 
-	// Add dependencies
-	for _, depID := range req.Dependencies {
-		if err := s.flagRepo.AddDependency(ctx, flagID, depID); err != nil {
-			s.logger.Errorw("Failed to add dependency", "error", err, "flagID", flagID, "depID", depID)
-			return nil, fmt.Errorf("failed to add dependency: %w", err)
-		}
+// validateActor checks an actor against the built-in rules and then the actor policy
+func (s *flagService) validateActor(actor string) error {
+	if err := validator.ValidateActor(actor); err != nil {
+		return err
 	}
+	return s.actorPolicy.Check(actor)
+}
 
-	flag.Dependencies = req.Dependencies
+// validateFlagCreateRequest checks a flag definition against the built-in rules and then the
+// naming convention
+func (s *flagService) validateFlagCreateRequest(req validator.FlagCreateRequest) error {
+	if err := validator.ValidateFlagCreateRequest(req); err != nil {
+		return err
+	}
+	return s.namePolicy.Check(req.Name)
+}
 
-	// Create audit log
-	auditLog := entity.NewAuditLog(flagID, entity.ActionCreate, actor, "Flag created")
-	if err := s.auditRepo.CreateAuditLog(ctx, auditLog); err != nil {
-		s.logger.Warnw("Failed to create audit log", "error", err, "flagID", flagID)
+// publish sends event to subscribers, once the surrounding transaction commits if there is one
+func (s *flagService) publish(ctx context.Context, event events.Event) {
+	if pending, ok := ctx.Value(pendingEventsKey{}).(*[]events.Event); ok {
+		*pending = append(*pending, event)
+		return
 	}
+	s.events.Publish(event)
+}
 
-	s.logger.Infow("Flag created successfully", "flagID", flagID, "name", req.Name, "actor", actor)
-	return flag, nil
+// inTx reports whether ctx carries a transaction. Best-effort steps that merely log their
+// errors outside a transaction must fail it instead, as Postgres aborts it anyway.
+func inTx(ctx context.Context) bool {
+	_, ok := repository.TxFromContext(ctx)
+	return ok
 }
 
-func (s *flagService) EnableFlag(ctx context.Context, flagID int64, actor, reason string) error {
-	if err := validator.ValidateFlagID(flagID); err != nil {
-		return err
-	}
-	if err := validator.ValidateActor(actor); err != nil {
-		return err
+// auditDependencyChanges writes one audit entry as action per added or removed dependency edge,
+// naming the dependency flag in the reason so the history explains how the dependency set evolved.
+func (s *flagService) auditDependencyChanges(ctx context.Context, flagID int64, action entity.AuditAction, dependencyIDs []int64, added bool, actor string) {
+	if len(dependencyIDs) == 0 {
+		return
 	}
 
-	// Get flag with dependencies
-	flag, err := s.flagRepo.GetFlagByID(ctx, flagID)
+	names := make(map[int64]string, len(dependencyIDs))
+	deps, err := s.flagRepo.GetFlagsByIDs(ctx, dependencyIDs)
 	if err != nil {
-		if errors.Is(err, repository.ErrFlagNotFound) {
-			return ErrFlagNotFound
+		s.log(ctx).Warnw("Failed to resolve dependency names for audit", "error", err, "flagID", flagID)
+	}
+	for _, dep := range deps {
+		names[dep.ID] = dep.Name
+	}
+
+	verb := "Removed dependency on"
+	if added {
+		verb = "Added dependency on"
+	}
+	for _, depID := range dependencyIDs {
+		name, ok := names[depID]
+		if !ok {
+			name = "unknown flag"
+		}
+		auditLog := entity.NewAuditLog(flagID, action, actor, fmt.Sprintf("%s %s (ID %d)", verb, name, depID))
+		if err := s.recordAudit(ctx, auditLog); err != nil {
+			s.log(ctx).Warnw("Failed to create dependency audit log", "error", err, "flagID", flagID, "depID", depID)
 		}
-		return fmt.Errorf("failed to get flag: %w", err)
 	}
+}
 
-	// Check if already enabled
-	if flag.IsEnabled() {
-		return nil // Already enabled, no-op
+// checkDependenciesActive returns a DependencyError if any dependency of the flag is not enabled,
+// or an UnavailableDependencyError if one has been archived or deleted
+func (s *flagService) checkDependenciesActive(ctx context.Context, flag *entity.Flag, actor string) error {
+	if !flag.HasDependencies() {
+		return nil
 	}
 
-	// Validate dependencies are enabled
-	if flag.HasDependencies() {
-		missingDeps, err := s.getMissingActiveDependencies(ctx, flag.Dependencies)
+	var missingDeps []*entity.Flag
+	for _, depID := range flag.Dependencies {
+		dep, err := s.flagRepo.GetFlagByID(ctx, depID)
 		if err != nil {
+			if errors.Is(err, repository.ErrFlagNotFound) {
+				return UnavailableDependencyError{Dependency: strconv.FormatInt(depID, 10), Reason: "deleted"}
+			}
 			return fmt.Errorf("failed to check dependencies: %w", err)
 		}
-		if len(missingDeps) > 0 {
-			s.logger.Warnw("Cannot enable flag due to missing dependencies", 
-				"flagID", flagID, "missingDeps", missingDeps, "actor", actor)
-			return DependencyError{
-				Message:             "Missing active dependencies",
-				MissingDependencies: missingDeps,
-			}
+		// Archived dependencies stay disabled until restored, so this is no ordinary missing one
+		if dep.IsArchived() {
+			s.log(ctx).Warnw("Cannot enable flag with archived dependency",
+				"flagID", flag.ID, "dependency", dep.Name, "actor", actor)
+			return UnavailableDependencyError{Dependency: dep.Name, Reason: "archived"}
+		}
+		if !dep.SatisfiesDependents() {
+			missingDeps = append(missingDeps, dep)
 		}
 	}
-
-	// Enable flag
-	if err := s.flagRepo.UpdateFlagStatus(ctx, flagID, entity.FlagEnabled); err != nil {
-		s.logger.Errorw("Failed to enable flag", "error", err, "flagID", flagID)
-		return fmt.Errorf("failed to enable flag: %w", err)
-	}
-
-	// Create audit log
-	auditLog := entity.NewAuditLog(flagID, entity.ActionEnable, actor, reason)
-	if err := s.auditRepo.CreateAuditLog(ctx, auditLog); err != nil {
-		s.logger.Warnw("Failed to create audit log", "error", err, "flagID", flagID)
+	if len(missingDeps) > 0 {
+		depErr := s.missingDependenciesError(ctx, missingDeps)
+		s.log(ctx).Warnw("Cannot enable flag due to missing dependencies",
+			"flagID", flag.ID, "missingDeps", depErr.MissingDependencies, "readyAt", depErr.ReadyAt, "actor", actor)
+		return depErr
 	}
-
-	s.logger.Infow("Flag enabled successfully", "flagID", flagID, "actor", actor, "reason", reason)
 	return nil
 }
 
-func (s *flagService) DisableFlag(ctx context.Context, flagID int64, actor, reason string) error {
-	if err := validator.ValidateFlagID(flagID); err != nil {
-		return err
+// missingDependenciesError builds the DependencyError for deps, with the scheduled enables of
+// those that have one. The schedule is only a hint, so failing to read it is just logged.
+func (s *flagService) missingDependenciesError(ctx context.Context, deps []*entity.Flag) DependencyError {
+	depErr := DependencyError{Message: "Missing active dependencies"}
+	ids := make([]int64, 0, len(deps))
+	for _, dep := range deps {
+		depErr.MissingDependencies = append(depErr.MissingDependencies, dep.Name)
+		ids = append(ids, dep.ID)
 	}
-	if err := validator.ValidateActor(actor); err != nil {
-		return err
+	if s.scheduleRepo == nil {
+		return depErr
 	}
 
-	// Get flag
-	flag, err := s.flagRepo.GetFlagByID(ctx, flagID)
+	enables, err := s.scheduleRepo.NextScheduledEnables(ctx, ids)
 	if err != nil {
-		if errors.Is(err, repository.ErrFlagNotFound) {
-			return ErrFlagNotFound
-		}
-		return fmt.Errorf("failed to get flag: %w", err)
+		s.log(ctx).Warnw("Failed to look up scheduled enables of missing dependencies", "error", err)
+		return depErr
 	}
-
-	// Check if already disabled
-	if flag.IsDisabled() {
-		return nil // Already disabled, no-op
+	if len(enables) == 0 {
+		return depErr
 	}
 
-	// Disable flag
-	if err := s.flagRepo.UpdateFlagStatus(ctx, flagID, entity.FlagDisabled); err != nil {
-		s.logger.Errorw("Failed to disable flag", "error", err, "flagID", flagID)
-		return fmt.Errorf("failed to disable flag: %w", err)
+	depErr.ScheduledEnables = make(map[string]time.Time, len(enables))
+	var readyAt time.Time
+	for _, dep := range deps {
+		at, ok := enables[dep.ID]
+		if !ok {
+			continue
+		}
+		depErr.ScheduledEnables[dep.Name] = at
+		if at.After(readyAt) {
+			readyAt = at
+		}
 	}
-
-	// Create audit log
-	auditLog := entity.NewAuditLog(flagID, entity.ActionDisable, actor, reason)
-	if err := s.auditRepo.CreateAuditLog(ctx, auditLog); err != nil {
-		s.logger.Warnw("Failed to create audit log", "error", err, "flagID", flagID)
+	if len(depErr.ScheduledEnables) == len(deps) {
+		depErr.ReadyAt = &readyAt
 	}
+	return depErr
+}
 
-	// Cascade disable dependents
-	if err := s.cascadeDisableDependents(ctx, flagID); err != nil {
-		s.logger.Errorw("Failed to cascade disable dependents", "error", err, "flagID", flagID)
-		// Don't return error, as the main flag was disabled successfully
+// validateDependenciesExist loads the dependencies in one query and returns an
+// UnknownDependenciesError listing every ID without a flag
+func (s *flagService) validateDependenciesExist(ctx context.Context, dependencyIDs []int64) error {
+	deps, err := s.flagRepo.GetFlagsByIDs(ctx, dependencyIDs)
+	if err != nil {
+		return fmt.Errorf("failed to validate dependencies: %w", err)
+	}
+	found := make(map[int64]bool, len(deps))
+	for _, dep := range deps {
+		found[dep.ID] = true
 	}
 
-	s.logger.Infow("Flag disabled successfully", "flagID", flagID, "actor", actor, "reason", reason)
+	var unknown []int64
+	for _, depID := range dependencyIDs {
+		if !found[depID] && !slices.Contains(unknown, depID) {
+			unknown = append(unknown, depID)
+		}
+	}
+	if len(unknown) > 0 {
+		return UnknownDependenciesError{
+			Message:              "Unknown dependencies",
+			UnknownDependencyIDs: unknown,
+		}
+	}
 	return nil
 }
 
-func (s *flagService) ToggleFlag(ctx context.Context, flagID int64, req validator.FlagToggleRequest, actor string) error {
-	if err := validator.ValidateFlagToggleRequest(req); err != nil {
-		return err
+// resolveDependencyNames looks up the IDs of the named flags, returning an
+// UnknownDependenciesError that lists every name without a flag
+func (s *flagService) resolveDependencyNames(ctx context.Context, names []string) ([]int64, error) {
+	ids := make([]int64, 0, len(names))
+	var unknown []string
+	for _, name := range names {
+		flag, err := s.flagRepo.GetFlagByName(ctx, name)
+		if err != nil {
+			if errors.Is(err, repository.ErrFlagNotFound) {
+				unknown = append(unknown, name)
+				continue
+			}
+			return nil, fmt.Errorf("failed to resolve dependency %s: %w", name, err)
+		}
+		ids = append(ids, flag.ID)
 	}
-
-	if req.Enable {
-		return s.EnableFlag(ctx, flagID, actor, req.Reason)
+	if len(unknown) > 0 {
+		return nil, UnknownDependenciesError{
+			Message:             "Unknown dependencies",
+			UnknownDependencies: unknown,
+		}
 	}
-	return s.DisableFlag(ctx, flagID, actor, req.Reason)
+	return ids, nil
 }
 
-func (s *flagService) GetFlag(ctx context.Context, flagID int64) (*entity.Flag, error) {
-	if err := validator.ValidateFlagID(flagID); err != nil {
-		return nil, err
-	}
+// getMissingActiveDependencies returns the names of dependencies that are not enabled.
+// Dependencies in maintenance are reported the same way as disabled ones.
+func (s *flagService) getMissingActiveDependencies(ctx context.Context, dependencyIDs []int64) ([]string, error) {
+	var missingDeps []string
 
-	flag, err := s.flagRepo.GetFlagByID(ctx, flagID)
-	if err != nil {
-		if errors.Is(err, repository.ErrFlagNotFound) {
-			return nil, ErrFlagNotFound
+	for _, depID := range dependencyIDs {
+		flag, err := s.flagRepo.GetFlagByID(ctx, depID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dependency flag %d: %w", depID, err)
+		}
+		if !flag.SatisfiesDependents() {
+			missingDeps = append(missingDeps, flag.Name)
 		}
-		return nil, fmt.Errorf("failed to get flag: %w", err)
 	}
 
-	return flag, nil
+	return missingDeps, nil
 }
 
-func (s *flagService) ListFlags(ctx context.Context) ([]*entity.Flag, error) {
-	flags, err := s.flagRepo.GetFlagsWithDependencies(ctx)
+// checkNoEnabledDependents returns an EnabledDependentsError if any direct dependent is enabled
+func (s *flagService) checkNoEnabledDependents(ctx context.Context, flagID int64) error {
+	dependentIDs, err := s.flagRepo.GetDependents(ctx, flagID)
 	if err != nil {
-		s.logger.Errorw("Failed to list flags", "error", err)
-		return nil, fmt.Errorf("failed to list flags: %w", err)
+		return fmt.Errorf("failed to get dependents: %w", err)
+	}
+	if len(dependentIDs) == 0 {
+		return nil
 	}
 
-	return flags, nil
+	dependents, err := s.flagRepo.GetFlagsByIDs(ctx, dependentIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get dependents: %w", err)
+	}
+
+	var enabled []string
+	for _, dependent := range dependents {
+		if dependent.IsEnabled() {
+			enabled = append(enabled, dependent.Name)
+		}
+	}
+	if len(enabled) > 0 {
+		return EnabledDependentsError{
+			Message:           "Dependent flags must be disabled first",
+			EnabledDependents: enabled,
+		}
+	}
+	return nil
 }
 
-func (s *flagService) GetFlagAuditLogs(ctx context.Context, flagID int64) ([]*entity.AuditLog, error) {
+// RestoreCascade re-enables the flags disabled by the most recent cascade from flagID, in
+// dependency order. Flags whose dependencies are still not enabled, high-risk flags and
+// flags that are already enabled are skipped.
+func (s *flagService) RestoreCascade(ctx context.Context, flagID int64, actor, reason string) (*entity.CascadeRestoreResult, error) {
 	if err := validator.ValidateFlagID(flagID); err != nil {
 		return nil, err
 	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+	if err := s.reasonPolicy.Check(string(entity.ActionEnable), reason); err != nil {
+		return nil, err
+	}
+	if s.cascadeEventRepo == nil {
+		return nil, ErrFeatureNotConfigured
+	}
 
-	// Verify flag exists
-	_, err := s.flagRepo.GetFlagByID(ctx, flagID)
-	if err != nil {
+	if _, err := s.flagRepo.GetFlagByID(ctx, flagID); err != nil {
 		if errors.Is(err, repository.ErrFlagNotFound) {
 			return nil, ErrFlagNotFound
 		}
-		return nil, fmt.Errorf("failed to verify flag existence: %w", err)
+		return nil, fmt.Errorf("failed to get flag: %w", err)
 	}
 
-	logs, err := s.auditRepo.ListAuditLogsByFlagID(ctx, flagID)
+	event, err := s.cascadeEventRepo.GetLatestCascadeEvent(ctx, flagID)
 	if err != nil {
-		s.logger.Errorw("Failed to get audit logs", "error", err, "flagID", flagID)
-		return nil, fmt.Errorf("failed to get audit logs: %w", err)
+		if errors.Is(err, repository.ErrCascadeEventNotFound) {
+			return nil, ErrCascadeNotFound
+		}
+		return nil, fmt.Errorf("failed to get cascade event: %w", err)
+	}
+	if event.IsRestored() {
+		return nil, ErrCascadeAlreadyRestored
 	}
 
-	return logs, nil
-}
+	result := &entity.CascadeRestoreResult{
+		CascadeEventID: event.ID,
+		Restored:       []string{},
+		Skipped:        []entity.SkippedFlag{},
+	}
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		var remaining []*entity.Flag
+		for _, id := range event.FlagIDs {
+			flag, err := s.flagRepo.GetFlagByID(ctx, id)
+			if err != nil {
+				return fmt.Errorf("failed to get flag %d: %w", id, err)
+			}
+			switch {
+			case flag.IsEnabled():
+				result.Skipped = append(result.Skipped, entity.SkippedFlag{Name: flag.Name, Reason: "already enabled"})
+			case flag.Locked:
+				result.Skipped = append(result.Skipped, entity.SkippedFlag{Name: flag.Name, Reason: "locked"})
+			case flag.IsArchived():
+				result.Skipped = append(result.Skipped, entity.SkippedFlag{Name: flag.Name, Reason: "archived"})
+			case flag.HighRisk:
+				result.Skipped = append(result.Skipped, entity.SkippedFlag{Name: flag.Name, Reason: "high-risk flags must be enabled with confirmation"})
+			default:
+				remaining = append(remaining, flag)
+			}
+		}
 
-// validateDependenciesExist checks if all dependency IDs exist
-func (s *flagService) validateDependenciesExist(ctx context.Context, dependencyIDs []int64) error {
-	for _, depID := range dependencyIDs {
-		_, err := s.flagRepo.GetFlagByID(ctx, depID)
-		if err != nil {
-			if errors.Is(err, repository.ErrFlagNotFound) {
-				return fmt.Errorf("dependency flag with ID %d not found", depID)
+		// Enable whatever has its dependencies satisfied until no more progress is made,
+		// so flags are restored after the flags they depend on
+		restoreReason := fmt.Sprintf("%s (restored from cascade event %d)", reason, event.ID)
+		for progress := true; progress && len(remaining) > 0; {
+			progress = false
+			var blocked []*entity.Flag
+			for _, flag := range remaining {
+				missing, err := s.getMissingActiveDependencies(ctx, flag.Dependencies)
+				if err != nil {
+					return fmt.Errorf("failed to check dependencies: %w", err)
+				}
+				if len(missing) > 0 {
+					blocked = append(blocked, flag)
+					continue
+				}
+
+				if err := s.updateStatus(ctx, flag, entity.FlagEnabled, actor); err != nil {
+					return fmt.Errorf("failed to enable flag %d: %w", flag.ID, err)
+				}
+				if err := s.recordAudit(ctx, entity.NewAuditLog(flag.ID, entity.ActionEnable, actor, restoreReason)); err != nil {
+					return fmt.Errorf("failed to create audit log: %w", err)
+				}
+				result.Restored = append(result.Restored, flag.Name)
+				progress = true
+			}
+			remaining = blocked
+		}
+
+		for _, flag := range remaining {
+			missing, err := s.getMissingActiveDependencies(ctx, flag.Dependencies)
+			if err != nil {
+				return fmt.Errorf("failed to check dependencies: %w", err)
 			}
-			return fmt.Errorf("failed to validate dependency %d: %w", depID, err)
+			result.Skipped = append(result.Skipped, entity.SkippedFlag{
+				Name:   flag.Name,
+				Reason: "dependencies not enabled: " + strings.Join(missing, ", "),
+			})
 		}
+
+		return s.cascadeEventRepo.MarkCascadeEventRestored(ctx, event.ID)
+	})
+	if err != nil {
+		s.log(ctx).Errorw("Failed to restore cascade", "error", err, "flagID", flagID, "cascadeEventID", event.ID)
+		return nil, fmt.Errorf("failed to restore cascade: %w", err)
 	}
-	return nil
+
+	s.log(ctx).Infow("Cascade restored", "flagID", flagID, "cascadeEventID", event.ID,
+		"restored", len(result.Restored), "skipped", len(result.Skipped), "actor", actor)
+	return result, nil
 }
 
-// getMissingActiveDependencies returns the names of dependencies that are not enabled
-func (s *flagService) getMissingActiveDependencies(ctx context.Context, dependencyIDs []int64) ([]string, error) {
-	var missingDeps []string
+// cascadeOrigin is the change a cascade started from: the root flag and what happened to it,
+// such as "was turned off by alice". It is quoted in the audit reason of every flag the
+// cascade reaches.
+type cascadeOrigin struct {
+	root  *entity.Flag
+	event string
+}
 
-	for _, depID := range dependencyIDs {
-		flag, err := s.flagRepo.GetFlagByID(ctx, depID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get dependency flag %d: %w", depID, err)
+// eventBy describes a change to a cascade's root flag, naming the actor unless the system
+// made it
+func eventBy(event, actor string) string {
+	if actor == "" || actor == validator.SystemActor {
+		return event
+	}
+	return fmt.Sprintf("%s by %s", event, actor)
+}
+
+// cascadeReason is the audit reason for a cascade moving dependent, which depends on parent,
+// to status
+func cascadeReason(origin cascadeOrigin, parent, dependent *entity.Flag, status entity.FlagStatus) string {
+	outcome := "Cascade-disabled"
+	if status == entity.FlagMaintenance {
+		outcome = fmt.Sprintf("Cascade-moved to maintenance (cascade strategy %q)", dependent.CascadeStrategy)
+	}
+	if parent.ID == origin.root.ID {
+		return fmt.Sprintf("%s: depends on %s, which %s", outcome, parent.Name, origin.event)
+	}
+	return fmt.Sprintf("%s: depends on %s, disabled because %s %s", outcome, parent.Name, origin.root.Name, origin.event)
+}
+
+// cascadeDisableDependents disables all flags that depend on the origin's root flag. Each
+// dependent is moved to the status given by its cascade strategy. A dependency cycle in the
+// stored data is reported as ErrCircularDependency once the rest of the cascade has run.
+func (s *flagService) cascadeDisableDependents(ctx context.Context, origin cascadeOrigin) error {
+	flagID := origin.root.ID
+	walk := &cascadeWalk{onPath: map[int64]bool{flagID: true}, visited: map[int64]bool{}, origin: origin}
+	err := s.cascadeDisable(ctx, origin.root, walk)
+
+	// Remember exactly which flags this cascade touched so they can be restored later
+	if s.cascadeEventRepo != nil && len(walk.cascaded) > 0 {
+		eventID, eventErr := s.cascadeEventRepo.CreateCascadeEvent(ctx, flagID, walk.cascaded)
+		if eventErr != nil && inTx(ctx) {
+			return fmt.Errorf("failed to record cascade event: %w", eventErr)
+		} else if eventErr != nil {
+			s.log(ctx).Errorw("Failed to record cascade event", "error", eventErr, "flagID", flagID)
+		} else {
+			s.log(ctx).Infow("Cascade event recorded", "flagID", flagID, "cascadeEventID", eventID, "flags", len(walk.cascaded))
 		}
-		if flag.IsDisabled() {
-			missingDeps = append(missingDeps, flag.Name)
+	}
+	return err
+}
+
+// PreviewCascadeDisable returns the flags a disable of the flag would cascade to, in the
+// order the cascade reaches them. Nothing is written.
+func (s *flagService) PreviewCascadeDisable(ctx context.Context, flagID int64) ([]*entity.Flag, error) {
+	flag, err := s.GetFlag(ctx, flagID)
+	if err != nil {
+		return nil, err
+	}
+
+	walk := &cascadeWalk{onPath: map[int64]bool{flagID: true}, visited: map[int64]bool{}, dryRun: true}
+	if err := s.cascadeDisable(ctx, flag, walk); err != nil {
+		if !errors.Is(err, ErrCircularDependency) {
+			return nil, err
 		}
+		// The real cascade also runs to completion around a cycle
+		s.log(ctx).Warnw("Dependency cycle found during cascade preview", "flagID", flagID)
 	}
 
-	return missingDeps, nil
+	affected := walk.affected
+	if affected == nil {
+		affected = []*entity.Flag{}
+	}
+	return affected, nil
+}
+
+// cascadeWalk is the state of one cascade. onPath holds the flags on the current branch
+// so a cycle is detected instead of walking it forever; visited skips flags already
+// reached through another branch; cascaded collects the flags that were disabled.
+// A dryRun walk writes nothing and collects the flags it would disable in affected.
+type cascadeWalk struct {
+	onPath   map[int64]bool
+	visited  map[int64]bool
+	cascaded []int64
+	origin   cascadeOrigin
+	dryRun   bool
+	affected []*entity.Flag
 }
 
-// cascadeDisableDependents disables all flags that depend on this flag
-func (s *flagService) cascadeDisableDependents(ctx context.Context, flagID int64) error {
-	dependents, err := s.flagRepo.GetDependents(ctx, flagID)
+// cascadeDisable walks the dependents of root depth-first. The walk keeps its own stack of
+// the flags on the current branch rather than recursing, so deep dependency chains do not
+// grow the Go stack; each flag is fetched and processed once, however many paths reach it.
+func (s *flagService) cascadeDisable(ctx context.Context, root *entity.Flag, walk *cascadeWalk) error {
+	// cascadeFrame is a flag on the current branch and its dependents still to visit
+	type cascadeFrame struct {
+		flag       *entity.Flag
+		dependents []int64
+	}
+
+	dependents, err := s.flagRepo.GetDependents(ctx, root.ID)
 	if err != nil {
 		return fmt.Errorf("failed to get dependents: %w", err)
 	}
+	stack := []*cascadeFrame{{flag: root, dependents: dependents}}
+
+	var cycleErr error
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		if len(top.dependents) == 0 {
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				delete(walk.onPath, top.flag.ID)
+			}
+			continue
+		}
+		parent, flagID, depID := top.flag, top.flag.ID, top.dependents[0]
+		top.dependents = top.dependents[1:]
+
+		if walk.onPath[depID] {
+			s.log(ctx).Warnw("Dependency cycle found during cascade", "depID", depID, "parentFlagID", flagID)
+			cycleErr = ErrCircularDependency
+			continue
+		}
+		if walk.visited[depID] {
+			continue
+		}
+		walk.visited[depID] = true
 
-	for _, depID := range dependents {
 		// Get dependent flag to check if it's enabled
 		depFlag, err := s.flagRepo.GetFlagByID(ctx, depID)
 		if err != nil {
-			s.logger.Errorw("Failed to get dependent flag", "error", err, "depID", depID)
+			if inTx(ctx) {
+				return fmt.Errorf("failed to get dependent flag %d: %w", depID, err)
+			}
+			s.log(ctx).Errorw("Failed to get dependent flag", "error", err, "depID", depID)
+			continue
+		}
+
+		// A locked flag keeps its status; its own dependents are unaffected as a result
+		if depFlag.IsEnabled() && depFlag.Locked {
+			s.log(ctx).Warnw("Skipping locked flag during cascade", "depID", depID, "parentFlagID", flagID)
+			continue
+		}
+		if !depFlag.IsEnabled() {
 			continue
 		}
 
-		if depFlag.IsEnabled() {
-			// Disable the dependent flag
-			if err := s.flagRepo.UpdateFlagStatus(ctx, depID, entity.FlagDisabled); err != nil {
-				s.logger.Errorw("Failed to cascade disable dependent", "error", err, "depID", depID)
+		if walk.dryRun {
+			walk.affected = append(walk.affected, depFlag)
+		} else {
+			// Disable the dependent flag according to its cascade strategy
+			targetStatus := depFlag.CascadeStatus()
+			if err := s.updateStatus(ctx, depFlag, targetStatus, validator.SystemActor); err != nil {
+				if inTx(ctx) {
+					return fmt.Errorf("failed to cascade disable dependent %d: %w", depID, err)
+				}
+				s.log(ctx).Errorw("Failed to cascade disable dependent", "error", err, "depID", depID)
 				continue
 			}
+			walk.cascaded = append(walk.cascaded, depID)
 
 			// Create audit log for cascade disable
-			auditLog := entity.NewAuditLog(depID, entity.ActionCascadeDisable, "system", 
-				fmt.Sprintf("Automatically disabled due to dependency flag %d being disabled", flagID))
-			if err := s.auditRepo.CreateAuditLog(ctx, auditLog); err != nil {
-				s.logger.Warnw("Failed to create cascade audit log", "error", err, "depID", depID)
+			action := entity.ActionCascadeDisable
+			if targetStatus == entity.FlagMaintenance {
+				action = entity.ActionCascadeMaintenance
+			}
+			reason := cascadeReason(walk.origin, parent, depFlag, targetStatus)
+			auditLog := entity.NewAuditLog(depID, action, validator.SystemActor, reason)
+			if err := s.recordAudit(ctx, auditLog); err != nil {
+				if inTx(ctx) {
+					return fmt.Errorf("failed to create cascade audit log: %w", err)
+				}
+				s.log(ctx).Warnw("Failed to create cascade audit log", "error", err, "depID", depID)
 			}
 
-			s.logger.Infow("Cascade disabled dependent flag", "depID", depID, "parentFlagID", flagID)
+			s.log(ctx).Infow("Cascade disabled dependent flag", "depID", depID, "parentFlagID", flagID, "status", targetStatus)
+		}
 
-			// Recursively disable dependents of this flag
-			if err := s.cascadeDisableDependents(ctx, depID); err != nil {
-				s.logger.Errorw("Failed to recursively cascade disable", "error", err, "depID", depID)
+		// Continue with the dependents of this flag before its siblings
+		next, err := s.flagRepo.GetDependents(ctx, depID)
+		if err != nil {
+			if inTx(ctx) {
+				return fmt.Errorf("failed to get dependents: %w", err)
 			}
+			s.log(ctx).Errorw("Failed to get dependents during cascade", "error", err, "depID", depID)
+			continue
 		}
+		walk.onPath[depID] = true
+		stack = append(stack, &cascadeFrame{flag: depFlag, dependents: next})
 	}
 
-	return nil
-} 
\ No newline at end of file
+	return cycleErr
+}
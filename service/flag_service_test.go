@@ -2,7 +2,12 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"featureflags/entity"
 	"featureflags/repository"
@@ -40,6 +45,20 @@ func TestFlagService_CreateFlag(t *testing.T) {
 		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionCreate, "test_user")
 	})
 
+	t.Run("create flag with description", func(t *testing.T) {
+		req := validator.FlagCreateRequest{
+			Name:        "described_on_create",
+			Description: "Shows the redesigned onboarding flow",
+		}
+
+		flag, err := service.CreateFlag(context.Background(), req, "test_user")
+		require.NoError(t, err)
+
+		stored, err := flagRepo.GetFlagByID(context.Background(), flag.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Shows the redesigned onboarding flow", stored.Description)
+	})
+
 	t.Run("create flag with dependencies", func(t *testing.T) {
 		// Create dependency flags first
 		dep1 := testDB.CreateTestFlag(t, "dep1", entity.FlagEnabled)
@@ -55,6 +74,52 @@ func TestFlagService_CreateFlag(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, "dependent_flag", flag.Name)
 		assert.Equal(t, []int64{dep1.ID, dep2.ID}, flag.Dependencies)
+
+		// One audit entry per added edge, naming the dependency
+		logs, err := auditRepo.ListAuditLogsByFlagID(context.Background(), flag.ID)
+		require.NoError(t, err)
+		var reasons []string
+		for _, log := range logs {
+			if log.Action == entity.ActionAddDependency {
+				assert.Equal(t, "test_user", log.Actor)
+				reasons = append(reasons, log.Reason)
+			}
+		}
+		assert.ElementsMatch(t, []string{
+			fmt.Sprintf("Added dependency on dep1 (ID %d)", dep1.ID),
+			fmt.Sprintf("Added dependency on dep2 (ID %d)", dep2.ID),
+		}, reasons)
+	})
+
+	t.Run("create flag with dependencies by name", func(t *testing.T) {
+		dep1 := testDB.CreateTestFlag(t, "named_dep1", entity.FlagEnabled)
+		dep2 := testDB.CreateTestFlag(t, "named_dep2", entity.FlagEnabled)
+
+		req := validator.FlagCreateRequest{
+			Name:            "named_dependent",
+			DependencyNames: []string{"named_dep1", "named_dep2"},
+		}
+
+		flag, err := service.CreateFlag(context.Background(), req, "test_user")
+		require.NoError(t, err)
+		assert.Equal(t, []int64{dep1.ID, dep2.ID}, flag.Dependencies)
+	})
+
+	t.Run("create flag with unknown dependency names", func(t *testing.T) {
+		testDB.CreateTestFlag(t, "named_known", entity.FlagEnabled)
+
+		req := validator.FlagCreateRequest{
+			Name:            "named_unknown",
+			DependencyNames: []string{"named_missing1", "named_known", "named_missing2"},
+		}
+
+		_, err := service.CreateFlag(context.Background(), req, "test_user")
+		var unknownErr UnknownDependenciesError
+		require.ErrorAs(t, err, &unknownErr)
+		assert.Equal(t, []string{"named_missing1", "named_missing2"}, unknownErr.UnknownDependencies)
+
+		_, err = flagRepo.GetFlagByName(context.Background(), "named_unknown")
+		assert.ErrorIs(t, err, repository.ErrFlagNotFound)
 	})
 
 	t.Run("create flag with circular dependency", func(t *testing.T) {
@@ -109,9 +174,52 @@ func TestFlagService_CreateFlag(t *testing.T) {
 		_, err = service.CreateFlag(context.Background(), req, "test_user")
 		assert.ErrorIs(t, err, ErrFlagAlreadyExists)
 	})
+
+	t.Run("reject dependency on its own ID", func(t *testing.T) {
+		probe := testDB.CreateTestFlag(t, "self_probe", entity.FlagDisabled)
+		nextID := probe.ID + 1
+		// Pretend the future ID already exists so only the self-dependency check can catch it
+		repo := &presetFlagRepository{FlagRepository: flagRepo, preset: nextID}
+		selfService := NewFlagService(repo, auditRepo, log)
+
+		req := validator.FlagCreateRequest{Name: "self_dependent", Dependencies: []int64{nextID}}
+		_, err := selfService.CreateFlag(context.Background(), req, "test_user")
+
+		assert.ErrorIs(t, err, ErrSelfDependency)
+		assert.ErrorIs(t, err, ErrCircularDependency)
+		_, err = flagRepo.GetFlagByName(context.Background(), "self_dependent")
+		assert.ErrorIs(t, err, repository.ErrFlagNotFound, "the flag must be rolled back")
+	})
 }
 
-func TestFlagService_EnableFlag(t *testing.T) {
+// presetFlagRepository reports a flag with the preset ID as existing before it is created
+type presetFlagRepository struct {
+	repository.FlagRepository
+	preset int64
+}
+
+func (r *presetFlagRepository) GetFlagByID(ctx context.Context, id int64) (*entity.Flag, error) {
+	if id == r.preset {
+		return &entity.Flag{ID: id, Name: "preset", Status: entity.FlagEnabled}, nil
+	}
+	return r.FlagRepository.GetFlagByID(ctx, id)
+}
+
+func (r *presetFlagRepository) GetFlagsByIDs(ctx context.Context, ids []int64) ([]*entity.Flag, error) {
+	flags, err := r.FlagRepository.GetFlagsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if id == r.preset {
+			flags = append(flags, &entity.Flag{ID: id, Name: "preset", Status: entity.FlagEnabled})
+			break
+		}
+	}
+	return flags, nil
+}
+
+func TestFlagService_BulkCreateFlags(t *testing.T) {
 	testDB := test.SetupTestDB(t)
 	defer testDB.Close()
 	defer testDB.CleanTables(t)
@@ -120,59 +228,67 @@ func TestFlagService_EnableFlag(t *testing.T) {
 	auditRepo := repository.NewAuditRepository(testDB.DB)
 	log := test.GetTestLogger()
 	service := NewFlagService(flagRepo, auditRepo, log)
+	ctx := context.Background()
 
-	t.Run("enable flag without dependencies", func(t *testing.T) {
-		flag := testDB.CreateTestFlag(t, "simple_flag", entity.FlagDisabled)
+	item := func(name string, deps ...validator.FlagRef) validator.FlagBulkCreateItem {
+		return validator.FlagBulkCreateItem{
+			FlagCreateRequest: validator.FlagCreateRequest{Name: name},
+			Dependencies:      deps,
+		}
+	}
 
-		err := service.EnableFlag(context.Background(), flag.ID, "test_user", "testing enable")
-		
-		require.NoError(t, err)
-		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
-		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionEnable, "test_user")
-	})
+	t.Run("resolves dependencies within the batch", func(t *testing.T) {
+		existing := testDB.CreateTestFlag(t, "bulk_existing", entity.FlagEnabled)
 
-	t.Run("enable flag with satisfied dependencies", func(t *testing.T) {
-		// Create enabled dependencies
-		dep1 := testDB.CreateTestFlag(t, "enable_dep1", entity.FlagEnabled)
-		dep2 := testDB.CreateTestFlag(t, "enable_dep2", entity.FlagEnabled)
-		
-		// Create dependent flag
-		flag := testDB.CreateTestFlagWithDependencies(t, "dependent_satisfied", entity.FlagDisabled, []int64{dep1.ID, dep2.ID})
+		// checkout is listed before the auth flag it depends on
+		flags, err := service.BulkCreateFlags(ctx, validator.FlagBulkCreateRequest{Flags: []validator.FlagBulkCreateItem{
+			item("bulk_checkout", "bulk_auth", validator.FlagRef(fmt.Sprint(existing.ID))),
+			item("bulk_auth", "bulk_existing"),
+		}}, "importer")
 
-		err := service.EnableFlag(context.Background(), flag.ID, "test_user", "dependencies satisfied")
-		
 		require.NoError(t, err)
-		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
+		require.Len(t, flags, 2)
+		assert.Equal(t, "bulk_checkout", flags[0].Name)
+		assert.ElementsMatch(t, []int64{flags[1].ID, existing.ID}, flags[0].Dependencies)
+		assert.Equal(t, []int64{existing.ID}, flags[1].Dependencies)
+		testDB.AssertAuditLogExists(t, flags[0].ID, entity.ActionCreate, "importer")
 	})
 
-	t.Run("fail to enable flag with missing dependencies", func(t *testing.T) {
-		// Create mixed dependencies (one enabled, one disabled)
-		dep1 := testDB.CreateTestFlag(t, "enabled_dep", entity.FlagEnabled)
-		dep2 := testDB.CreateTestFlag(t, "disabled_dep", entity.FlagDisabled)
-		
-		// Create dependent flag
-		flag := testDB.CreateTestFlagWithDependencies(t, "dependent_missing", entity.FlagDisabled, []int64{dep1.ID, dep2.ID})
+	t.Run("any rejected flag rolls back the batch", func(t *testing.T) {
+		_, err := service.BulkCreateFlags(ctx, validator.FlagBulkCreateRequest{Flags: []validator.FlagBulkCreateItem{
+			item("bulk_ok"),
+			item("bulk_cycle_a", "bulk_cycle_b"),
+			item("bulk_cycle_b", "bulk_cycle_a"),
+			item("bulk_missing_dep", "no_such_flag"),
+			item("bulk_existing"),
+		}}, "importer")
 
-		err := service.EnableFlag(context.Background(), flag.ID, "test_user", "should fail")
-		
-		require.Error(t, err)
-		
-		// Check if it's a dependency error with the expected format
-		if depErr, ok := err.(DependencyError); ok {
-			assert.Equal(t, "Missing active dependencies", depErr.Message)
-			assert.Contains(t, depErr.MissingDependencies, "disabled_dep")
+		var bulkErr BulkCreateError
+		require.ErrorAs(t, err, &bulkErr)
+		var rejected []int
+		for _, item := range bulkErr.Items {
+			rejected = append(rejected, item.Index)
 		}
-		
-		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+		assert.Equal(t, []int{1, 2, 3, 4}, rejected)
+
+		_, err = flagRepo.GetFlagByName(ctx, "bulk_ok")
+		assert.ErrorIs(t, err, repository.ErrFlagNotFound)
 	})
 
-	t.Run("enable non-existent flag", func(t *testing.T) {
-		err := service.EnableFlag(context.Background(), 99999, "test_user", "should fail")
-		assert.ErrorIs(t, err, ErrFlagNotFound)
+	t.Run("duplicate names in the batch", func(t *testing.T) {
+		_, err := service.BulkCreateFlags(ctx, validator.FlagBulkCreateRequest{Flags: []validator.FlagBulkCreateItem{
+			item("bulk_twice"),
+			item("bulk_twice"),
+		}}, "importer")
+
+		var bulkErr BulkCreateError
+		require.ErrorAs(t, err, &bulkErr)
+		require.Len(t, bulkErr.Items, 1)
+		assert.Equal(t, 1, bulkErr.Items[0].Index)
 	})
 }
 
-func TestFlagService_DisableFlag(t *testing.T) {
+func TestFlagService_BulkToggleFlags(t *testing.T) {
 	testDB := test.SetupTestDB(t)
 	defer testDB.Close()
 	defer testDB.CleanTables(t)
@@ -181,41 +297,84 @@ func TestFlagService_DisableFlag(t *testing.T) {
 	auditRepo := repository.NewAuditRepository(testDB.DB)
 	log := test.GetTestLogger()
 	service := NewFlagService(flagRepo, auditRepo, log)
+	ctx := context.Background()
 
-	t.Run("disable flag without dependents", func(t *testing.T) {
-		flag := testDB.CreateTestFlag(t, "disable_simple_flag", entity.FlagEnabled)
+	toggle := func(enable bool, ids ...int64) ([]*entity.BulkToggleItem, error) {
+		return service.BulkToggleFlags(ctx, validator.FlagBulkToggleRequest{
+			FlagIDs: ids,
+			Enable:  enable,
+			Reason:  "bulk change",
+		}, "test_user")
+	}
+
+	t.Run("enable runs in dependency order", func(t *testing.T) {
+		auth := testDB.CreateTestFlag(t, "bulk_toggle_auth", entity.FlagDisabled)
+		checkout := testDB.CreateTestFlagWithDependencies(t, "bulk_toggle_checkout", entity.FlagDisabled, []int64{auth.ID})
+
+		// checkout is listed before the auth flag it depends on
+		results, err := toggle(true, checkout.ID, auth.ID)
 
-		err := service.DisableFlag(context.Background(), flag.ID, "test_user", "testing disable")
-		
 		require.NoError(t, err)
-		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
-		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionDisable, "test_user")
+		require.Len(t, results, 2)
+		assert.Equal(t, checkout.ID, results[0].FlagID)
+		assert.True(t, results[0].Changed)
+		assert.Equal(t, entity.FlagEnabled, results[1].Status)
+		testDB.AssertFlagStatus(t, checkout.ID, entity.FlagEnabled)
+		testDB.AssertAuditLogExists(t, auth.ID, entity.ActionEnable, "test_user")
 	})
 
-	t.Run("disable flag with cascade to dependents", func(t *testing.T) {
-		// Create dependency chain: dep -> flag1 -> flag2
-		dep := testDB.CreateTestFlag(t, "cascade_dependency", entity.FlagEnabled)
-		flag1 := testDB.CreateTestFlagWithDependencies(t, "cascade_flag1", entity.FlagEnabled, []int64{dep.ID})
-		flag2 := testDB.CreateTestFlagWithDependencies(t, "cascade_flag2", entity.FlagEnabled, []int64{flag1.ID})
+	t.Run("disable cascades to flags outside the batch", func(t *testing.T) {
+		root := testDB.CreateTestFlag(t, "bulk_toggle_root", entity.FlagEnabled)
+		child := testDB.CreateTestFlagWithDependencies(t, "bulk_toggle_child", entity.FlagEnabled, []int64{root.ID})
+		outside := testDB.CreateTestFlagWithDependencies(t, "bulk_toggle_outside", entity.FlagEnabled, []int64{child.ID})
+
+		results, err := toggle(false, root.ID, child.ID)
 
-		// Disable the root dependency
-		err := service.DisableFlag(context.Background(), dep.ID, "test_user", "cascade test")
-		
 		require.NoError(t, err)
-		
-		// All flags should be disabled
-		testDB.AssertFlagStatus(t, dep.ID, entity.FlagDisabled)
-		testDB.AssertFlagStatus(t, flag1.ID, entity.FlagDisabled)
-		testDB.AssertFlagStatus(t, flag2.ID, entity.FlagDisabled)
-		
-		// Check audit logs for cascade actions
-		testDB.AssertAuditLogExists(t, dep.ID, entity.ActionDisable, "test_user")
-		testDB.AssertAuditLogExists(t, flag1.ID, entity.ActionCascadeDisable, "system")
-		testDB.AssertAuditLogExists(t, flag2.ID, entity.ActionCascadeDisable, "system")
+		require.Len(t, results, 2)
+		assert.True(t, results[1].Changed)
+		testDB.AssertFlagStatus(t, child.ID, entity.FlagDisabled)
+		testDB.AssertFlagStatus(t, outside.ID, entity.FlagDisabled)
+		testDB.AssertAuditLogExists(t, child.ID, entity.ActionDisable, "test_user")
+	})
+
+	t.Run("any rejected flag rolls back the batch", func(t *testing.T) {
+		ok := testDB.CreateTestFlag(t, "bulk_toggle_ok", entity.FlagEnabled)
+		locked := testDB.CreateTestFlag(t, "bulk_toggle_locked", entity.FlagEnabled)
+		require.NoError(t, service.LockFlag(ctx, locked.ID, "admin", "must stay on"))
+
+		_, err := toggle(false, ok.ID, locked.ID, 999999)
+
+		var bulkErr BulkToggleError
+		require.ErrorAs(t, err, &bulkErr)
+		require.Len(t, bulkErr.Items, 1)
+		assert.Equal(t, 2, bulkErr.Items[0].Index)
+
+		_, err = toggle(false, ok.ID, locked.ID)
+
+		require.ErrorAs(t, err, &bulkErr)
+		require.Len(t, bulkErr.Items, 1)
+		assert.Equal(t, "bulk_toggle_locked", bulkErr.Items[0].Name)
+		testDB.AssertFlagStatus(t, ok.ID, entity.FlagEnabled)
+	})
+
+	t.Run("enable checks dependencies outside the batch", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "bulk_toggle_dep", entity.FlagDisabled)
+		ready := testDB.CreateTestFlag(t, "bulk_toggle_ready", entity.FlagDisabled)
+		blocked := testDB.CreateTestFlagWithDependencies(t, "bulk_toggle_blocked", entity.FlagDisabled, []int64{dep.ID})
+
+		_, err := toggle(true, ready.ID, blocked.ID)
+
+		var bulkErr BulkToggleError
+		require.ErrorAs(t, err, &bulkErr)
+		require.Len(t, bulkErr.Items, 1)
+		assert.Equal(t, 1, bulkErr.Items[0].Index)
+		assert.Contains(t, bulkErr.Items[0].Error, "bulk_toggle_dep")
+		testDB.AssertFlagStatus(t, ready.ID, entity.FlagDisabled)
 	})
 }
 
-func TestFlagService_ToggleFlag(t *testing.T) {
+func TestFlagService_UpdateFlag(t *testing.T) {
 	testDB := test.SetupTestDB(t)
 	defer testDB.Close()
 	defer testDB.CleanTables(t)
@@ -225,36 +384,153 @@ func TestFlagService_ToggleFlag(t *testing.T) {
 	log := test.GetTestLogger()
 	service := NewFlagService(flagRepo, auditRepo, log)
 
-	t.Run("toggle flag to enabled", func(t *testing.T) {
-		flag := testDB.CreateTestFlag(t, "toggle_flag", entity.FlagDisabled)
+	t.Run("replace dependencies", func(t *testing.T) {
+		dep1 := testDB.CreateTestFlag(t, "update_dep1", entity.FlagEnabled)
+		dep2 := testDB.CreateTestFlag(t, "update_dep2", entity.FlagEnabled)
+		flag := testDB.CreateTestFlagWithDependencies(t, "update_flag", entity.FlagDisabled, []int64{dep1.ID})
 
-		req := validator.FlagToggleRequest{
-			Enable: true,
-			Reason: "testing toggle enable",
+		updated, err := service.UpdateFlag(context.Background(), flag.ID,
+			validator.FlagUpdateRequest{Dependencies: []int64{dep2.ID}}, "test_user")
+
+		require.NoError(t, err)
+		assert.Equal(t, []int64{dep2.ID}, updated.Dependencies)
+		logs, err := auditRepo.ListAuditLogsByFlagID(context.Background(), flag.ID)
+		require.NoError(t, err)
+		var reasons []string
+		for _, log := range logs {
+			if log.Action == entity.ActionUpdate {
+				reasons = append(reasons, log.Reason)
+			}
 		}
+		assert.ElementsMatch(t, []string{
+			fmt.Sprintf("Added dependency on update_dep2 (ID %d)", dep2.ID),
+			fmt.Sprintf("Removed dependency on update_dep1 (ID %d)", dep1.ID),
+		}, reasons)
+	})
+
+	t.Run("remove all dependencies", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "clear_dep", entity.FlagEnabled)
+		flag := testDB.CreateTestFlagWithDependencies(t, "clear_flag", entity.FlagEnabled, []int64{dep.ID})
+
+		updated, err := service.UpdateFlag(context.Background(), flag.ID,
+			validator.FlagUpdateRequest{Dependencies: []int64{}}, "test_user")
 
-		err := service.ToggleFlag(context.Background(), flag.ID, req, "test_user")
-		
 		require.NoError(t, err)
-		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
+		assert.Empty(t, updated.Dependencies)
 	})
 
-	t.Run("toggle flag to disabled", func(t *testing.T) {
-		flag := testDB.CreateTestFlag(t, "toggle_flag2", entity.FlagEnabled)
+	t.Run("change description only", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "described_dep", entity.FlagEnabled)
+		flag := testDB.CreateTestFlagWithDependencies(t, "described_flag", entity.FlagDisabled, []int64{dep.ID})
+		description := "Routes checkout traffic to the new payment provider"
 
-		req := validator.FlagToggleRequest{
-			Enable: false,
-			Reason: "testing toggle disable",
-		}
+		updated, err := service.UpdateFlag(context.Background(), flag.ID,
+			validator.FlagUpdateRequest{Description: &description}, "test_user")
 
-		err := service.ToggleFlag(context.Background(), flag.ID, req, "test_user")
-		
 		require.NoError(t, err)
-		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+		assert.Equal(t, description, updated.Description)
+		assert.Equal(t, []int64{dep.ID}, updated.Dependencies, "omitted dependencies are kept")
+		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionUpdate, "test_user")
+
+		cleared := ""
+		updated, err = service.UpdateFlag(context.Background(), flag.ID,
+			validator.FlagUpdateRequest{Description: &cleared}, "test_user")
+		require.NoError(t, err)
+		assert.Empty(t, updated.Description)
+	})
+
+	t.Run("replace tags", func(t *testing.T) {
+		flag, err := service.CreateFlag(context.Background(), validator.FlagCreateRequest{
+			Name: "tagged_flag",
+			Tags: map[string]string{"team": "growth", "subsystem": "onboarding"},
+		}, "test_user")
+		require.NoError(t, err)
+
+		updated, err := service.UpdateFlag(context.Background(), flag.ID,
+			validator.FlagUpdateRequest{Tags: map[string]string{"team": "payments"}}, "test_user")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"team": "payments"}, updated.Tags)
+		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionUpdate, "test_user")
+
+		updated, err = service.UpdateFlag(context.Background(), flag.ID,
+			validator.FlagUpdateRequest{Tags: map[string]string{}}, "test_user")
+		require.NoError(t, err)
+		assert.Empty(t, updated.Tags)
+	})
+
+	t.Run("reject overlong description", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "verbose_flag", entity.FlagDisabled)
+		description := strings.Repeat("x", 1001)
+
+		_, err := service.UpdateFlag(context.Background(), flag.ID,
+			validator.FlagUpdateRequest{Description: &description}, "test_user")
+		assert.Error(t, err)
+	})
+
+	t.Run("reject circular dependency", func(t *testing.T) {
+		a := testDB.CreateTestFlag(t, "cycle_a", entity.FlagDisabled)
+		b := testDB.CreateTestFlagWithDependencies(t, "cycle_b", entity.FlagDisabled, []int64{a.ID})
+
+		_, err := service.UpdateFlag(context.Background(), a.ID,
+			validator.FlagUpdateRequest{Dependencies: []int64{b.ID}}, "test_user")
+		assert.ErrorIs(t, err, ErrCircularDependency)
+
+		_, err = service.UpdateFlag(context.Background(), a.ID,
+			validator.FlagUpdateRequest{Dependencies: []int64{a.ID}}, "test_user")
+		assert.ErrorIs(t, err, ErrSelfDependency)
+
+		deps, err := flagRepo.GetDependencies(context.Background(), a.ID)
+		require.NoError(t, err)
+		assert.Empty(t, deps)
+	})
+
+	t.Run("reject cycle through a chain deeper than ten", func(t *testing.T) {
+		// chain_11 -> chain_10 -> ... -> chain_0
+		root := testDB.CreateTestFlag(t, "chain_0", entity.FlagDisabled)
+		tail := root
+		for i := 1; i <= 11; i++ {
+			tail = testDB.CreateTestFlagWithDependencies(t, fmt.Sprintf("chain_%d", i), entity.FlagDisabled, []int64{tail.ID})
+		}
+
+		_, err := service.UpdateFlag(context.Background(), root.ID,
+			validator.FlagUpdateRequest{Dependencies: []int64{tail.ID}}, "test_user")
+		assert.ErrorIs(t, err, ErrCircularDependency)
+	})
+
+	t.Run("reject chain deeper than the maximum depth", func(t *testing.T) {
+		shallowService := NewFlagService(repository.NewFlagRepository(testDB.DB, repository.WithMaxDependencyDepth(3)), auditRepo, log)
+		// deep_3 -> deep_2 -> deep_1 -> deep_0
+		tail := testDB.CreateTestFlag(t, "deep_0", entity.FlagDisabled)
+		for i := 1; i <= 3; i++ {
+			tail = testDB.CreateTestFlagWithDependencies(t, fmt.Sprintf("deep_%d", i), entity.FlagDisabled, []int64{tail.ID})
+		}
+		head := testDB.CreateTestFlag(t, "deep_head", entity.FlagDisabled)
+
+		_, err := shallowService.UpdateFlag(context.Background(), head.ID,
+			validator.FlagUpdateRequest{Dependencies: []int64{tail.ID}}, "test_user")
+		assert.ErrorIs(t, err, ErrDependencyTooDeep)
+		assert.NotErrorIs(t, err, ErrCircularDependency)
+	})
+
+	t.Run("enabled flag cannot gain a disabled dependency", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "off_dep", entity.FlagDisabled)
+		flag := testDB.CreateTestFlag(t, "on_flag", entity.FlagEnabled)
+
+		_, err := service.UpdateFlag(context.Background(), flag.ID,
+			validator.FlagUpdateRequest{Dependencies: []int64{dep.ID}}, "test_user")
+
+		var depErr DependencyError
+		require.ErrorAs(t, err, &depErr)
+		assert.Contains(t, depErr.MissingDependencies, "off_dep")
+	})
+
+	t.Run("flag not found", func(t *testing.T) {
+		_, err := service.UpdateFlag(context.Background(), 99999, validator.FlagUpdateRequest{}, "test_user")
+		assert.ErrorIs(t, err, ErrFlagNotFound)
 	})
 }
 
-func TestFlagService_GetFlag(t *testing.T) {
+func TestFlagService_RenameFlag(t *testing.T) {
 	testDB := test.SetupTestDB(t)
 	defer testDB.Close()
 	defer testDB.CleanTables(t)
@@ -263,25 +539,58 @@ func TestFlagService_GetFlag(t *testing.T) {
 	auditRepo := repository.NewAuditRepository(testDB.DB)
 	log := test.GetTestLogger()
 	service := NewFlagService(flagRepo, auditRepo, log)
+	ctx := context.Background()
 
-	t.Run("get existing flag", func(t *testing.T) {
-		createdFlag := testDB.CreateTestFlag(t, "get_test_flag", entity.FlagEnabled)
+	rename := func(flagID int64, name string) (*entity.Flag, error) {
+		return service.RenameFlag(ctx, flagID, validator.FlagRenameRequest{Name: name}, "test_user")
+	}
+
+	t.Run("renamed flag keeps its dependents", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "rename_auth", entity.FlagEnabled)
+		dependent := testDB.CreateTestFlagWithDependencies(t, "rename_checkout", entity.FlagEnabled, []int64{flag.ID})
+
+		renamed, err := rename(flag.ID, "rename_auth_v2")
 
-		flag, err := service.GetFlag(context.Background(), createdFlag.ID)
-		
 		require.NoError(t, err)
-		assert.Equal(t, createdFlag.ID, flag.ID)
-		assert.Equal(t, "get_test_flag", flag.Name)
-		assert.Equal(t, entity.FlagEnabled, flag.Status)
+		assert.Equal(t, "rename_auth_v2", renamed.Name)
+		_, err = service.GetFlagByName(ctx, "rename_auth")
+		assert.ErrorIs(t, err, ErrFlagNotFound)
+		loaded, err := service.GetFlag(ctx, dependent.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{flag.ID}, loaded.Dependencies)
+
+		logs, err := service.GetFlagAuditLogs(ctx, flag.ID, entity.AuditFilter{Action: entity.ActionUpdate})
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		assert.Equal(t, "Renamed from rename_auth to rename_auth_v2", logs[0].Reason)
 	})
 
-	t.Run("get non-existent flag", func(t *testing.T) {
-		_, err := service.GetFlag(context.Background(), 99999)
-		assert.ErrorIs(t, err, ErrFlagNotFound)
+	t.Run("name taken by another flag", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "rename_taken_a", entity.FlagDisabled)
+		testDB.CreateTestFlag(t, "rename_taken_b", entity.FlagDisabled)
+
+		_, err := rename(flag.ID, "rename_taken_b")
+		assert.ErrorIs(t, err, ErrFlagAlreadyExists)
+	})
+
+	t.Run("archived flag is rejected", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "rename_archived", entity.FlagDisabled)
+		require.NoError(t, service.ArchiveFlag(ctx, flag.ID, "test_user", "retired"))
+
+		_, err := rename(flag.ID, "rename_archived_v2")
+		assert.ErrorIs(t, err, ErrFlagArchived)
+	})
+
+	t.Run("invalid name is rejected", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "rename_invalid", entity.FlagDisabled)
+
+		var validationErrs validator.ValidationErrors
+		_, err := rename(flag.ID, "-bad name")
+		assert.ErrorAs(t, err, &validationErrs)
 	})
 }
 
-func TestFlagService_ListFlags(t *testing.T) {
+func TestFlagService_AddDependency(t *testing.T) {
 	testDB := test.SetupTestDB(t)
 	defer testDB.Close()
 	defer testDB.CleanTables(t)
@@ -290,32 +599,53 @@ func TestFlagService_ListFlags(t *testing.T) {
 	auditRepo := repository.NewAuditRepository(testDB.DB)
 	log := test.GetTestLogger()
 	service := NewFlagService(flagRepo, auditRepo, log)
+	ctx := context.Background()
+
+	attach := func(flagID, dependsOnID int64) (*entity.Flag, error) {
+		return service.AddDependency(ctx, flagID, validator.FlagDependencyRequest{DependsOnID: dependsOnID}, "test_user")
+	}
+
+	enabledDep := testDB.CreateTestFlag(t, "attach_enabled_dep", entity.FlagEnabled)
+	disabledDep := testDB.CreateTestFlag(t, "attach_disabled_dep", entity.FlagDisabled)
+	flag := testDB.CreateTestFlag(t, "attach_flag", entity.FlagEnabled)
+
+	t.Run("attach an edge", func(t *testing.T) {
+		updated, err := attach(flag.ID, enabledDep.ID)
 
-	t.Run("list flags", func(t *testing.T) {
-		// Create test flags
-		flag1 := testDB.CreateTestFlag(t, "list_flag1", entity.FlagEnabled)
-		flag2 := testDB.CreateTestFlag(t, "list_flag2", entity.FlagDisabled)
-		
-		flags, err := service.ListFlags(context.Background())
-		
 		require.NoError(t, err)
-		assert.Len(t, flags, 2)
-		
-		// Verify flags are returned with correct IDs
-		flagIDs := make(map[int64]bool)
-		flagNames := make(map[string]bool)
-		for _, flag := range flags {
-			flagIDs[flag.ID] = true
-			flagNames[flag.Name] = true
-		}
-		assert.True(t, flagIDs[flag1.ID])
-		assert.True(t, flagIDs[flag2.ID])
-		assert.True(t, flagNames["list_flag1"])
-		assert.True(t, flagNames["list_flag2"])
+		assert.Equal(t, []int64{enabledDep.ID}, updated.Dependencies)
+		deps, err := flagRepo.GetDependencies(ctx, flag.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{enabledDep.ID}, deps)
+		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionUpdate, "test_user")
+	})
+
+	t.Run("enabled flag cannot gain a disabled dependency", func(t *testing.T) {
+		_, err := attach(flag.ID, disabledDep.ID)
+
+		var depErr DependencyError
+		require.ErrorAs(t, err, &depErr)
+		assert.Equal(t, []string{"attach_disabled_dep"}, depErr.MissingDependencies)
+	})
+
+	t.Run("cycles and self-references are rejected", func(t *testing.T) {
+		_, err := attach(enabledDep.ID, flag.ID)
+		assert.ErrorIs(t, err, ErrCircularDependency)
+
+		_, err = attach(flag.ID, flag.ID)
+		assert.ErrorIs(t, err, ErrSelfDependency)
+	})
+
+	t.Run("both flags must exist", func(t *testing.T) {
+		_, err := attach(99999, enabledDep.ID)
+		assert.ErrorIs(t, err, ErrFlagNotFound)
+
+		_, err = attach(flag.ID, 99999)
+		assert.ErrorIs(t, err, ErrFlagNotFound)
 	})
 }
 
-func TestFlagService_GetFlagAuditLogs(t *testing.T) {
+func TestFlagService_RemoveDependency(t *testing.T) {
 	testDB := test.SetupTestDB(t)
 	defer testDB.Close()
 	defer testDB.CleanTables(t)
@@ -325,38 +655,3446 @@ func TestFlagService_GetFlagAuditLogs(t *testing.T) {
 	log := test.GetTestLogger()
 	service := NewFlagService(flagRepo, auditRepo, log)
 
-	t.Run("get audit logs for flag", func(t *testing.T) {
-		flag := testDB.CreateTestFlag(t, "audit_test_flag", entity.FlagDisabled)
-		
-		// Perform some operations to generate audit logs
-		err := service.EnableFlag(context.Background(), flag.ID, "user1", "enable for test")
+	dep1 := testDB.CreateTestFlag(t, "detach_dep1", entity.FlagEnabled)
+	dep2 := testDB.CreateTestFlag(t, "detach_dep2", entity.FlagEnabled)
+	flag := testDB.CreateTestFlagWithDependencies(t, "detach_flag", entity.FlagEnabled, []int64{dep1.ID, dep2.ID})
+
+	t.Run("remove one edge", func(t *testing.T) {
+		updated, err := service.RemoveDependency(context.Background(), flag.ID, dep1.ID, "test_user")
+
 		require.NoError(t, err)
-		
-		err = service.DisableFlag(context.Background(), flag.ID, "user2", "disable for test")
+		assert.Equal(t, []int64{dep2.ID}, updated.Dependencies)
+		deps, err := flagRepo.GetDependencies(context.Background(), flag.ID)
 		require.NoError(t, err)
+		assert.Equal(t, []int64{dep2.ID}, deps)
+		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionUpdate, "test_user")
+	})
 
-		logs, err := service.GetFlagAuditLogs(context.Background(), flag.ID)
-		
-		require.NoError(t, err)
-		assert.GreaterOrEqual(t, len(logs), 2) // At least enable and disable logs
-		
-		// Verify log details
-		foundEnable := false
-		foundDisable := false
-		for _, log := range logs {
-			if log.Action == entity.ActionEnable && log.Actor == "user1" {
-				foundEnable = true
-			}
-			if log.Action == entity.ActionDisable && log.Actor == "user2" {
-				foundDisable = true
-			}
-		}
-		assert.True(t, foundEnable, "Enable audit log not found")
-		assert.True(t, foundDisable, "Disable audit log not found")
+	t.Run("missing edge is an error", func(t *testing.T) {
+		_, err := service.RemoveDependency(context.Background(), flag.ID, dep1.ID, "test_user")
+		assert.ErrorIs(t, err, ErrDependencyNotFound)
 	})
 
-	t.Run("get audit logs for non-existent flag", func(t *testing.T) {
-		_, err := service.GetFlagAuditLogs(context.Background(), 99999)
+	t.Run("flag not found", func(t *testing.T) {
+		_, err := service.RemoveDependency(context.Background(), 99999, dep1.ID, "test_user")
 		assert.ErrorIs(t, err, ErrFlagNotFound)
 	})
-} 
\ No newline at end of file
+}
+
+func TestFlagService_DeleteFlag(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	t.Run("delete flag and its dependency edges", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "delete_dep", entity.FlagEnabled)
+		flag := testDB.CreateTestFlagWithDependencies(t, "delete_me", entity.FlagEnabled, []int64{dep.ID})
+
+		err := service.DeleteFlag(context.Background(), flag.ID, "test_user", "obsolete")
+
+		require.NoError(t, err)
+		_, err = service.GetFlag(context.Background(), flag.ID)
+		assert.ErrorIs(t, err, ErrFlagNotFound)
+		dependents, err := flagRepo.GetDependents(context.Background(), dep.ID)
+		require.NoError(t, err)
+		assert.Empty(t, dependents)
+		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionDelete, "test_user")
+	})
+
+	t.Run("refuse to delete flag with dependents", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "still_needed", entity.FlagEnabled)
+		testDB.CreateTestFlagWithDependencies(t, "needs_it", entity.FlagEnabled, []int64{dep.ID})
+
+		err := service.DeleteFlag(context.Background(), dep.ID, "test_user", "obsolete")
+
+		var depsErr HasDependentsError
+		require.ErrorAs(t, err, &depsErr)
+		assert.ErrorIs(t, err, ErrFlagHasDependents)
+		assert.Equal(t, []string{"needs_it"}, depsErr.Dependents)
+		testDB.AssertFlagStatus(t, dep.ID, entity.FlagEnabled)
+	})
+
+	t.Run("flag not found", func(t *testing.T) {
+		err := service.DeleteFlag(context.Background(), 99999, "test_user", "obsolete")
+		assert.ErrorIs(t, err, ErrFlagNotFound)
+	})
+}
+
+func TestFlagService_EnableFlag(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	t.Run("enable flag without dependencies", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "simple_flag", entity.FlagDisabled)
+
+		_, err := service.EnableFlag(context.Background(), flag.ID, "test_user", "testing enable")
+		
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
+		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionEnable, "test_user")
+	})
+
+	t.Run("enable flag with satisfied dependencies", func(t *testing.T) {
+		// Create enabled dependencies
+		dep1 := testDB.CreateTestFlag(t, "enable_dep1", entity.FlagEnabled)
+		dep2 := testDB.CreateTestFlag(t, "enable_dep2", entity.FlagEnabled)
+		
+		// Create dependent flag
+		flag := testDB.CreateTestFlagWithDependencies(t, "dependent_satisfied", entity.FlagDisabled, []int64{dep1.ID, dep2.ID})
+
+		_, err := service.EnableFlag(context.Background(), flag.ID, "test_user", "dependencies satisfied")
+		
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
+	})
+
+	t.Run("fail to enable flag with missing dependencies", func(t *testing.T) {
+		// Create mixed dependencies (one enabled, one disabled)
+		dep1 := testDB.CreateTestFlag(t, "enabled_dep", entity.FlagEnabled)
+		dep2 := testDB.CreateTestFlag(t, "disabled_dep", entity.FlagDisabled)
+		
+		// Create dependent flag
+		flag := testDB.CreateTestFlagWithDependencies(t, "dependent_missing", entity.FlagDisabled, []int64{dep1.ID, dep2.ID})
+
+		_, err := service.EnableFlag(context.Background(), flag.ID, "test_user", "should fail")
+		
+		require.Error(t, err)
+		
+		// Check if it's a dependency error with the expected format
+		if depErr, ok := err.(DependencyError); ok {
+			assert.Equal(t, "Missing active dependencies", depErr.Message)
+			assert.Contains(t, depErr.MissingDependencies, "disabled_dep")
+		}
+		
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+	})
+
+	t.Run("enable non-existent flag", func(t *testing.T) {
+		_, err := service.EnableFlag(context.Background(), 99999, "test_user", "should fail")
+		assert.ErrorIs(t, err, ErrFlagNotFound)
+	})
+}
+
+func TestFlagService_DisableFlag(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	t.Run("disable flag without dependents", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "disable_simple_flag", entity.FlagEnabled)
+
+		_, err := service.DisableFlag(context.Background(), flag.ID, "test_user", "testing disable")
+		
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionDisable, "test_user")
+	})
+
+	t.Run("disable flag with cascade to dependents", func(t *testing.T) {
+		// Create dependency chain: dep -> flag1 -> flag2
+		dep := testDB.CreateTestFlag(t, "cascade_dependency", entity.FlagEnabled)
+		flag1 := testDB.CreateTestFlagWithDependencies(t, "cascade_flag1", entity.FlagEnabled, []int64{dep.ID})
+		flag2 := testDB.CreateTestFlagWithDependencies(t, "cascade_flag2", entity.FlagEnabled, []int64{flag1.ID})
+
+		// Disable the root dependency
+		_, err := service.DisableFlag(context.Background(), dep.ID, "test_user", "cascade test")
+		
+		require.NoError(t, err)
+		
+		// All flags should be disabled
+		testDB.AssertFlagStatus(t, dep.ID, entity.FlagDisabled)
+		testDB.AssertFlagStatus(t, flag1.ID, entity.FlagDisabled)
+		testDB.AssertFlagStatus(t, flag2.ID, entity.FlagDisabled)
+		
+		// Check audit logs for cascade actions
+		testDB.AssertAuditLogExists(t, dep.ID, entity.ActionDisable, "test_user")
+		testDB.AssertAuditLogExists(t, flag1.ID, entity.ActionCascadeDisable, "system")
+		testDB.AssertAuditLogExists(t, flag2.ID, entity.ActionCascadeDisable, "system")
+
+		// The reasons trace each flag back to the disable that started the cascade
+		filter := entity.AuditFilter{Action: entity.ActionCascadeDisable}
+		logs, err := service.GetFlagAuditLogs(context.Background(), flag1.ID, filter)
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		assert.Equal(t, "Cascade-disabled: depends on cascade_dependency, which was turned off by test_user", logs[0].Reason)
+		logs, err = service.GetFlagAuditLogs(context.Background(), flag2.ID, filter)
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		assert.Equal(t, "Cascade-disabled: depends on cascade_flag1, disabled because cascade_dependency was turned off by test_user", logs[0].Reason)
+	})
+
+	t.Run("diamond cascade disables each flag once", func(t *testing.T) {
+		// database <- (left, right) <- top <- leaf
+		database := testDB.CreateTestFlag(t, "diamond_database", entity.FlagEnabled)
+		left := testDB.CreateTestFlagWithDependencies(t, "diamond_left", entity.FlagEnabled, []int64{database.ID})
+		right := testDB.CreateTestFlagWithDependencies(t, "diamond_right", entity.FlagEnabled, []int64{database.ID})
+		top := testDB.CreateTestFlagWithDependencies(t, "diamond_top", entity.FlagEnabled, []int64{left.ID, right.ID})
+		leaf := testDB.CreateTestFlagWithDependencies(t, "diamond_leaf", entity.FlagEnabled, []int64{top.ID})
+
+		_, err := service.DisableFlag(context.Background(), database.ID, "test_user", "diamond test")
+
+		require.NoError(t, err)
+		filter := entity.AuditFilter{Action: entity.ActionCascadeDisable}
+		for _, flag := range []*entity.Flag{left, right, top, leaf} {
+			testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+			logs, err := service.GetFlagAuditLogs(context.Background(), flag.ID, filter)
+			require.NoError(t, err)
+			assert.Len(t, logs, 1, flag.Name)
+		}
+	})
+
+	t.Run("cascade moves dependents with maintenance strategy into maintenance", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "strategy_dependency", entity.FlagEnabled)
+
+		req := validator.FlagCreateRequest{
+			Name:            "strategy_dependent",
+			Dependencies:    []int64{dep.ID},
+			CascadeStrategy: string(entity.CascadeMaintenance),
+		}
+		flag, err := service.CreateFlag(context.Background(), req, "test_user")
+		require.NoError(t, err)
+		_, err = service.EnableFlag(context.Background(), flag.ID, "test_user", "enable dependent")
+		require.NoError(t, err)
+
+		_, err = service.DisableFlag(context.Background(), dep.ID, "test_user", "strategy test")
+
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, dep.ID, entity.FlagDisabled)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagMaintenance)
+		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionCascadeMaintenance, "system")
+	})
+
+	t.Run("failure partway through the cascade rolls back the whole disable", func(t *testing.T) {
+		root := testDB.CreateTestFlag(t, "atomic_root", entity.FlagEnabled)
+		child := testDB.CreateTestFlagWithDependencies(t, "atomic_child", entity.FlagEnabled, []int64{root.ID})
+		grandchild := testDB.CreateTestFlagWithDependencies(t, "atomic_grandchild", entity.FlagEnabled, []int64{child.ID})
+
+		failing := &failingStatusRepository{FlagRepository: flagRepo, failFor: grandchild.ID}
+		failingService := NewFlagService(failing, auditRepo, log)
+
+		_, err := failingService.DisableFlag(context.Background(), root.ID, "test_user", "should roll back")
+
+		require.ErrorIs(t, err, errStatusUpdateFailed)
+		testDB.AssertFlagStatus(t, root.ID, entity.FlagEnabled)
+		testDB.AssertFlagStatus(t, child.ID, entity.FlagEnabled)
+		testDB.AssertFlagStatus(t, grandchild.ID, entity.FlagEnabled)
+		logs, err := auditRepo.ListAuditLogsByFlagID(context.Background(), root.ID)
+		require.NoError(t, err)
+		assert.Empty(t, logs)
+	})
+}
+
+// failingStatusRepository fails status updates of one flag
+type failingStatusRepository struct {
+	repository.FlagRepository
+	failFor int64
+}
+
+var errStatusUpdateFailed = errors.New("status update failed")
+
+func (r *failingStatusRepository) UpdateFlagStatus(ctx context.Context, id int64, status entity.FlagStatus, expectedVersion int64, actor string) error {
+	if id == r.failFor {
+		return errStatusUpdateFailed
+	}
+	return r.FlagRepository.UpdateFlagStatus(ctx, id, status, expectedVersion, actor)
+}
+
+// staleFlagRepository serves a snapshot of one flag taken earlier, as a writer that read the
+// flag before another writer changed it would see it
+type staleFlagRepository struct {
+	repository.FlagRepository
+	snapshot *entity.Flag
+}
+
+func (r *staleFlagRepository) GetFlagByID(ctx context.Context, id int64) (*entity.Flag, error) {
+	if id == r.snapshot.ID {
+		stale := *r.snapshot
+		return &stale, nil
+	}
+	return r.FlagRepository.GetFlagByID(ctx, id)
+}
+
+func TestFlagService_ConcurrentModification(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+	ctx := context.Background()
+
+	t.Run("second of two stale writers is rejected", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "raced_flag", entity.FlagDisabled)
+		snapshot, err := flagRepo.GetFlagByID(ctx, flag.ID)
+		require.NoError(t, err)
+
+		// Both writers read the flag as disabled; the first one wins
+		first := NewFlagService(&staleFlagRepository{FlagRepository: flagRepo, snapshot: snapshot}, auditRepo, log)
+		second := NewFlagService(&staleFlagRepository{FlagRepository: flagRepo, snapshot: snapshot}, auditRepo, log)
+
+		_, err = first.EnableFlag(ctx, flag.ID, "operator_a", "")
+		require.NoError(t, err)
+		_, err = second.EnableFlag(ctx, flag.ID, "operator_b", "")
+		assert.ErrorIs(t, err, ErrConcurrentModification)
+
+		logs, err := auditRepo.ListAuditLogsByFlagID(ctx, flag.ID)
+		require.NoError(t, err)
+		var enables int
+		for _, l := range logs {
+			if l.Action == entity.ActionEnable {
+				enables++
+				assert.Equal(t, "operator_a", l.Actor)
+			}
+		}
+		assert.Equal(t, 1, enables, "only the winning write is audited")
+	})
+
+	t.Run("lock invalidates an earlier read", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "lock_raced_flag", entity.FlagEnabled)
+		snapshot, err := flagRepo.GetFlagByID(ctx, flag.ID)
+		require.NoError(t, err)
+
+		require.NoError(t, service.LockFlag(ctx, flag.ID, "operator_a", "freeze"))
+
+		stale := NewFlagService(&staleFlagRepository{FlagRepository: flagRepo, snapshot: snapshot}, auditRepo, log)
+		_, err = stale.DisableFlag(ctx, flag.ID, "operator_b", "")
+		assert.ErrorIs(t, err, ErrConcurrentModification)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
+	})
+
+	t.Run("version increases with each status change", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "versioned_flag", entity.FlagDisabled)
+		before, err := flagRepo.GetFlagByID(ctx, flag.ID)
+		require.NoError(t, err)
+
+		_, err = service.EnableFlag(ctx, flag.ID, "test_user", "")
+		require.NoError(t, err)
+
+		after, err := flagRepo.GetFlagByID(ctx, flag.ID)
+		require.NoError(t, err)
+		assert.Equal(t, before.Version+1, after.Version)
+	})
+}
+
+func TestFlagService_ToggleFlag(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	t.Run("toggle flag to enabled", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "toggle_flag", entity.FlagDisabled)
+
+		req := validator.FlagToggleRequest{
+			Enable: true,
+			Reason: "testing toggle enable",
+		}
+
+		_, err := service.ToggleFlag(context.Background(), flag.ID, req, "test_user")
+		
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
+	})
+
+	t.Run("toggle flag to disabled", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "toggle_flag2", entity.FlagEnabled)
+
+		req := validator.FlagToggleRequest{
+			Enable: false,
+			Reason: "testing toggle disable",
+		}
+
+		_, err := service.ToggleFlag(context.Background(), flag.ID, req, "test_user")
+		
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+	})
+
+	t.Run("real change is reported", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "toggle_changed", entity.FlagDisabled)
+
+		req := validator.FlagToggleRequest{Enable: true, Reason: "testing change"}
+		change, err := service.ToggleFlag(context.Background(), flag.ID, req, "test_user")
+
+		require.NoError(t, err)
+		assert.Equal(t, &entity.StatusChange{
+			Changed:        true,
+			PreviousStatus: entity.FlagDisabled,
+			Status:         entity.FlagEnabled,
+		}, change)
+	})
+
+	t.Run("no-op toggles are not changes", func(t *testing.T) {
+		enabled := testDB.CreateTestFlag(t, "toggle_noop_on", entity.FlagEnabled)
+		disabled := testDB.CreateTestFlag(t, "toggle_noop_off", entity.FlagDisabled)
+
+		change, err := service.EnableFlag(context.Background(), enabled.ID, "test_user", "already on")
+		require.NoError(t, err)
+		assert.False(t, change.Changed)
+		assert.Equal(t, entity.FlagEnabled, change.PreviousStatus)
+
+		change, err = service.DisableFlag(context.Background(), disabled.ID, "test_user", "already off")
+		require.NoError(t, err)
+		assert.False(t, change.Changed)
+		assert.Equal(t, entity.FlagDisabled, change.PreviousStatus)
+
+		// Nothing happened, so nothing is audited
+		for _, flagID := range []int64{enabled.ID, disabled.ID} {
+			logs, err := auditRepo.ListAuditLogsByFlagID(context.Background(), flagID)
+			require.NoError(t, err)
+			assert.Empty(t, logs)
+		}
+	})
+
+	t.Run("cascade enables dependencies first", func(t *testing.T) {
+		// database <- auth <- checkout, profile (already enabled) <- checkout
+		database := testDB.CreateTestFlag(t, "cascade_enable_database", entity.FlagDisabled)
+		auth := testDB.CreateTestFlagWithDependencies(t, "cascade_enable_auth", entity.FlagDisabled, []int64{database.ID})
+		profile := testDB.CreateTestFlag(t, "cascade_enable_profile", entity.FlagEnabled)
+		checkout := testDB.CreateTestFlagWithDependencies(t, "cascade_enable_checkout", entity.FlagDisabled, []int64{auth.ID, profile.ID})
+
+		req := validator.FlagToggleRequest{Enable: true, Reason: "launch checkout", Cascade: true}
+		change, err := service.ToggleFlag(context.Background(), checkout.ID, req, "test_user")
+
+		require.NoError(t, err)
+		assert.True(t, change.Changed)
+		require.Len(t, change.CascadeEnabled, 2)
+		assert.Equal(t, database.ID, change.CascadeEnabled[0].ID)
+		assert.Equal(t, auth.ID, change.CascadeEnabled[1].ID)
+		for _, flagID := range []int64{database.ID, auth.ID, checkout.ID} {
+			testDB.AssertFlagStatus(t, flagID, entity.FlagEnabled)
+		}
+		testDB.AssertAuditLogExists(t, database.ID, entity.ActionCascadeEnable, "system")
+		testDB.AssertAuditLogExists(t, auth.ID, entity.ActionCascadeEnable, "system")
+		testDB.AssertAuditLogExists(t, checkout.ID, entity.ActionEnable, "test_user")
+	})
+
+	t.Run("cascade enable rolls back when a dependency cannot be enabled", func(t *testing.T) {
+		database := testDB.CreateTestFlag(t, "cascade_rollback_database", entity.FlagDisabled)
+		locked := testDB.CreateTestFlagWithDependencies(t, "cascade_rollback_locked", entity.FlagDisabled, []int64{database.ID})
+		require.NoError(t, service.LockFlag(context.Background(), locked.ID, "test_user", "frozen for audit"))
+		checkout := testDB.CreateTestFlagWithDependencies(t, "cascade_rollback_checkout", entity.FlagDisabled, []int64{locked.ID})
+
+		req := validator.FlagToggleRequest{Enable: true, Reason: "launch checkout", Cascade: true}
+		_, err := service.ToggleFlag(context.Background(), checkout.ID, req, "test_user")
+
+		assert.ErrorIs(t, err, ErrFlagLocked)
+		testDB.AssertFlagStatus(t, database.ID, entity.FlagDisabled)
+		testDB.AssertFlagStatus(t, checkout.ID, entity.FlagDisabled)
+	})
+}
+
+func TestFlagService_ToggleFlagInEnvironment(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	envRepo := repository.NewEnvironmentRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log, WithEnvironmentRepository(envRepo))
+	ctx := context.Background()
+
+	enable := validator.FlagToggleRequest{Enable: true, Reason: "launch"}
+	disable := validator.FlagToggleRequest{Enable: false, Reason: "rollback"}
+
+	envStatus := func(t *testing.T, flagID int64, env string) entity.FlagStatus {
+		status, err := envRepo.GetFlagEnvironmentStatus(ctx, flagID, env)
+		require.NoError(t, err)
+		return status.Status
+	}
+
+	t.Run("flags start disabled in every environment", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "env_default", entity.FlagEnabled)
+
+		environments, err := service.ListFlagEnvironments(ctx, flag.ID)
+		require.NoError(t, err)
+		require.NotEmpty(t, environments)
+		assert.Equal(t, entity.GlobalEnvironment, environments[0].Environment)
+		assert.Equal(t, entity.FlagEnabled, environments[0].Status)
+		for _, env := range environments[1:] {
+			assert.Equal(t, entity.FlagDisabled, env.Status, env.Environment)
+		}
+	})
+
+	t.Run("toggle is scoped to the environment", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "env_scoped", entity.FlagDisabled)
+
+		change, err := service.ToggleFlagInEnvironment(ctx, flag.ID, "prod", enable, "test_user")
+		require.NoError(t, err)
+		assert.True(t, change.Changed)
+
+		assert.Equal(t, entity.FlagEnabled, envStatus(t, flag.ID, "prod"))
+		assert.Equal(t, entity.FlagDisabled, envStatus(t, flag.ID, "staging"))
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+
+		logs, err := auditRepo.ListAuditLogsByFlagID(ctx, flag.ID)
+		require.NoError(t, err)
+		require.NotEmpty(t, logs)
+		assert.Equal(t, entity.ActionEnable, logs[0].Action)
+		assert.Equal(t, "prod", logs[0].Environment)
+
+		change, err = service.ToggleFlagInEnvironment(ctx, flag.ID, "prod", enable, "test_user")
+		require.NoError(t, err)
+		assert.False(t, change.Changed)
+	})
+
+	t.Run("no environment toggles the global status", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "env_global", entity.FlagDisabled)
+
+		_, err := service.ToggleFlagInEnvironment(ctx, flag.ID, "", enable, "test_user")
+		require.NoError(t, err)
+
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
+		assert.Equal(t, entity.FlagDisabled, envStatus(t, flag.ID, "prod"))
+	})
+
+	t.Run("dependencies are checked within the environment", func(t *testing.T) {
+		base := testDB.CreateTestFlag(t, "env_dep_base", entity.FlagEnabled)
+		dependent := testDB.CreateTestFlagWithDependencies(t, "env_dep_dependent", entity.FlagDisabled, []int64{base.ID})
+
+		_, err := service.ToggleFlagInEnvironment(ctx, dependent.ID, "staging", enable, "test_user")
+		var depErr DependencyError
+		require.ErrorAs(t, err, &depErr)
+		assert.Equal(t, []string{"env_dep_base"}, depErr.MissingDependencies)
+
+		_, err = service.ToggleFlagInEnvironment(ctx, base.ID, "staging", enable, "test_user")
+		require.NoError(t, err)
+		_, err = service.ToggleFlagInEnvironment(ctx, dependent.ID, "staging", enable, "test_user")
+		require.NoError(t, err)
+		assert.Equal(t, entity.FlagEnabled, envStatus(t, dependent.ID, "staging"))
+	})
+
+	t.Run("disable cascades within the environment only", func(t *testing.T) {
+		base := testDB.CreateTestFlag(t, "env_cascade_base", entity.FlagEnabled)
+		dependent := testDB.CreateTestFlagWithDependencies(t, "env_cascade_dependent", entity.FlagEnabled, []int64{base.ID})
+		for _, id := range []int64{base.ID, dependent.ID} {
+			for _, env := range []string{"dev", "prod"} {
+				_, err := service.ToggleFlagInEnvironment(ctx, id, env, enable, "test_user")
+				require.NoError(t, err)
+			}
+		}
+
+		_, err := service.ToggleFlagInEnvironment(ctx, base.ID, "dev", disable, "test_user")
+		require.NoError(t, err)
+
+		assert.Equal(t, entity.FlagDisabled, envStatus(t, dependent.ID, "dev"))
+		assert.Equal(t, entity.FlagEnabled, envStatus(t, dependent.ID, "prod"))
+		testDB.AssertFlagStatus(t, dependent.ID, entity.FlagEnabled)
+		testDB.AssertAuditLogExists(t, dependent.ID, entity.ActionCascadeDisable, "system")
+	})
+
+	t.Run("unknown environment is rejected", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "env_unknown", entity.FlagDisabled)
+
+		_, err := service.ToggleFlagInEnvironment(ctx, flag.ID, "qa", enable, "test_user")
+		assert.ErrorIs(t, err, ErrEnvironmentNotFound)
+	})
+
+	t.Run("cascade enable is global only", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "env_cascade_enable", entity.FlagDisabled)
+
+		_, err := service.ToggleFlagInEnvironment(ctx, flag.ID, "prod",
+			validator.FlagToggleRequest{Enable: true, Cascade: true, Reason: "launch"}, "test_user")
+		assert.ErrorIs(t, err, ErrEnvironmentCascade)
+	})
+
+	t.Run("archive disables the flag everywhere", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "env_archive", entity.FlagEnabled)
+		_, err := service.ToggleFlagInEnvironment(ctx, flag.ID, "prod", enable, "test_user")
+		require.NoError(t, err)
+
+		require.NoError(t, service.ArchiveFlag(ctx, flag.ID, "admin", "retired"))
+
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+		assert.Equal(t, entity.FlagDisabled, envStatus(t, flag.ID, "prod"))
+		_, err = service.ToggleFlagInEnvironment(ctx, flag.ID, "prod", enable, "test_user")
+		assert.ErrorIs(t, err, ErrFlagArchived)
+	})
+}
+
+func TestFlagService_Environments(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	envRepo := repository.NewEnvironmentRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log, WithEnvironmentRepository(envRepo))
+	ctx := context.Background()
+
+	enable := validator.FlagToggleRequest{Enable: true, Reason: "launch"}
+
+	t.Run("states across environments in one response", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "states_checkout", entity.FlagEnabled)
+		_, err := service.ToggleFlagInEnvironment(ctx, flag.ID, "staging", enable, "test_user")
+		require.NoError(t, err)
+
+		states, err := service.GetFlagStates(ctx, flag.ID)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]entity.FlagStatus{
+			"global":  entity.FlagEnabled,
+			"dev":     entity.FlagDisabled,
+			"staging": entity.FlagEnabled,
+			"prod":    entity.FlagDisabled,
+		}, states)
+	})
+
+	t.Run("states of an unknown flag", func(t *testing.T) {
+		_, err := service.GetFlagStates(ctx, 999999)
+		assert.ErrorIs(t, err, ErrFlagNotFound)
+	})
+
+	t.Run("created environment shows up everywhere until deleted", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "states_perf", entity.FlagDisabled)
+
+		require.NoError(t, service.CreateEnvironment(ctx, validator.EnvironmentCreateRequest{Name: "perf"}, "admin_user"))
+		assert.ErrorIs(t, service.CreateEnvironment(ctx, validator.EnvironmentCreateRequest{Name: "perf"}, "admin_user"), ErrEnvironmentExists)
+
+		environments, err := service.ListEnvironments(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dev", "global", "perf", "prod", "staging"}, environments)
+
+		_, err = service.ToggleFlagInEnvironment(ctx, flag.ID, "perf", enable, "test_user")
+		require.NoError(t, err)
+		states, err := service.GetFlagStates(ctx, flag.ID)
+		require.NoError(t, err)
+		assert.Equal(t, entity.FlagEnabled, states["perf"])
+
+		require.NoError(t, service.DeleteEnvironment(ctx, "perf", "admin_user"))
+		states, err = service.GetFlagStates(ctx, flag.ID)
+		require.NoError(t, err)
+		assert.NotContains(t, states, "perf")
+		assert.ErrorIs(t, service.DeleteEnvironment(ctx, "perf", "admin_user"), ErrEnvironmentNotFound)
+	})
+
+	t.Run("global environment cannot be deleted", func(t *testing.T) {
+		assert.ErrorIs(t, service.DeleteEnvironment(ctx, entity.GlobalEnvironment, "admin_user"), ErrGlobalEnvironment)
+	})
+}
+
+func TestFlagService_DiffEnvironments(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	envRepo := repository.NewEnvironmentRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log, WithEnvironmentRepository(envRepo))
+	ctx := context.Background()
+
+	enable := validator.FlagToggleRequest{Enable: true, Reason: "launch"}
+	toggle := func(t *testing.T, flagID int64, env string) {
+		_, err := service.ToggleFlagInEnvironment(ctx, flagID, env, enable, "test_user")
+		require.NoError(t, err)
+	}
+
+	staged := testDB.CreateTestFlag(t, "diff_staged", entity.FlagDisabled)
+	toggle(t, staged.ID, "staging")
+	released := testDB.CreateTestFlag(t, "diff_released", entity.FlagDisabled)
+	toggle(t, released.ID, "staging")
+	toggle(t, released.ID, "prod")
+	hotfix := testDB.CreateTestFlag(t, "diff_hotfix", entity.FlagEnabled)
+	toggle(t, hotfix.ID, "prod")
+	testDB.CreateTestFlag(t, "diff_untouched", entity.FlagDisabled)
+
+	t.Run("flags enabled in only one environment", func(t *testing.T) {
+		diff, err := service.DiffEnvironments(ctx, "staging", "prod")
+		require.NoError(t, err)
+		assert.Equal(t, "staging", diff.From)
+		assert.Equal(t, "prod", diff.To)
+		assert.Equal(t, []*entity.FlagStateDiff{
+			{FlagID: hotfix.ID, Name: "diff_hotfix", FromStatus: entity.FlagDisabled, ToStatus: entity.FlagEnabled},
+			{FlagID: staged.ID, Name: "diff_staged", FromStatus: entity.FlagEnabled, ToStatus: entity.FlagDisabled},
+		}, diff.Flags)
+	})
+
+	t.Run("global status is compared too", func(t *testing.T) {
+		diff, err := service.DiffEnvironments(ctx, entity.GlobalEnvironment, "dev")
+		require.NoError(t, err)
+		require.Len(t, diff.Flags, 1)
+		assert.Equal(t, "diff_hotfix", diff.Flags[0].Name)
+		assert.Equal(t, entity.FlagEnabled, diff.Flags[0].FromStatus)
+	})
+
+	t.Run("same environment has no differences", func(t *testing.T) {
+		diff, err := service.DiffEnvironments(ctx, "prod", "prod")
+		require.NoError(t, err)
+		assert.Empty(t, diff.Flags)
+	})
+
+	t.Run("unknown or missing environment", func(t *testing.T) {
+		_, err := service.DiffEnvironments(ctx, "staging", "qa")
+		assert.ErrorIs(t, err, ErrEnvironmentNotFound)
+		_, err = service.DiffEnvironments(ctx, "staging", "")
+		assert.ErrorIs(t, err, ErrInvalidEnvironmentDiff)
+	})
+}
+
+func TestFlagService_IdempotentToggle(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	keyRepo := repository.NewIdempotencyKeyRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log, WithIdempotencyKeys(keyRepo, time.Hour))
+	ctx := context.Background()
+
+	countAudits := func(t *testing.T, flagID int64) int {
+		logs, err := auditRepo.ListAuditLogsByFlagID(ctx, flagID)
+		require.NoError(t, err)
+		return len(logs)
+	}
+
+	t.Run("retry with the same key replays the outcome", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "idem_retry", entity.FlagDisabled)
+		req := validator.FlagToggleRequest{Enable: true, Reason: "launch", IdempotencyKey: "idem-retry"}
+
+		first, err := service.ToggleFlagInEnvironment(ctx, flag.ID, "", req, "test_user")
+		require.NoError(t, err)
+		audits := countAudits(t, flag.ID)
+
+		second, err := service.ToggleFlagInEnvironment(ctx, flag.ID, "", req, "test_user")
+		require.NoError(t, err)
+		assert.Equal(t, first, second)
+		assert.True(t, second.Changed)
+		assert.Equal(t, audits, countAudits(t, flag.ID))
+	})
+
+	t.Run("reusing a key for another request is rejected", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "idem_reuse", entity.FlagDisabled)
+		req := validator.FlagToggleRequest{Enable: true, Reason: "launch", IdempotencyKey: "idem-reuse"}
+		_, err := service.ToggleFlagInEnvironment(ctx, flag.ID, "", req, "test_user")
+		require.NoError(t, err)
+
+		req.Enable = false
+		_, err = service.ToggleFlagInEnvironment(ctx, flag.ID, "", req, "test_user")
+		assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
+	})
+
+	t.Run("failed toggles can be retried with the same key", func(t *testing.T) {
+		base := testDB.CreateTestFlag(t, "idem_base", entity.FlagDisabled)
+		dependent := testDB.CreateTestFlagWithDependencies(t, "idem_dependent", entity.FlagDisabled, []int64{base.ID})
+		req := validator.FlagToggleRequest{Enable: true, Reason: "launch", IdempotencyKey: "idem-failed"}
+
+		_, err := service.ToggleFlagInEnvironment(ctx, dependent.ID, "", req, "test_user")
+		var depErr DependencyError
+		require.ErrorAs(t, err, &depErr)
+
+		_, err = service.EnableFlag(ctx, base.ID, "test_user", "launch")
+		require.NoError(t, err)
+		change, err := service.ToggleFlagInEnvironment(ctx, dependent.ID, "", req, "test_user")
+		require.NoError(t, err)
+		assert.True(t, change.Changed)
+	})
+
+	t.Run("expired keys are purged", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "idem_expired", entity.FlagDisabled)
+		req := validator.FlagToggleRequest{Enable: true, Reason: "launch", IdempotencyKey: "idem-expired"}
+		_, err := service.ToggleFlagInEnvironment(ctx, flag.ID, "", req, "test_user")
+		require.NoError(t, err)
+		_, err = testDB.DB.Exec("UPDATE idempotency_keys SET created_at = NOW() - INTERVAL '2 hours' WHERE key = $1", req.IdempotencyKey)
+		require.NoError(t, err)
+
+		require.NoError(t, service.PurgeIdempotencyKeys(ctx))
+
+		_, err = keyRepo.GetIdempotencyKey(ctx, req.IdempotencyKey, time.Time{})
+		assert.ErrorIs(t, err, repository.ErrIdempotencyKeyNotFound)
+	})
+}
+
+func TestFlagService_GetFlag(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	t.Run("get existing flag", func(t *testing.T) {
+		createdFlag := testDB.CreateTestFlag(t, "get_test_flag", entity.FlagEnabled)
+
+		flag, err := service.GetFlag(context.Background(), createdFlag.ID)
+		
+		require.NoError(t, err)
+		assert.Equal(t, createdFlag.ID, flag.ID)
+		assert.Equal(t, "get_test_flag", flag.Name)
+		assert.Equal(t, entity.FlagEnabled, flag.Status)
+	})
+
+	t.Run("get non-existent flag", func(t *testing.T) {
+		_, err := service.GetFlag(context.Background(), 99999)
+		assert.ErrorIs(t, err, ErrFlagNotFound)
+	})
+
+	t.Run("get flag by name", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "get_by_name_dep", entity.FlagEnabled)
+		createdFlag := testDB.CreateTestFlagWithDependencies(t, "get_by_name_flag", entity.FlagDisabled, []int64{dep.ID})
+
+		flag, err := service.GetFlagByName(context.Background(), "get_by_name_flag")
+
+		require.NoError(t, err)
+		assert.Equal(t, createdFlag.ID, flag.ID)
+		assert.Equal(t, []int64{dep.ID}, flag.Dependencies)
+
+		_, err = service.GetFlagByName(context.Background(), "missing_flag")
+		assert.ErrorIs(t, err, ErrFlagNotFound)
+
+		var validationErrs validator.ValidationErrors
+		_, err = service.GetFlagByName(context.Background(), "not a name")
+		assert.ErrorAs(t, err, &validationErrs)
+	})
+}
+
+func TestFlagService_ListFlags(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	t.Run("list flags", func(t *testing.T) {
+		testDB.WithTxTest(t, func(ctx context.Context) {
+			// Create test flags
+			flag1 := testDB.CreateTestFlagContext(ctx, t, "list_flag1", entity.FlagEnabled)
+			flag2 := testDB.CreateTestFlagContext(ctx, t, "list_flag2", entity.FlagDisabled)
+
+			flags, err := service.ListFlags(ctx)
+
+			require.NoError(t, err)
+			assert.Len(t, flags, 2)
+
+			// Verify flags are returned with correct IDs
+			flagIDs := make(map[int64]bool)
+			flagNames := make(map[string]bool)
+			for _, flag := range flags {
+				flagIDs[flag.ID] = true
+				flagNames[flag.Name] = true
+			}
+			assert.True(t, flagIDs[flag1.ID])
+			assert.True(t, flagIDs[flag2.ID])
+			assert.True(t, flagNames["list_flag1"])
+			assert.True(t, flagNames["list_flag2"])
+		})
+	})
+
+	t.Run("rolled back flags are gone", func(t *testing.T) {
+		flags, err := service.ListFlags(context.Background())
+
+		require.NoError(t, err)
+		assert.Empty(t, flags)
+	})
+}
+
+func TestFlagService_ListFlagsPaginated(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	t.Run("pages are ordered by name and report the total", func(t *testing.T) {
+		testDB.WithTxTest(t, func(ctx context.Context) {
+			dep := testDB.CreateTestFlagContext(ctx, t, "page_a", entity.FlagEnabled)
+			testDB.CreateTestFlagContext(ctx, t, "page_b", entity.FlagDisabled)
+			last := testDB.CreateTestFlagContext(ctx, t, "page_c", entity.FlagDisabled)
+			require.NoError(t, flagRepo.AddDependency(ctx, last.ID, dep.ID))
+
+			flags, total, err := service.ListFlagsPaginated(ctx, entity.FlagFilter{}, 2, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 3, total)
+			require.Len(t, flags, 2)
+			assert.Equal(t, "page_a", flags[0].Name)
+			assert.Equal(t, "page_b", flags[1].Name)
+
+			flags, total, err = service.ListFlagsPaginated(ctx, entity.FlagFilter{}, 2, 2)
+			require.NoError(t, err)
+			assert.Equal(t, 3, total)
+			require.Len(t, flags, 1)
+			assert.Equal(t, "page_c", flags[0].Name)
+			assert.Equal(t, []int64{dep.ID}, flags[0].Dependencies)
+		})
+	})
+
+	t.Run("filter by status", func(t *testing.T) {
+		testDB.WithTxTest(t, func(ctx context.Context) {
+			testDB.CreateTestFlagContext(ctx, t, "status_on", entity.FlagEnabled)
+			testDB.CreateTestFlagContext(ctx, t, "status_off1", entity.FlagDisabled)
+			testDB.CreateTestFlagContext(ctx, t, "status_off2", entity.FlagDisabled)
+
+			flags, total, err := service.ListFlagsPaginated(ctx, entity.FlagFilter{Status: entity.FlagDisabled}, 1, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 2, total)
+			require.Len(t, flags, 1)
+			assert.Equal(t, "status_off1", flags[0].Name)
+
+			flags, total, err = service.ListFlagsPaginated(ctx, entity.FlagFilter{Status: entity.FlagEnabled}, 10, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 1, total)
+			require.Len(t, flags, 1)
+			assert.Equal(t, "status_on", flags[0].Name)
+		})
+	})
+
+	t.Run("filter by tags", func(t *testing.T) {
+		testDB.WithTxTest(t, func(ctx context.Context) {
+			payments := testDB.CreateTestFlagContext(ctx, t, "tag_payments", entity.FlagEnabled)
+			checkout := testDB.CreateTestFlagContext(ctx, t, "tag_checkout", entity.FlagDisabled)
+			testDB.CreateTestFlagContext(ctx, t, "tag_untagged", entity.FlagEnabled)
+			require.NoError(t, flagRepo.SetTags(ctx, payments.ID, map[string]string{"team": "payments", "tier": "critical"}))
+			require.NoError(t, flagRepo.SetTags(ctx, checkout.ID, map[string]string{"team": "payments"}))
+
+			filter := entity.FlagFilter{Tags: []entity.Tag{{Key: "team", Value: "payments"}}}
+			flags, total, err := service.ListFlagsPaginated(ctx, filter, 10, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 2, total)
+			require.Len(t, flags, 2)
+			assert.Equal(t, "tag_checkout", flags[0].Name)
+			assert.Equal(t, map[string]string{"team": "payments"}, flags[0].Tags)
+
+			// Multiple tags must all match
+			filter.Tags = append(filter.Tags, entity.Tag{Key: "tier", Value: "critical"})
+			flags, total, err = service.ListFlagsPaginated(ctx, filter, 10, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 1, total)
+			require.Len(t, flags, 1)
+			assert.Equal(t, "tag_payments", flags[0].Name)
+
+			// Combined with a status filter
+			filter = entity.FlagFilter{Status: entity.FlagEnabled, Tags: []entity.Tag{{Key: "team", Value: "payments"}}}
+			flags, _, err = service.ListFlagsPaginated(ctx, filter, 10, 0)
+			require.NoError(t, err)
+			require.Len(t, flags, 1)
+			assert.Equal(t, "tag_payments", flags[0].Name)
+		})
+	})
+
+	t.Run("unknown status", func(t *testing.T) {
+		_, _, err := service.ListFlagsPaginated(context.Background(), entity.FlagFilter{Status: "on"}, 10, 0)
+		assert.ErrorIs(t, err, ErrInvalidFlagStatus)
+	})
+
+	t.Run("invalid paging", func(t *testing.T) {
+		_, _, err := service.ListFlagsPaginated(context.Background(), entity.FlagFilter{}, MaxFlagPageSize+1, 0)
+		assert.ErrorIs(t, err, ErrInvalidFlagPage)
+		_, _, err = service.ListFlagsPaginated(context.Background(), entity.FlagFilter{}, 0, 0)
+		assert.ErrorIs(t, err, ErrInvalidFlagPage)
+		_, _, err = service.ListFlagsPaginated(context.Background(), entity.FlagFilter{}, 10, -1)
+		assert.ErrorIs(t, err, ErrInvalidFlagPage)
+	})
+}
+
+func TestFlagService_GetFlagDependents(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	t.Run("direct dependents with status", func(t *testing.T) {
+		root := testDB.CreateTestFlag(t, "dependents_root", entity.FlagEnabled)
+		child := testDB.CreateTestFlagWithDependencies(t, "dependents_child", entity.FlagEnabled, []int64{root.ID})
+		testDB.CreateTestFlagWithDependencies(t, "dependents_grandchild", entity.FlagDisabled, []int64{child.ID})
+
+		dependents, err := service.GetFlagDependents(context.Background(), root.ID)
+
+		require.NoError(t, err)
+		require.Len(t, dependents, 1)
+		assert.Equal(t, "dependents_child", dependents[0].Name)
+		assert.Equal(t, entity.FlagEnabled, dependents[0].Status)
+	})
+
+	t.Run("flag without dependents", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "dependents_leaf", entity.FlagEnabled)
+
+		dependents, err := service.GetFlagDependents(context.Background(), flag.ID)
+
+		require.NoError(t, err)
+		assert.NotNil(t, dependents)
+		assert.Empty(t, dependents)
+	})
+
+	t.Run("flag not found", func(t *testing.T) {
+		_, err := service.GetFlagDependents(context.Background(), 99999)
+		assert.ErrorIs(t, err, ErrFlagNotFound)
+	})
+}
+
+func TestFlagService_PreviewCascadeDisable(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	t.Run("diamond reports each flag once and writes nothing", func(t *testing.T) {
+		// database <- (left, right) <- top; off depends on database but is already disabled
+		database := testDB.CreateTestFlag(t, "preview_database", entity.FlagEnabled)
+		left := testDB.CreateTestFlagWithDependencies(t, "preview_left", entity.FlagEnabled, []int64{database.ID})
+		right := testDB.CreateTestFlagWithDependencies(t, "preview_right", entity.FlagEnabled, []int64{database.ID})
+		top := testDB.CreateTestFlagWithDependencies(t, "preview_top", entity.FlagEnabled, []int64{left.ID, right.ID})
+		testDB.CreateTestFlagWithDependencies(t, "preview_off", entity.FlagDisabled, []int64{database.ID})
+
+		flags, err := service.PreviewCascadeDisable(context.Background(), database.ID)
+
+		require.NoError(t, err)
+		names := make([]string, 0, len(flags))
+		for _, flag := range flags {
+			names = append(names, flag.Name)
+		}
+		assert.ElementsMatch(t, []string{"preview_left", "preview_right", "preview_top"}, names)
+
+		for _, id := range []int64{database.ID, left.ID, right.ID, top.ID} {
+			testDB.AssertFlagStatus(t, id, entity.FlagEnabled)
+		}
+		logs, err := auditRepo.ListAllAuditLogs(context.Background(), 100, 0)
+		require.NoError(t, err)
+		assert.Empty(t, logs)
+	})
+
+	t.Run("flag not found", func(t *testing.T) {
+		_, err := service.PreviewCascadeDisable(context.Background(), 99999)
+		assert.ErrorIs(t, err, ErrFlagNotFound)
+	})
+}
+
+func TestFlagService_GetFlagAuditLogs(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	t.Run("get audit logs for flag", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "audit_test_flag", entity.FlagDisabled)
+		
+		// Perform some operations to generate audit logs
+		_, err := service.EnableFlag(context.Background(), flag.ID, "user1", "enable for test")
+		require.NoError(t, err)
+		
+		_, err = service.DisableFlag(context.Background(), flag.ID, "user2", "disable for test")
+		require.NoError(t, err)
+
+		logs, err := service.GetFlagAuditLogs(context.Background(), flag.ID, entity.AuditFilter{})
+		
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, len(logs), 2) // At least enable and disable logs
+		
+		// Verify log details
+		foundEnable := false
+		foundDisable := false
+		for _, log := range logs {
+			if log.Action == entity.ActionEnable && log.Actor == "user1" {
+				foundEnable = true
+			}
+			if log.Action == entity.ActionDisable && log.Actor == "user2" {
+				foundDisable = true
+			}
+		}
+		assert.True(t, foundEnable, "Enable audit log not found")
+		assert.True(t, foundDisable, "Disable audit log not found")
+	})
+
+	t.Run("filter by action, actor and time", func(t *testing.T) {
+		base := testDB.CreateTestFlag(t, "audit_filter_base", entity.FlagEnabled)
+		dependent := testDB.CreateTestFlagWithDependencies(t, "audit_filter_dependent", entity.FlagDisabled, []int64{base.ID})
+		start := time.Now()
+		_, err := service.EnableFlag(context.Background(), dependent.ID, "user1", "enable dependent")
+		require.NoError(t, err)
+		_, err = service.DisableFlag(context.Background(), base.ID, "user2", "outage")
+		require.NoError(t, err)
+
+		logs, err := service.GetFlagAuditLogs(context.Background(), dependent.ID,
+			entity.AuditFilter{Action: entity.ActionCascadeDisable})
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		assert.Equal(t, "system", logs[0].Actor)
+
+		logs, err = service.GetFlagAuditLogs(context.Background(), dependent.ID, entity.AuditFilter{Actor: "user1"})
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		assert.Equal(t, entity.ActionEnable, logs[0].Action)
+
+		logs, err = service.GetFlagAuditLogs(context.Background(), dependent.ID, entity.AuditFilter{To: start})
+		require.NoError(t, err)
+		assert.Empty(t, logs)
+		logs, err = service.GetFlagAuditLogs(context.Background(), dependent.ID, entity.AuditFilter{From: start})
+		require.NoError(t, err)
+		assert.Len(t, logs, 2)
+	})
+
+	t.Run("invalid filters", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "audit_filter_invalid", entity.FlagDisabled)
+
+		_, err := service.GetFlagAuditLogs(context.Background(), flag.ID, entity.AuditFilter{Action: "explode"})
+		assert.ErrorIs(t, err, ErrInvalidAuditAction)
+
+		now := time.Now()
+		_, err = service.GetFlagAuditLogs(context.Background(), flag.ID, entity.AuditFilter{From: now, To: now.Add(-time.Hour)})
+		assert.ErrorIs(t, err, ErrInvalidAuditWindow)
+	})
+
+	t.Run("get audit logs for non-existent flag", func(t *testing.T) {
+		_, err := service.GetFlagAuditLogs(context.Background(), 99999, entity.AuditFilter{})
+		assert.ErrorIs(t, err, ErrFlagNotFound)
+	})
+}
+
+func TestFlagService_Maintenance(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	t.Run("dependency in maintenance blocks enable", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "maint_dep", entity.FlagMaintenance)
+		flag := testDB.CreateTestFlagWithDependencies(t, "maint_dependent", entity.FlagDisabled, []int64{dep.ID})
+
+		_, err := service.EnableFlag(context.Background(), flag.ID, "test_user", "should fail")
+
+		var depErr DependencyError
+		require.ErrorAs(t, err, &depErr)
+		assert.Contains(t, depErr.MissingDependencies, "maint_dep")
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+	})
+
+	t.Run("normal enable is rejected for flag in maintenance", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "maint_enable", entity.FlagMaintenance)
+
+		_, err := service.EnableFlag(context.Background(), flag.ID, "test_user", "should fail")
+
+		assert.ErrorIs(t, err, ErrFlagInMaintenance)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagMaintenance)
+	})
+
+	t.Run("entering maintenance cascades enabled dependents", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "maint_root", entity.FlagEnabled)
+		flag := testDB.CreateTestFlagWithDependencies(t, "maint_child", entity.FlagEnabled, []int64{dep.ID})
+
+		err := service.SetMaintenance(context.Background(), dep.ID, "test_user", "planned maintenance")
+
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, dep.ID, entity.FlagMaintenance)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+		testDB.AssertAuditLogExists(t, dep.ID, entity.ActionMaintenance, "test_user")
+		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionCascadeDisable, "system")
+	})
+
+	t.Run("resume enables flag from maintenance", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "maint_resume", entity.FlagMaintenance)
+
+		err := service.ResumeFlag(context.Background(), flag.ID, validator.FlagResumeRequest{Reason: "maintenance finished"}, "test_user")
+
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
+		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionResume, "test_user")
+	})
+
+	t.Run("resume rejects flag not in maintenance", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "maint_not", entity.FlagDisabled)
+
+		err := service.ResumeFlag(context.Background(), flag.ID, validator.FlagResumeRequest{Reason: "should fail"}, "test_user")
+
+		assert.ErrorIs(t, err, ErrFlagNotInMaintenance)
+	})
+}
+
+func TestFlagService_LockFlag(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	t.Run("locked flag rejects manual toggles", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "lock_toggle", entity.FlagEnabled)
+		require.NoError(t, service.LockFlag(context.Background(), flag.ID, "admin", "compliance kill switch"))
+		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionLock, "admin")
+
+		_, err := service.DisableFlag(context.Background(), flag.ID, "test_user", "should fail")
+		assert.ErrorIs(t, err, ErrFlagLocked)
+		_, err = service.EnableFlag(context.Background(), flag.ID, "test_user", "should fail")
+		assert.ErrorIs(t, err, ErrFlagLocked)
+		err = service.SetMaintenance(context.Background(), flag.ID, "test_user", "should fail")
+		assert.ErrorIs(t, err, ErrFlagLocked)
+		_, err = service.UpdateFlag(context.Background(), flag.ID, validator.FlagUpdateRequest{}, "test_user")
+		assert.ErrorIs(t, err, ErrFlagLocked)
+
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
+	})
+
+	t.Run("locked flag resists cascade", func(t *testing.T) {
+		root := testDB.CreateTestFlag(t, "lock_root", entity.FlagEnabled)
+		locked := testDB.CreateTestFlagWithDependencies(t, "lock_child", entity.FlagEnabled, []int64{root.ID})
+		other := testDB.CreateTestFlagWithDependencies(t, "lock_sibling", entity.FlagEnabled, []int64{root.ID})
+		require.NoError(t, service.LockFlag(context.Background(), locked.ID, "admin", "must stay on"))
+
+		_, err := service.DisableFlag(context.Background(), root.ID, "test_user", "root off")
+
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, locked.ID, entity.FlagEnabled)
+		testDB.AssertFlagStatus(t, other.ID, entity.FlagDisabled)
+	})
+
+	t.Run("unlock allows changes again", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "lock_unlock", entity.FlagEnabled)
+		require.NoError(t, service.LockFlag(context.Background(), flag.ID, "admin", "freeze"))
+		require.NoError(t, service.UnlockFlag(context.Background(), flag.ID, "admin", "thaw"))
+		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionUnlock, "admin")
+
+		_, err := service.DisableFlag(context.Background(), flag.ID, "test_user", "now allowed")
+
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+	})
+}
+
+func TestFlagService_ArchiveFlag(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+	ctx := context.Background()
+
+	listNames := func(t *testing.T, filter entity.FlagFilter) []string {
+		flags, _, err := service.ListFlagsPaginated(ctx, filter, 50, 0)
+		require.NoError(t, err)
+		names := make([]string, len(flags))
+		for i, flag := range flags {
+			names[i] = flag.Name
+		}
+		return names
+	}
+
+	t.Run("archive disables and cascades but keeps the flag", func(t *testing.T) {
+		root := testDB.CreateTestFlag(t, "archive_root", entity.FlagEnabled)
+		child := testDB.CreateTestFlagWithDependencies(t, "archive_child", entity.FlagEnabled, []int64{root.ID})
+
+		require.NoError(t, service.ArchiveFlag(ctx, root.ID, "admin", "retired"))
+
+		testDB.AssertFlagStatus(t, root.ID, entity.FlagDisabled)
+		testDB.AssertFlagStatus(t, child.ID, entity.FlagDisabled)
+		testDB.AssertAuditLogExists(t, root.ID, entity.ActionDisable, "admin")
+		testDB.AssertAuditLogExists(t, root.ID, entity.ActionArchive, "admin")
+		flag, err := service.GetFlag(ctx, root.ID)
+		require.NoError(t, err)
+		assert.True(t, flag.IsArchived())
+	})
+
+	t.Run("archived flags are listed only on request", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "archive_listed", entity.FlagDisabled)
+		require.NoError(t, service.ArchiveFlag(ctx, flag.ID, "admin", "retired"))
+
+		assert.NotContains(t, listNames(t, entity.FlagFilter{}), "archive_listed")
+		assert.Contains(t, listNames(t, entity.FlagFilter{IncludeArchived: true}), "archive_listed")
+	})
+
+	t.Run("archived flag cannot be enabled or re-created", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "archive_blocked", entity.FlagDisabled)
+		require.NoError(t, service.ArchiveFlag(ctx, flag.ID, "admin", "retired"))
+
+		_, err := service.EnableFlag(ctx, flag.ID, "test_user", "should fail")
+		assert.ErrorIs(t, err, ErrFlagArchived)
+		_, err = service.CreateFlag(ctx, validator.FlagCreateRequest{Name: "archive_blocked"}, "test_user")
+		assert.ErrorIs(t, err, ErrFlagAlreadyExists)
+	})
+
+	t.Run("archived flag cannot enter or leave maintenance", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "archive_maintenance", entity.FlagDisabled)
+		require.NoError(t, service.ArchiveFlag(ctx, flag.ID, "admin", "retired"))
+
+		assert.ErrorIs(t, service.SetMaintenance(ctx, flag.ID, "test_user", "should fail"), ErrFlagArchived)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+
+		// A flag left in maintenance by older data is not resumed either
+		_, err := testDB.DB.Exec("UPDATE flags SET status = 'maintenance' WHERE id = $1", flag.ID)
+		require.NoError(t, err)
+		err = service.ResumeFlag(ctx, flag.ID, validator.FlagResumeRequest{Reason: "should fail"}, "test_user")
+		assert.ErrorIs(t, err, ErrFlagArchived)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagMaintenance)
+	})
+
+	t.Run("flag depending on an archived flag cannot be enabled", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "archive_dependency", entity.FlagEnabled)
+		flag := testDB.CreateTestFlagWithDependencies(t, "archive_dependent", entity.FlagDisabled, []int64{dep.ID})
+		require.NoError(t, service.ArchiveFlag(ctx, dep.ID, "admin", "retired"))
+
+		_, err := service.EnableFlag(ctx, flag.ID, "test_user", "should fail")
+		var unavailableErr UnavailableDependencyError
+		require.ErrorAs(t, err, &unavailableErr)
+		assert.Equal(t, "dependency archive_dependency has been archived", err.Error())
+		assert.ErrorIs(t, err, ErrDependencyUnavailable)
+
+		_, err = service.ToggleFlag(ctx, flag.ID, validator.FlagToggleRequest{Enable: true, Cascade: true, Reason: "should fail"}, "test_user")
+		assert.ErrorIs(t, err, ErrDependencyUnavailable)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+	})
+
+	t.Run("locked flag cannot be archived", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "archive_locked", entity.FlagEnabled)
+		require.NoError(t, service.LockFlag(ctx, flag.ID, "admin", "freeze"))
+
+		assert.ErrorIs(t, service.ArchiveFlag(ctx, flag.ID, "admin", "retired"), ErrFlagLocked)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
+	})
+
+	t.Run("restore makes the flag usable again", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "archive_restore", entity.FlagEnabled)
+		require.NoError(t, service.ArchiveFlag(ctx, flag.ID, "admin", "retired"))
+		restored, err := service.RestoreFlag(ctx, flag.ID, "admin", "needed again")
+		require.NoError(t, err)
+		assert.False(t, restored.IsArchived())
+		assert.Equal(t, entity.FlagDisabled, restored.Status)
+		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionRestore, "admin")
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+		assert.Contains(t, listNames(t, entity.FlagFilter{}), "archive_restore")
+
+		_, err = service.EnableFlag(ctx, flag.ID, "test_user", "back on")
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
+	})
+
+	t.Run("restore rejects a flag that is not archived", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "archive_not_archived", entity.FlagDisabled)
+
+		_, err := service.RestoreFlag(ctx, flag.ID, "admin", "nothing to restore")
+		assert.ErrorIs(t, err, ErrFlagNotArchived)
+	})
+}
+
+func TestFlagService_EnableWhenReady(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	pendingRepo := repository.NewPendingEnableRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log, WithPendingEnableRepository(pendingRepo))
+
+	worker := NewWorker(time.Minute, log)
+	worker.Register("pending_enables", service.ProcessPendingEnables)
+
+	t.Run("enables immediately when dependencies are satisfied", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "ready_dep", entity.FlagEnabled)
+		flag := testDB.CreateTestFlagWithDependencies(t, "ready_flag", entity.FlagDisabled, []int64{dep.ID})
+
+		pending, err := service.EnableWhenReady(context.Background(), flag.ID, "deployer", "ship it")
+
+		require.NoError(t, err)
+		assert.Nil(t, pending)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
+	})
+
+	t.Run("worker enables flag once dependencies are satisfied", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "later_dep", entity.FlagDisabled)
+		flag := testDB.CreateTestFlagWithDependencies(t, "later_flag", entity.FlagDisabled, []int64{dep.ID})
+
+		pending, err := service.EnableWhenReady(context.Background(), flag.ID, "deployer", "ship when ready")
+		require.NoError(t, err)
+		require.NotNil(t, pending)
+
+		// Retrying returns the same intent instead of creating a new one
+		again, err := service.EnableWhenReady(context.Background(), flag.ID, "deployer", "ship when ready")
+		require.NoError(t, err)
+		assert.Equal(t, pending.ID, again.ID)
+
+		// Dependencies still missing: nothing happens
+		worker.RunOnce(context.Background())
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+
+		_, err = service.EnableFlag(context.Background(), dep.ID, "test_user", "dependency ready")
+		require.NoError(t, err)
+		worker.RunOnce(context.Background())
+
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
+		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionEnable, "deployer")
+
+		resolved, err := pendingRepo.GetPendingEnableByID(context.Background(), pending.ID)
+		require.NoError(t, err)
+		assert.Equal(t, entity.PendingEnableCompleted, resolved.Status)
+		assert.Equal(t, 2, resolved.Attempts)
+	})
+
+	t.Run("cancelled intent is not processed", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "cancel_dep", entity.FlagDisabled)
+		flag := testDB.CreateTestFlagWithDependencies(t, "cancel_flag", entity.FlagDisabled, []int64{dep.ID})
+
+		pending, err := service.EnableWhenReady(context.Background(), flag.ID, "deployer", "ship when ready")
+		require.NoError(t, err)
+
+		require.NoError(t, service.CancelPendingEnable(context.Background(), flag.ID, pending.ID, "deployer"))
+		_, err = service.EnableFlag(context.Background(), dep.ID, "test_user", "dependency ready")
+		require.NoError(t, err)
+		worker.RunOnce(context.Background())
+
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+		assert.ErrorIs(t, service.CancelPendingEnable(context.Background(), flag.ID, pending.ID, "deployer"), ErrPendingEnableNotFound)
+	})
+}
+
+func TestFlagService_GetDependencyGraph(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log, WithGraphNodeLimit(3))
+
+	// Chain: graph_a <- graph_b <- graph_c <- graph_d
+	a := testDB.CreateTestFlag(t, "graph_a", entity.FlagEnabled)
+	b := testDB.CreateTestFlagWithDependencies(t, "graph_b", entity.FlagEnabled, []int64{a.ID})
+	c := testDB.CreateTestFlagWithDependencies(t, "graph_c", entity.FlagDisabled, []int64{b.ID})
+	d := testDB.CreateTestFlagWithDependencies(t, "graph_d", entity.FlagDisabled, []int64{c.ID})
+
+	nodeIDs := func(graph *entity.DependencyGraph) []int64 {
+		var ids []int64
+		for _, node := range graph.Nodes {
+			ids = append(ids, node.ID)
+		}
+		return ids
+	}
+
+	t.Run("bounded subgraph around root in both directions", func(t *testing.T) {
+		graph, err := service.GetDependencyGraph(context.Background(), b.ID, 1)
+
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int64{a.ID, b.ID, c.ID}, nodeIDs(graph))
+		assert.ElementsMatch(t, []entity.DependencyEdge{
+			{FlagID: b.ID, DependsOnID: a.ID},
+			{FlagID: c.ID, DependsOnID: b.ID},
+		}, graph.Edges)
+		assert.False(t, graph.Truncated)
+		assert.NotContains(t, nodeIDs(graph), d.ID)
+	})
+
+	t.Run("full graph is truncated at the node limit", func(t *testing.T) {
+		graph, err := service.GetDependencyGraph(context.Background(), 0, 0)
+
+		require.NoError(t, err)
+		assert.True(t, graph.Truncated)
+		assert.Equal(t, 4, graph.TotalNodes)
+		assert.Equal(t, []int64{a.ID, b.ID, c.ID}, nodeIDs(graph))
+		assert.Len(t, graph.Edges, 2)
+	})
+
+	t.Run("rooted graph larger than the limit keeps nearest nodes", func(t *testing.T) {
+		graph, err := service.GetDependencyGraph(context.Background(), d.ID, 10)
+
+		require.NoError(t, err)
+		assert.True(t, graph.Truncated)
+		assert.ElementsMatch(t, []int64{b.ID, c.ID, d.ID}, nodeIDs(graph))
+	})
+
+	t.Run("invalid depth is rejected", func(t *testing.T) {
+		_, err := service.GetDependencyGraph(context.Background(), a.ID, MaxGraphDepth+1)
+		assert.ErrorIs(t, err, ErrInvalidGraphDepth)
+
+		_, err = service.GetDependencyGraph(context.Background(), a.ID, 0)
+		assert.ErrorIs(t, err, ErrInvalidGraphDepth, "a depth of 0 is not taken to mean the default")
+	})
+}
+
+func TestFlagService_GetEnablePlan(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+	ctx := context.Background()
+
+	t.Run("dependencies come before their dependents", func(t *testing.T) {
+		// database <- auth <- profile, auth <- checkout, profile <- checkout
+		database := testDB.CreateTestFlag(t, "plan_database", entity.FlagDisabled)
+		auth := testDB.CreateTestFlagWithDependencies(t, "plan_auth", entity.FlagDisabled, []int64{database.ID})
+		profile := testDB.CreateTestFlagWithDependencies(t, "plan_profile", entity.FlagDisabled, []int64{database.ID, auth.ID})
+		checkout := testDB.CreateTestFlagWithDependencies(t, "plan_checkout", entity.FlagDisabled, []int64{profile.ID, auth.ID})
+
+		plan, err := service.GetEnablePlan(ctx, checkout.ID)
+
+		require.NoError(t, err)
+		assert.Equal(t, checkout.ID, plan.FlagID)
+		var order []int64
+		for _, step := range plan.Steps {
+			order = append(order, step.ID)
+		}
+		assert.Equal(t, []int64{database.ID, auth.ID, profile.ID}, order)
+
+		// Following the plan front to back never fails a dependency check
+		for _, step := range plan.Steps {
+			_, err := service.EnableFlag(ctx, step.ID, "test_user", "follow plan")
+			require.NoError(t, err, step.Name)
+		}
+		_, err = service.EnableFlag(ctx, checkout.ID, "test_user", "follow plan")
+		require.NoError(t, err)
+	})
+
+	t.Run("flag without dependencies has an empty plan", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "plan_standalone", entity.FlagDisabled)
+
+		plan, err := service.GetEnablePlan(ctx, flag.ID)
+
+		require.NoError(t, err)
+		assert.Empty(t, plan.Steps)
+	})
+
+	t.Run("cycle names the flags on it", func(t *testing.T) {
+		flagA := testDB.CreateTestFlag(t, "plan_cycle_a", entity.FlagDisabled)
+		flagB := testDB.CreateTestFlagWithDependencies(t, "plan_cycle_b", entity.FlagDisabled, []int64{flagA.ID})
+		root := testDB.CreateTestFlagWithDependencies(t, "plan_cycle_root", entity.FlagDisabled, []int64{flagB.ID})
+		require.NoError(t, flagRepo.AddDependency(ctx, flagA.ID, flagB.ID))
+
+		_, err := service.GetEnablePlan(ctx, root.ID)
+
+		var cycleErr CycleError
+		require.ErrorAs(t, err, &cycleErr)
+		assert.ErrorIs(t, err, ErrCircularDependency)
+		assert.Equal(t, []string{"plan_cycle_b", "plan_cycle_a"}, cycleErr.Cycle)
+	})
+
+	t.Run("unknown flag", func(t *testing.T) {
+		_, err := service.GetEnablePlan(ctx, 99999)
+		assert.ErrorIs(t, err, ErrFlagNotFound)
+	})
+}
+
+func TestFlagService_SatisfyDependencies(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	t.Run("enables only prerequisites in dependency order", func(t *testing.T) {
+		// database <- auth <- checkout, profile (already enabled) <- checkout
+		database := testDB.CreateTestFlag(t, "satisfy_database", entity.FlagDisabled)
+		auth := testDB.CreateTestFlagWithDependencies(t, "satisfy_auth", entity.FlagDisabled, []int64{database.ID})
+		profile := testDB.CreateTestFlag(t, "satisfy_profile", entity.FlagEnabled)
+		checkout := testDB.CreateTestFlagWithDependencies(t, "satisfy_checkout", entity.FlagDisabled, []int64{auth.ID, profile.ID})
+
+		enabled, err := service.SatisfyDependencies(context.Background(), checkout.ID, "test_user", "prepare launch")
+
+		require.NoError(t, err)
+		require.Len(t, enabled, 2)
+		assert.Equal(t, database.ID, enabled[0].ID)
+		assert.Equal(t, auth.ID, enabled[1].ID)
+
+		testDB.AssertFlagStatus(t, database.ID, entity.FlagEnabled)
+		testDB.AssertFlagStatus(t, auth.ID, entity.FlagEnabled)
+		testDB.AssertFlagStatus(t, checkout.ID, entity.FlagDisabled)
+		testDB.AssertAuditLogExists(t, auth.ID, entity.ActionEnable, "test_user")
+
+		// The target can now be enabled normally
+		_, err = service.EnableFlag(context.Background(), checkout.ID, "test_user", "launch")
+		require.NoError(t, err)
+	})
+
+	t.Run("rolls back when a prerequisite is in maintenance", func(t *testing.T) {
+		base := testDB.CreateTestFlag(t, "satisfy_base", entity.FlagDisabled)
+		maint := testDB.CreateTestFlagWithDependencies(t, "satisfy_maint", entity.FlagMaintenance, []int64{base.ID})
+		target := testDB.CreateTestFlagWithDependencies(t, "satisfy_target", entity.FlagDisabled, []int64{maint.ID})
+
+		_, err := service.SatisfyDependencies(context.Background(), target.ID, "test_user", "prepare launch")
+
+		assert.ErrorIs(t, err, ErrFlagInMaintenance)
+		testDB.AssertFlagStatus(t, base.ID, entity.FlagDisabled)
+	})
+}
+
+func TestFlagService_HighRiskEnableConfirmation(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	pendingRepo := repository.NewPendingEnableRepository(testDB.DB)
+	scheduleRepo := repository.NewScheduledChangeRepository(testDB.DB)
+	service := NewFlagService(flagRepo, auditRepo, log, WithConfirmationSecret([]byte("test-secret"), time.Minute),
+		WithPendingEnableRepository(pendingRepo), WithScheduledChangeRepository(scheduleRepo))
+	ctx := context.Background()
+
+	createHighRisk := func(t *testing.T, name string) *entity.Flag {
+		flag, err := service.CreateFlag(context.Background(), validator.FlagCreateRequest{Name: name, HighRisk: true}, "test_user")
+		require.NoError(t, err)
+		return flag
+	}
+	// makeHighRisk marks an existing flag high-risk behind the service's back
+	makeHighRisk := func(t *testing.T, flagID int64) {
+		_, err := testDB.DB.Exec("UPDATE flags SET high_risk = TRUE WHERE id = $1", flagID)
+		require.NoError(t, err)
+	}
+
+	t.Run("two-step confirm flow", func(t *testing.T) {
+		flag := createHighRisk(t, "kill_switch")
+		req := validator.FlagToggleRequest{Enable: true, Reason: "turn on kill switch"}
+
+		_, err := service.ToggleFlag(context.Background(), flag.ID, req, "test_user")
+
+		var confirmErr ConfirmationRequiredError
+		require.ErrorAs(t, err, &confirmErr)
+		assert.NotEmpty(t, confirmErr.Token)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+
+		req.ConfirmationToken = confirmErr.Token
+		_, err = service.ToggleFlag(context.Background(), flag.ID, req, "test_user")
+
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
+	})
+
+	t.Run("invalid token is rejected", func(t *testing.T) {
+		flag := createHighRisk(t, "kill_switch_invalid")
+		req := validator.FlagToggleRequest{Enable: true, Reason: "turn on kill switch", ConfirmationToken: "9999999999.deadbeef"}
+
+		_, err := service.ToggleFlag(context.Background(), flag.ID, req, "test_user")
+
+		var confirmErr ConfirmationRequiredError
+		require.ErrorAs(t, err, &confirmErr)
+		assert.Equal(t, "Confirmation token is invalid or expired", confirmErr.Message)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+	})
+
+	t.Run("token is stale after the flag changes", func(t *testing.T) {
+		flag := createHighRisk(t, "kill_switch_stale")
+		req := validator.FlagToggleRequest{Enable: true, Reason: "turn on kill switch"}
+
+		var confirmErr ConfirmationRequiredError
+		_, err := service.ToggleFlag(context.Background(), flag.ID, req, "test_user")
+		require.ErrorAs(t, err, &confirmErr)
+
+		// Any update bumps updated_at, which is the version the token is bound to
+		_, err = testDB.DB.Exec("UPDATE flags SET updated_at = updated_at + INTERVAL '1 second' WHERE id = $1", flag.ID)
+		require.NoError(t, err)
+
+		req.ConfirmationToken = confirmErr.Token
+		_, err = service.ToggleFlag(context.Background(), flag.ID, req, "test_user")
+
+		require.ErrorAs(t, err, &confirmErr)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+	})
+
+	t.Run("enable without a toggle is refused", func(t *testing.T) {
+		flag := createHighRisk(t, "kill_switch_direct")
+
+		_, err := service.EnableFlag(ctx, flag.ID, "test_user", "no confirmation")
+		assert.ErrorIs(t, err, ErrConfirmationRequired)
+
+		_, err = service.EnableWhenReady(ctx, flag.ID, "test_user", "no confirmation")
+		assert.ErrorIs(t, err, ErrConfirmationRequired)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+	})
+
+	t.Run("pending enable of a flag that became high-risk is cancelled", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "kill_switch_pending_dep", entity.FlagDisabled)
+		flag := testDB.CreateTestFlagWithDependencies(t, "kill_switch_pending", entity.FlagDisabled, []int64{dep.ID})
+		pending, err := service.EnableWhenReady(ctx, flag.ID, "test_user", "ship when ready")
+		require.NoError(t, err)
+		require.NotNil(t, pending)
+		makeHighRisk(t, flag.ID)
+		_, err = service.EnableFlag(ctx, dep.ID, "test_user", "dependency ready")
+		require.NoError(t, err)
+
+		require.NoError(t, service.ProcessPendingEnables(ctx))
+
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+		resolved, err := pendingRepo.GetPendingEnableByID(ctx, pending.ID)
+		require.NoError(t, err)
+		assert.Equal(t, entity.PendingEnableCancelled, resolved.Status)
+	})
+
+	t.Run("resume needs confirmation", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "kill_switch_resume", entity.FlagEnabled)
+		require.NoError(t, service.SetMaintenance(ctx, flag.ID, "test_user", "maintenance window"))
+		makeHighRisk(t, flag.ID)
+		req := validator.FlagResumeRequest{Reason: "maintenance finished"}
+
+		err := service.ResumeFlag(ctx, flag.ID, req, "test_user")
+
+		var confirmErr ConfirmationRequiredError
+		require.ErrorAs(t, err, &confirmErr)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagMaintenance)
+
+		req.ConfirmationToken = confirmErr.Token
+		require.NoError(t, service.ResumeFlag(ctx, flag.ID, req, "test_user"))
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagEnabled)
+	})
+
+	t.Run("prerequisites are not enabled past a high-risk dependency", func(t *testing.T) {
+		dep := createHighRisk(t, "kill_switch_prerequisite")
+		flag := testDB.CreateTestFlagWithDependencies(t, "kill_switch_prerequisite_user", entity.FlagDisabled, []int64{dep.ID})
+
+		_, err := service.SatisfyDependencies(ctx, flag.ID, "test_user", "prepare launch")
+		assert.ErrorIs(t, err, ErrConfirmationRequired)
+
+		_, err = service.ToggleFlag(ctx, flag.ID, validator.FlagToggleRequest{Enable: true, Cascade: true, Reason: "launch"}, "test_user")
+		assert.ErrorIs(t, err, ErrConfirmationRequired)
+		testDB.AssertFlagStatus(t, dep.ID, entity.FlagDisabled)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+	})
+
+	t.Run("scheduled enable is refused", func(t *testing.T) {
+		flag := createHighRisk(t, "kill_switch_scheduled")
+		schedule := validator.FlagScheduleRequest{Status: string(entity.FlagEnabled), ScheduledAt: time.Now().Add(time.Hour), Reason: "planned launch"}
+
+		_, err := service.ScheduleChange(ctx, flag.ID, schedule, "planner")
+		assert.ErrorIs(t, err, ErrConfirmationRequired)
+
+		// A flag that became high-risk after its enable was scheduled
+		other := testDB.CreateTestFlag(t, "kill_switch_scheduled_later", entity.FlagDisabled)
+		change, err := service.ScheduleChange(ctx, other.ID, schedule, "planner")
+		require.NoError(t, err)
+		makeHighRisk(t, other.ID)
+		_, err = testDB.DB.Exec("UPDATE scheduled_changes SET scheduled_at = NOW() - INTERVAL '1 minute' WHERE id = $1", change.ID)
+		require.NoError(t, err)
+
+		require.NoError(t, service.ProcessScheduledChanges(ctx))
+
+		testDB.AssertFlagStatus(t, other.ID, entity.FlagDisabled)
+		resolved, err := scheduleRepo.GetScheduledChangeByID(ctx, change.ID)
+		require.NoError(t, err)
+		assert.Equal(t, entity.ScheduledChangeFailed, resolved.Status)
+	})
+
+	t.Run("expired token is rejected", func(t *testing.T) {
+		tokens := newConfirmationTokens([]byte("test-secret"), time.Minute)
+		flag := &entity.Flag{ID: 1, UpdatedAt: time.Now()}
+
+		token, _ := tokens.Issue(flag)
+		assert.True(t, tokens.Verify(flag, token))
+
+		tokens.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+		assert.False(t, tokens.Verify(flag, token))
+	})
+}
+
+func TestFlagService_ListAuditLogs(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	first := testDB.CreateTestFlag(t, "page_first", entity.FlagDisabled)
+	second := testDB.CreateTestFlag(t, "page_second", entity.FlagDisabled)
+	_, err := service.EnableFlag(context.Background(), first.ID, "user1", "enable first")
+	require.NoError(t, err)
+	_, err = service.EnableFlag(context.Background(), second.ID, "user2", "enable second")
+	require.NoError(t, err)
+	_, err = service.DisableFlag(context.Background(), first.ID, "user1", "disable first")
+	require.NoError(t, err)
+
+	t.Run("pages across all flags", func(t *testing.T) {
+		page, err := service.ListAuditLogs(context.Background(), 2, 0)
+		require.NoError(t, err)
+		assert.Len(t, page, 2)
+
+		rest, err := service.ListAuditLogs(context.Background(), 2, 2)
+		require.NoError(t, err)
+		assert.Len(t, rest, 1)
+
+		flagIDs := map[int64]bool{}
+		for _, entry := range append(page, rest...) {
+			flagIDs[entry.FlagID] = true
+		}
+		assert.Len(t, flagIDs, 2)
+	})
+
+	t.Run("offset past the end returns an empty page", func(t *testing.T) {
+		page, err := service.ListAuditLogs(context.Background(), 10, 100)
+		require.NoError(t, err)
+		assert.NotNil(t, page)
+		assert.Empty(t, page)
+	})
+
+	t.Run("invalid paging", func(t *testing.T) {
+		_, err := service.ListAuditLogs(context.Background(), 0, 0)
+		assert.ErrorIs(t, err, ErrInvalidAuditPage)
+		_, err = service.ListAuditLogs(context.Background(), MaxAuditPageSize+1, 0)
+		assert.ErrorIs(t, err, ErrInvalidAuditPage)
+		_, err = service.ListAuditLogs(context.Background(), 10, -1)
+		assert.ErrorIs(t, err, ErrInvalidAuditPage)
+	})
+}
+
+func TestFlagService_StreamAuditLogs(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	t.Run("subscriber receives toggle audit", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "streamed_flag", entity.FlagDisabled)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		logs, err := service.StreamAuditLogs(ctx, entity.AuditFilter{Action: entity.ActionEnable})
+		require.NoError(t, err)
+
+		req := validator.FlagToggleRequest{Enable: true, Reason: "stream test"}
+		_, err = service.ToggleFlag(context.Background(), flag.ID, req, "stream_user")
+		require.NoError(t, err)
+
+		select {
+		case got := <-logs:
+			assert.Equal(t, flag.ID, got.FlagID)
+			assert.Equal(t, entity.ActionEnable, got.Action)
+			assert.Equal(t, "stream_user", got.Actor)
+			assert.NotZero(t, got.ID)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for audit event")
+		}
+
+		cancel()
+		_, open := <-logs
+		assert.False(t, open, "stream should close once the context is cancelled")
+	})
+
+	t.Run("invalid action filter is rejected", func(t *testing.T) {
+		_, err := service.StreamAuditLogs(context.Background(), entity.AuditFilter{Action: "bogus"})
+		assert.ErrorIs(t, err, ErrInvalidAuditAction)
+	})
+}
+
+func TestFlagService_StrictAudit(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := &flakyAuditRepository{failing: true}
+	log := test.GetTestLogger()
+	ctx := context.Background()
+
+	t.Run("strict audit rolls back the enable", func(t *testing.T) {
+		service := NewFlagService(flagRepo, auditRepo, log, WithStrictAudit(true))
+		flag := testDB.CreateTestFlag(t, "strict_audit_flag", entity.FlagDisabled)
+
+		_, err := service.EnableFlag(ctx, flag.ID, "test_user", "launch")
+		assert.ErrorIs(t, err, errAuditUnavailable)
+
+		stored, err := flagRepo.GetFlagByID(ctx, flag.ID)
+		require.NoError(t, err)
+		assert.Equal(t, entity.FlagDisabled, stored.Status)
+		assert.Equal(t, flag.Version, stored.Version)
+	})
+
+	t.Run("strict audit rolls back cascade-enabled dependencies", func(t *testing.T) {
+		service := NewFlagService(flagRepo, auditRepo, log, WithStrictAudit(true))
+		base := testDB.CreateTestFlag(t, "strict_audit_base", entity.FlagDisabled)
+		dependent := testDB.CreateTestFlagWithDependencies(t, "strict_audit_dependent", entity.FlagDisabled, []int64{base.ID})
+
+		_, err := service.ToggleFlag(ctx, dependent.ID, validator.FlagToggleRequest{Enable: true, Cascade: true, Reason: "launch"}, "test_user")
+		assert.ErrorIs(t, err, errAuditUnavailable)
+
+		stored, err := flagRepo.GetFlagByID(ctx, base.ID)
+		require.NoError(t, err)
+		assert.Equal(t, entity.FlagDisabled, stored.Status)
+	})
+
+	t.Run("lenient audit keeps the enable", func(t *testing.T) {
+		service := NewFlagService(flagRepo, auditRepo, log)
+		flag := testDB.CreateTestFlag(t, "lenient_audit_flag", entity.FlagDisabled)
+
+		change, err := service.EnableFlag(ctx, flag.ID, "test_user", "launch")
+		require.NoError(t, err)
+		assert.True(t, change.Changed)
+
+		stored, err := flagRepo.GetFlagByID(ctx, flag.ID)
+		require.NoError(t, err)
+		assert.Equal(t, entity.FlagEnabled, stored.Status)
+	})
+}
+
+func TestFlagService_StreamFlagChanges(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := service.StreamFlagChanges(ctx)
+
+	next := func(t *testing.T) *entity.FlagStatusEvent {
+		select {
+		case change := <-changes:
+			return change
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for flag status event")
+			return nil
+		}
+	}
+
+	t.Run("disable publishes the flag and its cascade", func(t *testing.T) {
+		base := testDB.CreateTestFlag(t, "stream_base", entity.FlagEnabled)
+		dependent := testDB.CreateTestFlagWithDependencies(t, "stream_dependent", entity.FlagEnabled, []int64{base.ID})
+
+		_, err := service.DisableFlag(context.Background(), base.ID, "stream_user", "stream test")
+		require.NoError(t, err)
+
+		got := next(t)
+		assert.Equal(t, base.ID, got.FlagID)
+		assert.Equal(t, "stream_base", got.Name)
+		assert.Equal(t, entity.FlagDisabled, got.Status)
+		assert.Equal(t, entity.GlobalEnvironment, got.Environment)
+		assert.Equal(t, "stream_user", got.ChangedBy)
+
+		got = next(t)
+		assert.Equal(t, dependent.ID, got.FlagID)
+		assert.Equal(t, entity.FlagDisabled, got.Status)
+		assert.Equal(t, "system", got.ChangedBy)
+	})
+
+	t.Run("rejected enable publishes nothing", func(t *testing.T) {
+		base := testDB.CreateTestFlag(t, "stream_rejected_base", entity.FlagDisabled)
+		dependent := testDB.CreateTestFlagWithDependencies(t, "stream_rejected", entity.FlagDisabled, []int64{base.ID})
+
+		_, err := service.EnableFlag(context.Background(), dependent.ID, "stream_user", "stream test")
+		require.Error(t, err)
+
+		select {
+		case change := <-changes:
+			t.Fatalf("unexpected event for flag %d", change.FlagID)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("stream closes with its context", func(t *testing.T) {
+		cancel()
+		for range changes {
+		}
+	})
+}
+
+func TestFlagService_DisablePolicy(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	createEnabledPair := func(t *testing.T, prefix, policy string) (*entity.Flag, *entity.Flag) {
+		base, err := service.CreateFlag(context.Background(), validator.FlagCreateRequest{Name: prefix + "_base", DisablePolicy: policy}, "test_user")
+		require.NoError(t, err)
+		_, err = service.EnableFlag(context.Background(), base.ID, "test_user", "enable base")
+		require.NoError(t, err)
+
+		dependent := testDB.CreateTestFlagWithDependencies(t, prefix+"_dependent", entity.FlagEnabled, []int64{base.ID})
+		return base, dependent
+	}
+
+	t.Run("default policy cascades", func(t *testing.T) {
+		base, dependent := createEnabledPair(t, "cascade", "")
+		assert.Equal(t, entity.DisablePolicyCascade, base.DisablePolicy)
+
+		_, err := service.DisableFlag(context.Background(), base.ID, "test_user", "turn off base")
+
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, base.ID, entity.FlagDisabled)
+		testDB.AssertFlagStatus(t, dependent.ID, entity.FlagDisabled)
+	})
+
+	t.Run("block policy refuses while dependents are enabled", func(t *testing.T) {
+		base, dependent := createEnabledPair(t, "block", "block")
+
+		_, err := service.DisableFlag(context.Background(), base.ID, "test_user", "turn off base")
+
+		var blockErr EnabledDependentsError
+		require.ErrorAs(t, err, &blockErr)
+		assert.Equal(t, []string{dependent.Name}, blockErr.EnabledDependents)
+		testDB.AssertFlagStatus(t, base.ID, entity.FlagEnabled)
+		testDB.AssertFlagStatus(t, dependent.ID, entity.FlagEnabled)
+	})
+
+	t.Run("block policy allows disable once dependents are disabled", func(t *testing.T) {
+		base, dependent := createEnabledPair(t, "unblocked", "block")
+		_, err := service.DisableFlag(context.Background(), dependent.ID, "test_user", "turn off dependent")
+		require.NoError(t, err)
+
+		_, err = service.DisableFlag(context.Background(), base.ID, "test_user", "turn off base")
+
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, base.ID, entity.FlagDisabled)
+	})
+
+	t.Run("block policy refuses maintenance while dependents are enabled", func(t *testing.T) {
+		base, dependent := createEnabledPair(t, "block_maintenance", "block")
+
+		err := service.SetMaintenance(context.Background(), base.ID, "test_user", "maintenance window")
+
+		var blockErr EnabledDependentsError
+		require.ErrorAs(t, err, &blockErr)
+		assert.Equal(t, []string{dependent.Name}, blockErr.EnabledDependents)
+		testDB.AssertFlagStatus(t, base.ID, entity.FlagEnabled)
+		testDB.AssertFlagStatus(t, dependent.ID, entity.FlagEnabled)
+	})
+}
+
+func TestFlagService_GetAuditReport(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	ctx := context.Background()
+	search, err := service.CreateFlag(ctx, validator.FlagCreateRequest{Name: "search_v2"}, "alice")
+	require.NoError(t, err)
+	billing, err := service.CreateFlag(ctx, validator.FlagCreateRequest{Name: "billing_v2"}, "bob")
+	require.NoError(t, err)
+	_, err = service.EnableFlag(ctx, search.ID, "alice", "launch search")
+	require.NoError(t, err)
+	_, err = service.EnableFlag(ctx, billing.ID, "alice", "launch billing")
+	require.NoError(t, err)
+	_, err = service.DisableFlag(ctx, billing.ID, "bob", "roll back billing")
+	require.NoError(t, err)
+
+	from := time.Now().Add(-time.Hour)
+	to := time.Now().Add(time.Hour)
+
+	groupsByKey := func(report *entity.AuditReport) map[string]*entity.AuditReportGroup {
+		groups := map[string]*entity.AuditReportGroup{}
+		for _, group := range report.Groups {
+			groups[group.Key] = group
+		}
+		return groups
+	}
+
+	t.Run("group by actor", func(t *testing.T) {
+		report, err := service.GetAuditReport(ctx, entity.AuditReportByActor, from, to)
+		require.NoError(t, err)
+
+		groups := groupsByKey(report)
+		require.Len(t, groups, 2)
+		assert.Equal(t, 3, groups["alice"].Total)
+		assert.Equal(t, 2, groups["alice"].Actions[entity.ActionEnable])
+		assert.Equal(t, []string{"billing_v2", "search_v2"}, groups["alice"].Flags)
+		assert.Equal(t, 2, groups["bob"].Total)
+		assert.Equal(t, 1, groups["bob"].Actions[entity.ActionDisable])
+		assert.Equal(t, []string{"billing_v2"}, groups["bob"].Flags)
+	})
+
+	t.Run("group by flag", func(t *testing.T) {
+		report, err := service.GetAuditReport(ctx, entity.AuditReportByFlag, from, to)
+		require.NoError(t, err)
+
+		groups := groupsByKey(report)
+		require.Len(t, groups, 2)
+		assert.Equal(t, 3, groups["billing_v2"].Total)
+		assert.Equal(t, 2, groups["search_v2"].Total)
+		assert.Equal(t, 1, groups["search_v2"].Actions[entity.ActionCreate])
+	})
+
+	t.Run("group by action", func(t *testing.T) {
+		report, err := service.GetAuditReport(ctx, entity.AuditReportByAction, from, to)
+		require.NoError(t, err)
+
+		groups := groupsByKey(report)
+		require.Len(t, groups, 3)
+		assert.Equal(t, 2, groups[string(entity.ActionCreate)].Total)
+		assert.Equal(t, 2, groups[string(entity.ActionEnable)].Total)
+		assert.Equal(t, []string{"billing_v2"}, groups[string(entity.ActionDisable)].Flags)
+	})
+
+	t.Run("window outside activity is empty", func(t *testing.T) {
+		report, err := service.GetAuditReport(ctx, entity.AuditReportByActor, from.Add(-48*time.Hour), from)
+		require.NoError(t, err)
+		assert.Empty(t, report.Groups)
+	})
+
+	t.Run("invalid grouping and window are rejected", func(t *testing.T) {
+		_, err := service.GetAuditReport(ctx, "team", from, to)
+		assert.ErrorIs(t, err, ErrInvalidReportGrouping)
+
+		_, err = service.GetAuditReport(ctx, entity.AuditReportByActor, to, from)
+		assert.ErrorIs(t, err, ErrInvalidReportWindow)
+
+		_, err = service.GetAuditReport(ctx, entity.AuditReportByActor, to.Add(-400*24*time.Hour), to)
+		assert.ErrorIs(t, err, ErrInvalidReportWindow)
+	})
+}
+
+func TestFlagService_CyclicDependencyData(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	t.Run("enable reports a manually inserted cycle", func(t *testing.T) {
+		flagA := testDB.CreateTestFlag(t, "cycle_enable_a", entity.FlagEnabled)
+		flagB := testDB.CreateTestFlagWithDependencies(t, "cycle_enable_b", entity.FlagDisabled, []int64{flagA.ID})
+
+		// AddDependency does not check cycles at the repository level
+		require.NoError(t, flagRepo.AddDependency(context.Background(), flagA.ID, flagB.ID))
+
+		_, err := service.EnableFlag(context.Background(), flagB.ID, "test_user", "enable on a cycle")
+
+		assert.ErrorIs(t, err, ErrCircularDependency)
+		testDB.AssertFlagStatus(t, flagB.ID, entity.FlagDisabled)
+	})
+
+	t.Run("cascade terminates on a cycle", func(t *testing.T) {
+		flagA := testDB.CreateTestFlag(t, "cycle_cascade_a", entity.FlagEnabled)
+		flagB := testDB.CreateTestFlagWithDependencies(t, "cycle_cascade_b", entity.FlagEnabled, []int64{flagA.ID})
+		require.NoError(t, flagRepo.AddDependency(context.Background(), flagA.ID, flagB.ID))
+
+		origin := cascadeOrigin{root: flagA, event: "was turned off"}
+		assert.ErrorIs(t, service.(*flagService).cascadeDisableDependents(context.Background(), origin), ErrCircularDependency)
+		testDB.AssertFlagStatus(t, flagB.ID, entity.FlagDisabled)
+	})
+
+	t.Run("graph lists the cycles", func(t *testing.T) {
+		flagA := testDB.CreateTestFlag(t, "cycle_graph_a", entity.FlagEnabled)
+		flagB := testDB.CreateTestFlagWithDependencies(t, "cycle_graph_b", entity.FlagEnabled, []int64{flagA.ID})
+		require.NoError(t, flagRepo.AddDependency(context.Background(), flagA.ID, flagB.ID))
+
+		graph, err := service.GetDependencyGraph(context.Background(), 0, 0)
+
+		require.NoError(t, err)
+		assert.Contains(t, graph.Cycles, []int64{flagA.ID, flagB.ID})
+	})
+}
+
+func TestFlagService_ExportFlag(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	t.Run("export references dependencies by name", func(t *testing.T) {
+		auth := testDB.CreateTestFlag(t, "export_auth", entity.FlagEnabled)
+		profile := testDB.CreateTestFlag(t, "export_profile", entity.FlagEnabled)
+		checkout, err := service.CreateFlag(context.Background(), validator.FlagCreateRequest{
+			Name:          "export_checkout",
+			Dependencies:  []int64{profile.ID, auth.ID},
+			DisablePolicy: "block",
+			HighRisk:      true,
+		}, "test_user")
+		require.NoError(t, err)
+
+		export, err := service.ExportFlag(context.Background(), checkout.ID)
+
+		require.NoError(t, err)
+		assert.Equal(t, entity.FlagExportVersion, export.Version)
+		require.Len(t, export.Flags, 1)
+		definition := export.Flags[0]
+		assert.Equal(t, "export_checkout", definition.Name)
+		assert.Equal(t, entity.FlagDisabled, definition.Status)
+		assert.Equal(t, entity.DisablePolicyBlock, definition.DisablePolicy)
+		assert.True(t, definition.HighRisk)
+		assert.Equal(t, []string{"export_auth", "export_profile"}, definition.Dependencies)
+	})
+
+	t.Run("export unknown flag", func(t *testing.T) {
+		_, err := service.ExportFlag(context.Background(), 99999)
+		assert.ErrorIs(t, err, ErrFlagNotFound)
+	})
+}
+
+func TestFlagService_ImportExport(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+	ctx := context.Background()
+
+	item := func(name string, deps ...string) validator.FlagImportItem {
+		return validator.FlagImportItem{FlagCreateRequest: validator.FlagCreateRequest{Name: name}, Dependencies: deps}
+	}
+
+	t.Run("import creates flags in dependency order", func(t *testing.T) {
+		checkout := item("import_checkout", "import_auth", "import_profile")
+		checkout.Description = "New checkout"
+		checkout.Tags = map[string]string{"team": "payments"}
+
+		result, err := service.ImportFlags(ctx, validator.FlagImportRequest{
+			Version: entity.FlagExportVersion,
+			Flags:   []validator.FlagImportItem{checkout, item("import_profile", "import_auth"), item("import_auth")},
+		}, "importer")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"import_auth", "import_profile", "import_checkout"}, result.Created)
+		assert.Empty(t, result.Updated)
+		assert.Empty(t, result.Skipped)
+
+		export, err := service.ExportFlags(ctx)
+		require.NoError(t, err)
+		require.Len(t, export.Flags, 3)
+		assert.Equal(t, "import_auth", export.Flags[0].Name)
+		definition := export.Flags[1]
+		assert.Equal(t, "import_checkout", definition.Name)
+		assert.Equal(t, "New checkout", definition.Description)
+		assert.Equal(t, map[string]string{"team": "payments"}, definition.Tags)
+		assert.Equal(t, []string{"import_auth", "import_profile"}, definition.Dependencies)
+	})
+
+	t.Run("re-importing an export changes nothing", func(t *testing.T) {
+		export, err := service.ExportFlags(ctx)
+		require.NoError(t, err)
+		body, err := json.Marshal(export)
+		require.NoError(t, err)
+		var req validator.FlagImportRequest
+		require.NoError(t, json.Unmarshal(body, &req))
+
+		result, err := service.ImportFlags(ctx, req, "importer")
+		require.NoError(t, err)
+		assert.Empty(t, result.Created)
+		assert.Empty(t, result.Updated)
+		assert.Len(t, result.Skipped, 3)
+	})
+
+	t.Run("import updates dependencies of existing flags", func(t *testing.T) {
+		result, err := service.ImportFlags(ctx, validator.FlagImportRequest{
+			Version: entity.FlagExportVersion,
+			Flags:   []validator.FlagImportItem{item("import_checkout", "import_auth")},
+		}, "importer")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"import_checkout"}, result.Updated)
+
+		checkout, err := flagRepo.GetFlagByName(ctx, "import_checkout")
+		require.NoError(t, err)
+		auth, err := flagRepo.GetFlagByName(ctx, "import_auth")
+		require.NoError(t, err)
+		assert.Equal(t, []int64{auth.ID}, checkout.Dependencies)
+		assert.Empty(t, checkout.Tags)
+	})
+
+	t.Run("rejected import changes nothing", func(t *testing.T) {
+		_, err := service.ImportFlags(ctx, validator.FlagImportRequest{
+			Version: entity.FlagExportVersion,
+			Flags: []validator.FlagImportItem{
+				item("import_new"),
+				item("import_unknown_dep", "import_missing"),
+				item("import_cycle_a", "import_cycle_b"),
+				item("import_cycle_b", "import_cycle_a"),
+			},
+		}, "importer")
+		var importErr ImportError
+		require.ErrorAs(t, err, &importErr)
+		require.Len(t, importErr.Items, 3)
+		assert.Equal(t, "import_unknown_dep", importErr.Items[0].Name)
+		assert.Equal(t, "import_cycle_a", importErr.Items[1].Name)
+
+		_, err = flagRepo.GetFlagByName(ctx, "import_new")
+		assert.ErrorIs(t, err, repository.ErrFlagNotFound)
+	})
+
+	t.Run("duplicate names are rejected before any lookup", func(t *testing.T) {
+		_, err := service.ImportFlags(ctx, validator.FlagImportRequest{
+			Version: entity.FlagExportVersion,
+			Flags: []validator.FlagImportItem{
+				item("import_dup"),
+				item("import_unique", "import_missing"),
+				item("Import_Dup"),
+				item("import_dup"),
+			},
+		}, "importer")
+		var importErr ImportError
+		require.ErrorAs(t, err, &importErr)
+		assert.Equal(t, []BulkItemError{
+			{Index: 2, Name: "Import_Dup", Error: `flag name duplicates "import_dup" at index 0`},
+			{Index: 3, Name: "import_dup", Error: `flag name duplicates "import_dup" at index 0`},
+		}, importErr.Items)
+
+		_, err = flagRepo.GetFlagByName(ctx, "import_dup")
+		assert.ErrorIs(t, err, repository.ErrFlagNotFound)
+	})
+
+	t.Run("unsupported version is rejected", func(t *testing.T) {
+		_, err := service.ImportFlags(ctx, validator.FlagImportRequest{
+			Version: entity.FlagExportVersion + 1,
+			Flags:   []validator.FlagImportItem{item("import_future")},
+		}, "importer")
+		assert.ErrorIs(t, err, ErrUnsupportedExportVersion)
+	})
+}
+
+func TestFlagService_CascadeGracePeriod(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	cascadeRepo := repository.NewPendingCascadeRepository(testDB.DB)
+	log := test.GetTestLogger()
+	grace := time.Minute
+	now := time.Now()
+	service := NewFlagService(flagRepo, auditRepo, log, WithCascadeGracePeriod(cascadeRepo, grace),
+		WithClock(func() time.Time { return now }))
+
+	t.Run("re-enable within grace window cancels cascade", func(t *testing.T) {
+		base := testDB.CreateTestFlag(t, "grace_blip_base", entity.FlagEnabled)
+		dependent := testDB.CreateTestFlagWithDependencies(t, "grace_blip_dependent", entity.FlagEnabled, []int64{base.ID})
+
+		_, err := service.DisableFlag(context.Background(), base.ID, "test_user", "transient outage")
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, dependent.ID, entity.FlagEnabled)
+
+		_, err = service.EnableFlag(context.Background(), base.ID, "test_user", "outage over")
+		require.NoError(t, err)
+		now = now.Add(2 * grace)
+		require.NoError(t, service.ProcessPendingCascades(context.Background()))
+
+		testDB.AssertFlagStatus(t, dependent.ID, entity.FlagEnabled)
+	})
+
+	t.Run("cascade runs once grace window passes", func(t *testing.T) {
+		base := testDB.CreateTestFlag(t, "grace_outage_base", entity.FlagEnabled)
+		dependent := testDB.CreateTestFlagWithDependencies(t, "grace_outage_dependent", entity.FlagEnabled, []int64{base.ID})
+
+		_, err := service.DisableFlag(context.Background(), base.ID, "test_user", "real outage")
+		require.NoError(t, err)
+
+		// Not due yet
+		require.NoError(t, service.ProcessPendingCascades(context.Background()))
+		testDB.AssertFlagStatus(t, dependent.ID, entity.FlagEnabled)
+
+		now = now.Add(2 * grace)
+		require.NoError(t, service.ProcessPendingCascades(context.Background()))
+
+		testDB.AssertFlagStatus(t, dependent.ID, entity.FlagDisabled)
+		testDB.AssertAuditLogExists(t, dependent.ID, entity.ActionCascadeDisable, "system")
+	})
+}
+
+func TestFlagService_RolloutSchedule(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	rolloutRepo := repository.NewRolloutScheduleRepository(testDB.DB)
+	log := test.GetTestLogger()
+	now := time.Now()
+	service := NewFlagService(flagRepo, auditRepo, log, WithRolloutScheduleRepository(rolloutRepo),
+		WithClock(func() time.Time { return now }))
+	ctx := context.Background()
+
+	canary := validator.FlagRolloutScheduleRequest{StartPercentage: 5, StepPercentage: 40, StepIntervalMinutes: 60, Reason: "canary"}
+	hour := time.Hour
+
+	rollout := func(t *testing.T, flagID int64) int {
+		flag, err := flagRepo.GetFlagByID(ctx, flagID)
+		require.NoError(t, err)
+		return flag.RolloutPercentage
+	}
+
+	t.Run("worker raises the percentage each interval until 100", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "rollout_canary", entity.FlagEnabled)
+
+		schedule, err := service.ScheduleRollout(ctx, flag.ID, canary, "test_user")
+		require.NoError(t, err)
+		assert.Equal(t, entity.RolloutScheduleActive, schedule.Status)
+		assert.Equal(t, 5, rollout(t, flag.ID))
+
+		// Not due yet
+		require.NoError(t, service.ProcessRolloutSchedules(ctx))
+		assert.Equal(t, 5, rollout(t, flag.ID))
+
+		for _, want := range []int{45, 85, 100} {
+			now = now.Add(hour)
+			require.NoError(t, service.ProcessRolloutSchedules(ctx))
+			assert.Equal(t, want, rollout(t, flag.ID))
+		}
+
+		schedule, err = service.GetRolloutSchedule(ctx, flag.ID)
+		require.NoError(t, err)
+		assert.Equal(t, entity.RolloutScheduleCompleted, schedule.Status)
+		assert.Nil(t, schedule.NextStepAt)
+
+		logs, err := auditRepo.ListAuditLogsByFlagID(ctx, flag.ID)
+		require.NoError(t, err)
+		var steps []string
+		for _, entry := range logs {
+			if entry.Action == entity.ActionUpdate && entry.Actor == validator.SystemActor {
+				steps = append(steps, entry.Reason)
+			}
+		}
+		assert.Len(t, steps, 3)
+		assert.Contains(t, steps, "Rollout schedule of test_user raised the rollout percentage (rollout percentage from 85% to 100%)")
+	})
+
+	t.Run("paused schedule takes no steps until resumed", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "rollout_paused", entity.FlagEnabled)
+		_, err := service.ScheduleRollout(ctx, flag.ID, canary, "test_user")
+		require.NoError(t, err)
+
+		schedule, err := service.PauseRollout(ctx, flag.ID, "test_user", "error rate up")
+		require.NoError(t, err)
+		assert.Equal(t, entity.RolloutSchedulePaused, schedule.Status)
+		now = now.Add(2 * hour)
+		require.NoError(t, service.ProcessRolloutSchedules(ctx))
+		assert.Equal(t, 5, rollout(t, flag.ID))
+
+		_, err = service.ResumeRollout(ctx, flag.ID, "test_user", "error rate back to normal")
+		require.NoError(t, err)
+		now = now.Add(hour)
+		require.NoError(t, service.ProcessRolloutSchedules(ctx))
+		assert.Equal(t, 45, rollout(t, flag.ID))
+	})
+
+	t.Run("rollback aborts the schedule and sets the percentage to 0", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "rollout_rollback", entity.FlagEnabled)
+		_, err := service.ScheduleRollout(ctx, flag.ID, canary, "test_user")
+		require.NoError(t, err)
+
+		schedule, err := service.RollbackRollout(ctx, flag.ID, "test_user", "checkout errors")
+		require.NoError(t, err)
+		assert.Equal(t, entity.RolloutScheduleAborted, schedule.Status)
+		assert.Equal(t, 0, rollout(t, flag.ID))
+
+		now = now.Add(hour)
+		require.NoError(t, service.ProcessRolloutSchedules(ctx))
+		assert.Equal(t, 0, rollout(t, flag.ID))
+
+		_, err = service.PauseRollout(ctx, flag.ID, "test_user", "too late")
+		assert.ErrorIs(t, err, ErrRolloutScheduleFinished)
+	})
+
+	t.Run("abort keeps the percentage reached", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "rollout_abort", entity.FlagEnabled)
+		_, err := service.ScheduleRollout(ctx, flag.ID, canary, "test_user")
+		require.NoError(t, err)
+		now = now.Add(hour)
+		require.NoError(t, service.ProcessRolloutSchedules(ctx))
+
+		_, err = service.AbortRollout(ctx, flag.ID, "test_user", "holding at this share")
+		require.NoError(t, err)
+		now = now.Add(hour)
+		require.NoError(t, service.ProcessRolloutSchedules(ctx))
+		assert.Equal(t, 45, rollout(t, flag.ID))
+	})
+
+	t.Run("flag without a schedule", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "rollout_none", entity.FlagEnabled)
+		_, err := service.PauseRollout(ctx, flag.ID, "test_user", "pause")
+		assert.ErrorIs(t, err, ErrRolloutScheduleNotFound)
+	})
+}
+
+func TestFlagService_ScheduledChanges(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	scheduleRepo := repository.NewScheduledChangeRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log, WithScheduledChangeRepository(scheduleRepo))
+	delay := 200 * time.Millisecond
+
+	schedule := func(status entity.FlagStatus, at time.Time) validator.FlagScheduleRequest {
+		return validator.FlagScheduleRequest{Status: string(status), ScheduledAt: at, Reason: "planned launch"}
+	}
+
+	t.Run("missing dependency with a scheduled enable gives an ETA", func(t *testing.T) {
+		launch := testDB.CreateTestFlag(t, "schedule_eta_launch", entity.FlagDisabled)
+		payments := testDB.CreateTestFlag(t, "schedule_eta_payments", entity.FlagDisabled)
+		checkout := testDB.CreateTestFlagWithDependencies(t, "schedule_eta_checkout", entity.FlagDisabled, []int64{launch.ID, payments.ID})
+		at := time.Now().Add(time.Hour).Truncate(time.Second)
+		_, err := service.ScheduleChange(context.Background(), launch.ID, schedule(entity.FlagEnabled, at), "planner")
+		require.NoError(t, err)
+
+		// Only one of the missing dependencies is scheduled, so there is no time to retry at
+		_, err = service.EnableFlag(context.Background(), checkout.ID, "test_user", "launch checkout")
+		var depErr DependencyError
+		require.ErrorAs(t, err, &depErr)
+		assert.Len(t, depErr.ScheduledEnables, 1)
+		assert.True(t, at.Equal(depErr.ScheduledEnables["schedule_eta_launch"]))
+		assert.Nil(t, depErr.ReadyAt)
+
+		later := at.Add(time.Hour)
+		_, err = service.ScheduleChange(context.Background(), payments.ID, schedule(entity.FlagEnabled, later), "planner")
+		require.NoError(t, err)
+
+		_, err = service.EnableFlag(context.Background(), checkout.ID, "test_user", "launch checkout")
+		require.ErrorAs(t, err, &depErr)
+		require.NotNil(t, depErr.ReadyAt)
+		assert.True(t, later.Equal(*depErr.ReadyAt))
+	})
+
+	t.Run("rejects a time in the past", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "schedule_past", entity.FlagDisabled)
+
+		_, err := service.ScheduleChange(context.Background(), flag.ID, schedule(entity.FlagEnabled, time.Now().Add(-time.Minute)), "planner")
+
+		assert.ErrorIs(t, err, ErrScheduleInPast)
+	})
+
+	t.Run("applies due changes as the scheduling actor", func(t *testing.T) {
+		enable := testDB.CreateTestFlag(t, "schedule_enable", entity.FlagDisabled)
+		disable := testDB.CreateTestFlag(t, "schedule_disable", entity.FlagEnabled)
+
+		enableChange, err := service.ScheduleChange(context.Background(), enable.ID, schedule(entity.FlagEnabled, time.Now().Add(delay)), "planner")
+		require.NoError(t, err)
+		_, err = service.ScheduleChange(context.Background(), disable.ID, schedule(entity.FlagDisabled, time.Now().Add(delay)), "planner")
+		require.NoError(t, err)
+
+		// Not due yet
+		require.NoError(t, service.ProcessScheduledChanges(context.Background()))
+		testDB.AssertFlagStatus(t, enable.ID, entity.FlagDisabled)
+
+		time.Sleep(2 * delay)
+		require.NoError(t, service.ProcessScheduledChanges(context.Background()))
+
+		testDB.AssertFlagStatus(t, enable.ID, entity.FlagEnabled)
+		testDB.AssertFlagStatus(t, disable.ID, entity.FlagDisabled)
+		testDB.AssertAuditLogExists(t, enable.ID, entity.ActionEnable, "planner")
+		testDB.AssertAuditLogExists(t, disable.ID, entity.ActionDisable, "planner")
+
+		resolved, err := scheduleRepo.GetScheduledChangeByID(context.Background(), enableChange.ID)
+		require.NoError(t, err)
+		assert.Equal(t, entity.ScheduledChangeApplied, resolved.Status)
+		assert.NotNil(t, resolved.ResolvedAt)
+	})
+
+	t.Run("enable with unmet dependencies is recorded as failed", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "schedule_dep", entity.FlagDisabled)
+		flag := testDB.CreateTestFlagWithDependencies(t, "schedule_blocked", entity.FlagDisabled, []int64{dep.ID})
+
+		change, err := service.ScheduleChange(context.Background(), flag.ID, schedule(entity.FlagEnabled, time.Now().Add(delay)), "planner")
+		require.NoError(t, err)
+
+		time.Sleep(2 * delay)
+		require.NoError(t, service.ProcessScheduledChanges(context.Background()))
+
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+		resolved, err := scheduleRepo.GetScheduledChangeByID(context.Background(), change.ID)
+		require.NoError(t, err)
+		assert.Equal(t, entity.ScheduledChangeFailed, resolved.Status)
+		assert.Contains(t, resolved.FailureReason, "schedule_dep")
+
+		// Failed changes are not retried once the dependency is enabled
+		_, err = service.EnableFlag(context.Background(), dep.ID, "test_user", "dependency ready")
+		require.NoError(t, err)
+		require.NoError(t, service.ProcessScheduledChanges(context.Background()))
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+	})
+}
+
+func TestFlagService_ExpireFlags(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+	ctx := context.Background()
+
+	createExpiring := func(name string, expiresAt time.Time) *entity.Flag {
+		flag, err := service.CreateFlag(ctx, validator.FlagCreateRequest{Name: name, ExpiresAt: &expiresAt}, "test_user")
+		require.NoError(t, err)
+		return flag
+	}
+	expireNow := func(flagID int64) {
+		_, err := testDB.DB.Exec("UPDATE flags SET expires_at = NOW() - INTERVAL '1 minute' WHERE id = $1", flagID)
+		require.NoError(t, err)
+	}
+
+	t.Run("rejects an expiry in the past", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		_, err := service.CreateFlag(ctx, validator.FlagCreateRequest{Name: "expiry_past", ExpiresAt: &past}, "test_user")
+		assert.ErrorIs(t, err, ErrExpiryInPast)
+	})
+
+	t.Run("expired flag is disabled and cascades", func(t *testing.T) {
+		base := createExpiring("expiry_base", time.Now().Add(time.Hour))
+		_, err := service.EnableFlag(ctx, base.ID, "test_user", "launch")
+		require.NoError(t, err)
+		dependent := testDB.CreateTestFlagWithDependencies(t, "expiry_dependent", entity.FlagEnabled, []int64{base.ID})
+		notYet := createExpiring("expiry_not_yet", time.Now().Add(time.Hour))
+		_, err = service.EnableFlag(ctx, notYet.ID, "test_user", "launch")
+		require.NoError(t, err)
+
+		expireNow(base.ID)
+		require.NoError(t, service.ExpireFlags(ctx))
+
+		testDB.AssertFlagStatus(t, base.ID, entity.FlagDisabled)
+		testDB.AssertFlagStatus(t, dependent.ID, entity.FlagDisabled)
+		testDB.AssertFlagStatus(t, notYet.ID, entity.FlagEnabled)
+		testDB.AssertAuditLogExists(t, base.ID, entity.ActionExpire, "system")
+		testDB.AssertAuditLogExists(t, dependent.ID, entity.ActionCascadeDisable, "system")
+
+		// The flag is kept, so a later sweep leaves it alone
+		require.NoError(t, service.ExpireFlags(ctx))
+		_, err = service.GetFlag(ctx, base.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("filter by expiring before", func(t *testing.T) {
+		soon := createExpiring("expiry_soon", time.Now().Add(time.Hour))
+		createExpiring("expiry_later", time.Now().Add(48*time.Hour))
+		testDB.CreateTestFlag(t, "expiry_never", entity.FlagDisabled)
+
+		before := time.Now().Add(24 * time.Hour)
+		flags, total, err := service.ListFlagsPaginated(ctx, entity.FlagFilter{ExpiringBefore: &before}, 50, 0)
+
+		require.NoError(t, err)
+		names := make([]string, len(flags))
+		for i, flag := range flags {
+			names[i] = flag.Name
+		}
+		assert.Contains(t, names, soon.Name)
+		assert.NotContains(t, names, "expiry_later")
+		assert.NotContains(t, names, "expiry_never")
+		assert.Equal(t, len(flags), total)
+	})
+}
+
+func TestFlagService_FlagActors(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+	ctx := context.Background()
+
+	assertActors := func(t *testing.T, flagID int64, createdBy, updatedBy string) {
+		flag, err := flagRepo.GetFlagByID(ctx, flagID)
+		require.NoError(t, err)
+		assert.Equal(t, createdBy, flag.CreatedBy)
+		assert.Equal(t, updatedBy, flag.UpdatedBy)
+	}
+
+	t.Run("creator is recorded on both columns", func(t *testing.T) {
+		flag, err := service.CreateFlag(ctx, validator.FlagCreateRequest{Name: "actors_create"}, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, "alice", flag.CreatedBy)
+		assertActors(t, flag.ID, "alice", "alice")
+	})
+
+	t.Run("status changes record their actor", func(t *testing.T) {
+		flag, err := service.CreateFlag(ctx, validator.FlagCreateRequest{Name: "actors_toggle"}, "alice")
+		require.NoError(t, err)
+
+		_, err = service.EnableFlag(ctx, flag.ID, "bob", "launch")
+		require.NoError(t, err)
+		assertActors(t, flag.ID, "alice", "bob")
+
+		_, err = service.DisableFlag(ctx, flag.ID, "carol", "rollback")
+		require.NoError(t, err)
+		assertActors(t, flag.ID, "alice", "carol")
+	})
+
+	t.Run("cascaded dependents are updated by system", func(t *testing.T) {
+		base, err := service.CreateFlag(ctx, validator.FlagCreateRequest{Name: "actors_base"}, "alice")
+		require.NoError(t, err)
+		_, err = service.EnableFlag(ctx, base.ID, "alice", "launch")
+		require.NoError(t, err)
+		dependent, err := service.CreateFlag(ctx, validator.FlagCreateRequest{Name: "actors_dependent", Dependencies: []int64{base.ID}}, "alice")
+		require.NoError(t, err)
+		_, err = service.EnableFlag(ctx, dependent.ID, "alice", "launch")
+		require.NoError(t, err)
+
+		_, err = service.DisableFlag(ctx, base.ID, "bob", "incident")
+		require.NoError(t, err)
+		assertActors(t, base.ID, "alice", "bob")
+		assertActors(t, dependent.ID, "alice", "system")
+	})
+
+	t.Run("lock changes record their actor", func(t *testing.T) {
+		flag, err := service.CreateFlag(ctx, validator.FlagCreateRequest{Name: "actors_lock"}, "alice")
+		require.NoError(t, err)
+		require.NoError(t, service.LockFlag(ctx, flag.ID, "dave", "freeze"))
+		assertActors(t, flag.ID, "alice", "dave")
+	})
+}
+
+func TestFlagService_ListFlappyFlags(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	ctx := context.Background()
+	flaky := testDB.CreateTestFlag(t, "flaky_flag", entity.FlagDisabled)
+	stable := testDB.CreateTestFlag(t, "stable_flag", entity.FlagDisabled)
+
+	for i := 0; i < 3; i++ {
+		_, err := service.EnableFlag(ctx, flaky.ID, "test_user", "flip on")
+		require.NoError(t, err)
+		_, err = service.DisableFlag(ctx, flaky.ID, "test_user", "flip off")
+		require.NoError(t, err)
+	}
+	_, err := service.EnableFlag(ctx, stable.ID, "test_user", "launch")
+	require.NoError(t, err)
+
+	t.Run("only flags above the threshold are returned", func(t *testing.T) {
+		flags, err := service.ListFlappyFlags(ctx, 7, 5)
+
+		require.NoError(t, err)
+		require.Len(t, flags, 1)
+		assert.Equal(t, flaky.ID, flags[0].ID)
+		assert.Equal(t, 6, flags[0].ToggleCount)
+	})
+
+	t.Run("results are ordered by toggle count", func(t *testing.T) {
+		flags, err := service.ListFlappyFlags(ctx, 7, 1)
+
+		require.NoError(t, err)
+		require.Len(t, flags, 2)
+		assert.Equal(t, "flaky_flag", flags[0].Name)
+		assert.Equal(t, "stable_flag", flags[1].Name)
+		assert.Equal(t, 1, flags[1].ToggleCount)
+	})
+
+	t.Run("invalid parameters are rejected", func(t *testing.T) {
+		_, err := service.ListFlappyFlags(ctx, 0, 5)
+		assert.ErrorIs(t, err, ErrInvalidFlappinessQuery)
+
+		_, err = service.ListFlappyFlags(ctx, 7, 0)
+		assert.ErrorIs(t, err, ErrInvalidFlappinessQuery)
+	})
+}
+
+func TestFlagService_ListUnusedFlags(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	ctx := context.Background()
+	backdate := func(t *testing.T, flagID int64, days int) {
+		_, err := testDB.DB.Exec("UPDATE flags SET created_at = NOW() - make_interval(days => $2) WHERE id = $1", flagID, days)
+		require.NoError(t, err)
+	}
+
+	used := testDB.CreateTestFlag(t, "unused_check_used", entity.FlagEnabled)
+	neverEvaluated := testDB.CreateTestFlag(t, "unused_check_never", entity.FlagEnabled)
+	stale := testDB.CreateTestFlag(t, "unused_check_stale", entity.FlagEnabled)
+	recent := testDB.CreateTestFlag(t, "unused_check_recent", entity.FlagEnabled)
+	backdate(t, used.ID, 200)
+	backdate(t, neverEvaluated.ID, 200)
+	backdate(t, stale.ID, 200)
+
+	_, err := service.IsFlagEnabled(ctx, used.Name)
+	require.NoError(t, err)
+	require.NoError(t, service.FlushEvaluations(ctx))
+	_, err = testDB.DB.Exec("INSERT INTO flag_evaluations (flag_id, last_evaluated_at) VALUES ($1, NOW() - INTERVAL '120 days')", stale.ID)
+	require.NoError(t, err)
+
+	t.Run("flush records last evaluation", func(t *testing.T) {
+		flag, err := service.GetFlag(ctx, used.ID)
+		require.NoError(t, err)
+		require.NotNil(t, flag.LastEvaluatedAt)
+		assert.WithinDuration(t, time.Now(), *flag.LastEvaluatedAt, time.Minute)
+	})
+
+	t.Run("unused flags in window", func(t *testing.T) {
+		flags, err := service.ListUnusedFlags(ctx, 90)
+		require.NoError(t, err)
+
+		var names []string
+		for _, flag := range flags {
+			names = append(names, flag.Name)
+		}
+		// recent was created inside the window, so it has not had the chance to be used
+		assert.Equal(t, []string{neverEvaluated.Name, stale.Name}, names)
+		assert.NotContains(t, names, recent.Name)
+	})
+
+	t.Run("invalid window", func(t *testing.T) {
+		_, err := service.ListUnusedFlags(ctx, 0)
+		assert.ErrorIs(t, err, ErrInvalidUnusedWindow)
+	})
+}
+
+func TestFlagService_GetFlagStats(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+	ctx := context.Background()
+
+	// base <- middle <- top, plus base <- side, and a standalone flag
+	base := testDB.CreateTestFlag(t, "stats_base", entity.FlagEnabled)
+	middle := testDB.CreateTestFlagWithDependencies(t, "stats_middle", entity.FlagEnabled, []int64{base.ID})
+	testDB.CreateTestFlagWithDependencies(t, "stats_top", entity.FlagDisabled, []int64{middle.ID})
+	testDB.CreateTestFlagWithDependencies(t, "stats_side", entity.FlagMaintenance, []int64{base.ID})
+	testDB.CreateTestFlag(t, "stats_standalone", entity.FlagDisabled)
+	archived := testDB.CreateTestFlag(t, "stats_archived", entity.FlagDisabled)
+	require.NoError(t, service.ArchiveFlag(ctx, archived.ID, "test_user", "Retired"))
+
+	stats, err := service.GetFlagStats(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, stats.Total)
+	assert.Equal(t, 2, stats.Enabled)
+	assert.Equal(t, 2, stats.Disabled)
+	assert.Equal(t, 1, stats.Maintenance)
+	assert.Equal(t, 3, stats.WithDependencies)
+	assert.Equal(t, 2, stats.MaxDependencyDepth)
+	// top, side and standalone
+	assert.Equal(t, 3, stats.LeafFlags)
+	assert.WithinDuration(t, time.Now(), stats.GeneratedAt, time.Minute)
+}
+
+func TestFlagService_RestoreCascade(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log,
+		WithCascadeEventRepository(repository.NewCascadeEventRepository(testDB.DB)))
+
+	ctx := context.Background()
+
+	t.Run("disable then restore round trip", func(t *testing.T) {
+		base := testDB.CreateTestFlag(t, "restore_base", entity.FlagEnabled)
+		mid := testDB.CreateTestFlagWithDependencies(t, "restore_mid", entity.FlagEnabled, []int64{base.ID})
+		leaf := testDB.CreateTestFlagWithDependencies(t, "restore_leaf", entity.FlagEnabled, []int64{mid.ID})
+		// Disabled by an operator before the cascade, so it must stay disabled
+		manual := testDB.CreateTestFlagWithDependencies(t, "restore_manual", entity.FlagDisabled, []int64{base.ID})
+
+		_, err := service.DisableFlag(ctx, base.ID, "test_user", "outage")
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, mid.ID, entity.FlagDisabled)
+		testDB.AssertFlagStatus(t, leaf.ID, entity.FlagDisabled)
+		_, err = service.EnableFlag(ctx, base.ID, "test_user", "outage over")
+		require.NoError(t, err)
+
+		result, err := service.RestoreCascade(ctx, base.ID, "test_user", "restore after outage")
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"restore_mid", "restore_leaf"}, result.Restored)
+		assert.Empty(t, result.Skipped)
+		testDB.AssertFlagStatus(t, mid.ID, entity.FlagEnabled)
+		testDB.AssertFlagStatus(t, leaf.ID, entity.FlagEnabled)
+		testDB.AssertFlagStatus(t, manual.ID, entity.FlagDisabled)
+		testDB.AssertAuditLogExists(t, leaf.ID, entity.ActionEnable, "test_user")
+
+		_, err = service.RestoreCascade(ctx, base.ID, "test_user", "restore again")
+		assert.ErrorIs(t, err, ErrCascadeAlreadyRestored)
+	})
+
+	t.Run("flags with unsatisfied dependencies are skipped", func(t *testing.T) {
+		base := testDB.CreateTestFlag(t, "restore_skip_base", entity.FlagEnabled)
+		dependent := testDB.CreateTestFlagWithDependencies(t, "restore_skip_dependent", entity.FlagEnabled, []int64{base.ID})
+
+		_, err := service.DisableFlag(ctx, base.ID, "test_user", "outage")
+		require.NoError(t, err)
+
+		result, err := service.RestoreCascade(ctx, base.ID, "test_user", "restore too early")
+
+		require.NoError(t, err)
+		assert.Empty(t, result.Restored)
+		require.Len(t, result.Skipped, 1)
+		assert.Equal(t, dependent.Name, result.Skipped[0].Name)
+		testDB.AssertFlagStatus(t, dependent.ID, entity.FlagDisabled)
+	})
+
+	t.Run("flag without cascade", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "restore_none", entity.FlagEnabled)
+
+		_, err := service.RestoreCascade(ctx, flag.ID, "test_user", "nothing to restore")
+		assert.ErrorIs(t, err, ErrCascadeNotFound)
+	})
+}
+
+func TestFlagService_RestoreSubtree(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+	ctx := context.Background()
+	restore := func(flagID int64) (*entity.SubtreeRestoreResult, error) {
+		return service.RestoreSubtree(ctx, flagID, validator.FlagRestoreSubtreeRequest{Reason: "incident resolved"}, "test_user")
+	}
+
+	t.Run("restores cascade-disabled dependents in dependency order", func(t *testing.T) {
+		base := testDB.CreateTestFlag(t, "subtree_base", entity.FlagEnabled)
+		mid := testDB.CreateTestFlagWithDependencies(t, "subtree_mid", entity.FlagEnabled, []int64{base.ID})
+		leaf := testDB.CreateTestFlagWithDependencies(t, "subtree_leaf", entity.FlagEnabled, []int64{mid.ID, base.ID})
+		// Turned off by an operator before the incident, so it must stay off
+		manual := testDB.CreateTestFlagWithDependencies(t, "subtree_manual", entity.FlagEnabled, []int64{base.ID})
+		_, err := service.DisableFlag(ctx, manual.ID, "test_user", "not ready")
+		require.NoError(t, err)
+
+		_, err = service.DisableFlag(ctx, base.ID, "test_user", "incident")
+		require.NoError(t, err)
+		testDB.AssertFlagStatus(t, leaf.ID, entity.FlagDisabled)
+
+		result, err := restore(base.ID)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"subtree_base", "subtree_mid", "subtree_leaf"}, result.Restored)
+		require.Len(t, result.Skipped, 1)
+		assert.Equal(t, entity.SkippedFlag{Name: manual.Name, Reason: "last disabled by disable"}, result.Skipped[0])
+		testDB.AssertFlagStatus(t, leaf.ID, entity.FlagEnabled)
+		testDB.AssertFlagStatus(t, manual.ID, entity.FlagDisabled)
+
+		logs, err := auditRepo.ListAuditLogsByFlagIDFiltered(ctx, leaf.ID, entity.AuditFilter{Action: entity.ActionEnable})
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		assert.Equal(t, "incident resolved (restored with the subtree of subtree_base)", logs[0].Reason)
+	})
+
+	t.Run("root that cannot be enabled changes nothing", func(t *testing.T) {
+		dep := testDB.CreateTestFlag(t, "subtree_blocked_dep", entity.FlagDisabled)
+		root := testDB.CreateTestFlagWithDependencies(t, "subtree_blocked_root", entity.FlagDisabled, []int64{dep.ID})
+
+		_, err := restore(root.ID)
+
+		var depErr DependencyError
+		assert.ErrorAs(t, err, &depErr)
+		testDB.AssertFlagStatus(t, root.ID, entity.FlagDisabled)
+	})
+}
+
+func TestFlagService_ScanDependencyDrift(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	ctx := context.Background()
+
+	// introduceDrift disables a flag behind the service's back, as an out-of-band edit would
+	introduceDrift := func(t *testing.T, flagID int64) {
+		_, err := testDB.DB.Exec("UPDATE flags SET status = 'disabled' WHERE id = $1", flagID)
+		require.NoError(t, err)
+	}
+
+	setStatus := func(t *testing.T, flagID int64, status entity.FlagStatus) {
+		flag, err := flagRepo.GetFlagByID(ctx, flagID)
+		require.NoError(t, err)
+		require.NoError(t, flagRepo.UpdateFlagStatus(ctx, flagID, status, flag.Version, "test"))
+	}
+
+	countDriftAudits := func(t *testing.T, flagID int64) int {
+		logs, err := auditRepo.ListAuditLogsByFlagID(ctx, flagID)
+		require.NoError(t, err)
+		count := 0
+		for _, log := range logs {
+			if log.Action == entity.ActionDriftDetected {
+				count++
+			}
+		}
+		return count
+	}
+
+	t.Run("drift is reported once", func(t *testing.T) {
+		service := NewFlagService(flagRepo, auditRepo, log)
+
+		base := testDB.CreateTestFlag(t, "drift_base", entity.FlagEnabled)
+		dependent := testDB.CreateTestFlagWithDependencies(t, "drift_dependent", entity.FlagEnabled, []int64{base.ID})
+		introduceDrift(t, base.ID)
+
+		require.NoError(t, service.ScanDependencyDrift(ctx))
+		require.NoError(t, service.ScanDependencyDrift(ctx))
+
+		assert.Equal(t, 1, countDriftAudits(t, dependent.ID))
+		testDB.AssertAuditLogExists(t, dependent.ID, entity.ActionDriftDetected, "system")
+		// Without auto-correct the flag is left alone
+		testDB.AssertFlagStatus(t, dependent.ID, entity.FlagEnabled)
+
+		// Once fixed and broken again, the drift is reported anew
+		setStatus(t, base.ID, entity.FlagEnabled)
+		require.NoError(t, service.ScanDependencyDrift(ctx))
+		introduceDrift(t, base.ID)
+		require.NoError(t, service.ScanDependencyDrift(ctx))
+
+		assert.Equal(t, 2, countDriftAudits(t, dependent.ID))
+		setStatus(t, dependent.ID, entity.FlagDisabled)
+	})
+
+	t.Run("archived flags are skipped", func(t *testing.T) {
+		service := NewFlagService(flagRepo, auditRepo, log)
+
+		base := testDB.CreateTestFlag(t, "drift_archived_base", entity.FlagEnabled)
+		archived := testDB.CreateTestFlagWithDependencies(t, "drift_archived", entity.FlagEnabled, []int64{base.ID})
+		require.NoError(t, flagRepo.SetFlagArchived(ctx, archived.ID, true, "test"))
+		introduceDrift(t, base.ID)
+
+		require.NoError(t, service.ScanDependencyDrift(ctx))
+
+		assert.Equal(t, 0, countDriftAudits(t, archived.ID))
+	})
+
+	t.Run("auto-correct cascades the drifted flag", func(t *testing.T) {
+		service := NewFlagService(flagRepo, auditRepo, log, WithDriftAutoCorrect(true))
+
+		base := testDB.CreateTestFlag(t, "drift_fix_base", entity.FlagEnabled)
+		mid := testDB.CreateTestFlagWithDependencies(t, "drift_fix_mid", entity.FlagEnabled, []int64{base.ID})
+		leaf := testDB.CreateTestFlagWithDependencies(t, "drift_fix_leaf", entity.FlagEnabled, []int64{mid.ID})
+		introduceDrift(t, base.ID)
+
+		require.NoError(t, service.ScanDependencyDrift(ctx))
+
+		testDB.AssertAuditLogExists(t, mid.ID, entity.ActionDriftDetected, "system")
+		testDB.AssertFlagStatus(t, mid.ID, entity.FlagDisabled)
+		testDB.AssertAuditLogExists(t, mid.ID, entity.ActionCascadeDisable, "system")
+		testDB.AssertFlagStatus(t, leaf.ID, entity.FlagDisabled)
+
+		drifts, err := flagRepo.ListDependencyDrift(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, drifts)
+	})
+}
+
+func TestFlagService_GetClosureSize(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+	ctx := context.Background()
+
+	// base <- mid <- top <- leaf, and side <- base
+	base := testDB.CreateTestFlag(t, "closure_base", entity.FlagEnabled)
+	mid := testDB.CreateTestFlagWithDependencies(t, "closure_mid", entity.FlagEnabled, []int64{base.ID})
+	side := testDB.CreateTestFlagWithDependencies(t, "closure_side", entity.FlagEnabled, []int64{base.ID})
+	top := testDB.CreateTestFlagWithDependencies(t, "closure_top", entity.FlagEnabled, []int64{mid.ID})
+	leaf := testDB.CreateTestFlagWithDependencies(t, "closure_leaf", entity.FlagEnabled, []int64{top.ID, side.ID})
+
+	t.Run("middle of the graph", func(t *testing.T) {
+		closure, err := service.GetClosureSize(ctx, mid.ID)
+
+		require.NoError(t, err)
+		assert.Equal(t, entity.ClosureStats{Count: 1, MaxDepth: 1}, closure.Dependencies)
+		assert.Equal(t, entity.ClosureStats{Count: 2, MaxDepth: 2}, closure.Dependents)
+	})
+
+	t.Run("root and leaf", func(t *testing.T) {
+		closure, err := service.GetClosureSize(ctx, base.ID)
+		require.NoError(t, err)
+		assert.Equal(t, entity.ClosureStats{}, closure.Dependencies)
+		assert.Equal(t, entity.ClosureStats{Count: 4, MaxDepth: 3}, closure.Dependents)
+
+		// Depth is the longest path: base is two hops away via side, three via top and mid
+		closure, err = service.GetClosureSize(ctx, leaf.ID)
+		require.NoError(t, err)
+		assert.Equal(t, entity.ClosureStats{Count: 4, MaxDepth: 3}, closure.Dependencies)
+		assert.Equal(t, entity.ClosureStats{}, closure.Dependents)
+	})
+
+	t.Run("node limit truncates", func(t *testing.T) {
+		limited := NewFlagService(flagRepo, auditRepo, log, WithGraphNodeLimit(2))
+
+		closure, err := limited.GetClosureSize(ctx, base.ID)
+
+		require.NoError(t, err)
+		assert.True(t, closure.Dependents.Truncated)
+		assert.False(t, closure.Dependencies.Truncated)
+	})
+
+	t.Run("cycle terminates", func(t *testing.T) {
+		a := testDB.CreateTestFlag(t, "closure_cycle_a", entity.FlagDisabled)
+		b := testDB.CreateTestFlagWithDependencies(t, "closure_cycle_b", entity.FlagDisabled, []int64{a.ID})
+		// Inserted directly, as the service refuses to create cycles
+		_, err := testDB.DB.Exec("INSERT INTO flag_dependencies (flag_id, depends_on_id) VALUES ($1, $2)", a.ID, b.ID)
+		require.NoError(t, err)
+
+		closure, err := service.GetClosureSize(ctx, a.ID)
+
+		require.NoError(t, err)
+		assert.Equal(t, entity.ClosureStats{Count: 1, MaxDepth: 1}, closure.Dependencies)
+		assert.Equal(t, entity.ClosureStats{Count: 1, MaxDepth: 1}, closure.Dependents)
+	})
+
+	t.Run("unknown flag", func(t *testing.T) {
+		_, err := service.GetClosureSize(ctx, 99999)
+		assert.ErrorIs(t, err, ErrFlagNotFound)
+	})
+}
+
+// noFullScanRepository fails any query that loads every flag or every dependency edge
+type noFullScanRepository struct {
+	repository.FlagRepository
+}
+
+var errFullScan = errors.New("full scan not allowed")
+
+func (r *noFullScanRepository) ListFlags(ctx context.Context) ([]*entity.Flag, error) {
+	return nil, errFullScan
+}
+
+func (r *noFullScanRepository) ListDependencyEdges(ctx context.Context) ([]*entity.DependencyEdge, error) {
+	return nil, errFullScan
+}
+
+func TestFlagService_EvaluateFlags(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+	ctx := context.Background()
+
+	base := testDB.CreateTestFlag(t, "eval_batch_base", entity.FlagDisabled)
+	testDB.CreateTestFlagWithDependencies(t, "eval_batch_checkout", entity.FlagEnabled, []int64{base.ID})
+	search := testDB.CreateTestFlag(t, "eval_batch_search", entity.FlagEnabled)
+	testDB.CreateTestFlagWithDependencies(t, "eval_batch_ranking", entity.FlagEnabled, []int64{search.ID})
+
+	t.Run("selected flags by name and ID", func(t *testing.T) {
+		req := validator.FlagEvaluateRequest{
+			Key:   "user-42",
+			Flags: []validator.FlagRef{"eval_batch_checkout", validator.FlagRef(fmt.Sprint(search.ID)), "eval_batch_ranking", "no_such_flag"},
+		}
+
+		result, err := service.EvaluateFlags(ctx, req)
+
+		require.NoError(t, err)
+		assert.Equal(t, "user-42", result.Key)
+		assert.Equal(t, map[string]bool{
+			"eval_batch_checkout": false, // its dependency is disabled
+			"eval_batch_search":   true,
+			"eval_batch_ranking":  true,
+		}, result.Flags)
+		assert.Equal(t, []string{"no_such_flag"}, result.Unknown)
+
+		// Same key, same flags, same answer
+		again, err := service.EvaluateFlags(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, result, again)
+	})
+
+	t.Run("selected flags load only their dependency closure", func(t *testing.T) {
+		scoped := NewFlagService(&noFullScanRepository{FlagRepository: flagRepo}, auditRepo, log)
+
+		result, err := scoped.EvaluateFlags(ctx, validator.FlagEvaluateRequest{Flags: []validator.FlagRef{"eval_batch_checkout", "eval_batch_search"}})
+
+		require.NoError(t, err)
+		assert.Equal(t, map[string]bool{"eval_batch_checkout": false, "eval_batch_search": true}, result.Flags)
+	})
+
+	t.Run("user_id is another name for key", func(t *testing.T) {
+		byKey, err := service.EvaluateFlags(ctx, validator.FlagEvaluateRequest{Key: "user-42"})
+		require.NoError(t, err)
+		byUserID, err := service.EvaluateFlags(ctx, validator.FlagEvaluateRequest{UserID: "user-42"})
+		require.NoError(t, err)
+		assert.Equal(t, byKey, byUserID)
+	})
+
+	t.Run("omitted flags evaluates all", func(t *testing.T) {
+		result, err := service.EvaluateFlags(ctx, validator.FlagEvaluateRequest{Key: "user-42"})
+
+		require.NoError(t, err)
+		assert.Len(t, result.Flags, 4)
+		assert.False(t, result.Flags["eval_batch_base"])
+		assert.Empty(t, result.Unknown)
+	})
+
+	t.Run("flags on a cycle are disabled", func(t *testing.T) {
+		a := testDB.CreateTestFlag(t, "eval_cycle_a", entity.FlagEnabled)
+		b := testDB.CreateTestFlagWithDependencies(t, "eval_cycle_b", entity.FlagEnabled, []int64{a.ID})
+		_, err := testDB.DB.Exec("INSERT INTO flag_dependencies (flag_id, depends_on_id) VALUES ($1, $2)", a.ID, b.ID)
+		require.NoError(t, err)
+
+		result, err := service.EvaluateFlags(ctx, validator.FlagEvaluateRequest{Flags: []validator.FlagRef{"eval_cycle_a", "eval_cycle_b"}})
+
+		require.NoError(t, err)
+		assert.False(t, result.Flags["eval_cycle_a"])
+		assert.False(t, result.Flags["eval_cycle_b"])
+	})
+}
+
+func TestFlagService_CancelledContext(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+
+	base := testDB.CreateTestFlag(t, "cancel_base", entity.FlagEnabled)
+	testDB.CreateTestFlagWithDependencies(t, "cancel_dependent", entity.FlagEnabled, []int64{base.ID})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	t.Run("disable returns the context error", func(t *testing.T) {
+		start := time.Now()
+		_, err := service.DisableFlag(ctx, base.ID, "test_user", "")
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Less(t, time.Since(start), time.Second)
+		testDB.AssertFlagStatus(t, base.ID, entity.FlagEnabled)
+	})
+
+	t.Run("list returns the context error", func(t *testing.T) {
+		_, _, err := service.ListFlagsPaginated(ctx, entity.FlagFilter{}, DefaultFlagPageSize, 0)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestFlagService_EvaluateFlag(t *testing.T) {
+	testDB := test.SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := test.GetTestLogger()
+	service := NewFlagService(flagRepo, auditRepo, log)
+	ctx := context.Background()
+
+	// users whose bucket for a flag is inside and outside a 50% rollout
+	splitUsers := func(t *testing.T, flagName string) (in, out string) {
+		for i := 0; in == "" || out == ""; i++ {
+			user := fmt.Sprintf("user-%d", i)
+			if rolloutBucket(flagName, user) < 50 {
+				in = user
+			} else {
+				out = user
+			}
+		}
+		return in, out
+	}
+
+	t.Run("rollout decides for an enabled flag", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "rollout_half", entity.FlagEnabled)
+		require.NoError(t, flagRepo.UpdateFlagRollout(ctx, flag.ID, 50, "test"))
+		in, out := splitUsers(t, flag.Name)
+
+		evaluation, err := service.EvaluateFlag(ctx, flag.Name, in)
+		require.NoError(t, err)
+		assert.True(t, evaluation.Enabled)
+
+		evaluation, err = service.EvaluateFlag(ctx, fmt.Sprint(flag.ID), out)
+		require.NoError(t, err)
+		assert.False(t, evaluation.Enabled)
+
+		// The answer is stable across calls
+		for i := 0; i < 5; i++ {
+			again, err := service.EvaluateFlag(ctx, flag.Name, in)
+			require.NoError(t, err)
+			assert.True(t, again.Enabled)
+		}
+	})
+
+	t.Run("disabled flag is off at 100 percent", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "rollout_disabled", entity.FlagDisabled)
+
+		evaluation, err := service.EvaluateFlag(ctx, flag.Name, "user-1")
+		require.NoError(t, err)
+		assert.False(t, evaluation.Enabled)
+	})
+
+	t.Run("disabled transitive dependency turns the flag off", func(t *testing.T) {
+		// Stored enabled behind a disabled dependency, as a partial cascade could leave it
+		base := testDB.CreateTestFlag(t, "rollout_base", entity.FlagDisabled)
+		middle := testDB.CreateTestFlagWithDependencies(t, "rollout_middle", entity.FlagEnabled, []int64{base.ID})
+		flag := testDB.CreateTestFlagWithDependencies(t, "rollout_gated", entity.FlagEnabled, []int64{middle.ID})
+
+		evaluation, err := service.EvaluateFlag(ctx, flag.Name, "")
+		require.NoError(t, err)
+		assert.Equal(t, &entity.FlagEvaluation{Name: "rollout_gated", Enabled: false}, evaluation)
+
+		evaluation, err = service.EvaluateFlag(ctx, flag.Name, "user-1")
+		require.NoError(t, err)
+		assert.False(t, evaluation.Enabled)
+	})
+
+	t.Run("rollout is set on create and update", func(t *testing.T) {
+		zero := 0
+		flag, err := service.CreateFlag(ctx, validator.FlagCreateRequest{Name: "rollout_created", RolloutPercentage: &zero}, "test_user")
+		require.NoError(t, err)
+		assert.Equal(t, 0, flag.RolloutPercentage)
+
+		full := entity.FullRollout
+		updated, err := service.UpdateFlag(ctx, flag.ID, validator.FlagUpdateRequest{RolloutPercentage: &full}, "test_user")
+		require.NoError(t, err)
+		assert.Equal(t, entity.FullRollout, updated.RolloutPercentage)
+		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionUpdate, "test_user")
+
+		tooHigh := 101
+		_, err = service.UpdateFlag(ctx, flag.ID, validator.FlagUpdateRequest{RolloutPercentage: &tooHigh}, "test_user")
+		var validationErrs validator.ValidationErrors
+		require.ErrorAs(t, err, &validationErrs)
+		assert.Equal(t, "Must be at most 100", validationErrs.Errors[0].Message)
+	})
+
+	t.Run("without a user the rollout is not applied", func(t *testing.T) {
+		evaluation, err := service.EvaluateFlag(ctx, "rollout_half", "")
+		require.NoError(t, err)
+		assert.Equal(t, &entity.FlagEvaluation{Name: "rollout_half", Enabled: true}, evaluation)
+	})
+
+	t.Run("unknown flag", func(t *testing.T) {
+		_, err := service.EvaluateFlag(ctx, "no_such_flag", "")
+		assert.ErrorIs(t, err, ErrFlagNotFound)
+	})
+}
+
+func TestFlagService_Policies(t *testing.T) {
+	ctx := context.Background()
+	log := test.GetTestLogger()
+
+	namePolicy, err := validator.NewNamePolicy(`[a-z]+_v[0-9]+`)
+	require.NoError(t, err)
+	reasonPolicy, err := validator.NewReasonPolicy(map[string]int{"delete": 10}, "")
+	require.NoError(t, err)
+
+	// Every check below fails before the repositories are reached, so none are needed
+	strict := NewFlagService(nil, nil, log,
+		WithActorPolicy(validator.NewActorPolicy([]string{"alice"}, nil)),
+		WithNamePolicy(namePolicy),
+		WithReasonPolicy(reasonPolicy),
+	)
+
+	t.Run("actor policy", func(t *testing.T) {
+		_, err := strict.CreateFlag(ctx, validator.FlagCreateRequest{Name: "checkout_v2"}, "mallory")
+		assert.ErrorIs(t, err, validator.ErrActorNotAllowed)
+	})
+
+	t.Run("name policy", func(t *testing.T) {
+		_, err := strict.CreateFlag(ctx, validator.FlagCreateRequest{Name: "Checkout"}, "alice")
+		var validationErrs validator.ValidationErrors
+		require.ErrorAs(t, err, &validationErrs)
+		assert.Equal(t, "Name", validationErrs.Errors[0].Field)
+
+		_, err = strict.RenameFlag(ctx, 1, validator.FlagRenameRequest{Name: "Checkout"}, "alice")
+		assert.ErrorAs(t, err, &validationErrs)
+	})
+
+	t.Run("reason policy", func(t *testing.T) {
+		err := strict.DeleteFlag(ctx, 1, "alice", "cleanup")
+		var validationErrs validator.ValidationErrors
+		require.ErrorAs(t, err, &validationErrs)
+		assert.Equal(t, "Reason", validationErrs.Errors[0].Field)
+	})
+
+	t.Run("policies do not leak between services", func(t *testing.T) {
+		lenient := NewFlagService(nil, nil, log).(*flagService)
+
+		assert.NoError(t, lenient.validateActor("mallory"))
+		assert.NoError(t, lenient.validateFlagCreateRequest(validator.FlagCreateRequest{Name: "Checkout"}))
+		assert.NoError(t, lenient.reasonPolicy.Check("delete", "cleanup"))
+	})
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"featureflags/entity"
+	"featureflags/validator"
+)
+
+// maxSyncExportSize caps how much of a sync source's export is read
+const maxSyncExportSize = 32 << 20
+
+// SyncFlags fetches the flag export of another instance and applies it like ImportFlags, in
+// one transaction. Without req.Overwrite, flags that already exist here are skipped.
+func (s *flagService) SyncFlags(ctx context.Context, req validator.FlagSyncRequest, actor string) (*entity.FlagImportResult, error) {
+	if err := validator.ValidateFlagSyncRequest(req); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+
+	document, err := s.fetchExport(ctx, req)
+	if err != nil {
+		s.log(ctx).Warnw("Sync source failed", "source", req.SourceURL, "error", err, "actor", actor)
+		return nil, err
+	}
+	if document.Version != entity.FlagExportVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedExportVersion, document.Version)
+	}
+	if len(document.Flags) == 0 {
+		return &entity.FlagImportResult{Created: []string{}, Updated: []string{}, Skipped: []entity.SkippedFlag{}}, nil
+	}
+
+	result, err := s.importDocument(ctx, *document, req.Overwrite, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log(ctx).Infow("Flags synced", "source", req.SourceURL, "created", len(result.Created),
+		"updated", len(result.Updated), "skipped", len(result.Skipped), "actor", actor)
+	return result, nil
+}
+
+// fetchExport reads the export document of the sync source. The API key is never part of
+// the returned errors.
+func (s *flagService) fetchExport(ctx context.Context, req validator.FlagSyncRequest) (*validator.FlagImportRequest, error) {
+	exportURL := strings.TrimSuffix(req.SourceURL, "/") + "/api/v1/flags/export"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, exportURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSyncSourceUnreachable, err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if req.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+req.APIKey)
+	}
+
+	resp, err := s.syncClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSyncSourceUnreachable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: export request returned %s", ErrSyncSourceInvalid, resp.Status)
+	}
+
+	var document validator.FlagImportRequest
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSyncExportSize)).Decode(&document); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSyncSourceInvalid, err)
+	}
+	return &document, nil
+}
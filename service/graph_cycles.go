@@ -0,0 +1,57 @@
+package service
+
+import (
+	"slices"
+
+	"featureflags/entity"
+)
+
+// findCycles returns the dependency cycles among edges, each as the flag IDs along the cycle
+// in dependency order (every flag depends on the next, and the last on the first). Each back
+// edge met by a depth-first walk yields one cycle, so every flag on a cycle shows up, though
+// not every distinct cycle through the same flags is listed.
+func findCycles(edges []entity.DependencyEdge) [][]int64 {
+	adjacency := make(map[int64][]int64)
+	for _, edge := range edges {
+		adjacency[edge.FlagID] = append(adjacency[edge.FlagID], edge.DependsOnID)
+	}
+	ids := make([]int64, 0, len(adjacency))
+	for id, deps := range adjacency {
+		ids = append(ids, id)
+		slices.Sort(deps)
+	}
+	slices.Sort(ids)
+
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := make(map[int64]int, len(adjacency))
+	var path []int64
+	var cycles [][]int64
+
+	var visit func(id int64)
+	visit = func(id int64) {
+		state[id] = onPath
+		path = append(path, id)
+		for _, dep := range adjacency[id] {
+			switch state[dep] {
+			case unvisited:
+				visit(dep)
+			case onPath:
+				start := slices.Index(path, dep)
+				cycles = append(cycles, slices.Clone(path[start:]))
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = done
+	}
+
+	for _, id := range ids {
+		if state[id] == unvisited {
+			visit(id)
+		}
+	}
+	return cycles
+}
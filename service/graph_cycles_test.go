@@ -0,0 +1,31 @@
+package service
+
+import (
+	"testing"
+
+	"featureflags/entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindCycles(t *testing.T) {
+	edge := func(flagID, dependsOnID int64) entity.DependencyEdge {
+		return entity.DependencyEdge{FlagID: flagID, DependsOnID: dependsOnID}
+	}
+
+	t.Run("acyclic graph has no cycles", func(t *testing.T) {
+		// Diamond: 1 -> 2, 1 -> 3, 2 -> 4, 3 -> 4
+		assert.Empty(t, findCycles([]entity.DependencyEdge{edge(1, 2), edge(1, 3), edge(2, 4), edge(3, 4)}))
+		assert.Empty(t, findCycles(nil))
+	})
+
+	t.Run("cycles are reported in dependency order", func(t *testing.T) {
+		cycles := findCycles([]entity.DependencyEdge{edge(1, 2), edge(2, 3), edge(3, 1), edge(3, 4)})
+		assert.Equal(t, [][]int64{{1, 2, 3}}, cycles)
+	})
+
+	t.Run("self dependency and separate cycles", func(t *testing.T) {
+		cycles := findCycles([]entity.DependencyEdge{edge(5, 5), edge(7, 8), edge(8, 7)})
+		assert.Equal(t, [][]int64{{5}, {7, 8}}, cycles)
+	})
+}
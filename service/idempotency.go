@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"featureflags/entity"
+	"featureflags/repository"
+	"featureflags/validator"
+)
+
+// toggleOnce runs toggle unless a toggle with the same idempotency key already ran, in which
+// case its outcome is returned instead. The toggle and the key commit together, so of two
+// concurrent requests with one key, the one that loses rolls back and returns the winner's
+// outcome. Failed toggles store nothing and may be retried with the same key.
+func (s *flagService) toggleOnce(ctx context.Context, flagID int64, env string, req validator.FlagToggleRequest, toggle func(ctx context.Context) (*entity.StatusChange, error)) (*entity.StatusChange, error) {
+	hash, err := toggleRequestHash(flagID, env, req)
+	if err != nil {
+		return nil, err
+	}
+	notBefore := time.Now().Add(-s.idempotencyTTL)
+
+	if change, err := s.replayToggle(ctx, req.IdempotencyKey, hash, notBefore); change != nil || err != nil {
+		return change, err
+	}
+
+	var change *entity.StatusChange
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		change, err = toggle(ctx)
+		if err != nil {
+			return err
+		}
+		response, err := json.Marshal(change)
+		if err != nil {
+			return fmt.Errorf("failed to encode toggle outcome: %w", err)
+		}
+		return s.idempotencyRepo.CreateIdempotencyKey(ctx, &entity.IdempotencyKey{
+			Key:         req.IdempotencyKey,
+			FlagID:      flagID,
+			RequestHash: hash,
+			Response:    response,
+		}, notBefore)
+	})
+	if err != nil {
+		// A concurrent request with the same key may have committed first
+		if replayed, replayErr := s.replayToggle(ctx, req.IdempotencyKey, hash, notBefore); replayed != nil || replayErr != nil {
+			return replayed, replayErr
+		}
+		return nil, err
+	}
+	return change, nil
+}
+
+// replayToggle returns the stored outcome of the toggle sent with key, or nil if the key is
+// unused. A key stored for a different request fails with ErrIdempotencyKeyReused.
+func (s *flagService) replayToggle(ctx context.Context, key, hash string, notBefore time.Time) (*entity.StatusChange, error) {
+	stored, err := s.idempotencyRepo.GetIdempotencyKey(ctx, key, notBefore)
+	if err != nil {
+		if errors.Is(err, repository.ErrIdempotencyKeyNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	if stored.RequestHash != hash {
+		return nil, ErrIdempotencyKeyReused
+	}
+
+	var change entity.StatusChange
+	if err := json.Unmarshal(stored.Response, &change); err != nil {
+		return nil, fmt.Errorf("failed to decode stored toggle outcome: %w", err)
+	}
+	s.log(ctx).Infow("Replayed idempotent toggle", "flagID", stored.FlagID, "key", key)
+	return &change, nil
+}
+
+// toggleRequestHash identifies a toggle by its flag, environment and body
+func toggleRequestHash(flagID int64, env string, req validator.FlagToggleRequest) (string, error) {
+	if env == "" {
+		env = entity.GlobalEnvironment
+	}
+	body, err := json.Marshal(struct {
+		FlagID      int64                       `json:"flag_id"`
+		Environment string                      `json:"environment"`
+		Request     validator.FlagToggleRequest `json:"request"`
+	}{flagID, env, req})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode toggle request: %w", err)
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// PurgeIdempotencyKeys deletes the idempotency keys that have expired
+func (s *flagService) PurgeIdempotencyKeys(ctx context.Context) error {
+	if s.idempotencyRepo == nil {
+		return nil
+	}
+	deleted, err := s.idempotencyRepo.DeleteIdempotencyKeysBefore(ctx, time.Now().Add(-s.idempotencyTTL))
+	if err != nil {
+		return fmt.Errorf("failed to purge idempotency keys: %w", err)
+	}
+	if deleted > 0 {
+		s.log(ctx).Infow("Purged expired idempotency keys", "count", deleted)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"testing"
+
+	"featureflags/validator"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToggleRequestHash(t *testing.T) {
+	hash := func(flagID int64, env string, req validator.FlagToggleRequest) string {
+		sum, err := toggleRequestHash(flagID, env, req)
+		require.NoError(t, err)
+		return sum
+	}
+	enable := validator.FlagToggleRequest{Enable: true, Reason: "launch"}
+
+	t.Run("key is not part of the request", func(t *testing.T) {
+		keyed := enable
+		keyed.IdempotencyKey = "retry-1"
+		assert.Equal(t, hash(1, "", enable), hash(1, "", keyed))
+	})
+
+	t.Run("no environment is the global one", func(t *testing.T) {
+		assert.Equal(t, hash(1, "", enable), hash(1, "global", enable))
+	})
+
+	t.Run("flag, environment and body tell requests apart", func(t *testing.T) {
+		base := hash(1, "", enable)
+		assert.NotEqual(t, base, hash(2, "", enable))
+		assert.NotEqual(t, base, hash(1, "prod", enable))
+		assert.NotEqual(t, base, hash(1, "", validator.FlagToggleRequest{Enable: false, Reason: "launch"}))
+		assert.NotEqual(t, base, hash(1, "", validator.FlagToggleRequest{Enable: true, Reason: "other"}))
+	})
+}
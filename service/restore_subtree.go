@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"featureflags/entity"
+	"featureflags/repository"
+	"featureflags/validator"
+)
+
+// RestoreSubtree enables flagID and then every transitive dependent whose last disable was a
+// cascade, in dependency order, in one transaction. Dependents an operator turned off, or
+// that expired, stay off, as do locked, archived and high-risk ones and those whose
+// dependencies are still not enabled; they are reported as skipped. A disabled high-risk root
+// needs a confirmation token, as for a toggle.
+func (s *flagService) RestoreSubtree(ctx context.Context, flagID int64, req validator.FlagRestoreSubtreeRequest, actor string) (*entity.SubtreeRestoreResult, error) {
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if err := validator.ValidateFlagRestoreSubtreeRequest(req); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+	if err := s.reasonPolicy.Check(string(entity.ActionEnable), req.Reason); err != nil {
+		return nil, err
+	}
+
+	root, err := s.GetFlag(ctx, flagID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkEnableConfirmation(ctx, flagID, req.ConfirmationToken, actor); err != nil {
+		return nil, err
+	}
+
+	result := &entity.SubtreeRestoreResult{Restored: []string{}, Skipped: []entity.SkippedFlag{}}
+	restoreReason := fmt.Sprintf("%s (restored with the subtree of %s)", req.Reason, root.Name)
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		change, err := s.enableFlag(ctx, flagID, actor, restoreReason, true)
+		if err != nil {
+			return err
+		}
+		if change.Changed {
+			result.Restored = append(result.Restored, root.Name)
+		}
+
+		dependents, err := s.collectSubtree(ctx, flagID)
+		if err != nil {
+			return err
+		}
+
+		var remaining []*entity.Flag
+		for _, flag := range dependents {
+			if flag.Status != entity.FlagDisabled {
+				continue
+			}
+			lastDisable, err := s.lastDisableAction(ctx, flag.ID)
+			if err != nil {
+				return err
+			}
+			switch {
+			case lastDisable != entity.ActionCascadeDisable:
+				reason := "not disabled by a cascade"
+				if lastDisable != "" {
+					reason = fmt.Sprintf("last disabled by %s", lastDisable)
+				}
+				result.Skipped = append(result.Skipped, entity.SkippedFlag{Name: flag.Name, Reason: reason})
+			case flag.Locked:
+				result.Skipped = append(result.Skipped, entity.SkippedFlag{Name: flag.Name, Reason: "locked"})
+			case flag.IsArchived():
+				result.Skipped = append(result.Skipped, entity.SkippedFlag{Name: flag.Name, Reason: "archived"})
+			case flag.HighRisk:
+				result.Skipped = append(result.Skipped, entity.SkippedFlag{Name: flag.Name, Reason: "high-risk flags must be enabled with confirmation"})
+			default:
+				remaining = append(remaining, flag)
+			}
+		}
+
+		// Enable whatever has its dependencies satisfied until no more progress is made,
+		// so flags are restored after the flags they depend on
+		for progress := true; progress && len(remaining) > 0; {
+			progress = false
+			var blocked []*entity.Flag
+			for _, flag := range remaining {
+				missing, err := s.getMissingActiveDependencies(ctx, flag.Dependencies)
+				if err != nil {
+					return fmt.Errorf("failed to check dependencies: %w", err)
+				}
+				if len(missing) > 0 {
+					blocked = append(blocked, flag)
+					continue
+				}
+
+				if err := s.updateStatus(ctx, flag, entity.FlagEnabled, actor); err != nil {
+					return fmt.Errorf("failed to enable flag %d: %w", flag.ID, err)
+				}
+				if err := s.recordAudit(ctx, entity.NewAuditLog(flag.ID, entity.ActionEnable, actor, restoreReason)); err != nil {
+					return fmt.Errorf("failed to create audit log: %w", err)
+				}
+				result.Restored = append(result.Restored, flag.Name)
+				progress = true
+			}
+			remaining = blocked
+		}
+
+		for _, flag := range remaining {
+			missing, err := s.getMissingActiveDependencies(ctx, flag.Dependencies)
+			if err != nil {
+				return fmt.Errorf("failed to check dependencies: %w", err)
+			}
+			result.Skipped = append(result.Skipped, entity.SkippedFlag{
+				Name:   flag.Name,
+				Reason: "dependencies not enabled: " + strings.Join(missing, ", "),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		s.log(ctx).Warnw("Failed to restore subtree", "error", err, "flagID", flagID, "actor", actor)
+		return nil, err
+	}
+
+	s.log(ctx).Infow("Subtree restored", "flagID", flagID, "restored", len(result.Restored),
+		"skipped", len(result.Skipped), "actor", actor)
+	return result, nil
+}
+
+// collectSubtree returns the transitive dependents of flagID, nearest first
+func (s *flagService) collectSubtree(ctx context.Context, flagID int64) ([]*entity.Flag, error) {
+	visited := map[int64]bool{flagID: true}
+	var ids []int64
+	for queue := []int64{flagID}; len(queue) > 0; queue = queue[1:] {
+		dependentIDs, err := s.flagRepo.GetDependents(ctx, queue[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dependents: %w", err)
+		}
+		for _, id := range dependentIDs {
+			if !visited[id] {
+				visited[id] = true
+				ids = append(ids, id)
+				queue = append(queue, id)
+			}
+		}
+	}
+
+	flags := make([]*entity.Flag, 0, len(ids))
+	for _, id := range ids {
+		flag, err := s.flagRepo.GetFlagByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrFlagNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get flag %d: %w", id, err)
+		}
+		flags = append(flags, flag)
+	}
+	return flags, nil
+}
+
+// lastDisableAction returns the action of the flag's most recent global disable, or "" if
+// none is recorded
+func (s *flagService) lastDisableAction(ctx context.Context, flagID int64) (entity.AuditAction, error) {
+	logs, err := s.auditRepo.ListAuditLogsByFlagID(ctx, flagID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get audit logs: %w", err)
+	}
+	for _, entry := range logs {
+		if entry.Environment != "" {
+			continue
+		}
+		switch entry.Action {
+		case entity.ActionDisable, entity.ActionCascadeDisable, entity.ActionExpire:
+			return entry.Action, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,21 @@
+package service
+
+import (
+	"hash/fnv"
+
+	"featureflags/entity"
+)
+
+// rolloutBucket places a user in one of 100 buckets for a flag. The bucket depends only on
+// the flag name and user ID, so a user keeps the same answer across requests and instances,
+// and raising the percentage only ever adds users.
+func rolloutBucket(flagName, userID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(flagName + userID))
+	return int(h.Sum32() % 100)
+}
+
+// inRollout reports whether the user falls within the flag's rollout percentage
+func inRollout(flag *entity.Flag, userID string) bool {
+	return rolloutBucket(flag.Name, userID) < flag.RolloutPercentage
+}
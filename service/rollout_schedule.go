@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"featureflags/entity"
+	"featureflags/repository"
+	"featureflags/validator"
+)
+
+// ScheduleRollout sets the flag's rollout percentage to req.StartPercentage and has
+// ProcessRolloutSchedules raise it by req.StepPercentage every interval until the flag is on
+// for every user. A schedule the flag already has is replaced.
+func (s *flagService) ScheduleRollout(ctx context.Context, flagID int64, req validator.FlagRolloutScheduleRequest, actor string) (*entity.RolloutSchedule, error) {
+	if s.rolloutRepo == nil {
+		return nil, ErrFeatureNotConfigured
+	}
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+	if err := validator.ValidateFlagRolloutScheduleRequest(req); err != nil {
+		return nil, err
+	}
+	if err := s.reasonPolicy.Check(string(entity.ActionUpdate), req.Reason); err != nil {
+		return nil, err
+	}
+
+	var schedule *entity.RolloutSchedule
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		flag, err := s.getRolloutFlag(ctx, flagID)
+		if err != nil {
+			return err
+		}
+
+		now := s.now()
+		schedule = entity.NewRolloutSchedule(flagID, req.StartPercentage, req.StepPercentage, req.StepIntervalMinutes, actor, now)
+		if req.StartPercentage == entity.FullRollout {
+			schedule.Stop(entity.RolloutScheduleCompleted, now)
+		}
+		if err := s.rolloutRepo.SaveRolloutSchedule(ctx, schedule); err != nil {
+			return err
+		}
+		reason := fmt.Sprintf("%s (rollout schedule: %d%% then +%d%% every %s)", req.Reason,
+			req.StartPercentage, req.StepPercentage, schedule.StepInterval())
+		return s.setRolloutPercentage(ctx, flag, req.StartPercentage, actor, reason)
+	})
+	if err != nil {
+		s.log(ctx).Errorw("Failed to schedule rollout", "error", err, "flagID", flagID, "actor", actor)
+		return nil, err
+	}
+
+	s.log(ctx).Infow("Rollout scheduled", "flagID", flagID, "start", req.StartPercentage, "step", req.StepPercentage,
+		"intervalMinutes", req.StepIntervalMinutes, "actor", actor)
+	return schedule, nil
+}
+
+// GetRolloutSchedule returns the flag's rollout schedule
+func (s *flagService) GetRolloutSchedule(ctx context.Context, flagID int64) (*entity.RolloutSchedule, error) {
+	if s.rolloutRepo == nil {
+		return nil, ErrFeatureNotConfigured
+	}
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	return s.getRolloutSchedule(ctx, flagID)
+}
+
+// PauseRollout stops the flag's rollout schedule from taking further steps until it is resumed.
+// Pausing a paused schedule changes nothing.
+func (s *flagService) PauseRollout(ctx context.Context, flagID int64, actor, reason string) (*entity.RolloutSchedule, error) {
+	return s.changeRolloutSchedule(ctx, flagID, actor, reason, func(ctx context.Context, flag *entity.Flag, schedule *entity.RolloutSchedule) (bool, error) {
+		if schedule.Status == entity.RolloutSchedulePaused {
+			return false, nil
+		}
+		schedule.Stop(entity.RolloutSchedulePaused, s.now())
+		return true, s.recordAudit(ctx, entity.NewAuditLog(flag.ID, entity.ActionUpdate, actor, "Paused rollout schedule: "+reason))
+	})
+}
+
+// ResumeRollout restarts a paused rollout schedule; its next step is one interval away.
+// Resuming an active schedule changes nothing.
+func (s *flagService) ResumeRollout(ctx context.Context, flagID int64, actor, reason string) (*entity.RolloutSchedule, error) {
+	return s.changeRolloutSchedule(ctx, flagID, actor, reason, func(ctx context.Context, flag *entity.Flag, schedule *entity.RolloutSchedule) (bool, error) {
+		if schedule.Status == entity.RolloutScheduleActive {
+			return false, nil
+		}
+		schedule.Resume(s.now())
+		return true, s.recordAudit(ctx, entity.NewAuditLog(flag.ID, entity.ActionUpdate, actor, "Resumed rollout schedule: "+reason))
+	})
+}
+
+// AbortRollout ends the flag's rollout schedule, leaving the rollout percentage where it is
+func (s *flagService) AbortRollout(ctx context.Context, flagID int64, actor, reason string) (*entity.RolloutSchedule, error) {
+	return s.changeRolloutSchedule(ctx, flagID, actor, reason, func(ctx context.Context, flag *entity.Flag, schedule *entity.RolloutSchedule) (bool, error) {
+		schedule.Stop(entity.RolloutScheduleAborted, s.now())
+		return true, s.recordAudit(ctx, entity.NewAuditLog(flag.ID, entity.ActionUpdate, actor, "Aborted rollout schedule: "+reason))
+	})
+}
+
+// RollbackRollout aborts the flag's rollout schedule, if it has not finished, and sets the
+// rollout percentage to 0
+func (s *flagService) RollbackRollout(ctx context.Context, flagID int64, actor, reason string) (*entity.RolloutSchedule, error) {
+	if s.rolloutRepo == nil {
+		return nil, ErrFeatureNotConfigured
+	}
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+	if err := s.reasonPolicy.Check(string(entity.ActionUpdate), reason); err != nil {
+		return nil, err
+	}
+
+	var schedule *entity.RolloutSchedule
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		flag, err := s.getRolloutFlag(ctx, flagID)
+		if err != nil {
+			return err
+		}
+		if schedule, err = s.getRolloutSchedule(ctx, flagID); err != nil {
+			return err
+		}
+		if !schedule.IsFinished() {
+			schedule.Stop(entity.RolloutScheduleAborted, s.now())
+			if err := s.rolloutRepo.SaveRolloutSchedule(ctx, schedule); err != nil {
+				return err
+			}
+		}
+		return s.setRolloutPercentage(ctx, flag, 0, actor, "Rolled back rollout schedule: "+reason)
+	})
+	if err != nil {
+		s.log(ctx).Errorw("Failed to roll back rollout", "error", err, "flagID", flagID, "actor", actor)
+		return nil, err
+	}
+
+	s.log(ctx).Infow("Rollout rolled back", "flagID", flagID, "actor", actor)
+	return schedule, nil
+}
+
+// ProcessRolloutSchedules takes the next step of every active rollout schedule that is due,
+// auditing each step as the system actor. A schedule reaching 100% completes. Locked flags
+// are left alone and retried on the next run; archived flags have their schedule aborted. It
+// is run by the schedule worker.
+func (s *flagService) ProcessRolloutSchedules(ctx context.Context) error {
+	if s.rolloutRepo == nil {
+		return nil
+	}
+
+	schedules, err := s.rolloutRepo.ListDueRolloutSchedules(ctx, s.now())
+	if err != nil {
+		return fmt.Errorf("failed to list rollout schedules: %w", err)
+	}
+
+	for _, schedule := range schedules {
+		var percentage int
+		err := s.withinTx(ctx, func(ctx context.Context) error {
+			flag, err := s.getRolloutFlag(ctx, schedule.FlagID)
+			if errors.Is(err, ErrFlagArchived) {
+				schedule.Stop(entity.RolloutScheduleAborted, s.now())
+				return s.rolloutRepo.SaveRolloutSchedule(ctx, schedule)
+			}
+			if err != nil {
+				return err
+			}
+
+			percentage = schedule.NextPercentage(flag.RolloutPercentage)
+			if percentage == entity.FullRollout {
+				schedule.Stop(entity.RolloutScheduleCompleted, s.now())
+			} else {
+				schedule.Resume(s.now())
+			}
+			if err := s.rolloutRepo.SaveRolloutSchedule(ctx, schedule); err != nil {
+				return err
+			}
+			reason := fmt.Sprintf("Rollout schedule of %s raised the rollout percentage", schedule.Actor)
+			return s.setRolloutPercentage(ctx, flag, percentage, validator.SystemActor, reason)
+		})
+		if err != nil {
+			if errors.Is(err, ErrFlagLocked) {
+				s.log(ctx).Warnw("Skipping rollout step of locked flag", "flagID", schedule.FlagID)
+				continue
+			}
+			s.log(ctx).Errorw("Failed to take rollout step", "error", err, "flagID", schedule.FlagID)
+			continue
+		}
+		s.log(ctx).Infow("Rollout step taken", "flagID", schedule.FlagID, "percentage", percentage, "status", schedule.Status)
+	}
+	return nil
+}
+
+// changeRolloutSchedule applies change to the flag's unfinished rollout schedule and saves it
+// if change reports that it changed, all in one transaction
+func (s *flagService) changeRolloutSchedule(ctx context.Context, flagID int64, actor, reason string, change func(ctx context.Context, flag *entity.Flag, schedule *entity.RolloutSchedule) (bool, error)) (*entity.RolloutSchedule, error) {
+	if s.rolloutRepo == nil {
+		return nil, ErrFeatureNotConfigured
+	}
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+	if err := s.reasonPolicy.Check(string(entity.ActionUpdate), reason); err != nil {
+		return nil, err
+	}
+
+	var schedule *entity.RolloutSchedule
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		flag, err := s.getRolloutFlag(ctx, flagID)
+		if err != nil {
+			return err
+		}
+		if schedule, err = s.getRolloutSchedule(ctx, flagID); err != nil {
+			return err
+		}
+		if schedule.IsFinished() {
+			return ErrRolloutScheduleFinished
+		}
+
+		changed, err := change(ctx, flag, schedule)
+		if err != nil || !changed {
+			return err
+		}
+		return s.rolloutRepo.SaveRolloutSchedule(ctx, schedule)
+	})
+	if err != nil {
+		s.log(ctx).Warnw("Failed to change rollout schedule", "error", err, "flagID", flagID, "actor", actor)
+		return nil, err
+	}
+
+	s.log(ctx).Infow("Rollout schedule changed", "flagID", flagID, "status", schedule.Status, "actor", actor)
+	return schedule, nil
+}
+
+// getRolloutFlag returns the flag if its rollout may be changed
+func (s *flagService) getRolloutFlag(ctx context.Context, flagID int64) (*entity.Flag, error) {
+	flag, err := s.flagRepo.GetFlagByID(ctx, flagID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFlagNotFound) {
+			return nil, ErrFlagNotFound
+		}
+		return nil, fmt.Errorf("failed to get flag: %w", err)
+	}
+	if flag.Locked {
+		return nil, ErrFlagLocked
+	}
+	if flag.IsArchived() {
+		return nil, ErrFlagArchived
+	}
+	return flag, nil
+}
+
+func (s *flagService) getRolloutSchedule(ctx context.Context, flagID int64) (*entity.RolloutSchedule, error) {
+	schedule, err := s.rolloutRepo.GetRolloutSchedule(ctx, flagID)
+	if err != nil {
+		if errors.Is(err, repository.ErrRolloutScheduleNotFound) {
+			return nil, ErrRolloutScheduleNotFound
+		}
+		return nil, fmt.Errorf("failed to get rollout schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// setRolloutPercentage writes the flag's new rollout percentage with an audit entry saying
+// what changed, unless it already has that percentage
+func (s *flagService) setRolloutPercentage(ctx context.Context, flag *entity.Flag, percentage int, actor, reason string) error {
+	if flag.RolloutPercentage == percentage {
+		return nil
+	}
+	if err := s.flagRepo.UpdateFlagRollout(ctx, flag.ID, percentage, actor); err != nil {
+		return err
+	}
+	reason = fmt.Sprintf("%s (rollout percentage from %d%% to %d%%)", reason, flag.RolloutPercentage, percentage)
+	if err := s.recordAudit(ctx, entity.NewAuditLog(flag.ID, entity.ActionUpdate, actor, reason)); err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+	return nil
+}
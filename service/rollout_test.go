@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"featureflags/entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRolloutBucket(t *testing.T) {
+	t.Run("same user always lands in the same bucket", func(t *testing.T) {
+		first := rolloutBucket("checkout_v2", "user-42")
+		for i := 0; i < 100; i++ {
+			assert.Equal(t, first, rolloutBucket("checkout_v2", "user-42"))
+		}
+		assert.GreaterOrEqual(t, first, 0)
+		assert.Less(t, first, 100)
+	})
+
+	t.Run("buckets differ between flags", func(t *testing.T) {
+		differ := false
+		for i := 0; i < 20 && !differ; i++ {
+			user := fmt.Sprintf("user-%d", i)
+			differ = rolloutBucket("checkout_v2", user) != rolloutBucket("search_v3", user)
+		}
+		assert.True(t, differ, "users should not get the same bucket for every flag")
+	})
+
+	t.Run("users spread across buckets", func(t *testing.T) {
+		flag := &entity.Flag{Name: "checkout_v2", RolloutPercentage: 25}
+		in := 0
+		for i := 0; i < 10000; i++ {
+			if inRollout(flag, fmt.Sprintf("user-%d", i)) {
+				in++
+			}
+		}
+		assert.InDelta(t, 2500, in, 250)
+	})
+
+	t.Run("raising the percentage keeps existing users", func(t *testing.T) {
+		low := &entity.Flag{Name: "checkout_v2", RolloutPercentage: 10}
+		high := &entity.Flag{Name: "checkout_v2", RolloutPercentage: 50}
+		for i := 0; i < 1000; i++ {
+			user := fmt.Sprintf("user-%d", i)
+			if inRollout(low, user) {
+				assert.True(t, inRollout(high, user), user)
+			}
+		}
+	})
+
+	t.Run("0 and 100 percent", func(t *testing.T) {
+		none := &entity.Flag{Name: "checkout_v2", RolloutPercentage: 0}
+		all := &entity.Flag{Name: "checkout_v2", RolloutPercentage: 100}
+		for i := 0; i < 1000; i++ {
+			user := fmt.Sprintf("user-%d", i)
+			assert.False(t, inRollout(none, user))
+			assert.True(t, inRollout(all, user))
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"featureflags/entity"
+	"featureflags/validator"
+)
+
+// ScheduleChange records an enable or disable of the flag to be applied by
+// ProcessScheduledChanges once req.ScheduledAt has passed.
+func (s *flagService) ScheduleChange(ctx context.Context, flagID int64, req validator.FlagScheduleRequest, actor string) (*entity.ScheduledChange, error) {
+	if s.scheduleRepo == nil {
+		return nil, ErrFeatureNotConfigured
+	}
+	if err := validator.ValidateFlagID(flagID); err != nil {
+		return nil, err
+	}
+	if err := s.validateActor(actor); err != nil {
+		return nil, err
+	}
+	if err := s.reasonPolicy.Check(validator.ToggleAction(req.Status == "enabled"), req.Reason); err != nil {
+		return nil, err
+	}
+	if !req.ScheduledAt.After(time.Now()) {
+		return nil, ErrScheduleInPast
+	}
+
+	flag, err := s.GetFlag(ctx, flagID)
+	if err != nil {
+		return nil, err
+	}
+	// Nobody is around to confirm the enable when it runs
+	if flag.HighRisk && req.Status == string(entity.FlagEnabled) {
+		return nil, fmt.Errorf("%w: %s cannot be enabled on a schedule", ErrConfirmationRequired, flag.Name)
+	}
+
+	change := entity.NewScheduledChange(flagID, entity.FlagStatus(req.Status), req.ScheduledAt, actor, req.Reason)
+	id, err := s.scheduleRepo.CreateScheduledChange(ctx, change)
+	if err != nil {
+		s.log(ctx).Errorw("Failed to schedule flag change", "error", err, "flagID", flagID)
+		return nil, fmt.Errorf("failed to schedule change: %w", err)
+	}
+	change.ID = id
+
+	s.log(ctx).Infow("Flag change scheduled", "flagID", flagID, "scheduleID", id,
+		"targetStatus", change.TargetStatus, "scheduledAt", change.ScheduledAt, "actor", actor)
+	return change, nil
+}
+
+// ProcessScheduledChanges applies every scheduled change that is due, on behalf of the actor
+// who scheduled it. A change the flag's current state rules out, such as an enable whose
+// dependencies are still disabled, is marked failed and the flag is left alone; other errors
+// leave the change pending for the next run. It is run by the schedule worker.
+func (s *flagService) ProcessScheduledChanges(ctx context.Context) error {
+	if s.scheduleRepo == nil {
+		return nil
+	}
+
+	changes, err := s.scheduleRepo.ListDueScheduledChanges(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to list scheduled changes: %w", err)
+	}
+
+	for _, change := range changes {
+		reason := fmt.Sprintf("%s (scheduled change %d)", change.Reason, change.ID)
+		if change.TargetStatus == entity.FlagEnabled {
+			_, err = s.EnableFlag(ctx, change.FlagID, change.Actor, reason)
+		} else {
+			_, err = s.DisableFlag(ctx, change.FlagID, change.Actor, reason)
+		}
+
+		status, failure := entity.ScheduledChangeApplied, ""
+		if err != nil {
+			var rejected bool
+			if failure, rejected = scheduleRejection(err); !rejected {
+				s.log(ctx).Errorw("Failed to apply scheduled change", "error", err, "scheduleID", change.ID)
+				continue
+			}
+			status = entity.ScheduledChangeFailed
+		}
+
+		if err := s.scheduleRepo.ResolveScheduledChange(ctx, change.ID, status, failure); err != nil {
+			s.log(ctx).Errorw("Failed to resolve scheduled change", "error", err, "scheduleID", change.ID)
+			continue
+		}
+		s.log(ctx).Infow("Scheduled change resolved", "flagID", change.FlagID, "scheduleID", change.ID,
+			"status", status, "failure", failure)
+	}
+
+	return nil
+}
+
+// scheduleRejection describes why err rules the scheduled change out. It returns false for
+// transient failures that are worth retrying on the next run.
+func scheduleRejection(err error) (string, bool) {
+	var depErr DependencyError
+	if errors.As(err, &depErr) {
+		return fmt.Sprintf("%s: %s", depErr.Message, strings.Join(depErr.MissingDependencies, ", ")), true
+	}
+	var dependentsErr EnabledDependentsError
+	if errors.As(err, &dependentsErr) {
+		return fmt.Sprintf("%s: %s", dependentsErr.Message, strings.Join(dependentsErr.EnabledDependents, ", ")), true
+	}
+	switch {
+	case errors.Is(err, ErrFlagNotFound), errors.Is(err, ErrFlagLocked),
+		errors.Is(err, ErrFlagInMaintenance), errors.Is(err, ErrCircularDependency), errors.Is(err, ErrFlagArchived),
+		errors.Is(err, ErrConfirmationRequired):
+		return err.Error(), true
+	}
+	return "", false
+}
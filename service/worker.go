@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"featureflags/pkg/logger"
+)
+
+// Worker periodically runs registered background jobs
+type Worker struct {
+	interval time.Duration
+	jobs     []workerJob
+	logger   *logger.Logger
+}
+
+type workerJob struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+func NewWorker(interval time.Duration, log *logger.Logger) *Worker {
+	return &Worker{
+		interval: interval,
+		logger:   log,
+	}
+}
+
+// Register adds a job that is executed on every tick
+func (w *Worker) Register(name string, run func(ctx context.Context) error) {
+	w.jobs = append(w.jobs, workerJob{name: name, run: run})
+}
+
+// Start runs all jobs every interval until the context is cancelled
+func (w *Worker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.logger.Infow("Background worker started", "interval", w.interval, "jobs", len(w.jobs))
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Infow("Background worker stopped")
+			return
+		case <-ticker.C:
+			w.RunOnce(ctx)
+		}
+	}
+}
+
+// RunOnce runs every registered job a single time. A failing job does not stop the others.
+func (w *Worker) RunOnce(ctx context.Context) {
+	for _, job := range w.jobs {
+		if err := job.run(ctx); err != nil {
+			w.logger.Errorw("Background job failed", "job", job.name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"featureflags/config"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDatabase_StatementTimeout tests that Postgres aborts statements exceeding DATABASE_STATEMENT_TIMEOUT
+func TestDatabase_StatementTimeout(t *testing.T) {
+	port, err := strconv.Atoi(getEnvOrDefault("TEST_DB_PORT", "5432"))
+	require.NoError(t, err)
+
+	dbCfg := config.Database{
+		Host:             getEnvOrDefault("TEST_DB_HOST", "localhost"),
+		Port:             port,
+		User:             getEnvOrDefault("TEST_DB_USER", "featureflags"),
+		Password:         getEnvOrDefault("TEST_DB_PASSWORD", "featureflags"),
+		Name:             getEnvOrDefault("TEST_DB_NAME", getEnvOrDefault("POSTGRES_DB", "featureflags")+"_test"),
+		SSLMode:          "disable",
+		StatementTimeout: 100 * time.Millisecond,
+	}
+
+	db, err := sqlx.Connect("postgres", dbCfg.DSN())
+	require.NoError(t, err, "Failed to connect to test database")
+	defer db.Close()
+
+	start := time.Now()
+	_, err = db.Exec("SELECT pg_sleep(5)")
+
+	var pqErr *pq.Error
+	require.ErrorAs(t, err, &pqErr)
+	assert.Equal(t, pq.ErrorCode("57014"), pqErr.Code, "expected query_canceled")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
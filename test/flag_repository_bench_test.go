@@ -0,0 +1,79 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"featureflags/entity"
+	"featureflags/repository"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+// queryCount counts statements sent through the "postgres-counting" driver
+var queryCount atomic.Int64
+
+func init() {
+	sql.Register("postgres-counting", countingDriver{})
+}
+
+// countingDriver wraps lib/pq and counts every query and exec it runs
+type countingDriver struct{}
+
+func (countingDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := pq.Driver{}.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return countingConn{conn}, nil
+}
+
+type countingConn struct {
+	driver.Conn
+}
+
+func (c countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryCount.Add(1)
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (c countingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	queryCount.Add(1)
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+// BenchmarkGetFlagsWithDependencies reports queries/op alongside time; it should stay at two
+// (flags and edges) however many flags there are
+func BenchmarkGetFlagsWithDependencies(b *testing.B) {
+	testDB := SetupTestDB(b)
+	defer testDB.Close()
+	defer testDB.CleanTables(b)
+
+	// A chain of 200 flags, each depending on the previous one
+	var prev []int64
+	for i := 0; i < 200; i++ {
+		flag := testDB.CreateTestFlagWithDependencies(b, fmt.Sprintf("bench_%03d", i), entity.FlagEnabled, prev)
+		prev = []int64{flag.ID}
+	}
+
+	db, err := sqlx.Open("postgres-counting", testDSN())
+	require.NoError(b, err)
+	defer db.Close()
+	flagRepo := repository.NewFlagRepository(sqlx.NewDb(db.DB, "postgres"))
+
+	ctx := context.Background()
+	queryCount.Store(0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		flags, err := flagRepo.GetFlagsWithDependencies(ctx)
+		require.NoError(b, err)
+		require.Len(b, flags, 200)
+	}
+	b.ReportMetric(float64(queryCount.Load())/float64(b.N), "queries/op")
+}
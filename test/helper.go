@@ -3,9 +3,11 @@ package test
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"testing"
 
+	"featureflags/config"
 	"featureflags/entity"
 	"featureflags/migrations"
 	"featureflags/pkg/logger"
@@ -16,27 +18,27 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// TestAuth authenticates every actor the scenarios act as, with the token "<actor>-token"
+var TestAuth = config.Auth{Tokens: map[string]string{
+	"test_user-token":  "test_user",
+	"admin_user-token": "admin_user",
+	"deploy-bot-token": "deploy-bot",
+	"mallory-token":    "mallory",
+}}
+
+// AuthenticateAs sets the bearer token TestAuth maps to actor
+func AuthenticateAs(req *http.Request, actor string) {
+	req.Header.Set("Authorization", "Bearer "+actor+"-token")
+}
+
 // TestDB wraps a test database connection
 type TestDB struct {
 	DB *sqlx.DB
 }
 
 // SetupTestDB creates a test database and runs migrations
-func SetupTestDB(t *testing.T) *TestDB {
-	// Use environment variables or defaults for test database
-	host := getEnvOrDefault("TEST_DB_HOST", "localhost")
-	port := getEnvOrDefault("TEST_DB_PORT", "5432")
-	user := getEnvOrDefault("TEST_DB_USER", "featureflags")
-	password := getEnvOrDefault("TEST_DB_PASSWORD", "featureflags")
-	
-	// Get base database name and add _test suffix
-	baseDBName := getEnvOrDefault("POSTGRES_DB", "featureflags")
-	dbName := getEnvOrDefault("TEST_DB_NAME", baseDBName+"_test")
-
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbName)
-
-	db, err := sqlx.Connect("postgres", connStr)
+func SetupTestDB(t testing.TB) *TestDB {
+	db, err := sqlx.Connect("postgres", testDSN())
 	require.NoError(t, err, "Failed to connect to test database")
 
 	// Run migrations - check multiple possible paths
@@ -60,20 +62,43 @@ func (tdb *TestDB) Close() {
 }
 
 // CleanTables removes all data from tables (for test isolation)
-func (tdb *TestDB) CleanTables(t *testing.T) {
-	_, err := tdb.DB.Exec("TRUNCATE TABLE audit_logs, flag_dependencies, flags RESTART IDENTITY CASCADE")
+func (tdb *TestDB) CleanTables(t testing.TB) {
+	_, err := tdb.DB.Exec("TRUNCATE TABLE cascade_event_flags, cascade_events, flag_evaluations, pending_cascades, pending_enables, scheduled_changes, rollout_schedules, idempotency_keys, flag_environment_status, audit_logs, flag_tags, flag_dependencies, flags RESTART IDENTITY CASCADE")
 	require.NoError(t, err, "Failed to clean test tables")
+	_, err = tdb.DB.Exec("DELETE FROM environments WHERE name NOT IN ('global', 'dev', 'staging', 'prod')")
+	require.NoError(t, err, "Failed to clean test environments")
+}
+
+// WithTxTest runs fn inside a transaction that is rolled back once fn returns, so the test
+// leaves nothing behind and needs no CleanTables. Repository and service calls must use the
+// context passed to fn; they then join the transaction instead of committing on their own.
+// Sequences are not rolled back, so tests must not rely on specific IDs. A failed statement
+// aborts the transaction, so only assert on errors raised before the query runs.
+func (tdb *TestDB) WithTxTest(t *testing.T, fn func(ctx context.Context)) {
+	tx, err := tdb.DB.BeginTxx(context.Background(), nil)
+	require.NoError(t, err, "Failed to begin test transaction")
+	defer func() {
+		require.NoError(t, tx.Rollback(), "Failed to roll back test transaction")
+	}()
+
+	fn(repository.ContextWithTx(context.Background(), tx))
 }
 
 // CreateTestFlag creates a test flag in the database
-func (tdb *TestDB) CreateTestFlag(t *testing.T, name string, status entity.FlagStatus) *entity.Flag {
+func (tdb *TestDB) CreateTestFlag(t testing.TB, name string, status entity.FlagStatus) *entity.Flag {
+	return tdb.CreateTestFlagContext(context.Background(), t, name, status)
+}
+
+// CreateTestFlagContext creates a test flag using ctx, e.g. inside WithTxTest
+func (tdb *TestDB) CreateTestFlagContext(ctx context.Context, t testing.TB, name string, status entity.FlagStatus) *entity.Flag {
 	flag := &entity.Flag{
-		Name:   name,
-		Status: status,
+		Name:              name,
+		Status:            status,
+		RolloutPercentage: entity.FullRollout,
 	}
 
 	flagRepo := repository.NewFlagRepository(tdb.DB)
-	flagID, err := flagRepo.CreateFlag(context.Background(), flag)
+	flagID, err := flagRepo.CreateFlag(ctx, flag)
 	require.NoError(t, err, "Failed to create test flag")
 
 	flag.ID = flagID
@@ -81,7 +106,7 @@ func (tdb *TestDB) CreateTestFlag(t *testing.T, name string, status entity.FlagS
 }
 
 // CreateTestFlagWithDependencies creates a test flag with dependencies
-func (tdb *TestDB) CreateTestFlagWithDependencies(t *testing.T, name string, status entity.FlagStatus, deps []int64) *entity.Flag {
+func (tdb *TestDB) CreateTestFlagWithDependencies(t testing.TB, name string, status entity.FlagStatus, deps []int64) *entity.Flag {
 	flag := tdb.CreateTestFlag(t, name, status)
 	
 	if len(deps) > 0 {
@@ -96,6 +121,21 @@ func (tdb *TestDB) CreateTestFlagWithDependencies(t *testing.T, name string, sta
 	return flag
 }
 
+// testDSN builds the test database connection string from environment variables or defaults
+func testDSN() string {
+	host := getEnvOrDefault("TEST_DB_HOST", "localhost")
+	port := getEnvOrDefault("TEST_DB_PORT", "5432")
+	user := getEnvOrDefault("TEST_DB_USER", "featureflags")
+	password := getEnvOrDefault("TEST_DB_PASSWORD", "featureflags")
+
+	// Get base database name and add _test suffix
+	baseDBName := getEnvOrDefault("POSTGRES_DB", "featureflags")
+	dbName := getEnvOrDefault("TEST_DB_NAME", baseDBName+"_test")
+
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		host, port, user, password, dbName)
+}
+
 // GetTestLogger creates a test logger
 func GetTestLogger() *logger.Logger {
 	log, err := logger.New("debug", "development")
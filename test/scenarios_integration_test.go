@@ -2,6 +2,7 @@ package test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -42,9 +43,10 @@ func SetupIntegrationTest(t *testing.T) *IntegrationTestSuite {
 	// Setup Echo app
 	app := echo.New()
 	cfg := &config.Config{
-		Swagger: config.Swagger{Enabled: false}, // Disable swagger for tests
+		Swagger: config.Swagger{UIEnabled: false, SpecEnabled: false}, // Disable swagger for tests
+		Auth:    TestAuth,
 	}
-	handler.RegisterRoutes(app, flagController, cfg, log)
+	handler.RegisterRoutes(app, flagController, testDB.DB, cfg, log, nil)
 
 	return &IntegrationTestSuite{
 		testDB:     testDB,
@@ -81,8 +83,9 @@ func TestExampleScenario1_CheckoutDependencies(t *testing.T) {
 		err := json.Unmarshal(response.Body.Bytes(), &errorResp)
 		require.NoError(t, err)
 		
-		assert.Equal(t, "Missing active dependencies", errorResp["error"])
-		missingDeps := errorResp["missing_dependencies"].([]interface{})
+		assert.Equal(t, "MISSING_DEPENDENCIES", errorResp["code"])
+		assert.Equal(t, "Missing active dependencies", errorResp["message"])
+		missingDeps := errorResp["details"].(map[string]interface{})["missing_dependencies"].([]interface{})
 		assert.Contains(t, missingDeps, "auth_v2")
 		assert.Contains(t, missingDeps, "user_profile_v2")
 		
@@ -96,7 +99,7 @@ func TestExampleScenario1_CheckoutDependencies(t *testing.T) {
 		
 		err = json.Unmarshal(response.Body.Bytes(), &errorResp)
 		require.NoError(t, err)
-		missingDeps = errorResp["missing_dependencies"].([]interface{})
+		missingDeps = errorResp["details"].(map[string]interface{})["missing_dependencies"].([]interface{})
 		assert.Contains(t, missingDeps, "user_profile_v2")
 		assert.NotContains(t, missingDeps, "auth_v2") // auth_v2 should not be in missing deps
 		
@@ -137,12 +140,14 @@ func TestExampleScenario2_MissingDependencyErrorFormat(t *testing.T) {
 		
 		// Verify exact error format as specified in requirements
 		expectedError := map[string]interface{}{
-			"error":                "Missing active dependencies",
-			"missing_dependencies": []interface{}{"auth_v2"},
+			"code":    "MISSING_DEPENDENCIES",
+			"message": "Missing active dependencies",
+			"details": map[string]interface{}{
+				"missing_dependencies": []interface{}{"auth_v2"},
+			},
 		}
 		
-		assert.Equal(t, expectedError["error"], errorResp["error"])
-		assert.Equal(t, expectedError["missing_dependencies"], errorResp["missing_dependencies"])
+		assert.Equal(t, expectedError, errorResp)
 		
 		t.Logf("✅ Scenario 2 passed: Error format matches requirements exactly")
 		t.Logf("Response: %s", response.Body.String())
@@ -261,7 +266,8 @@ func TestComplexScenarioIntegration(t *testing.T) {
 		var errorResp map[string]interface{}
 		err := json.Unmarshal(response.Body.Bytes(), &errorResp)
 		require.NoError(t, err)
-		assert.Equal(t, "Missing active dependencies", errorResp["error"])
+		assert.Equal(t, "MISSING_DEPENDENCIES", errorResp["code"])
+		assert.Equal(t, "Missing active dependencies", errorResp["message"])
 		
 		// Test 2: Enable flags in correct dependency order
 		toggleFlagHelper(t, suite, databaseFlag.ID, true, "Enable database")
@@ -311,6 +317,123 @@ func TestComplexScenarioIntegration(t *testing.T) {
 	})
 }
 
+// TestUnknownDependencyIDRejected tests that creating a flag on a dependency ID no flag has is
+// a client error listing the unknown IDs
+func TestUnknownDependencyIDRejected(t *testing.T) {
+	suite := SetupIntegrationTest(t)
+	defer suite.Cleanup(t)
+
+	authFlag := createFlagHelper(t, suite, "auth_v2", []int64{})
+
+	response := makeRequestHelper(t, suite, "POST", "/api/v1/flags", map[string]interface{}{
+		"name":         "checkout_v2",
+		"dependencies": []int64{authFlag.ID, 99999, 99998},
+	}, "test_user")
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	var errorResp map[string]interface{}
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &errorResp))
+	assert.Equal(t, map[string]interface{}{
+		"code":    "UNKNOWN_DEPENDENCIES",
+		"message": "Unknown dependencies",
+		"details": map[string]interface{}{
+			"unknown_dependency_ids": []interface{}{float64(99999), float64(99998)},
+		},
+	}, errorResp)
+
+	_, err := repository.NewFlagRepository(suite.testDB.DB).GetFlagByName(context.Background(), "checkout_v2")
+	assert.ErrorIs(t, err, repository.ErrFlagNotFound)
+}
+
+func TestConditionalFlagReads(t *testing.T) {
+	suite := SetupIntegrationTest(t)
+	defer suite.Cleanup(t)
+
+	authFlag := createFlagHelper(t, suite, "auth_v2", []int64{})
+	checkoutFlag := createFlagHelper(t, suite, "checkout_v2", []int64{authFlag.ID})
+	toggleFlagHelper(t, suite, authFlag.ID, true, "Enable auth")
+	toggleFlagHelper(t, suite, checkoutFlag.ID, true, "Enable checkout")
+
+	get := func(url, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		AuthenticateAs(req, "test_user")
+		rec := httptest.NewRecorder()
+		suite.app.ServeHTTP(rec, req)
+		return rec
+	}
+	checkoutURL := fmt.Sprintf("/api/v1/flags/%d", checkoutFlag.ID)
+
+	flagResponse := get(checkoutURL, "")
+	require.Equal(t, http.StatusOK, flagResponse.Code)
+	flagETag := flagResponse.Header().Get("ETag")
+	require.NotEmpty(t, flagETag)
+	listResponse := get("/api/v1/flags", "")
+	require.Equal(t, http.StatusOK, listResponse.Code)
+	listETag := listResponse.Header().Get("ETag")
+	require.NotEmpty(t, listETag)
+
+	t.Run("unchanged flags are not sent again", func(t *testing.T) {
+		rec := get(checkoutURL, flagETag)
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Empty(t, rec.Body.String())
+
+		rec = get("/api/v1/flags", listETag)
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("cascade disable changes the dependent's ETag", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, toggleFlagHelper(t, suite, authFlag.ID, false, "Auth issues detected").Code)
+
+		rec := get(checkoutURL, flagETag)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotEqual(t, flagETag, rec.Header().Get("ETag"))
+		var flag entity.Flag
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &flag))
+		assert.Equal(t, entity.FlagDisabled, flag.Status)
+
+		rec = get("/api/v1/flags", listETag)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotEqual(t, listETag, rec.Header().Get("ETag"))
+	})
+}
+
+func TestCascadeAuditSharesRequestID(t *testing.T) {
+	suite := SetupIntegrationTest(t)
+	defer suite.Cleanup(t)
+
+	authFlag := createFlagHelper(t, suite, "auth_v2", []int64{})
+	checkoutFlag := createFlagHelper(t, suite, "checkout_v2", []int64{authFlag.ID})
+	toggleFlagHelper(t, suite, authFlag.ID, true, "Enable auth")
+	toggleFlagHelper(t, suite, checkoutFlag.ID, true, "Enable checkout")
+
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/flags/%d/toggle", authFlag.ID),
+		bytes.NewBufferString(`{"enable":false,"reason":"Auth issues detected"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", "incident-1234")
+	AuthenticateAs(req, "test_user")
+	rec := httptest.NewRecorder()
+	suite.app.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "incident-1234", rec.Header().Get("X-Request-ID"))
+
+	for _, flagID := range []int64{authFlag.ID, checkoutFlag.ID} {
+		response := makeRequestHelper(t, suite, "GET", fmt.Sprintf("/api/v1/flags/%d/audit", flagID), nil, "test_user")
+		require.Equal(t, http.StatusOK, response.Code)
+		var auditResponse struct {
+			AuditLogs []entity.AuditLog `json:"audit_logs"`
+		}
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &auditResponse))
+		require.NotEmpty(t, auditResponse.AuditLogs)
+		// Newest first: the disable, or the cascade it caused
+		assert.Equal(t, "incident-1234", auditResponse.AuditLogs[0].RequestID)
+		assert.NotEqual(t, "incident-1234", auditResponse.AuditLogs[1].RequestID)
+	}
+}
+
 // Helper functions for the scenario tests
 
 func createFlagHelper(t *testing.T, suite *IntegrationTestSuite, name string, dependencies []int64) *entity.Flag {
@@ -352,7 +475,7 @@ func makeRequestHelper(t *testing.T, suite *IntegrationTestSuite, method, url st
 	req := httptest.NewRequest(method, url, bytes.NewBuffer(reqBody))
 	req.Header.Set("Content-Type", "application/json")
 	if actor != "" {
-		req.Header.Set("X-Actor", actor)
+		AuthenticateAs(req, actor)
 	}
 	
 	rec := httptest.NewRecorder()
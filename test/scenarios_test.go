@@ -2,6 +2,7 @@ package test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -36,8 +37,8 @@ func TestScenario1_DependencyValidation(t *testing.T) {
 
 	// Setup Echo
 	e := echo.New()
-	cfg := &config.Config{Swagger: config.Swagger{Enabled: false}}
-	handler.RegisterRoutes(e, flagController, cfg, log)
+	cfg := &config.Config{Swagger: config.Swagger{UIEnabled: false, SpecEnabled: false}, Auth: TestAuth}
+	handler.RegisterRoutes(e, flagController, testDB.DB, cfg, log, nil)
 
 	t.Run("Create dependencies first", func(t *testing.T) {
 		// Create auth_v2 flag
@@ -45,7 +46,7 @@ func TestScenario1_DependencyValidation(t *testing.T) {
 		authJSON, _ := json.Marshal(authReq)
 		req := httptest.NewRequest(http.MethodPost, "/api/v1/flags", bytes.NewReader(authJSON))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Actor", "test_user")
+		AuthenticateAs(req, "test_user")
 		rec := httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
@@ -60,7 +61,7 @@ func TestScenario1_DependencyValidation(t *testing.T) {
 		profileJSON, _ := json.Marshal(profileReq)
 		req = httptest.NewRequest(http.MethodPost, "/api/v1/flags", bytes.NewReader(profileJSON))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Actor", "test_user")
+		AuthenticateAs(req, "test_user")
 		rec = httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
@@ -79,7 +80,7 @@ func TestScenario1_DependencyValidation(t *testing.T) {
 		checkoutJSON, _ := json.Marshal(checkoutReq)
 		req := httptest.NewRequest(http.MethodPost, "/api/v1/flags", bytes.NewReader(checkoutJSON))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Actor", "test_user")
+		AuthenticateAs(req, "test_user")
 		rec := httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
@@ -98,7 +99,7 @@ func TestScenario1_DependencyValidation(t *testing.T) {
 		toggleJSON, _ := json.Marshal(toggleReq)
 		req := httptest.NewRequest(http.MethodPost, "/api/v1/flags/3/toggle", bytes.NewReader(toggleJSON))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Actor", "test_user")
+		AuthenticateAs(req, "test_user")
 		rec := httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
@@ -107,9 +108,11 @@ func TestScenario1_DependencyValidation(t *testing.T) {
 		var errorResponse map[string]interface{}
 		json.Unmarshal(rec.Body.Bytes(), &errorResponse)
 		
-		assert.Equal(t, "Missing active dependencies", errorResponse["error"])
-		assert.Contains(t, errorResponse["missing_dependencies"], "auth_v2")
-		assert.Contains(t, errorResponse["missing_dependencies"], "user_profile_v2")
+		assert.Equal(t, "MISSING_DEPENDENCIES", errorResponse["code"])
+		assert.Equal(t, "Missing active dependencies", errorResponse["message"])
+		details := errorResponse["details"].(map[string]interface{})
+		assert.Contains(t, details["missing_dependencies"], "auth_v2")
+		assert.Contains(t, details["missing_dependencies"], "user_profile_v2")
 	})
 
 	t.Run("Enable dependencies first", func(t *testing.T) {
@@ -121,7 +124,7 @@ func TestScenario1_DependencyValidation(t *testing.T) {
 		toggleJSON, _ := json.Marshal(toggleReq)
 		req := httptest.NewRequest(http.MethodPost, "/api/v1/flags/1/toggle", bytes.NewReader(toggleJSON))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Actor", "test_user")
+		AuthenticateAs(req, "test_user")
 		rec := httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
@@ -130,7 +133,7 @@ func TestScenario1_DependencyValidation(t *testing.T) {
 		// Enable user_profile_v2
 		req = httptest.NewRequest(http.MethodPost, "/api/v1/flags/2/toggle", bytes.NewReader(toggleJSON))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Actor", "test_user")
+		AuthenticateAs(req, "test_user")
 		rec = httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
@@ -145,7 +148,7 @@ func TestScenario1_DependencyValidation(t *testing.T) {
 		toggleJSON, _ := json.Marshal(toggleReq)
 		req := httptest.NewRequest(http.MethodPost, "/api/v1/flags/3/toggle", bytes.NewReader(toggleJSON))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Actor", "test_user")
+		AuthenticateAs(req, "test_user")
 		rec := httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
@@ -173,8 +176,8 @@ func TestScenario2_MissingDependenciesError(t *testing.T) {
 
 	// Setup Echo
 	e := echo.New()
-	cfg := &config.Config{Swagger: config.Swagger{Enabled: false}}
-	handler.RegisterRoutes(e, flagController, cfg, log)
+	cfg := &config.Config{Swagger: config.Swagger{UIEnabled: false, SpecEnabled: false}, Auth: TestAuth}
+	handler.RegisterRoutes(e, flagController, testDB.DB, cfg, log, nil)
 
 	// Create auth_v2 (enabled) and user_profile_v2 (disabled)
 	authFlag := testDB.CreateTestFlag(t, "auth_v2", entity.FlagEnabled)
@@ -189,7 +192,7 @@ func TestScenario2_MissingDependenciesError(t *testing.T) {
 		toggleJSON, _ := json.Marshal(toggleReq)
 		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/flags/%d/toggle", checkoutFlag.ID), bytes.NewReader(toggleJSON))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Actor", "test_user")
+		AuthenticateAs(req, "test_user")
 		rec := httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
@@ -199,8 +202,9 @@ func TestScenario2_MissingDependenciesError(t *testing.T) {
 		json.Unmarshal(rec.Body.Bytes(), &errorResponse)
 		
 		// Verify exact error format as specified in requirements
-		assert.Equal(t, "Missing active dependencies", errorResponse["error"])
-		missingDeps := errorResponse["missing_dependencies"].([]interface{})
+		assert.Equal(t, "MISSING_DEPENDENCIES", errorResponse["code"])
+		assert.Equal(t, "Missing active dependencies", errorResponse["message"])
+		missingDeps := errorResponse["details"].(map[string]interface{})["missing_dependencies"].([]interface{})
 		assert.Len(t, missingDeps, 1)
 		assert.Contains(t, missingDeps, "user_profile_v2")
 		assert.NotContains(t, missingDeps, "auth_v2") // auth_v2 is enabled, so not missing
@@ -222,8 +226,8 @@ func TestScenario3_CascadeDisable(t *testing.T) {
 
 	// Setup Echo
 	e := echo.New()
-	cfg := &config.Config{Swagger: config.Swagger{Enabled: false}}
-	handler.RegisterRoutes(e, flagController, cfg, log)
+	cfg := &config.Config{Swagger: config.Swagger{UIEnabled: false, SpecEnabled: false}, Auth: TestAuth}
+	handler.RegisterRoutes(e, flagController, testDB.DB, cfg, log, nil)
 
 	// Create dependency chain: auth_v2 -> checkout_v2 -> payment_v2
 	authFlag := testDB.CreateTestFlag(t, "auth_v2", entity.FlagEnabled)
@@ -238,7 +242,7 @@ func TestScenario3_CascadeDisable(t *testing.T) {
 		toggleJSON, _ := json.Marshal(toggleReq)
 		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/flags/%d/toggle", authFlag.ID), bytes.NewReader(toggleJSON))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Actor", "admin_user")
+		AuthenticateAs(req, "admin_user")
 		rec := httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
@@ -253,6 +257,7 @@ func TestScenario3_CascadeDisable(t *testing.T) {
 	t.Run("Verify cascade disable audit logs", func(t *testing.T) {
 		// Check audit logs for cascade actions
 		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/flags/%d/audit", checkoutFlag.ID), nil)
+		AuthenticateAs(req, "test_user")
 		rec := httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
@@ -269,7 +274,7 @@ func TestScenario3_CascadeDisable(t *testing.T) {
 			log := logInterface.(map[string]interface{})
 			if log["action"] == "cascade_disable" && log["actor"] == "system" {
 				foundCascadeLog = true
-				assert.Contains(t, log["reason"], "Automatically disabled due to dependency")
+				assert.Equal(t, "Cascade-disabled: depends on auth_v2, which was turned off by admin_user", log["reason"])
 				break
 			}
 		}
@@ -292,15 +297,15 @@ func TestScenario4_CircularDependency(t *testing.T) {
 
 	// Setup Echo
 	e := echo.New()
-	cfg := &config.Config{Swagger: config.Swagger{Enabled: false}}
-	handler.RegisterRoutes(e, flagController, cfg, log)
+	cfg := &config.Config{Swagger: config.Swagger{UIEnabled: false, SpecEnabled: false}, Auth: TestAuth}
+	handler.RegisterRoutes(e, flagController, testDB.DB, cfg, log, nil)
 
 	t.Run("Create flag A", func(t *testing.T) {
 		flagAReq := validator.FlagCreateRequest{Name: "flag_A"}
 		flagAJSON, _ := json.Marshal(flagAReq)
 		req := httptest.NewRequest(http.MethodPost, "/api/v1/flags", bytes.NewReader(flagAJSON))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Actor", "test_user")
+		AuthenticateAs(req, "test_user")
 		rec := httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
@@ -315,7 +320,7 @@ func TestScenario4_CircularDependency(t *testing.T) {
 		flagBJSON, _ := json.Marshal(flagBReq)
 		req := httptest.NewRequest(http.MethodPost, "/api/v1/flags", bytes.NewReader(flagBJSON))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Actor", "test_user")
+		AuthenticateAs(req, "test_user")
 		rec := httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
@@ -330,7 +335,7 @@ func TestScenario4_CircularDependency(t *testing.T) {
 		flagCJSON, _ := json.Marshal(flagCReq)
 		req := httptest.NewRequest(http.MethodPost, "/api/v1/flags", bytes.NewReader(flagCJSON))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Actor", "test_user")
+		AuthenticateAs(req, "test_user")
 		rec := httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
@@ -339,27 +344,40 @@ func TestScenario4_CircularDependency(t *testing.T) {
 
 	t.Run("Try to make flag A depend on flag C - should detect circular dependency", func(t *testing.T) {
 		// This would create: A->C->B->A (circular)
-		// We need to test this at the service level since we can't modify dependencies via API
-		// Let's create a direct circular dependency instead
-		
+		updateJSON, _ := json.Marshal(validator.FlagUpdateRequest{Dependencies: []int64{3}})
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/flags/1", bytes.NewReader(updateJSON))
+		req.Header.Set("Content-Type", "application/json")
+		AuthenticateAs(req, "test_user")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Circular dependency detected")
+		assert.Contains(t, rec.Body.String(), `"code":"CIRCULAR_DEPENDENCY"`)
+
 		flagDReq := validator.FlagCreateRequest{
 			Name:         "flag_D",
 			Dependencies: []int64{1}, // D depends on A
 		}
 		flagDJSON, _ := json.Marshal(flagDReq)
-		req := httptest.NewRequest(http.MethodPost, "/api/v1/flags", bytes.NewReader(flagDJSON))
+		req = httptest.NewRequest(http.MethodPost, "/api/v1/flags", bytes.NewReader(flagDJSON))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Actor", "test_user")
-		rec := httptest.NewRecorder()
+		AuthenticateAs(req, "test_user")
+		rec = httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusCreated, rec.Code)
 
-		// Now try to create a flag that would make A depend on D (creating A->D->A)
-		// This is a limitation of the current API - we'd need an update endpoint to test this fully
-		// For now, we'll test the circular dependency detection at the repository level
-		
-		// Test direct circular dependency
+		// Making A depend on D would create A->D->A
+		updateJSON, _ = json.Marshal(validator.FlagUpdateRequest{Dependencies: []int64{4}})
+		req = httptest.NewRequest(http.MethodPut, "/api/v1/flags/1", bytes.NewReader(updateJSON))
+		req.Header.Set("Content-Type", "application/json")
+		AuthenticateAs(req, "test_user")
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
 		flagEReq := validator.FlagCreateRequest{
 			Name:         "flag_E",
 			Dependencies: []int64{4}, // E depends on D
@@ -367,7 +385,7 @@ func TestScenario4_CircularDependency(t *testing.T) {
 		flagEJSON, _ := json.Marshal(flagEReq)
 		req = httptest.NewRequest(http.MethodPost, "/api/v1/flags", bytes.NewReader(flagEJSON))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Actor", "test_user")
+		AuthenticateAs(req, "test_user")
 		rec = httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
@@ -391,8 +409,8 @@ func TestScenario5_ComplexDependencyChain(t *testing.T) {
 
 	// Setup Echo
 	e := echo.New()
-	cfg := &config.Config{Swagger: config.Swagger{Enabled: false}}
-	handler.RegisterRoutes(e, flagController, cfg, log)
+	cfg := &config.Config{Swagger: config.Swagger{UIEnabled: false, SpecEnabled: false}, Auth: TestAuth}
+	handler.RegisterRoutes(e, flagController, testDB.DB, cfg, log, nil)
 
 	// Create complex dependency chain:
 	// database_v2 (base)
@@ -423,7 +441,7 @@ func TestScenario5_ComplexDependencyChain(t *testing.T) {
 			flagJSON, _ := json.Marshal(flagReq)
 			req := httptest.NewRequest(http.MethodPost, "/api/v1/flags", bytes.NewReader(flagJSON))
 			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("X-Actor", "test_user")
+			AuthenticateAs(req, "test_user")
 			rec := httptest.NewRecorder()
 			e.ServeHTTP(rec, req)
 
@@ -439,7 +457,7 @@ func TestScenario5_ComplexDependencyChain(t *testing.T) {
 		toggleJSON, _ := json.Marshal(toggleReq)
 		req := httptest.NewRequest(http.MethodPost, "/api/v1/flags/6/toggle", bytes.NewReader(toggleJSON))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Actor", "test_user")
+		AuthenticateAs(req, "test_user")
 		rec := httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
@@ -448,16 +466,30 @@ func TestScenario5_ComplexDependencyChain(t *testing.T) {
 		var errorResponse map[string]interface{}
 		json.Unmarshal(rec.Body.Bytes(), &errorResponse)
 		
-		assert.Equal(t, "Missing active dependencies", errorResponse["error"])
-		missingDeps := errorResponse["missing_dependencies"].([]interface{})
+		assert.Equal(t, "MISSING_DEPENDENCIES", errorResponse["code"])
+		assert.Equal(t, "Missing active dependencies", errorResponse["message"])
+		missingDeps := errorResponse["details"].(map[string]interface{})["missing_dependencies"].([]interface{})
 		assert.Contains(t, missingDeps, "user_profile_v2")
 		assert.Contains(t, missingDeps, "payment_v2")
 	})
 
 	t.Run("Enable all dependencies in correct order", func(t *testing.T) {
-		// Enable in dependency order: database_v2 -> auth_v2 -> user_profile_v2 -> checkout_v2 -> payment_v2 -> notification_v2
-		enableOrder := []int64{1, 2, 3, 4, 5, 6}
-		
+		// The enable plan orders the dependencies of notification_v2 so each comes after its own
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/flags/6/enable-plan", nil)
+		AuthenticateAs(req, "test_user")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var plan entity.EnablePlan
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &plan))
+		require.Len(t, plan.Steps, 5)
+		enableOrder := make([]int64, 0, len(plan.Steps)+1)
+		for _, step := range plan.Steps {
+			enableOrder = append(enableOrder, step.ID)
+		}
+		enableOrder = append(enableOrder, 6)
+
 		for _, flagID := range enableOrder {
 			toggleReq := validator.FlagToggleRequest{
 				Enable: true,
@@ -466,7 +498,7 @@ func TestScenario5_ComplexDependencyChain(t *testing.T) {
 			toggleJSON, _ := json.Marshal(toggleReq)
 			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/flags/%d/toggle", flagID), bytes.NewReader(toggleJSON))
 			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("X-Actor", "test_user")
+			AuthenticateAs(req, "test_user")
 			rec := httptest.NewRecorder()
 			e.ServeHTTP(rec, req)
 
@@ -482,7 +514,7 @@ func TestScenario5_ComplexDependencyChain(t *testing.T) {
 		toggleJSON, _ := json.Marshal(toggleReq)
 		req := httptest.NewRequest(http.MethodPost, "/api/v1/flags/1/toggle", bytes.NewReader(toggleJSON))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Actor", "admin_user")
+		AuthenticateAs(req, "admin_user")
 		rec := httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
@@ -493,4 +525,376 @@ func TestScenario5_ComplexDependencyChain(t *testing.T) {
 			testDB.AssertFlagStatus(t, i, entity.FlagDisabled)
 		}
 	})
-} 
\ No newline at end of file
+} 
+// TestScenario6_DelegatedActor tests that service accounts can act on behalf of a user
+func TestScenario6_DelegatedActor(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	// Setup services
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := GetTestLogger()
+	flagService := service.NewFlagService(flagRepo, auditRepo, log)
+	flagController := controller.NewFlagController(flagService, log)
+
+	// Setup Echo
+	e := echo.New()
+	cfg := &config.Config{
+		Swagger:    config.Swagger{UIEnabled: false, SpecEnabled: false},
+		Delegation: config.Delegation{ServiceAccounts: []string{"deploy-bot"}},
+		Auth:       TestAuth,
+	}
+	handler.RegisterRoutes(e, flagController, testDB.DB, cfg, log, nil)
+
+	toggle := func(flagID int64, actor, onBehalfOf string) *httptest.ResponseRecorder {
+		toggleJSON, _ := json.Marshal(validator.FlagToggleRequest{Enable: true, Reason: "Rollout pipeline"})
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/flags/%d/toggle", flagID), bytes.NewReader(toggleJSON))
+		req.Header.Set("Content-Type", "application/json")
+		AuthenticateAs(req, actor)
+		req.Header.Set("X-On-Behalf-Of", onBehalfOf)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Service account records composite actor", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "delegated_flag", entity.FlagDisabled)
+
+		rec := toggle(flag.ID, "deploy-bot", "alice")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		testDB.AssertAuditLogExists(t, flag.ID, entity.ActionEnable, "deploy-bot (on behalf of alice)")
+	})
+
+	t.Run("Other actors cannot delegate", func(t *testing.T) {
+		flag := testDB.CreateTestFlag(t, "undelegated_flag", entity.FlagDisabled)
+
+		rec := toggle(flag.ID, "mallory", "alice")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		testDB.AssertFlagStatus(t, flag.ID, entity.FlagDisabled)
+	})
+}
+
+// TestScenario7_FlagEvaluation tests the lightweight enabled endpoint used by SDKs
+func TestScenario7_FlagEvaluation(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	// Setup services
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := GetTestLogger()
+	flagService := service.NewFlagService(flagRepo, auditRepo, log)
+	flagController := controller.NewFlagController(flagService, log)
+
+	// Setup Echo
+	e := echo.New()
+	cfg := &config.Config{Swagger: config.Swagger{UIEnabled: false, SpecEnabled: false}, Auth: TestAuth}
+	handler.RegisterRoutes(e, flagController, testDB.DB, cfg, log, nil)
+
+	evaluate := func(ref, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/flags/"+ref+"/enabled", nil)
+		AuthenticateAs(req, "test_user")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// base is disabled, so checkout is enabled in storage but not effectively enabled
+	base := testDB.CreateTestFlag(t, "eval_base", entity.FlagDisabled)
+	checkout := testDB.CreateTestFlagWithDependencies(t, "eval_checkout", entity.FlagEnabled, []int64{base.ID})
+	search := testDB.CreateTestFlag(t, "eval_search", entity.FlagEnabled)
+
+	t.Run("Enabled flag", func(t *testing.T) {
+		rec := evaluate(fmt.Sprintf("%d", search.ID), "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"enabled":true}`, rec.Body.String())
+		assert.Contains(t, rec.Header().Get("Cache-Control"), "max-age=")
+	})
+
+	t.Run("Disabled flag by name as plain text", func(t *testing.T) {
+		rec := evaluate(base.Name, "text/plain")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "false", rec.Body.String())
+	})
+
+	t.Run("Enabled flag with unsatisfied dependency", func(t *testing.T) {
+		rec := evaluate(checkout.Name, "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"enabled":false}`, rec.Body.String())
+	})
+
+	t.Run("Unknown flag", func(t *testing.T) {
+		rec := evaluate("no_such_flag", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+// TestScenario8_ExpandDependencies tests inlining dependency details with ?expand=dependencies
+func TestScenario8_ExpandDependencies(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	// Setup services
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := GetTestLogger()
+	flagService := service.NewFlagService(flagRepo, auditRepo, log)
+	flagController := controller.NewFlagController(flagService, log)
+
+	// Setup Echo
+	e := echo.New()
+	cfg := &config.Config{Swagger: config.Swagger{UIEnabled: false, SpecEnabled: false}, Auth: TestAuth}
+	handler.RegisterRoutes(e, flagController, testDB.DB, cfg, log, nil)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		AuthenticateAs(req, "test_user")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	auth := testDB.CreateTestFlag(t, "expand_auth", entity.FlagEnabled)
+	payments := testDB.CreateTestFlag(t, "expand_payments", entity.FlagDisabled)
+	checkout := testDB.CreateTestFlagWithDependencies(t, "expand_checkout", entity.FlagDisabled, []int64{auth.ID, payments.ID})
+
+	t.Run("Default response keeps dependency IDs only", func(t *testing.T) {
+		rec := get(fmt.Sprintf("/api/v1/flags/%d", checkout.ID))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var flag map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &flag))
+		assert.Len(t, flag["dependencies"], 2)
+		assert.NotContains(t, flag, "dependencies_detail")
+	})
+
+	t.Run("Single flag with expanded dependencies", func(t *testing.T) {
+		rec := get(fmt.Sprintf("/api/v1/flags/%d?expand=dependencies", checkout.ID))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var flag entity.Flag
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &flag))
+		assert.ElementsMatch(t, []int64{auth.ID, payments.ID}, flag.Dependencies)
+		assert.ElementsMatch(t, []entity.GraphNode{
+			{ID: auth.ID, Name: "expand_auth", Status: entity.FlagEnabled},
+			{ID: payments.ID, Name: "expand_payments", Status: entity.FlagDisabled},
+		}, flag.DependenciesDetail)
+	})
+
+	t.Run("List with expanded dependencies", func(t *testing.T) {
+		rec := get("/api/v1/flags?expand=dependencies")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response struct {
+			Flags []entity.Flag `json:"flags"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.Len(t, response.Flags, 3)
+		for _, flag := range response.Flags {
+			if flag.ID == checkout.ID {
+				assert.Len(t, flag.DependenciesDetail, 2)
+			} else {
+				assert.Empty(t, flag.DependenciesDetail)
+			}
+		}
+	})
+
+	t.Run("Unsupported expand value", func(t *testing.T) {
+		rec := get(fmt.Sprintf("/api/v1/flags/%d?expand=audit", checkout.ID))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+// TestScenario9_FlagDetail tests the composite detail document and its include parameter
+func TestScenario9_FlagDetail(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	// Setup services
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	log := GetTestLogger()
+	flagService := service.NewFlagService(flagRepo, auditRepo, log)
+	flagController := controller.NewFlagController(flagService, log)
+
+	// Setup Echo
+	e := echo.New()
+	cfg := &config.Config{Swagger: config.Swagger{UIEnabled: false, SpecEnabled: false}, Auth: TestAuth}
+	handler.RegisterRoutes(e, flagController, testDB.DB, cfg, log, nil)
+
+	auth := testDB.CreateTestFlag(t, "detail_auth", entity.FlagEnabled)
+	checkout := testDB.CreateTestFlagWithDependencies(t, "detail_checkout", entity.FlagEnabled, []int64{auth.ID})
+	testDB.CreateTestFlagWithDependencies(t, "detail_mobile", entity.FlagDisabled, []int64{checkout.ID})
+	_, err := flagService.DisableFlag(context.Background(), checkout.ID, "test_user", "Rollback checkout")
+	require.NoError(t, err)
+
+	getDetail := func(t *testing.T, query string) map[string]interface{} {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/flags/%d/detail%s", checkout.ID, query), nil)
+		AuthenticateAs(req, "test_user")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var detail map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &detail))
+		return detail
+	}
+
+	t.Run("All sections by default", func(t *testing.T) {
+		detail := getDetail(t, "")
+		assert.Equal(t, "detail_checkout", detail["flag"].(map[string]interface{})["name"])
+		assert.Len(t, detail["dependencies"], 1)
+		assert.Len(t, detail["dependents"], 1)
+		assert.Len(t, detail["audit"], 1)
+	})
+
+	t.Run("Only dependencies", func(t *testing.T) {
+		detail := getDetail(t, "?include=dependencies")
+		assert.Contains(t, detail, "flag")
+		assert.Contains(t, detail, "dependencies")
+		assert.NotContains(t, detail, "dependents")
+		assert.NotContains(t, detail, "audit")
+	})
+
+	t.Run("Dependents and audit", func(t *testing.T) {
+		detail := getDetail(t, "?include=dependents,audit")
+		assert.NotContains(t, detail, "dependencies")
+		dependents := detail["dependents"].([]interface{})
+		require.Len(t, dependents, 1)
+		assert.Equal(t, "detail_mobile", dependents[0].(map[string]interface{})["name"])
+		assert.Len(t, detail["audit"], 1)
+	})
+
+	t.Run("Unsupported include value", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/flags/%d/detail?include=notes", checkout.ID), nil)
+		AuthenticateAs(req, "test_user")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Unknown flag", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/flags/999/detail", nil)
+		AuthenticateAs(req, "test_user")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+// exportOnlyService stands in for the flag service of another instance, serving a fixed export
+type exportOnlyService struct {
+	service.FlagService
+	export *entity.FlagExport
+}
+
+func (s exportOnlyService) ExportFlags(context.Context) (*entity.FlagExport, error) {
+	return s.export, nil
+}
+
+// TestScenario10_SyncFromInstance tests importing the flags of another instance over HTTP
+func TestScenario10_SyncFromInstance(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Close()
+	defer testDB.CleanTables(t)
+
+	log := GetTestLogger()
+
+	// The source instance only accepts its own token
+	source := &exportOnlyService{export: &entity.FlagExport{Version: entity.FlagExportVersion, Flags: []*entity.FlagDefinition{
+		{Name: "sync_auth", Status: entity.FlagEnabled, Description: "Auth from staging", Dependencies: []string{}},
+		{Name: "sync_checkout", Status: entity.FlagEnabled, Dependencies: []string{"sync_auth"}},
+	}}}
+	sourceEcho := echo.New()
+	sourceCfg := &config.Config{Auth: config.Auth{Tokens: map[string]string{"source-token": "sync_reader"}}}
+	handler.RegisterRoutes(sourceEcho, controller.NewFlagController(source, log), testDB.DB, sourceCfg, log, nil)
+	sourceServer := httptest.NewServer(sourceEcho)
+	defer sourceServer.Close()
+
+	// Setup the target instance
+	flagRepo := repository.NewFlagRepository(testDB.DB)
+	auditRepo := repository.NewAuditRepository(testDB.DB)
+	flagService := service.NewFlagService(flagRepo, auditRepo, log)
+	e := echo.New()
+	cfg := &config.Config{Auth: TestAuth, Admin: config.Admin{Actors: []string{"admin_user"}}}
+	handler.RegisterRoutes(e, controller.NewFlagController(flagService, log), testDB.DB, cfg, log, nil)
+
+	sync := func(actor string, body validator.FlagSyncRequest) *httptest.ResponseRecorder {
+		syncJSON, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/flags/sync-from", bytes.NewReader(syncJSON))
+		req.Header.Set("Content-Type", "application/json")
+		AuthenticateAs(req, actor)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Only admins can sync", func(t *testing.T) {
+		rec := sync("test_user", validator.FlagSyncRequest{SourceURL: sourceServer.URL, APIKey: "source-token"})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("Source rejects a wrong API key", func(t *testing.T) {
+		rec := sync("admin_user", validator.FlagSyncRequest{SourceURL: sourceServer.URL, APIKey: "wrong-token"})
+		assert.Equal(t, http.StatusBadGateway, rec.Code)
+		assert.Contains(t, rec.Body.String(), controller.CodeSyncSourceFailed)
+		assert.NotContains(t, rec.Body.String(), "wrong-token")
+	})
+
+	t.Run("Unreachable source", func(t *testing.T) {
+		rec := sync("admin_user", validator.FlagSyncRequest{SourceURL: "http://127.0.0.1:1", APIKey: "source-token"})
+		assert.Equal(t, http.StatusBadGateway, rec.Code)
+	})
+
+	t.Run("Flags of the source are created", func(t *testing.T) {
+		rec := sync("admin_user", validator.FlagSyncRequest{SourceURL: sourceServer.URL, APIKey: "source-token"})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var result entity.FlagImportResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.Equal(t, []string{"sync_auth", "sync_checkout"}, result.Created)
+
+		checkout, err := flagRepo.GetFlagByName(context.Background(), "sync_checkout")
+		require.NoError(t, err)
+		assert.Equal(t, entity.FlagDisabled, checkout.Status)
+		testDB.AssertAuditLogExists(t, checkout.ID, entity.ActionCreate, "admin_user")
+	})
+
+	t.Run("Existing flags are kept unless overwritten", func(t *testing.T) {
+		source.export.Flags[0].Description = "Auth from staging, revised"
+
+		rec := sync("admin_user", validator.FlagSyncRequest{SourceURL: sourceServer.URL, APIKey: "source-token"})
+		require.Equal(t, http.StatusOK, rec.Code)
+		var result entity.FlagImportResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.Empty(t, result.Updated)
+		assert.Equal(t, []entity.SkippedFlag{{Name: "sync_auth", Reason: "exists"}, {Name: "sync_checkout", Reason: "exists"}}, result.Skipped)
+
+		rec = sync("admin_user", validator.FlagSyncRequest{SourceURL: sourceServer.URL, APIKey: "source-token", Overwrite: true})
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.Equal(t, []string{"sync_auth"}, result.Updated)
+	})
+
+	t.Run("Export of another version is refused", func(t *testing.T) {
+		source.export.Version = entity.FlagExportVersion + 1
+		defer func() { source.export.Version = entity.FlagExportVersion }()
+
+		rec := sync("admin_user", validator.FlagSyncRequest{SourceURL: sourceServer.URL, APIKey: "source-token"})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "unsupported export version")
+	})
+}
@@ -0,0 +1,68 @@
+package validator
+
+import (
+	"errors"
+	"strings"
+)
+
+// MaxActorLength is the longest actor identity accepted
+const MaxActorLength = 100
+
+// SystemActor is the actor recorded for changes the service makes on its own, such as
+// cascades. Clients can never act as it.
+const SystemActor = "system"
+
+// delegationMarker separates a service account from the user it acts for
+const delegationMarker = " (on behalf of "
+
+// ErrActorNotAllowed is returned when an actor is rejected by the configured actor policy
+var ErrActorNotAllowed = errors.New("actor is not allowed")
+
+// ActorPolicy restricts which actors may make changes. A nil policy admits every actor.
+type ActorPolicy struct {
+	allow map[string]bool
+	deny  map[string]bool
+}
+
+// NewActorPolicy builds an actor policy. A non-empty allowlist admits only the listed actors;
+// the denylist rejects the listed actors. Nil or empty lists disable the respective check.
+func NewActorPolicy(allowlist, denylist []string) *ActorPolicy {
+	return &ActorPolicy{allow: toSet(allowlist), deny: toSet(denylist)}
+}
+
+// ActorPrincipal returns the identity responsible for a change. For delegated actors
+// ("deploy-bot (on behalf of alice)") that is the service account.
+func ActorPrincipal(actor string) string {
+	if i := strings.Index(actor, delegationMarker); i > 0 {
+		return actor[:i]
+	}
+	return actor
+}
+
+// Check returns ErrActorNotAllowed if the actor's principal is outside the policy. The policy
+// is for clients; the service's own changes as SystemActor always pass.
+func (p *ActorPolicy) Check(actor string) error {
+	if p == nil || actor == SystemActor {
+		return nil
+	}
+
+	principal := ActorPrincipal(actor)
+	if p.deny[principal] {
+		return ErrActorNotAllowed
+	}
+	if len(p.allow) > 0 && !p.allow[principal] {
+		return ErrActorNotAllowed
+	}
+	return nil
+}
+
+func toSet(items []string) map[string]bool {
+	if len(items) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
@@ -0,0 +1,37 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActorPolicy_Check(t *testing.T) {
+	t.Run("no policy allows everyone", func(t *testing.T) {
+		var policy *ActorPolicy
+		assert.NoError(t, policy.Check("anyone"))
+		assert.NoError(t, NewActorPolicy(nil, nil).Check("anyone"))
+	})
+
+	t.Run("allowlist", func(t *testing.T) {
+		policy := NewActorPolicy([]string{"alice", "deploy-bot"}, nil)
+
+		assert.NoError(t, policy.Check("alice"))
+		assert.NoError(t, policy.Check("deploy-bot (on behalf of mallory)"))
+		assert.ErrorIs(t, policy.Check("mallory"), ErrActorNotAllowed)
+		assert.ErrorIs(t, policy.Check("anonymous"), ErrActorNotAllowed)
+	})
+
+	t.Run("system actor is not subject to the policy", func(t *testing.T) {
+		policy := NewActorPolicy([]string{"alice"}, []string{SystemActor})
+
+		assert.NoError(t, policy.Check(SystemActor))
+	})
+
+	t.Run("denylist", func(t *testing.T) {
+		policy := NewActorPolicy(nil, []string{"anonymous"})
+
+		assert.NoError(t, policy.Check("alice"))
+		assert.ErrorIs(t, policy.Check("anonymous"), ErrActorNotAllowed)
+	})
+}
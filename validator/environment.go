@@ -0,0 +1,32 @@
+package validator
+
+import (
+	"regexp"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// MaxEnvironmentNameLength matches the environments.name column size
+const MaxEnvironmentNameLength = 64
+
+var environmentNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// EnvironmentCreateRequest represents the request payload for adding an environment
+type EnvironmentCreateRequest struct {
+	Name string `json:"name" validate:"required,environment_name"`
+}
+
+// validateEnvironmentName checks that an environment name is short, lowercase and limited to
+// letters, digits, '_' and '-'
+func validateEnvironmentName(fl validator.FieldLevel) bool {
+	name := fl.Field().String()
+	return len(name) <= MaxEnvironmentNameLength && environmentNameRegexp.MatchString(name)
+}
+
+// ValidateEnvironmentCreateRequest validates an environment creation request
+func ValidateEnvironmentCreateRequest(req EnvironmentCreateRequest) error {
+	if err := validate.Struct(req); err != nil {
+		return formatValidationErrors(err)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package validator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateEnvironmentCreateRequest(t *testing.T) {
+	assert.NoError(t, ValidateEnvironmentCreateRequest(EnvironmentCreateRequest{Name: "qa"}))
+	assert.NoError(t, ValidateEnvironmentCreateRequest(EnvironmentCreateRequest{Name: "eu-west_2"}))
+
+	var validationErrs ValidationErrors
+	require.ErrorAs(t, ValidateEnvironmentCreateRequest(EnvironmentCreateRequest{Name: "QA"}), &validationErrs)
+	assert.Equal(t, "Name", validationErrs.Errors[0].Field)
+	assert.Error(t, ValidateEnvironmentCreateRequest(EnvironmentCreateRequest{}))
+	assert.Error(t, ValidateEnvironmentCreateRequest(EnvironmentCreateRequest{Name: "-qa"}))
+	assert.Error(t, ValidateEnvironmentCreateRequest(EnvironmentCreateRequest{Name: strings.Repeat("a", MaxEnvironmentNameLength+1)}))
+}
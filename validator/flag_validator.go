@@ -1,9 +1,14 @@
 package validator
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -15,18 +20,167 @@ func init() {
 	
 	// Register custom validations
 	validate.RegisterValidation("flag_name", validateFlagName)
+	validate.RegisterValidation("no_control", validateNoControlChars)
+	validate.RegisterValidation("tag_key", validateTagKey)
+	validate.RegisterValidation("environment_name", validateEnvironmentName)
 }
 
 // FlagCreateRequest represents the request payload for creating a flag
 type FlagCreateRequest struct {
-	Name         string  `json:"name" validate:"required,flag_name,min=3,max=100"`
-	Dependencies []int64 `json:"dependencies,omitempty" validate:"dive,gt=0"`
+	Name            string            `json:"name" validate:"required,flag_name,min=3,max=100"`
+	Description     string            `json:"description,omitempty" validate:"omitempty,max=1000"`
+	Dependencies    []int64           `json:"dependencies,omitempty" validate:"dive,gt=0"`
+	DependencyNames []string          `json:"dependency_names,omitempty" validate:"excluded_with=Dependencies,max=100,dive,required,max=100"`
+	Tags            map[string]string `json:"tags,omitempty" validate:"omitempty,max=50,dive,keys,tag_key,endkeys,required,max=100,no_control"`
+	CascadeStrategy string            `json:"cascade_strategy,omitempty" validate:"omitempty,oneof=disable maintenance"`
+	DisablePolicy   string            `json:"disable_policy,omitempty" validate:"omitempty,oneof=cascade block"`
+	HighRisk        bool              `json:"high_risk,omitempty"`
+	// RolloutPercentage defaults to 100, i.e. on for every user once enabled
+	RolloutPercentage *int `json:"rollout_percentage,omitempty" validate:"omitempty,min=0,max=100"`
+	// ExpiresAt is an RFC3339 timestamp after which an enabled flag is disabled automatically
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// FlagBulkCreateRequest represents the request payload for creating several flags at once
+type FlagBulkCreateRequest struct {
+	Flags []FlagBulkCreateItem `json:"flags" validate:"required,min=1,max=100"`
+}
+
+// FlagBulkCreateItem is one flag of a bulk create. Dependencies may name other flags of the
+// same batch as well as reference existing flags by ID or name.
+type FlagBulkCreateItem struct {
+	FlagCreateRequest
+	Dependencies []FlagRef `json:"dependencies,omitempty"`
+}
+
+// FlagImportRequest is a flag export document to apply to this database
+type FlagImportRequest struct {
+	Version int              `json:"version"`
+	Flags   []FlagImportItem `json:"flags" validate:"required,min=1,max=1000"`
+}
+
+// FlagImportItem is one flag definition of an import. It takes the same fields as a single
+// create, but dependencies are referenced by name; an exported status is ignored.
+type FlagImportItem struct {
+	FlagCreateRequest
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+// FlagSyncRequest names another instance whose flags are imported into this one. APIKey is
+// sent to the source as a bearer token. Without Overwrite, flags that already exist here are
+// left as they are.
+type FlagSyncRequest struct {
+	SourceURL string `json:"source_url" validate:"required,http_url,max=2000"`
+	APIKey    string `json:"api_key" validate:"max=1000"`
+	Overwrite bool   `json:"overwrite"`
+}
+
+// FlagUpdateRequest represents the request payload for updating a flag. Omitted fields are left
+// unchanged. Dependencies and Tags replace the flag's full set; an empty list or object
+// removes them all.
+type FlagUpdateRequest struct {
+	Description       *string           `json:"description,omitempty" validate:"omitempty,max=1000"`
+	Dependencies      []int64           `json:"dependencies" validate:"dive,gt=0"`
+	Tags              map[string]string `json:"tags" validate:"max=50,dive,keys,tag_key,endkeys,required,max=100,no_control"`
+	RolloutPercentage *int              `json:"rollout_percentage,omitempty" validate:"omitempty,min=0,max=100"`
 }
 
 // FlagToggleRequest represents the request payload for toggling a flag
 type FlagToggleRequest struct {
-	Enable bool   `json:"enable"`
-	Reason string `json:"reason" validate:"required,min=3,max=500"`
+	Enable            bool   `json:"enable"`
+	Reason            string `json:"reason" validate:"required,min=3,max=500,no_control"`
+	ConfirmationToken string `json:"confirmation_token,omitempty"`
+	// Cascade on an enable first enables the flag's disabled transitive dependencies
+	Cascade bool `json:"cascade,omitempty"`
+	// IdempotencyKey comes from the Idempotency-Key header; a retry with the same key is
+	// answered with the first outcome instead of being applied again
+	IdempotencyKey string `json:"-" validate:"max=255"`
+}
+
+// FlagReasonRequest represents the request payload for actions that only need a reason
+type FlagReasonRequest struct {
+	Reason string `json:"reason" validate:"required,min=3,max=500,no_control"`
+}
+
+// FlagResumeRequest represents the request payload for bringing a flag back from maintenance
+type FlagResumeRequest struct {
+	Reason            string `json:"reason" validate:"required,min=3,max=500,no_control"`
+	ConfirmationToken string `json:"confirmation_token,omitempty"`
+}
+
+// FlagRestoreSubtreeRequest represents the request payload for restoring a flag's subtree
+type FlagRestoreSubtreeRequest struct {
+	Reason            string `json:"reason" validate:"required,min=3,max=500,no_control"`
+	ConfirmationToken string `json:"confirmation_token,omitempty"`
+}
+
+// FlagBulkToggleRequest represents the request payload for enabling or disabling several
+// flags at once
+type FlagBulkToggleRequest struct {
+	FlagIDs []int64 `json:"flag_ids" validate:"required,min=1,max=100,dive,gt=0"`
+	Enable  bool    `json:"enable"`
+	Reason  string  `json:"reason" validate:"required,min=3,max=500,no_control"`
+}
+
+// FlagRenameRequest represents the request payload for renaming a flag
+type FlagRenameRequest struct {
+	Name string `json:"name" validate:"required,flag_name,min=3,max=100"`
+}
+
+// FlagDependencyRequest represents the request payload for attaching a dependency to a flag
+type FlagDependencyRequest struct {
+	DependsOnID int64 `json:"depends_on_id" validate:"required,gt=0"`
+}
+
+// FlagScheduleRequest represents the request payload for scheduling an enable or disable.
+// ScheduledAt is an RFC3339 timestamp.
+type FlagScheduleRequest struct {
+	Status      string    `json:"status" validate:"required,oneof=enabled disabled"`
+	ScheduledAt time.Time `json:"scheduled_at" validate:"required"`
+	Reason      string    `json:"reason" validate:"required,min=3,max=500,no_control"`
+}
+
+// FlagRolloutScheduleRequest represents the request payload for a canary rollout: the rollout
+// percentage is set to StartPercentage and raised by StepPercentage every StepIntervalMinutes
+// until it reaches 100.
+type FlagRolloutScheduleRequest struct {
+	StartPercentage     int    `json:"start_percentage" validate:"min=0,max=100"`
+	StepPercentage      int    `json:"step_percentage" validate:"required,min=1,max=100"`
+	StepIntervalMinutes int    `json:"step_interval_minutes" validate:"required,min=1,max=10080"`
+	Reason              string `json:"reason" validate:"required,min=3,max=500,no_control"`
+}
+
+// FlagEvaluateRequest represents the request payload for evaluating several flags at once.
+// Without flags, every flag is evaluated. UserID is accepted as another name for Key.
+type FlagEvaluateRequest struct {
+	Key    string    `json:"key" validate:"max=256,no_control"`
+	UserID string    `json:"user_id,omitempty" validate:"max=256,no_control"`
+	Flags  []FlagRef `json:"flags,omitempty" validate:"max=1000"`
+}
+
+// EvaluationKey returns the key rollouts are evaluated against, preferring Key over UserID
+func (r FlagEvaluateRequest) EvaluationKey() string {
+	if r.Key != "" {
+		return r.Key
+	}
+	return r.UserID
+}
+
+// FlagRef references a flag by ID or name. Both JSON numbers and strings are accepted.
+type FlagRef string
+
+func (r *FlagRef) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*r = FlagRef(name)
+		return nil
+	}
+	var id int64
+	if err := json.Unmarshal(data, &id); err != nil {
+		return errors.New("flag reference must be a flag name or ID")
+	}
+	*r = FlagRef(strconv.FormatInt(id, 10))
+	return nil
 }
 
 // ValidationError represents a validation error with field details
@@ -56,6 +210,40 @@ func ValidateFlagCreateRequest(req FlagCreateRequest) error {
 	return nil
 }
 
+// ValidateFlagBulkCreateRequest validates the size of a bulk create request. The flags in it
+// are validated one by one, so each error can be attributed to its flag.
+func ValidateFlagBulkCreateRequest(req FlagBulkCreateRequest) error {
+	if err := validate.Struct(req); err != nil {
+		return formatValidationErrors(err)
+	}
+	return nil
+}
+
+// ValidateFlagImportRequest validates the size of an import. Like bulk creates, its flags are
+// validated one by one.
+func ValidateFlagImportRequest(req FlagImportRequest) error {
+	if err := validate.Struct(req); err != nil {
+		return formatValidationErrors(err)
+	}
+	return nil
+}
+
+// ValidateFlagSyncRequest validates a sync request
+func ValidateFlagSyncRequest(req FlagSyncRequest) error {
+	if err := validate.Struct(req); err != nil {
+		return formatValidationErrors(err)
+	}
+	return nil
+}
+
+// ValidateFlagUpdateRequest validates a flag update request
+func ValidateFlagUpdateRequest(req FlagUpdateRequest) error {
+	if err := validate.Struct(req); err != nil {
+		return formatValidationErrors(err)
+	}
+	return nil
+}
+
 // ValidateFlagToggleRequest validates a flag toggle request
 func ValidateFlagToggleRequest(req FlagToggleRequest) error {
 	if err := validate.Struct(req); err != nil {
@@ -64,6 +252,78 @@ func ValidateFlagToggleRequest(req FlagToggleRequest) error {
 	return nil
 }
 
+// ValidateFlagReasonRequest validates a reason-only request
+func ValidateFlagReasonRequest(req FlagReasonRequest) error {
+	if err := validate.Struct(req); err != nil {
+		return formatValidationErrors(err)
+	}
+	return nil
+}
+
+// ValidateFlagResumeRequest validates a resume request
+func ValidateFlagResumeRequest(req FlagResumeRequest) error {
+	if err := validate.Struct(req); err != nil {
+		return formatValidationErrors(err)
+	}
+	return nil
+}
+
+// ValidateFlagRestoreSubtreeRequest validates a subtree restore request
+func ValidateFlagRestoreSubtreeRequest(req FlagRestoreSubtreeRequest) error {
+	if err := validate.Struct(req); err != nil {
+		return formatValidationErrors(err)
+	}
+	return nil
+}
+
+// ValidateFlagBulkToggleRequest validates a bulk toggle request
+func ValidateFlagBulkToggleRequest(req FlagBulkToggleRequest) error {
+	if err := validate.Struct(req); err != nil {
+		return formatValidationErrors(err)
+	}
+	return nil
+}
+
+// ValidateFlagRenameRequest validates a flag rename request
+func ValidateFlagRenameRequest(req FlagRenameRequest) error {
+	if err := validate.Struct(req); err != nil {
+		return formatValidationErrors(err)
+	}
+	return nil
+}
+
+// ValidateFlagDependencyRequest validates a request to attach a dependency
+func ValidateFlagDependencyRequest(req FlagDependencyRequest) error {
+	if err := validate.Struct(req); err != nil {
+		return formatValidationErrors(err)
+	}
+	return nil
+}
+
+// ValidateFlagScheduleRequest validates a schedule request
+func ValidateFlagScheduleRequest(req FlagScheduleRequest) error {
+	if err := validate.Struct(req); err != nil {
+		return formatValidationErrors(err)
+	}
+	return nil
+}
+
+// ValidateFlagRolloutScheduleRequest validates a rollout schedule request
+func ValidateFlagRolloutScheduleRequest(req FlagRolloutScheduleRequest) error {
+	if err := validate.Struct(req); err != nil {
+		return formatValidationErrors(err)
+	}
+	return nil
+}
+
+// ValidateFlagEvaluateRequest validates a batch evaluation request
+func ValidateFlagEvaluateRequest(req FlagEvaluateRequest) error {
+	if err := validate.Struct(req); err != nil {
+		return formatValidationErrors(err)
+	}
+	return nil
+}
+
 // ValidateFlagID validates a flag ID
 func ValidateFlagID(id int64) error {
 	if id <= 0 {
@@ -72,13 +332,32 @@ func ValidateFlagID(id int64) error {
 	return nil
 }
 
-// ValidateActor validates an actor name
+// ValidateFlagName validates a flag name used to look a flag up. It applies the character and
+// length rules for new names but not the naming convention, which older flags may predate.
+func ValidateFlagName(name string) error {
+	if err := validate.Var(name, "required,flag_name,min=3,max=100"); err != nil {
+		formatted := formatValidationErrors(err).(ValidationErrors)
+		for i := range formatted.Errors {
+			formatted.Errors[i].Field = "name"
+		}
+		return formatted
+	}
+	return nil
+}
+
+// ValidateActor validates an actor name. The configured ActorPolicy is checked separately.
 func ValidateActor(actor string) error {
 	if actor == "" {
 		return errors.New("actor is required")
 	}
-	if len(actor) > 100 {
-		return errors.New("actor name too long (max 100 characters)")
+	if len(actor) > MaxActorLength {
+		return fmt.Errorf("actor name too long (max %d characters)", MaxActorLength)
+	}
+	if hasControlChars(actor) {
+		return ValidationErrors{Errors: []ValidationError{{
+			Field:   "actor",
+			Message: "Must be a single line without control characters",
+		}}}
 	}
 	return nil
 }
@@ -98,22 +377,42 @@ func validateFlagName(fl validator.FieldLevel) bool {
 	name := fl.Field().String()
 	
 	// Flag name should only contain alphanumeric characters, underscores, and hyphens
+	digitsOnly := true
 	for _, char := range name {
-		if !((char >= 'a' && char <= 'z') || 
-			 (char >= 'A' && char <= 'Z') || 
-			 (char >= '0' && char <= '9') || 
-			 char == '_' || char == '-') {
+		if char < '0' || char > '9' {
+			digitsOnly = false
+		}
+		if !((char >= 'a' && char <= 'z') ||
+			(char >= 'A' && char <= 'Z') ||
+			(char >= '0' && char <= '9') ||
+			char == '_' || char == '-') {
 			return false
 		}
 	}
 	
 	// Should not start or end with underscore or hyphen
 	if strings.HasPrefix(name, "_") || strings.HasPrefix(name, "-") ||
-	   strings.HasSuffix(name, "_") || strings.HasSuffix(name, "-") {
+		strings.HasSuffix(name, "_") || strings.HasSuffix(name, "-") {
 		return false
 	}
-	
-	return true
+
+	// Flags are looked up by ID or name in the same place, so a name cannot be a number
+	return !digitsOnly
+}
+
+// validateNoControlChars rejects newlines, tabs and other control characters, which would
+// break single-line log output and CSV exports of the audit trail
+func validateNoControlChars(fl validator.FieldLevel) bool {
+	return !hasControlChars(fl.Field().String())
+}
+
+func hasControlChars(s string) bool {
+	for _, char := range s {
+		if unicode.IsControl(char) {
+			return true
+		}
+	}
+	return false
 }
 
 // formatValidationErrors formats validator errors into a custom error format
@@ -127,13 +426,34 @@ func formatValidationErrors(err error) error {
 		case "required":
 			message = "This field is required"
 		case "flag_name":
-			message = "Flag name must contain only alphanumeric characters, underscores, and hyphens, and cannot start or end with underscore or hyphen"
+			message = "Flag name must contain only alphanumeric characters, underscores, and hyphens, cannot start or end with underscore or hyphen, and cannot consist of digits only"
 		case "min":
 			message = fmt.Sprintf("Must be at least %s characters long", err.Param())
+			if err.Kind() == reflect.Int {
+				message = fmt.Sprintf("Must be at least %s", err.Param())
+			}
 		case "max":
 			message = fmt.Sprintf("Must be at most %s characters long", err.Param())
+			switch err.Kind() {
+			case reflect.Slice, reflect.Map:
+				message = fmt.Sprintf("Must contain at most %s items", err.Param())
+			case reflect.Int:
+				message = fmt.Sprintf("Must be at most %s", err.Param())
+			}
 		case "gt":
 			message = fmt.Sprintf("Must be greater than %s", err.Param())
+		case "environment_name":
+			message = fmt.Sprintf("Environment name must be at most %d lowercase letters, digits, underscores or hyphens, starting with a letter or digit", MaxEnvironmentNameLength)
+		case "http_url":
+			message = "Must be an http or https URL"
+		case "tag_key":
+			message = fmt.Sprintf("Tag key must be at most %d letters, digits, underscores, dots or hyphens", MaxTagKeyLength)
+		case "no_control":
+			message = "Must be a single line without control characters"
+		case "excluded_with":
+			message = fmt.Sprintf("Cannot be set together with %s", err.Param())
+		case "oneof":
+			message = fmt.Sprintf("Must be one of: %s", strings.ReplaceAll(err.Param(), " ", ", "))
 		default:
 			message = "Invalid value"
 		}
@@ -145,4 +465,4 @@ func formatValidationErrors(err error) error {
 	}
 	
 	return ValidationErrors{Errors: validationErrors}
-} 
\ No newline at end of file
+}
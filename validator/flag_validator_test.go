@@ -0,0 +1,140 @@
+package validator
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateFlagToggleRequest_ControlCharacters(t *testing.T) {
+	tests := []struct {
+		name   string
+		reason string
+	}{
+		{"newline", "rollback\nINFO forged log line"},
+		{"carriage return", "rollback\r\nmore"},
+		{"tab", "roll\tback"},
+		{"null byte", "rollback\x00"},
+		{"escape sequence", "rollback \x1b[31mred"},
+		{"C1 control", "rollback \u0085"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFlagToggleRequest(FlagToggleRequest{Enable: true, Reason: tt.reason})
+			require.Error(t, err)
+
+			var validationErrs ValidationErrors
+			require.ErrorAs(t, err, &validationErrs)
+			require.Len(t, validationErrs.Errors, 1)
+			assert.Equal(t, "Reason", validationErrs.Errors[0].Field)
+		})
+	}
+
+	t.Run("plain reason is accepted", func(t *testing.T) {
+		assert.NoError(t, ValidateFlagToggleRequest(FlagToggleRequest{Enable: true, Reason: "Rollback after incident #42 – ünïcode ok"}))
+	})
+
+	t.Run("reason-only requests use the same rule", func(t *testing.T) {
+		assert.Error(t, ValidateFlagReasonRequest(FlagReasonRequest{Reason: "maintenance\nwindow"}))
+		assert.NoError(t, ValidateFlagReasonRequest(FlagReasonRequest{Reason: "maintenance window"}))
+	})
+}
+
+func TestValidateActor_ControlCharacters(t *testing.T) {
+	for _, actor := range []string{"alice\nadmin", "alice\t", "alice\x00", "\x1b[2Jalice"} {
+		err := ValidateActor(actor)
+		require.Error(t, err, "actor %q", actor)
+
+		var validationErrs ValidationErrors
+		require.ErrorAs(t, err, &validationErrs)
+		assert.Equal(t, "actor", validationErrs.Errors[0].Field)
+	}
+
+	assert.NoError(t, ValidateActor("deploy-bot (on behalf of alice)"))
+}
+
+func TestFlagEvaluateRequest_FlagRefs(t *testing.T) {
+	var req FlagEvaluateRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"key":"user-42","flags":["checkout_v2",7]}`), &req))
+	assert.Equal(t, []FlagRef{"checkout_v2", "7"}, req.Flags)
+	assert.NoError(t, ValidateFlagEvaluateRequest(req))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"flags":[true]}`), &req))
+
+	tooMany := FlagEvaluateRequest{Flags: make([]FlagRef, 1001)}
+	var validationErrs ValidationErrors
+	require.ErrorAs(t, ValidateFlagEvaluateRequest(tooMany), &validationErrs)
+	assert.Equal(t, "Must contain at most 1000 items", validationErrs.Errors[0].Message)
+}
+
+func TestValidateFlagCreateRequest_RolloutPercentage(t *testing.T) {
+	create := func(pct int) error {
+		return ValidateFlagCreateRequest(FlagCreateRequest{Name: "rollout_flag", RolloutPercentage: &pct})
+	}
+
+	assert.NoError(t, ValidateFlagCreateRequest(FlagCreateRequest{Name: "rollout_flag"}))
+	assert.NoError(t, create(0))
+	assert.NoError(t, create(100))
+
+	var validationErrs ValidationErrors
+	require.ErrorAs(t, create(101), &validationErrs)
+	assert.Equal(t, "Must be at most 100", validationErrs.Errors[0].Message)
+	require.ErrorAs(t, create(-1), &validationErrs)
+	assert.Equal(t, "Must be at least 0", validationErrs.Errors[0].Message)
+}
+
+func TestValidateFlagCreateRequest_DependencyNames(t *testing.T) {
+	assert.NoError(t, ValidateFlagCreateRequest(FlagCreateRequest{Name: "named_deps", DependencyNames: []string{"auth_v2"}}))
+	assert.Error(t, ValidateFlagCreateRequest(FlagCreateRequest{Name: "named_deps", DependencyNames: []string{""}}))
+
+	var validationErrs ValidationErrors
+	err := ValidateFlagCreateRequest(FlagCreateRequest{
+		Name:            "named_deps",
+		Dependencies:    []int64{1},
+		DependencyNames: []string{"auth_v2"},
+	})
+	require.ErrorAs(t, err, &validationErrs)
+	assert.Equal(t, "DependencyNames", validationErrs.Errors[0].Field)
+	assert.Equal(t, "Cannot be set together with Dependencies", validationErrs.Errors[0].Message)
+}
+
+func TestValidateFlagName(t *testing.T) {
+	assert.NoError(t, ValidateFlagName("checkout_v2"))
+
+	var validationErrs ValidationErrors
+	require.ErrorAs(t, ValidateFlagName("_checkout"), &validationErrs)
+	assert.Equal(t, "name", validationErrs.Errors[0].Field)
+	assert.Error(t, ValidateFlagName(""))
+	assert.Error(t, ValidateFlagName("ab"))
+	assert.Error(t, ValidateFlagName("checkout v2"))
+	assert.Error(t, ValidateFlagName("2024"), "a numeric name would be read as a flag ID")
+	assert.NoError(t, ValidateFlagName("2024_launch"))
+}
+
+func TestValidateFlagScheduleRequest(t *testing.T) {
+	var req FlagScheduleRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"status":"enabled","scheduled_at":"2030-01-02T15:04:05Z","reason":"launch day"}`), &req))
+	assert.NoError(t, ValidateFlagScheduleRequest(req))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"scheduled_at":"tomorrow"}`), &req))
+
+	var validationErrs ValidationErrors
+	require.ErrorAs(t, ValidateFlagScheduleRequest(FlagScheduleRequest{Status: "enabled", Reason: "launch day"}), &validationErrs)
+	assert.Equal(t, "ScheduledAt", validationErrs.Errors[0].Field)
+
+	req.Status = "maintenance"
+	assert.Error(t, ValidateFlagScheduleRequest(req))
+}
+
+func TestValidateFlagSyncRequest(t *testing.T) {
+	assert.NoError(t, ValidateFlagSyncRequest(FlagSyncRequest{SourceURL: "https://flags.staging.example.com"}))
+
+	var validationErrs ValidationErrors
+	require.ErrorAs(t, ValidateFlagSyncRequest(FlagSyncRequest{SourceURL: "ftp://flags.example.com"}), &validationErrs)
+	assert.Equal(t, "SourceURL", validationErrs.Errors[0].Field)
+	assert.Equal(t, "Must be an http or https URL", validationErrs.Errors[0].Message)
+	assert.Error(t, ValidateFlagSyncRequest(FlagSyncRequest{}))
+}
@@ -0,0 +1,37 @@
+package validator
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// NamePolicy enforces an organisation naming convention on new flag names on top of the
+// built-in charset rule. A nil policy enforces no convention.
+type NamePolicy struct {
+	pattern *regexp.Regexp
+	input   string
+}
+
+// NewNamePolicy compiles a naming convention. The pattern must match the whole name. An empty
+// pattern returns a nil policy.
+func NewNamePolicy(pattern string) (*NamePolicy, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	compiled, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid flag name pattern %q: %w", pattern, err)
+	}
+	return &NamePolicy{pattern: compiled, input: pattern}, nil
+}
+
+// Check returns a validation error on Name if name does not follow the convention
+func (p *NamePolicy) Check(name string) error {
+	if p == nil || p.pattern.MatchString(name) {
+		return nil
+	}
+	return ValidationErrors{Errors: []ValidationError{{
+		Field:   "Name",
+		Message: fmt.Sprintf("Flag name must follow the naming convention %s", p.input),
+	}}}
+}
@@ -0,0 +1,38 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamePolicy_Check(t *testing.T) {
+	t.Run("no pattern only applies the charset rule", func(t *testing.T) {
+		policy, err := NewNamePolicy("")
+		require.NoError(t, err)
+		assert.Nil(t, policy)
+		assert.NoError(t, policy.Check("AnyThing-Goes_1"))
+	})
+
+	t.Run("custom pattern", func(t *testing.T) {
+		policy, err := NewNamePolicy(`[a-z]+_[a-z0-9_]+_v[0-9]+`)
+		require.NoError(t, err)
+
+		assert.NoError(t, policy.Check("checkout_new_flow_v2"))
+
+		err = policy.Check("Checkout_new_flow_v2")
+		var validationErrs ValidationErrors
+		require.ErrorAs(t, err, &validationErrs)
+		assert.Equal(t, "Name", validationErrs.Errors[0].Field)
+		assert.Contains(t, validationErrs.Errors[0].Message, `[a-z]+_[a-z0-9_]+_v[0-9]+`)
+
+		// The pattern must match the whole name, not just a part of it
+		assert.Error(t, policy.Check("checkout_flow_v2_beta"))
+	})
+
+	t.Run("invalid pattern is rejected", func(t *testing.T) {
+		_, err := NewNamePolicy(`[a-z`)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,79 @@
+package validator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Reason policy action names for enables and disables
+const (
+	ReasonActionEnable  = "enable"
+	ReasonActionDisable = "disable"
+)
+
+// ticketActions are the actions that take a flag out of service, whose reasons must
+// reference a ticket when a ticket pattern is configured
+var ticketActions = map[string]bool{
+	ReasonActionDisable: true,
+	"maintenance":       true,
+	"archive":           true,
+}
+
+// ReasonPolicy enforces change-management rules on reasons on top of the built-in length rule.
+// A nil policy enforces no rules.
+type ReasonPolicy struct {
+	minLengths  map[string]int
+	ticket      *regexp.Regexp
+	ticketInput string
+}
+
+// NewReasonPolicy builds a reason policy. minLengths maps audit action names, such as
+// "disable" or "delete", to the fewest characters a reason for that action must have. A
+// non-empty ticketPattern must match somewhere in every reason for a disable, maintenance or
+// archive, e.g. `JIRA-\d+`. Empty arguments leave the respective rule out.
+func NewReasonPolicy(minLengths map[string]int, ticketPattern string) (*ReasonPolicy, error) {
+	var compiled *regexp.Regexp
+	if ticketPattern != "" {
+		var err error
+		compiled, err = regexp.Compile(ticketPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reason ticket pattern %q: %w", ticketPattern, err)
+		}
+	}
+	return &ReasonPolicy{minLengths: minLengths, ticket: compiled, ticketInput: ticketPattern}, nil
+}
+
+// ToggleAction returns the action name of an enable or disable
+func ToggleAction(enable bool) string {
+	if enable {
+		return ReasonActionEnable
+	}
+	return ReasonActionDisable
+}
+
+// Check checks the reason given for an action against the policy
+func (p *ReasonPolicy) Check(action, reason string) error {
+	if p == nil {
+		return nil
+	}
+
+	var errs []ValidationError
+	if minLength := p.minLengths[action]; utf8.RuneCountInString(strings.TrimSpace(reason)) < minLength {
+		errs = append(errs, ValidationError{
+			Field:   "Reason",
+			Message: fmt.Sprintf("Must be at least %d characters long for %s", minLength, action),
+		})
+	}
+	if ticketActions[action] && p.ticket != nil && !p.ticket.MatchString(reason) {
+		errs = append(errs, ValidationError{
+			Field:   "Reason",
+			Message: fmt.Sprintf("Must reference a ticket matching %s", p.ticketInput),
+		})
+	}
+	if len(errs) > 0 {
+		return ValidationErrors{Errors: errs}
+	}
+	return nil
+}
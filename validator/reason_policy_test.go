@@ -0,0 +1,60 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReasonPolicy_Check(t *testing.T) {
+	t.Run("no policy only applies the built-in rules", func(t *testing.T) {
+		var policy *ReasonPolicy
+		assert.NoError(t, policy.Check(ReasonActionDisable, "oops"))
+	})
+
+	t.Run("minimum length per action", func(t *testing.T) {
+		policy, err := NewReasonPolicy(map[string]int{"disable": 20, "delete": 10}, "")
+		require.NoError(t, err)
+
+		err = policy.Check(ReasonActionDisable, "too short")
+		var validationErrs ValidationErrors
+		require.ErrorAs(t, err, &validationErrs)
+		assert.Equal(t, "Reason", validationErrs.Errors[0].Field)
+		assert.Contains(t, validationErrs.Errors[0].Message, "20")
+
+		assert.NoError(t, policy.Check(ToggleAction(true), "too short"))
+		assert.NoError(t, policy.Check(ToggleAction(false), "payments outage, rolling back"))
+		assert.Error(t, policy.Check("delete", "cleanup"))
+		assert.NoError(t, policy.Check("archive", "cleanup"))
+	})
+
+	t.Run("disables must reference a ticket", func(t *testing.T) {
+		policy, err := NewReasonPolicy(nil, `JIRA-\d+`)
+		require.NoError(t, err)
+
+		err = policy.Check(ReasonActionDisable, "rollback")
+		var validationErrs ValidationErrors
+		require.ErrorAs(t, err, &validationErrs)
+		assert.Contains(t, validationErrs.Errors[0].Message, `JIRA-\d+`)
+
+		assert.NoError(t, policy.Check(ReasonActionDisable, "rollback for JIRA-123"))
+		assert.NoError(t, policy.Check(ReasonActionEnable, "rollout"))
+	})
+
+	t.Run("maintenance and archive must reference a ticket", func(t *testing.T) {
+		policy, err := NewReasonPolicy(nil, `JIRA-\d+`)
+		require.NoError(t, err)
+
+		for _, action := range []string{"maintenance", "archive"} {
+			assert.Error(t, policy.Check(action, "taking it offline"), action)
+			assert.NoError(t, policy.Check(action, "offline for JIRA-42"), action)
+		}
+		assert.NoError(t, policy.Check("restore", "bring it back"))
+	})
+
+	t.Run("invalid pattern is rejected", func(t *testing.T) {
+		_, err := NewReasonPolicy(nil, `JIRA-[`)
+		assert.Error(t, err)
+	})
+}
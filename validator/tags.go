@@ -0,0 +1,50 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	// MaxTagKeyLength and MaxTagValueLength match the flag_tags column sizes
+	MaxTagKeyLength   = 50
+	MaxTagValueLength = 100
+)
+
+// ErrInvalidTag is returned for a tag filter that is not in key:value form
+var ErrInvalidTag = errors.New("invalid tag")
+
+var tagKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// validateTagKey checks that a tag key is short and limited to letters, digits, '_', '.' and '-'
+func validateTagKey(fl validator.FieldLevel) bool {
+	return isValidTagKey(fl.Field().String())
+}
+
+func isValidTagKey(key string) bool {
+	return len(key) <= MaxTagKeyLength && tagKeyRegexp.MatchString(key)
+}
+
+func isValidTagValue(value string) bool {
+	return value != "" && len(value) <= MaxTagValueLength && !hasControlChars(value)
+}
+
+// ParseTag splits a "key:value" tag filter at the first colon. The value may itself contain
+// colons.
+func ParseTag(raw string) (key, value string, err error) {
+	key, value, ok := strings.Cut(raw, ":")
+	if !ok {
+		return "", "", fmt.Errorf("%w %q: must be in key:value form", ErrInvalidTag, raw)
+	}
+	if !isValidTagKey(key) {
+		return "", "", fmt.Errorf("%w %q: key must be 1-%d letters, digits, '_', '.' or '-'", ErrInvalidTag, raw, MaxTagKeyLength)
+	}
+	if !isValidTagValue(value) {
+		return "", "", fmt.Errorf("%w %q: value must be 1-%d characters on a single line", ErrInvalidTag, raw, MaxTagValueLength)
+	}
+	return key, value, nil
+}
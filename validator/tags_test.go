@@ -0,0 +1,46 @@
+package validator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTag(t *testing.T) {
+	t.Run("valid tags", func(t *testing.T) {
+		key, value, err := ParseTag("team:payments")
+		require.NoError(t, err)
+		assert.Equal(t, "team", key)
+		assert.Equal(t, "payments", value)
+
+		key, value, err = ParseTag("owner.url:https://example.com")
+		require.NoError(t, err)
+		assert.Equal(t, "owner.url", key)
+		assert.Equal(t, "https://example.com", value)
+	})
+
+	t.Run("malformed tags", func(t *testing.T) {
+		for _, raw := range []string{"team", "team:", ":payments", "te am:payments", "team:pay\nments", strings.Repeat("k", 51) + ":v"} {
+			_, _, err := ParseTag(raw)
+			assert.ErrorIs(t, err, ErrInvalidTag, raw)
+		}
+	})
+}
+
+func TestValidateFlagCreateRequest_Tags(t *testing.T) {
+	create := func(tags map[string]string) error {
+		return ValidateFlagCreateRequest(FlagCreateRequest{Name: "tagged_flag", Tags: tags})
+	}
+
+	assert.NoError(t, create(nil))
+	assert.NoError(t, create(map[string]string{"team": "payments", "subsystem": "checkout"}))
+
+	var validationErrs ValidationErrors
+	require.ErrorAs(t, create(map[string]string{"bad key": "payments"}), &validationErrs)
+	assert.Contains(t, validationErrs.Errors[0].Message, "Tag key")
+
+	assert.Error(t, create(map[string]string{"team": ""}))
+	assert.Error(t, create(map[string]string{"team": strings.Repeat("v", 101)}))
+}